@@ -1,55 +0,0 @@
-package main
-
-import (
-	"bytes"
-	"io"
-	"os"
-	"testing"
-)
-
-// TestMain initializes shared state (registry, history) before any tests run.
-func TestMain(m *testing.M) {
-	hist = NewHistory()
-	newRegistry()
-	os.Exit(m.Run())
-}
-
-// captureStdout runs fn and returns whatever it wrote to os.Stdout.
-func captureStdout(t *testing.T, fn func()) string {
-	t.Helper()
-	old := os.Stdout
-	r, w, err := os.Pipe()
-	if err != nil {
-		t.Fatal(err)
-	}
-	os.Stdout = w
-
-	fn()
-
-	w.Close()
-	os.Stdout = old
-
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
-	return buf.String()
-}
-
-// captureStderr runs fn and returns whatever it wrote to os.Stderr.
-func captureStderr(t *testing.T, fn func()) string {
-	t.Helper()
-	old := os.Stderr
-	r, w, err := os.Pipe()
-	if err != nil {
-		t.Fatal(err)
-	}
-	os.Stderr = w
-
-	fn()
-
-	w.Close()
-	os.Stderr = old
-
-	var buf bytes.Buffer
-	io.Copy(&buf, r)
-	return buf.String()
-}
@@ -1,118 +1,13 @@
 package main
 
 import (
-	"errors"
-	"fmt"
 	"os"
-	"os/exec"
-	"os/signal"
-	"syscall"
 
-	"github.com/chzyer/readline"
+	"github.com/codecrafters-io/shell-starter-go/shell"
 )
 
-var hist *History
-
-// main starts the shell: populates the command trie for TAB completion,
-// sets up readline, and enters the read-eval loop.
+// main runs the gosh shell; everything but the exit lives in package
+// shell, so that other programs can embed it.
 func main() {
-	hist = NewHistory()
-	if path := os.Getenv("HISTFILE"); path != "" {
-		hist.ReadFile(path)
-		hist.MarkFlushed() // don't re-append loaded entries on exit
-	}
-	// Save history on SIGTERM/SIGHUP (signals that bypass the readline loop).
-	sigCh := make(chan os.Signal, 1)
-	signal.Notify(sigCh, syscall.SIGTERM, syscall.SIGHUP)
-	go func() {
-		<-sigCh
-		saveHistory()
-		os.Exit(0)
-	}()
-
-	newRegistry()
-	initCommandTrie()
-	rl, err := readline.NewEx(&readline.Config{
-		Prompt:       "$ ",
-		AutoComplete: &builtinCompleter{},
-	})
-	if err != nil {
-		fmt.Println(err)
-		return
-	}
-	defer rl.Close()
-
-	for {
-		line, err := rl.Readline()
-		if err != nil { // EOF or ^C
-			break
-		}
-		handleInput(line)
-	}
-
-	saveHistory()
-}
-
-// saveHistory appends new (unflushed) history entries to HISTFILE if set.
-func saveHistory() {
-	if path := os.Getenv("HISTFILE"); path != "" {
-		hist.AppendFile(path)
-	}
-}
-
-// handleInput processes a single input line through the shell's execution
-// pipeline:
-//
-//	input
-//	  -> parsePipeline       split on unquoted '|'
-//	     len > 1?  ----yes----> executePipeline (see pipeline.go)
-//	     |
-//	     no (single command)
-//	     |
-//	  -> parseCommand        parse redirections + tokenize into name/args
-//	  -> openRedirects       open redirect target files for stdout/stderr
-//	  -> GetCommand          look up builtin; if found, run in-process
-//	     or exec.Command     otherwise spawn an external process
-func handleInput(input string) {
-	hist.Record(input)
-
-	// Split on pipes first; delegate multi-segment pipelines.
-	segments := parsePipeline(input)
-	if len(segments) > 1 {
-		executePipeline(segments)
-		return
-	}
-
-	// Single command: parse into name, args, and redirections.
-	parsed, err := parseCommand(input)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		return
-	}
-
-	// Open redirect target files; cleanup restores original stdout/stderr.
-	stdout, stderr, cleanup, err := openRedirects(parsed.Redirects)
-	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		return
-	}
-	defer cleanup()
-
-	// Try builtins first (cd, echo, pwd, type, exit).
-	if cmd, ok := GetCommand(parsed.Name); ok {
-		os.Stdout = stdout
-		os.Stderr = stderr
-		cmd.Run(parsed.Args)
-		return
-	}
-
-	// Fall back to external command lookup via PATH.
-	cmd := exec.Command(parsed.Name, parsed.Args...)
-	cmd.Stdout = stdout
-	cmd.Stderr = stderr
-	if err = cmd.Run(); err != nil {
-		if errors.Is(err, exec.ErrNotFound) {
-			fmt.Printf("%s: command not found\n", parsed.Name)
-		}
-	}
+	os.Exit(shell.Main(os.Args))
 }
@@ -0,0 +1,166 @@
+// abbr.go — fish-style abbreviations, expanded in the line editor.
+//
+//	abbr [-s]                  list the abbreviations as abbr -a commands
+//	abbr -a NAME EXPANSION...  define NAME; the EXPANSION words are joined
+//	                           with spaces
+//	abbr -e NAME...            erase NAME
+//
+// Names follow the rules for alias names (validAliasName).
+//
+// Unlike an alias, an abbreviation is replaced in the line being typed:
+// when space follows a word in command position (at the start of the
+// line or after ; | & or '(') that names an abbreviation, abbrListener
+// swaps in the expansion, which can then be edited. A line submitted with
+// an abbreviation as its last word has it expanded too, so what runs and
+// what history.Record sees is always the full command.
+//
+// Definitions and erasures made at the interactive prompt are saved to
+// ~/.goshrc, as abbr -a lines that replace any earlier line for the same
+// name, so that the next shell starts with them.
+
+package shell
+
+import (
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// builtinAbbr implements abbr.
+func (sh *interp) builtinAbbr(args []string) int {
+	opts, args, ok := sh.parseOptions("abbr", "aes", args)
+	if !ok {
+		return 2
+	}
+	mode := byte('s')
+	for _, opt := range opts {
+		mode = opt.c
+	}
+	switch mode {
+	case 'a':
+		if len(args) < 2 {
+			sh.printUsage("abbr")
+			return 2
+		}
+		name := args[0]
+		if !validAliasName(name) {
+			fmt.Fprintf(sh.stderr(), "abbr: `%s': invalid abbreviation name\n", name)
+			return 1
+		}
+		sh.abbrs[name] = strings.Join(args[1:], " ")
+		sh.addCommandName(name)
+		return sh.saveAbbr(name)
+	case 'e':
+		if len(args) == 0 {
+			sh.printUsage("abbr")
+			return 2
+		}
+		status := 0
+		for _, name := range args {
+			if _, ok := sh.abbrs[name]; !ok {
+				fmt.Fprintf(sh.stderr(), "abbr: %s: not found\n", name)
+				status = 1
+				continue
+			}
+			delete(sh.abbrs, name)
+			sh.dropCommandName(name)
+			status = max(status, sh.saveAbbr(name))
+		}
+		return status
+	}
+	if len(args) > 0 {
+		sh.printUsage("abbr")
+		return 2
+	}
+	for _, name := range slices.Sorted(maps.Keys(sh.abbrs)) {
+		fmt.Fprintln(sh.stdout(), sh.abbrDefinition(name))
+	}
+	return 0
+}
+
+// abbrDefinition returns the abbr command that recreates name.
+func (sh *interp) abbrDefinition(name string) string {
+	return "abbr -a " + shellQuote(name) + " " + shellQuote(sh.abbrs[name])
+}
+
+// saveAbbr rewrites the abbr -a line for name in abbrRCFile to match its
+// current definition, dropping it if name has been erased. The file is
+// replaced whole (replaceFile), so a failed write cannot truncate it.
+func (sh *interp) saveAbbr(name string) int {
+	if sh.abbrRCFile == "" {
+		return 0
+	}
+	data, err := os.ReadFile(sh.abbrRCFile)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Fprintf(sh.stderr(), "abbr: %s: %v\n", sh.abbrRCFile, fileError(err))
+		return 1
+	}
+	var lines []string
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		f := strings.Fields(line)
+		if line != "" && !(len(f) >= 3 && f[0] == "abbr" && f[1] == "-a" && f[2] == shellQuote(name)) {
+			lines = append(lines, line)
+		}
+	}
+	if n := len(lines); n > 0 && !strings.HasSuffix(lines[n-1], "\n") {
+		lines[n-1] += "\n"
+	}
+	if _, ok := sh.abbrs[name]; ok {
+		lines = append(lines, sh.abbrDefinition(name)+"\n")
+	}
+	if err := replaceFile(sh.abbrRCFile, []byte(strings.Join(lines, "")), 0o644); err != nil {
+		fmt.Fprintf(sh.stderr(), "abbr: %s: %v\n", sh.abbrRCFile, fileError(err))
+		return 1
+	}
+	return 0
+}
+
+// expandAbbr expands the word of line that ends at end, if it is an
+// abbreviation in command position, and returns the new line and the
+// position just past the expansion.
+func (sh *interp) expandAbbr(line []rune, end int) ([]rune, int, bool) {
+	start := end
+	for start > 0 && !unicode.IsSpace(line[start-1]) && !strings.ContainsRune(";|&(", line[start-1]) {
+		start--
+	}
+	expansion, ok := sh.abbrs[string(line[start:end])]
+	if !ok {
+		return nil, 0, false
+	}
+	before := strings.TrimRightFunc(string(line[:start]), unicode.IsSpace)
+	if before != "" && !strings.ContainsRune(";|&(", rune(before[len(before)-1])) {
+		return nil, 0, false
+	}
+	newLine := slices.Concat(line[:start], []rune(expansion), line[end:])
+	return newLine, start + len([]rune(expansion)), true
+}
+
+// expandLastAbbr expands an abbreviation at the end of a submitted line.
+func (sh *interp) expandLastAbbr(line string) string {
+	trimmed := []rune(strings.TrimRightFunc(line, unicode.IsSpace))
+	if newLine, _, ok := sh.expandAbbr(trimmed, len(trimmed)); ok {
+		return string(newLine)
+	}
+	return line
+}
+
+// abbrListener is the readline.Listener that expands abbreviations as
+// space is typed after them.
+type abbrListener struct {
+	sh *interp
+}
+
+// OnChange is called by readline after each key press.
+func (l abbrListener) OnChange(line []rune, pos int, key rune) ([]rune, int, bool) {
+	if key != ' ' || pos == 0 || line[pos-1] != ' ' {
+		return nil, 0, false
+	}
+	newLine, newPos, ok := l.sh.expandAbbr(line, pos-1)
+	if !ok {
+		return nil, 0, false
+	}
+	return newLine, newPos + 1, true
+}
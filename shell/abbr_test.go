@@ -0,0 +1,124 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAbbr(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "define and list", src: "abbr -a gco git checkout; abbr -a l ls; abbr", want: "abbr -a gco 'git checkout'\nabbr -a l ls\n"},
+		{name: "show", src: "abbr -a l ls; abbr -s", want: "abbr -a l ls\n"},
+		{name: "redefine", src: "abbr -a l ls; abbr -a l 'ls -l'; abbr", want: "abbr -a l 'ls -l'\n"},
+		{name: "erase", src: "abbr -a l ls; abbr -a g git; abbr -e l; abbr", want: "abbr -a g git\n"},
+		{name: "erase not found", src: "abbr -e nope", wantErr: "abbr: nope: not found\n", status: 1},
+		{name: "missing expansion", src: "abbr -a l", wantErr: "abbr: usage: abbr [-s] | -a name expansion ... | -e name ...\n", status: 2},
+		{name: "invalid name", src: "abbr -a 'a b' x", wantErr: "abbr: `a b': invalid abbreviation name\n", status: 1},
+		{name: "name with metacharacters", src: "abbr -a 'a;b' x; abbr -a 'g$' x; abbr -a '' x", wantErr: "abbr: `a;b': invalid abbreviation name\nabbr: `g$': invalid abbreviation name\nabbr: `': invalid abbreviation name\n", status: 1},
+		{name: "name is quoted", src: "abbr -a 'g*' git; abbr", want: "abbr -a 'g*' git\n"},
+		{name: "invalid option", src: "abbr -x", wantErr: "abbr: -x: invalid option\n" + "abbr: usage: abbr [-s] | -a name expansion ... | -e name ...\n", status: 2},
+		{name: "not expanded by the parser", src: "abbr -a hi 'echo hello'\nhi", want: "hi: command not found\n", status: 127},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sh.abbrs = map[string]string{}
+			t.Cleanup(func() { sh.abbrs = map[string]string{} })
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
+
+func TestAbbrListener(t *testing.T) {
+	sh := newTestShell(t)
+	sh.abbrs = map[string]string{"gco": "git checkout", "l": "ls -l"}
+	t.Cleanup(func() { sh.abbrs = map[string]string{} })
+	tests := []struct {
+		line    string
+		key     rune
+		want    string
+		wantPos int
+		wantOK  bool
+	}{
+		{line: "gco ", key: ' ', want: "git checkout ", wantPos: 13, wantOK: true},
+		{line: "echo hi; gco ", key: ' ', want: "echo hi; git checkout ", wantPos: 22, wantOK: true},
+		{line: "cat f |l ", key: ' ', want: "cat f |ls -l ", wantPos: 13, wantOK: true},
+		{line: "echo gco ", key: ' '},
+		{line: "gcox ", key: ' '},
+		{line: "gco", key: 'o'},
+	}
+	for _, tt := range tests {
+		line := []rune(tt.line)
+		got, pos, ok := abbrListener{sh: sh}.OnChange(line, len(line), tt.key)
+		if ok != tt.wantOK || string(got) != tt.want || pos != tt.wantPos {
+			t.Errorf("OnChange(%q, %q) = %q, %d, %v; want %q, %d, %v", tt.line, tt.key, got, pos, ok, tt.want, tt.wantPos, tt.wantOK)
+		}
+	}
+
+	// The cursor may be inside the line.
+	line := []rune("l  -a")
+	if got, pos, ok := (abbrListener{sh: sh}).OnChange(line, 2, ' '); !ok || string(got) != "ls -l  -a" || pos != 6 {
+		t.Errorf("OnChange mid-line = %q, %d, %v", got, pos, ok)
+	}
+
+	for line, want := range map[string]string{
+		"gco":      "git checkout",
+		"gco  ":    "git checkout",
+		"x && l":   "x && ls -l",
+		"echo l":   "echo l",
+		"gco main": "gco main",
+		"":         "",
+	} {
+		if got := sh.expandLastAbbr(line); got != want {
+			t.Errorf("expandLastAbbr(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
+
+func TestAbbrSave(t *testing.T) {
+	sh := newTestShell(t)
+	// ~/.goshrc is a link into a dotfiles directory, and private.
+	home, dotfiles := t.TempDir(), t.TempDir()
+	target, rc := filepath.Join(dotfiles, "goshrc"), filepath.Join(home, ".goshrc")
+	if err := os.WriteFile(target, []byte("echo hi\nabbr -a l ls\nabbr -a g git"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(target, rc); err != nil {
+		t.Fatal(err)
+	}
+	sh.abbrs, sh.abbrRCFile = map[string]string{"l": "ls", "g": "git"}, rc
+	t.Cleanup(func() { sh.abbrs, sh.abbrRCFile = map[string]string{}, "" })
+
+	captureStderr(t, sh, func() {
+		runTestScript(t, sh, "abbr -a l 'ls -la'; abbr -a gs git status; abbr -e g; abbr -a 'g*' x; abbr -a 'g*' git")
+	})
+	data, err := os.ReadFile(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "echo hi\nabbr -a l 'ls -la'\nabbr -a gs 'git status'\nabbr -a 'g*' git\n"
+	if string(data) != want {
+		t.Errorf("rc file = %q, want %q", data, want)
+	}
+	if info, err := os.Lstat(rc); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("rc file is no longer a symbolic link: %v", err)
+	}
+	if info, err := os.Stat(target); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("rc file mode = %v, %v; want -rw-------", info.Mode(), err)
+	}
+	if names, _ := filepath.Glob(filepath.Join(dotfiles, ".goshrc*")); len(names) > 0 {
+		t.Errorf("temporary files left behind: %q", names)
+	}
+}
@@ -0,0 +1,165 @@
+// alias.go — aliases: alias, unalias and their expansion by the parser.
+//
+//	alias [-p] [NAME[=VALUE]...]   define NAME, or print the definitions
+//	                               of NAME or of every alias
+//	unalias [-a] NAME...           remove NAME, or with -a every alias
+//
+// While shopt expand_aliases is on (the default in interactive shells),
+// the parser replaces an unquoted word naming an alias, where a command
+// name is expected, with the alias's value and reads on from the start
+// of that text, so an alias may expand into several words, operators or
+// a compound command. Rules, as in bash:
+//
+//   - Reserved words are recognised first and never expanded.
+//   - Within the text of its own expansion an alias is not expanded
+//     again, so alias ls='ls -F' works and alias a=b b=a stops.
+//   - If the value ends in a blank, the word after it is checked for an
+//     alias too (alias sudo='sudo ').
+//
+// Expansion happens when a line is parsed, so an alias defined on a line
+// takes effect from the next line on, and a function uses the aliases in
+// force when it was defined.
+
+package shell
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// aliasSpan marks the text an alias expanded into, up to end in the
+// parser's source, within which the alias is not expanded again.
+type aliasSpan struct {
+	name string
+	end  int
+}
+
+// expandAlias replaces the current token, while it is a word naming an
+// alias, with the alias's value and reads the token that starts it.
+func (p *parser) expandAlias() {
+	for p.tok.kind == tokWord && !reservedWords[p.tok.text] {
+		name := p.tok.text
+		value, ok := p.aliases[name]
+		if !ok || p.inAlias(name) {
+			return
+		}
+		start := p.tok.pos
+		delta := len(value) - (p.tok.end - start)
+		for i := range p.aliasSpans {
+			if p.aliasSpans[i].end > start {
+				p.aliasSpans[i].end += delta
+			}
+		}
+		end := start + len(value)
+		p.src = p.src[:start] + value + p.src[p.tok.end:]
+		p.aliasSpans = append(p.aliasSpans, aliasSpan{name: name, end: end})
+		p.aliasNext = 0
+		if strings.HasSuffix(value, " ") || strings.HasSuffix(value, "\t") {
+			p.aliasNext = end
+		}
+		p.pos, p.line = start, p.tok.line
+		p.advance()
+	}
+}
+
+// inAlias reports whether the current token lies in the expansion of the
+// alias name.
+func (p *parser) inAlias(name string) bool {
+	for _, s := range p.aliasSpans {
+		if s.name == name && p.tok.pos < s.end {
+			return true
+		}
+	}
+	return false
+}
+
+// expandNextAlias expands the current token if it is the first word after
+// an alias whose value ended in a blank.
+func (p *parser) expandNextAlias() {
+	if p.aliasNext > 0 && p.tok.pos >= p.aliasNext {
+		p.aliasNext = 0
+		p.expandAlias()
+	}
+}
+
+// validAliasName reports whether name can be an alias: non-empty and
+// free of blanks, quotes and the characters / $ ` = and the operators.
+func validAliasName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\n/$`='\"\\;&|()<>")
+}
+
+// aliasDefinition returns the alias command that recreates name.
+func (sh *interp) aliasDefinition(name string) string {
+	return "alias " + name + "='" + strings.ReplaceAll(sh.aliases[name], "'", `'\''`) + "'"
+}
+
+// builtinAlias implements alias.
+func (sh *interp) builtinAlias(args []string) int {
+	printAll := len(args) == 0
+	opts, args, ok := sh.parseOptions("alias", "p", args)
+	if !ok {
+		return 2
+	}
+	if len(opts) > 0 {
+		printAll = true
+	}
+	if printAll {
+		for _, name := range slices.Sorted(maps.Keys(sh.aliases)) {
+			fmt.Fprintln(sh.stdout(), sh.aliasDefinition(name))
+		}
+	}
+
+	status := 0
+	for _, arg := range args {
+		name, value, isDef := strings.Cut(arg, "=")
+		switch {
+		case !isDef:
+			if _, ok := sh.aliases[name]; !ok {
+				fmt.Fprintf(sh.stderr(), "alias: %s: not found\n", name)
+				status = 1
+				continue
+			}
+			fmt.Fprintln(sh.stdout(), sh.aliasDefinition(name))
+		case !validAliasName(name):
+			fmt.Fprintf(sh.stderr(), "alias: `%s': invalid alias name\n", name)
+			status = 1
+		default:
+			sh.aliases[name] = value
+			sh.addCommandName(name)
+		}
+	}
+	return status
+}
+
+// builtinUnalias implements unalias.
+func (sh *interp) builtinUnalias(args []string) int {
+	opts, args, ok := sh.parseOptions("unalias", "a", args)
+	if !ok {
+		return 2
+	}
+	if len(opts) > 0 {
+		names := slices.Collect(maps.Keys(sh.aliases))
+		clear(sh.aliases)
+		for _, name := range names {
+			sh.dropCommandName(name)
+		}
+		return 0
+	}
+	if len(args) == 0 {
+		sh.printUsage("unalias")
+		return 2
+	}
+	status := 0
+	for _, name := range args {
+		if _, ok := sh.aliases[name]; !ok {
+			fmt.Fprintf(sh.stderr(), "unalias: %s: not found\n", name)
+			status = 1
+			continue
+		}
+		delete(sh.aliases, name)
+		sh.dropCommandName(name)
+	}
+	return status
+}
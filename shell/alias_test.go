@@ -0,0 +1,74 @@
+package shell
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestAlias(t *testing.T) {
+	sh := newTestShell(t)
+	const on = "shopt -s expand_aliases\n"
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "expands the command name", src: on + "alias hi='echo hello'\nhi world", want: "hello world\n"},
+		{name: "off by default in scripts", src: "alias hi='echo hello'\nhi", want: "hi: command not found\n", status: 127},
+		{name: "takes effect on the next line", src: on + "alias hi='echo hello'; hi", want: "hi: command not found\n", status: 127},
+		{name: "only the command name", src: on + "alias hi='echo hello'\necho hi", want: "hi\n"},
+		{name: "quoted name is not expanded", src: on + "alias hi='echo hello'\n'hi'", want: "hi: command not found\n", status: 127},
+		{name: "self reference", src: on + "alias echo='echo -n'\necho a; echo b", want: "ab"},
+		{name: "mutual recursion stops", src: on + "alias a=b b=a\na", want: "a: command not found\n", status: 127},
+		{name: "chained", src: on + "alias a=b b='echo chained'\na", want: "chained\n"},
+		{name: "trailing blank expands next word", src: on + "alias run='command ' hi='echo hello'\nrun hi", want: "hello\n"},
+		{name: "no trailing blank", src: on + "alias run='command' hi='echo hello'\nrun hi", want: "hi: command not found\n", status: 127},
+		{name: "operators in value", src: on + "alias both='echo a; echo b |cat'\nboth", want: "a\nb\n"},
+		{name: "after operators", src: on + "alias hi='echo hello'\ntrue && hi | cat; if hi; then hi; fi", want: "hello\nhello\nhello\n"},
+		{name: "compound value", src: on + "alias loop='for i in 1 2; do echo $i; done'\nloop", want: "1\n2\n"},
+		{name: "reserved word not expanded", src: on + "alias if='echo no'\nif true; then echo yes; fi", want: "yes\n"},
+		{name: "function uses definition-time aliases", src: on + "alias hi='echo hello'\nf() { hi; }\nunalias hi\nf", want: "hello\n"},
+		{name: "list sorted and quoted", src: "alias b='it'\\''s' a=x\nalias; alias -p a", want: "alias a='x'\nalias b='it'\\''s'\nalias a='x'\nalias b='it'\\''s'\nalias a='x'\n"},
+		{name: "not found", src: "alias nope", wantErr: "alias: nope: not found\n", status: 1},
+		{name: "invalid name", src: "alias 'a/b=x'", wantErr: "alias: `a/b': invalid alias name\n", status: 1},
+		{name: "unalias", src: "alias a=x b=y\nunalias a; alias", want: "alias b='y'\n"},
+		{name: "unalias -a", src: "alias a=x b=y\nunalias -a; alias", want: ""},
+		{name: "unalias not found", src: "unalias nope", wantErr: "unalias: nope: not found\n", status: 1},
+		{name: "unalias usage", src: "unalias", wantErr: "unalias: usage: unalias [-a] name [name ...]\n", status: 2},
+		{name: "type", src: "alias ll='ls -l'\ntype ll; command -v ll", want: "ll is aliased to `ls -l'\nalias ll='ls -l'\n"},
+		{name: "subshell", src: "(alias a=x); alias", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
+
+func TestAliasCompletion(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestTrie(t, sh, []string{"echo"})
+	setupTestVars(t, sh, "PATH=")
+	sh.builtinAlias([]string{"gst=git status", "gco=git checkout", "echo=echo -n"})
+	if got := sh.commandTrie.FindByPrefix("g"); !slices.Equal(got, []string{"gco", "gst"}) {
+		t.Errorf("after alias, completions of g = %q", got)
+	}
+	sh.builtinUnalias([]string{"gst", "echo"})
+	if got := sh.commandTrie.FindByPrefix("g"); !slices.Equal(got, []string{"gco"}) {
+		t.Errorf("after unalias, completions of g = %q", got)
+	}
+	if got := sh.commandTrie.FindByPrefix("ec"); !slices.Equal(got, []string{"echo"}) {
+		t.Errorf("unalias of a builtin's name: completions of ec = %q", got)
+	}
+	sh.builtinUnalias([]string{"-a"})
+	if got := sh.commandTrie.FindByPrefix("g"); got != nil {
+		t.Errorf("after unalias -a, completions of g = %q", got)
+	}
+}
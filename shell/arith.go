@@ -0,0 +1,501 @@
+// arith.go — integer arithmetic evaluation (C-style expressions).
+//
+// Used wherever the shell evaluates integers: variables with the integer
+// attribute (declare -i) and, later, subscripts and offsets.
+//
+// Grammar (lowest to highest precedence):
+//
+//	expr    = assign { "," assign }
+//	assign  = ternary | name ( "=" | "+=" | "-=" | ... ) assign
+//	ternary = logor [ "?" expr ":" ternary ]
+//	logor   = logand { "||" logand }
+//	...     binary operators with C precedence
+//	unary   = ( "+" | "-" | "!" | "~" | "++" | "--" ) unary | postfix
+//	postfix = primary [ "++" | "--" ]
+//	primary = number | name | "(" expr ")"
+//
+// Names are looked up in the shell's variables; an unset or empty
+// variable is 0 and a variable holding an expression is evaluated
+// recursively.
+
+package shell
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// maxArithDepth bounds recursive evaluation of variables that hold
+// expressions referring to themselves (x='x+1').
+const maxArithDepth = 64
+
+// arithEval evaluates expr and returns its integer value.
+func (sh *interp) arithEval(expr string) (int64, error) {
+	return sh.arithEvalDepth(expr, 0)
+}
+
+func (sh *interp) arithEvalDepth(expr string, depth int) (int64, error) {
+	if depth > maxArithDepth {
+		return 0, fmt.Errorf("%s: expression recursion level exceeded", strings.TrimSpace(expr))
+	}
+	p := &arithParser{sh: sh, src: expr, depth: depth}
+	p.next()
+	if p.tok == "" {
+		return 0, nil
+	}
+	v, err := p.comma()
+	if err != nil {
+		return 0, err
+	}
+	if p.tok != "" {
+		return 0, p.syntaxError()
+	}
+	return v, nil
+}
+
+// arithParser is a recursive-descent evaluator. Parsing and evaluation
+// happen in one pass; skip suppresses side effects (assignments) in the
+// branches of &&, || and ?: that are not taken.
+type arithParser struct {
+	sh    *interp
+	src   string
+	pos   int
+	tok   string // current token; "" at end of input
+	depth int
+	skip  int
+}
+
+// arithOps lists multi-character operators, longest first so the lexer
+// prefers "<<=" over "<<" over "<".
+var arithOps = []string{
+	"<<=", ">>=",
+	"**", "++", "--", "<<", ">>", "<=", ">=", "==", "!=", "&&", "||",
+	"+=", "-=", "*=", "/=", "%=", "&=", "^=", "|=",
+}
+
+// next advances to the following token.
+func (p *arithParser) next() {
+	for p.pos < len(p.src) && isArithSpace(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos >= len(p.src) {
+		p.tok = ""
+		return
+	}
+	start := p.pos
+	c := p.src[p.pos]
+	switch {
+	case isDigit(c):
+		for p.pos < len(p.src) && (isNameChar(p.src[p.pos]) || p.src[p.pos] == '#') {
+			p.pos++
+		}
+	case isNameStart(c):
+		for p.pos < len(p.src) && isNameChar(p.src[p.pos]) {
+			p.pos++
+		}
+	default:
+		for _, op := range arithOps {
+			if strings.HasPrefix(p.src[p.pos:], op) {
+				p.pos += len(op)
+				p.tok = op
+				return
+			}
+		}
+		p.pos++
+	}
+	p.tok = p.src[start:p.pos]
+}
+
+func (p *arithParser) syntaxError() error {
+	tok := p.tok
+	if tok == "" {
+		tok = "end of expression"
+	}
+	return fmt.Errorf("%s: arithmetic syntax error (error token is %q)", strings.TrimSpace(p.src), tok)
+}
+
+func (p *arithParser) expect(tok string) error {
+	if p.tok != tok {
+		return p.syntaxError()
+	}
+	p.next()
+	return nil
+}
+
+func (p *arithParser) comma() (int64, error) {
+	v, err := p.assign()
+	for err == nil && p.tok == "," {
+		p.next()
+		v, err = p.assign()
+	}
+	return v, err
+}
+
+// assign handles "name op= expr". It peeks ahead: if the current token is a
+// name followed by an assignment operator, it assigns; otherwise it rewinds
+// and parses a ternary.
+func (p *arithParser) assign() (int64, error) {
+	if isNameStart(firstByte(p.tok)) {
+		save, saveTok := p.pos, p.tok
+		name := p.tok
+		p.next()
+		switch op := p.tok; op {
+		case "=", "+=", "-=", "*=", "/=", "%=", "<<=", ">>=", "&=", "^=", "|=":
+			p.next()
+			rhs, err := p.assign()
+			if err != nil {
+				return 0, err
+			}
+			v := rhs
+			if op != "=" {
+				cur, err := p.variable(name)
+				if err != nil {
+					return 0, err
+				}
+				if v, err = arithBinary(op[:len(op)-1], cur, rhs); err != nil {
+					return 0, err
+				}
+			}
+			return v, p.store(name, v)
+		}
+		p.pos, p.tok = save, saveTok
+	}
+	return p.ternary()
+}
+
+func (p *arithParser) ternary() (int64, error) {
+	cond, err := p.binary(0)
+	if err != nil || p.tok != "?" {
+		return cond, err
+	}
+	p.next()
+	if cond == 0 {
+		p.skip++
+	}
+	a, err := p.comma()
+	if cond == 0 {
+		p.skip--
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := p.expect(":"); err != nil {
+		return 0, err
+	}
+	if cond != 0 {
+		p.skip++
+	}
+	b, err := p.ternary()
+	if cond != 0 {
+		p.skip--
+	}
+	if err != nil {
+		return 0, err
+	}
+	if cond != 0 {
+		return a, nil
+	}
+	return b, nil
+}
+
+// arithLevels lists binary operators from lowest to highest precedence.
+var arithLevels = [][]string{
+	{"||"},
+	{"&&"},
+	{"|"},
+	{"^"},
+	{"&"},
+	{"==", "!="},
+	{"<", "<=", ">", ">="},
+	{"<<", ">>"},
+	{"+", "-"},
+	{"*", "/", "%"},
+}
+
+// binary parses a left-associative chain of operators at precedence level.
+func (p *arithParser) binary(level int) (int64, error) {
+	if level == len(arithLevels) {
+		return p.power()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return 0, err
+	}
+	for slices.Contains(arithLevels[level], p.tok) {
+		op := p.tok
+		p.next()
+		// Short-circuit: evaluate the right side without side effects.
+		short := (op == "&&" && left == 0) || (op == "||" && left != 0)
+		if short {
+			p.skip++
+		}
+		right, err := p.binary(level + 1)
+		if short {
+			p.skip--
+		}
+		if err != nil {
+			return 0, err
+		}
+		if short {
+			left = b2i(op == "||")
+			continue
+		}
+		if p.skip > 0 && (op == "/" || op == "%") && right == 0 {
+			left = 0
+			continue
+		}
+		if left, err = arithBinary(op, left, right); err != nil {
+			return 0, err
+		}
+	}
+	return left, nil
+}
+
+// power parses right-associative exponentiation.
+func (p *arithParser) power() (int64, error) {
+	base, err := p.unary()
+	if err != nil || p.tok != "**" {
+		return base, err
+	}
+	p.next()
+	exp, err := p.power()
+	if err != nil {
+		return 0, err
+	}
+	return arithBinary("**", base, exp)
+}
+
+func (p *arithParser) unary() (int64, error) {
+	switch op := p.tok; op {
+	case "+", "-", "!", "~":
+		p.next()
+		v, err := p.unary()
+		if err != nil {
+			return 0, err
+		}
+		switch op {
+		case "-":
+			return -v, nil
+		case "!":
+			return b2i(v == 0), nil
+		case "~":
+			return ^v, nil
+		}
+		return v, nil
+	case "++", "--":
+		p.next()
+		name := p.tok
+		if !isNameStart(firstByte(name)) {
+			return 0, p.syntaxError()
+		}
+		p.next()
+		v, err := p.variable(name)
+		if err != nil {
+			return 0, err
+		}
+		if op == "++" {
+			v++
+		} else {
+			v--
+		}
+		return v, p.store(name, v)
+	}
+	return p.postfix()
+}
+
+func (p *arithParser) postfix() (int64, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return 0, p.syntaxError()
+	case tok == "(":
+		p.next()
+		v, err := p.comma()
+		if err != nil {
+			return 0, err
+		}
+		return v, p.expect(")")
+	case isDigit(tok[0]):
+		p.next()
+		return parseArithNumber(tok)
+	case isNameStart(tok[0]):
+		p.next()
+		v, err := p.variable(tok)
+		if err != nil {
+			return 0, err
+		}
+		if p.tok == "++" || p.tok == "--" {
+			nv := v + 1
+			if p.tok == "--" {
+				nv = v - 1
+			}
+			p.next()
+			return v, p.store(tok, nv)
+		}
+		return v, nil
+	}
+	return 0, p.syntaxError()
+}
+
+// variable returns the integer value of a shell variable, evaluating its
+// contents as an expression when it is not a plain number.
+func (p *arithParser) variable(name string) (int64, error) {
+	val, _ := p.sh.vars.Get(name)
+	val = strings.TrimSpace(val)
+	if val == "" {
+		return 0, nil
+	}
+	if n, err := strconv.ParseInt(val, 10, 64); err == nil {
+		return n, nil
+	}
+	return p.sh.arithEvalDepth(val, p.depth+1)
+}
+
+// store assigns v to name unless evaluation is being skipped.
+func (p *arithParser) store(name string, v int64) error {
+	if p.skip > 0 {
+		return nil
+	}
+	return p.sh.vars.Set(name, strconv.FormatInt(v, 10))
+}
+
+// arithBinary applies a binary operator.
+func arithBinary(op string, a, b int64) (int64, error) {
+	switch op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/", "%":
+		if b == 0 {
+			return 0, fmt.Errorf("division by 0")
+		}
+		if op == "/" {
+			return a / b, nil
+		}
+		return a % b, nil
+	case "**":
+		if b < 0 {
+			return 0, fmt.Errorf("exponent less than 0")
+		}
+		r := int64(1)
+		for ; b > 0; b-- {
+			r *= a
+		}
+		return r, nil
+	case "<<":
+		return a << uint64(b), nil
+	case ">>":
+		return a >> uint64(b), nil
+	case "<":
+		return b2i(a < b), nil
+	case "<=":
+		return b2i(a <= b), nil
+	case ">":
+		return b2i(a > b), nil
+	case ">=":
+		return b2i(a >= b), nil
+	case "==":
+		return b2i(a == b), nil
+	case "!=":
+		return b2i(a != b), nil
+	case "&":
+		return a & b, nil
+	case "^":
+		return a ^ b, nil
+	case "|":
+		return a | b, nil
+	case "&&":
+		return b2i(a != 0 && b != 0), nil
+	case "||":
+		return b2i(a != 0 || b != 0), nil
+	}
+	return 0, fmt.Errorf("%s: unknown operator", op)
+}
+
+// parseArithNumber parses decimal, octal (0NN), hex (0xNN) and base#value
+// integer constants.
+func parseArithNumber(tok string) (int64, error) {
+	if i := strings.IndexByte(tok, '#'); i > 0 {
+		base, err := strconv.Atoi(tok[:i])
+		if err != nil || base < 2 || base > 64 {
+			return 0, fmt.Errorf("%s: invalid arithmetic base", tok)
+		}
+		var v int64
+		for _, c := range tok[i+1:] {
+			d := arithDigit(c, base)
+			if d < 0 {
+				return 0, fmt.Errorf("%s: value too great for base", tok)
+			}
+			v = v*int64(base) + int64(d)
+		}
+		return v, nil
+	}
+	v, err := strconv.ParseInt(tok, 0, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: value too great for base", tok)
+	}
+	return v, nil
+}
+
+// arithDigit returns the value of digit c in base, or -1. Bases up to 36
+// are case-insensitive; beyond that lowercase, uppercase, '@' and '_'
+// follow in order, as in bash.
+func arithDigit(c rune, base int) int {
+	d := -1
+	switch {
+	case c >= '0' && c <= '9':
+		d = int(c - '0')
+	case c >= 'a' && c <= 'z':
+		d = int(c-'a') + 10
+	case c >= 'A' && c <= 'Z':
+		d = int(c-'A') + 10
+		if base > 36 {
+			d += 26
+		}
+	case c == '@':
+		d = 62
+	case c == '_':
+		d = 63
+	}
+	if d >= base {
+		return -1
+	}
+	return d
+}
+
+func isArithSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n'
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// isNameStart reports whether c can begin a shell variable name.
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+// isNameChar reports whether c can continue a shell variable name.
+func isNameChar(c byte) bool {
+	return isNameStart(c) || isDigit(c)
+}
+
+func firstByte(s string) byte {
+	if s == "" {
+		return 0
+	}
+	return s[0]
+}
+
+func b2i(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
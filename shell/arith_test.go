@@ -0,0 +1,89 @@
+package shell
+
+import "testing"
+
+func TestArithEval(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		expr    string
+		want    int64
+		wantErr bool
+	}{
+		{expr: "", want: 0},
+		{expr: "1 + 2 * 3", want: 7},
+		{expr: "(1 + 2) * 3", want: 9},
+		{expr: "7 / 2", want: 3},
+		{expr: "7 % 3", want: 1},
+		{expr: "2 ** 10", want: 1024},
+		{expr: "-3 + +1", want: -2},
+		{expr: "!0", want: 1},
+		{expr: "~0", want: -1},
+		{expr: "1 << 4 | 1", want: 17},
+		{expr: "3 > 2 && 2 > 1", want: 1},
+		{expr: "0 || 0", want: 0},
+		{expr: "1 ? 10 : 20", want: 10},
+		{expr: "0 ? 10 : 20", want: 20},
+		{expr: "0x1f", want: 31},
+		{expr: "010", want: 8},
+		{expr: "2#101", want: 5},
+		{expr: "1, 2, 3", want: 3},
+		{expr: "x", want: 5},
+		{expr: "y + 1", want: 11},
+		{expr: "unset_var", want: 0},
+		{expr: "1 / 0", wantErr: true},
+		{expr: "1 +", wantErr: true},
+		{expr: "(1", wantErr: true},
+		{expr: "0 && 1 / 0", want: 0},
+	}
+
+	setupTestVars(t, sh, "x=5", "y=x*2")
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := sh.arithEval(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("arithEval(%q) error = %v, wantErr %v", tt.expr, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("arithEval(%q) = %d, want %d", tt.expr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArithAssignment(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh, "n=1")
+
+	steps := []struct {
+		expr  string
+		want  int64
+		wantN string
+	}{
+		{expr: "n += 4", want: 5, wantN: "5"},
+		{expr: "n++", want: 5, wantN: "6"},
+		{expr: "++n", want: 7, wantN: "7"},
+		{expr: "n--", want: 7, wantN: "6"},
+		{expr: "n = n * 2", want: 12, wantN: "12"},
+		{expr: "0 && (n = 99)", want: 0, wantN: "12"},
+	}
+	for _, s := range steps {
+		got, err := sh.arithEval(s.expr)
+		if err != nil {
+			t.Fatalf("arithEval(%q) error = %v", s.expr, err)
+		}
+		if got != s.want {
+			t.Errorf("arithEval(%q) = %d, want %d", s.expr, got, s.want)
+		}
+		if n := sh.getVar("n"); n != s.wantN {
+			t.Errorf("after %q, n = %q, want %q", s.expr, n, s.wantN)
+		}
+	}
+}
+
+func TestArithRecursionLimit(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh, "x=x+1")
+	if _, err := sh.arithEval("x"); err == nil {
+		t.Error("expected recursion error for self-referencing variable")
+	}
+}
@@ -0,0 +1,453 @@
+// arrays.go — indexed and associative array variables.
+//
+// An array is a variable with attrArray (Indexed, sparse int keys) or
+// attrAssoc (Assoc, string keys). Referring to an array without a
+// subscript means element 0, so scalar code paths (Get, Set) keep working.
+//
+// Compound assignment values "(a b [k]=v)" are parsed by parseCompound.
+// Declaration builtins (declare, export, ...) receive their operands after
+// expansion, so trimInput re-quotes compound values with quoteCompound and
+// the builtin parses them again without further expansion.
+
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// arrayElem is one element of a compound assignment. HasKey is set for
+// the explicit "[key]=value" form.
+type arrayElem struct {
+	Key    string
+	HasKey bool
+	Value  string
+}
+
+// isArray reports whether v is an indexed or associative array.
+func (v *variable) isArray() bool {
+	return v.Attrs&(attrArray|attrAssoc) != 0
+}
+
+// clone returns a deep copy of v.
+func (v *variable) clone() *variable {
+	cp := *v
+	if v.Indexed != nil {
+		cp.Indexed = make(map[int]string, len(v.Indexed))
+		for k, e := range v.Indexed {
+			cp.Indexed[k] = e
+		}
+	}
+	if v.Assoc != nil {
+		cp.Assoc = make(map[string]string, len(v.Assoc))
+		for k, e := range v.Assoc {
+			cp.Assoc[k] = e
+		}
+	}
+	return &cp
+}
+
+// scalar returns the value of v as a plain variable: element 0 for arrays.
+func (v *variable) scalar() (string, bool) {
+	switch {
+	case v.Attrs&attrAssoc != 0:
+		s, ok := v.Assoc["0"]
+		return s, ok
+	case v.Attrs&attrArray != 0:
+		s, ok := v.Indexed[0]
+		return s, ok
+	}
+	return v.Value, v.IsSet
+}
+
+// indices returns the set indices of an indexed array in ascending order.
+func (v *variable) indices() []int {
+	idx := make([]int, 0, len(v.Indexed))
+	for i := range v.Indexed {
+		idx = append(idx, i)
+	}
+	sort.Ints(idx)
+	return idx
+}
+
+// keys returns the subscripts of v in order: ascending indices for indexed
+// arrays, sorted keys for associative arrays, and "0" for a set scalar.
+func (v *variable) keys() []string {
+	var keys []string
+	switch {
+	case v.Attrs&attrAssoc != 0:
+		for k := range v.Assoc {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+	case v.Attrs&attrArray != 0:
+		for _, i := range v.indices() {
+			keys = append(keys, strconv.Itoa(i))
+		}
+	case v.IsSet:
+		keys = []string{"0"}
+	}
+	return keys
+}
+
+// values returns the elements of v in subscript order. A set scalar is a
+// one-element list.
+func (v *variable) values() []string {
+	var vals []string
+	switch {
+	case v.Attrs&attrAssoc != 0:
+		for _, k := range v.keys() {
+			vals = append(vals, v.Assoc[k])
+		}
+	case v.Attrs&attrArray != 0:
+		for _, i := range v.indices() {
+			vals = append(vals, v.Indexed[i])
+		}
+	case v.IsSet:
+		vals = []string{v.Value}
+	}
+	return vals
+}
+
+// toArray converts v into an array with the given kind (attrArray or
+// attrAssoc). A set scalar value becomes element 0.
+func (v *variable) toArray(kind varAttr) error {
+	if v.Attrs&kind != 0 {
+		return nil
+	}
+	if v.Attrs&attrAssoc != 0 && kind == attrArray {
+		return fmt.Errorf("cannot convert associative to indexed array")
+	}
+	if v.Attrs&attrArray != 0 && kind == attrAssoc {
+		return fmt.Errorf("cannot convert indexed to associative array")
+	}
+	if kind == attrAssoc {
+		v.Assoc = make(map[string]string)
+		if v.IsSet {
+			v.Assoc["0"] = v.Value
+		}
+	} else {
+		v.Indexed = make(map[int]string)
+		if v.IsSet {
+			v.Indexed[0] = v.Value
+		}
+	}
+	v.Attrs |= kind
+	v.Value = ""
+	return nil
+}
+
+// evalIndex turns a subscript into the key of an element of v. Indexed
+// subscripts are arithmetic; negative values count back from the end.
+func (v *variable) evalIndex(sh *interp, sub string) (string, error) {
+	if v.Attrs&attrAssoc != 0 {
+		return sub, nil
+	}
+	n, err := sh.arithEval(sub)
+	if err != nil {
+		return "", err
+	}
+	if n < 0 {
+		idx := v.indices()
+		if len(idx) == 0 || int(n)+idx[len(idx)-1]+1 < 0 {
+			return "", fmt.Errorf("[%s]: bad array subscript", sub)
+		}
+		n += int64(idx[len(idx)-1]) + 1
+	}
+	return strconv.FormatInt(n, 10), nil
+}
+
+// element returns the element with key (as produced by evalIndex).
+func (v *variable) element(key string) (string, bool) {
+	switch {
+	case v.Attrs&attrAssoc != 0:
+		s, ok := v.Assoc[key]
+		return s, ok
+	case v.Attrs&attrArray != 0:
+		i, _ := strconv.Atoi(key)
+		s, ok := v.Indexed[i]
+		return s, ok
+	}
+	if key == "0" {
+		return v.Value, v.IsSet
+	}
+	return "", false
+}
+
+// setElement stores value under key, converting a scalar to an indexed
+// array first.
+func (v *variable) setElement(sh *interp, key, value string) error {
+	if !v.isArray() {
+		if err := v.toArray(attrArray); err != nil {
+			return err
+		}
+	}
+	value, err := v.transform(sh, value)
+	if err != nil {
+		return err
+	}
+	if v.Attrs&attrAssoc != 0 {
+		v.Assoc[key] = value
+	} else {
+		i, _ := strconv.Atoi(key)
+		v.Indexed[i] = value
+	}
+	v.IsSet = true
+	return nil
+}
+
+// GetIndex returns element sub of name and whether it is set.
+func (t *varTable) GetIndex(name, sub string) (string, bool, error) {
+	v := t.Lookup(name)
+	if v == nil {
+		return "", false, nil
+	}
+	key, err := v.evalIndex(t.owner, sub)
+	if err != nil {
+		return "", false, err
+	}
+	s, ok := v.element(key)
+	return s, ok, nil
+}
+
+// writable returns the variable name refers to, creating it if needed, or
+// an error if it is readonly.
+func (t *varTable) writable(name string) (*variable, error) {
+	name, err := t.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := t.vars[name]
+	if !ok {
+		v = &variable{}
+		t.vars[name] = v
+	}
+	if v.Attrs&attrReadonly != 0 {
+		return nil, fmt.Errorf("%s: readonly variable", name)
+	}
+	return v, nil
+}
+
+// SetIndex assigns (or with appendMode, appends to) element sub of name.
+func (t *varTable) SetIndex(name, sub, value string, appendMode bool) error {
+	v, err := t.writable(name)
+	if err != nil {
+		return err
+	}
+	if !v.isArray() {
+		if err := v.toArray(attrArray); err != nil {
+			return err
+		}
+	}
+	key, err := v.evalIndex(t.owner, sub)
+	if err != nil {
+		return err
+	}
+	if appendMode {
+		old, _ := v.element(key)
+		if value, err = t.owner.appendValue(v, old, value); err != nil {
+			return err
+		}
+	}
+	return v.setElement(t.owner, key, value)
+}
+
+// Append implements NAME+=value for a scalar (or element 0 of an array):
+// integer variables add arithmetically, everything else concatenates.
+func (t *varTable) Append(name, value string) error {
+	v, err := t.writable(name)
+	if err != nil {
+		return err
+	}
+	old, _ := v.scalar()
+	if value, err = t.owner.appendValue(v, old, value); err != nil {
+		return err
+	}
+	if v.isArray() {
+		return v.setElement(t.owner, "0", value)
+	}
+	return t.Set(name, value)
+}
+
+// appendValue combines old and value for a += assignment to v.
+func (sh *interp) appendValue(v *variable, old, value string) (string, error) {
+	if v.Attrs&attrInteger == 0 {
+		return old + value, nil
+	}
+	a, err := sh.arithEval(old)
+	if err != nil {
+		return "", err
+	}
+	b, err := sh.arithEval(value)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(a+b, 10), nil
+}
+
+// SetArray assigns a compound value to name. Without appendMode the
+// existing elements are replaced; with it, new elements are added after the
+// highest index.
+func (t *varTable) SetArray(name string, elems []arrayElem, appendMode bool) error {
+	v, err := t.writable(name)
+	if err != nil {
+		return err
+	}
+	if !v.isArray() {
+		if !appendMode {
+			v.IsSet = false
+		}
+		if err := v.toArray(attrArray); err != nil {
+			return err
+		}
+	}
+	if !appendMode {
+		if v.Attrs&attrAssoc != 0 {
+			v.Assoc = make(map[string]string)
+		} else {
+			v.Indexed = make(map[int]string)
+		}
+	}
+	next := 0
+	if idx := v.indices(); len(idx) > 0 {
+		next = idx[len(idx)-1] + 1
+	}
+	for _, e := range elems {
+		if v.Attrs&attrAssoc != 0 {
+			if !e.HasKey {
+				return fmt.Errorf("%s: %s: must use subscript when assigning associative array", name, e.Value)
+			}
+			if err := v.setElement(t.owner, e.Key, e.Value); err != nil {
+				return err
+			}
+			continue
+		}
+		if e.HasKey {
+			key, err := v.evalIndex(t.owner, e.Key)
+			if err != nil {
+				return err
+			}
+			next, _ = strconv.Atoi(key)
+		}
+		if err := v.setElement(t.owner, strconv.Itoa(next), e.Value); err != nil {
+			return err
+		}
+		next++
+	}
+	v.IsSet = true
+	return nil
+}
+
+// UnsetIndex removes element sub of name.
+func (t *varTable) UnsetIndex(name, sub string) error {
+	v := t.Lookup(name)
+	if v == nil {
+		return nil
+	}
+	if v.Attrs&attrReadonly != 0 {
+		return fmt.Errorf("%s: cannot unset: readonly variable", name)
+	}
+	key, err := v.evalIndex(t.owner, sub)
+	if err != nil {
+		return err
+	}
+	switch {
+	case v.Attrs&attrAssoc != 0:
+		delete(v.Assoc, key)
+	case v.Attrs&attrArray != 0:
+		i, _ := strconv.Atoi(key)
+		delete(v.Indexed, i)
+	case key == "0":
+		return t.Unset(name)
+	}
+	return nil
+}
+
+// parseCompound expands the body of a compound assignment value "(...)"
+// into elements. Plain words may expand to several elements; "[key]=value"
+// words produce exactly one.
+func (sh *interp) parseCompound(value string) ([]arrayElem, error) {
+	body := value[1 : len(value)-1]
+	var elems []arrayElem
+	i := 0
+	for i < len(body) {
+		if isBlank(body[i]) || body[i] == '\n' {
+			i++
+			continue
+		}
+		start := i
+		if body[i] == '[' {
+			// A subscript may contain blanks: [k 2]=v.
+			if j := matchingBracket(body, i); j > 0 {
+				i = j
+			}
+		}
+		end := scanWord(body, i, "")
+		raw := body[start:end]
+		i = end
+
+		if raw[0] == '[' {
+			if j := matchingBracket(raw, 0); j > 0 && j+1 < len(raw) && raw[j+1] == '=' {
+				key, err := sh.expandWord(raw[1:j])
+				if err != nil {
+					return nil, err
+				}
+				val, err := sh.expandWord(raw[j+2:])
+				if err != nil {
+					return nil, err
+				}
+				elems = append(elems, arrayElem{Key: key, HasKey: true, Value: val})
+				continue
+			}
+		}
+		fields, err := sh.expandFields(raw)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range fields {
+			elems = append(elems, arrayElem{Value: f})
+		}
+	}
+	return elems, nil
+}
+
+// quoteCompound renders elements back into a compound value that
+// parseCompound reads as the same elements.
+func quoteCompound(elems []arrayElem) string {
+	parts := make([]string, len(elems))
+	for i, e := range elems {
+		if e.HasKey {
+			parts[i] = "[" + shellQuote(e.Key) + "]=" + shellQuote(e.Value)
+		} else {
+			parts[i] = shellQuote(e.Value)
+		}
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+// matchingBracket returns the index of the ']' matching the '[' at s[i],
+// or -1.
+func matchingBracket(s string, i int) int {
+	depth := 0
+	for ; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '[':
+			depth++
+		case ']':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// isBlank reports whether c is a space or tab.
+func isBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
@@ -0,0 +1,156 @@
+package shell
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSetArray(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name       string
+		assigns    []string // raw assignment words, applied in order
+		wantKeys   []string
+		wantValues []string
+	}{
+		{
+			name:       "simple list",
+			assigns:    []string{"a=(x y z)"},
+			wantKeys:   []string{"0", "1", "2"},
+			wantValues: []string{"x", "y", "z"},
+		},
+		{
+			name:       "quoted element keeps spaces",
+			assigns:    []string{`a=(x "y z")`},
+			wantKeys:   []string{"0", "1"},
+			wantValues: []string{"x", "y z"},
+		},
+		{
+			name:       "explicit indices are sparse",
+			assigns:    []string{"a=([3]=c d [0]=a)"},
+			wantKeys:   []string{"0", "3", "4"},
+			wantValues: []string{"a", "c", "d"},
+		},
+		{
+			name:       "subscript assignment converts scalar",
+			assigns:    []string{"a=first", "a[2]=third"},
+			wantKeys:   []string{"0", "2"},
+			wantValues: []string{"first", "third"},
+		},
+		{
+			name:       "append elements",
+			assigns:    []string{"a=(x)", "a+=(y z)"},
+			wantKeys:   []string{"0", "1", "2"},
+			wantValues: []string{"x", "y", "z"},
+		},
+		{
+			name:       "append to element",
+			assigns:    []string{"a=(x y)", "a[1]+=s"},
+			wantKeys:   []string{"0", "1"},
+			wantValues: []string{"x", "ys"},
+		},
+		{
+			name:       "arithmetic subscript",
+			assigns:    []string{"i=1", "a[i+1]=v"},
+			wantKeys:   []string{"2"},
+			wantValues: []string{"v"},
+		},
+		{
+			name:       "reassignment replaces",
+			assigns:    []string{"a=(x y z)", "a=(q)"},
+			wantKeys:   []string{"0"},
+			wantValues: []string{"q"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestVars(t, sh)
+			for _, w := range tt.assigns {
+				a, ok := parseAssignment(w)
+				if !ok {
+					t.Fatalf("parseAssignment(%q) failed", w)
+				}
+				if err := sh.performAssignment(a, true); err != nil {
+					t.Fatalf("assignment %q: %v", w, err)
+				}
+			}
+			v := sh.vars.Lookup("a")
+			if got := v.keys(); !slices.Equal(got, tt.wantKeys) {
+				t.Errorf("keys = %q, want %q", got, tt.wantKeys)
+			}
+			if got := v.values(); !slices.Equal(got, tt.wantValues) {
+				t.Errorf("values = %q, want %q", got, tt.wantValues)
+			}
+		})
+	}
+}
+
+func TestAssocArray(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh)
+	sh.vars.SetAttrs("m", attrAssoc, 0)
+
+	a, _ := parseAssignment(`m=([b]=2 [a]="1 one")`)
+	if err := sh.performAssignment(a, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := sh.vars.SetIndex("m", "c d", "3", false); err != nil {
+		t.Fatal(err)
+	}
+
+	v := sh.vars.Lookup("m")
+	if got, want := v.keys(), []string{"a", "b", "c d"}; !slices.Equal(got, want) {
+		t.Errorf("keys = %q, want %q", got, want)
+	}
+	if got, ok, _ := sh.vars.GetIndex("m", "a"); !ok || got != "1 one" {
+		t.Errorf("m[a] = %q, %v", got, ok)
+	}
+
+	a, _ = parseAssignment("m=(novalue)")
+	if err := sh.performAssignment(a, true); err == nil {
+		t.Error("expected error assigning associative array without subscript")
+	}
+
+	if err := sh.vars.SetAttrs("m", attrArray, 0); err == nil {
+		t.Error("expected error converting associative to indexed array")
+	}
+}
+
+func TestUnsetIndex(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh)
+	a, _ := parseAssignment("a=(x y z)")
+	sh.performAssignment(a, true)
+
+	if err := sh.vars.UnsetIndex("a", "-1"); err != nil {
+		t.Fatal(err)
+	}
+	if got := sh.vars.Lookup("a").values(); !slices.Equal(got, []string{"x", "y"}) {
+		t.Errorf("values after unset a[-1] = %q", got)
+	}
+	if err := sh.vars.UnsetIndex("missing", "0"); err != nil {
+		t.Errorf("unset of missing array element: %v", err)
+	}
+	if _, ok := sh.vars.vars["missing"]; ok {
+		t.Error("unset should not create variables")
+	}
+}
+
+func TestQuoteCompoundRoundTrip(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh)
+	elems := []arrayElem{
+		{Value: "plain"},
+		{Value: "with space"},
+		{Value: "$dollar"},
+		{Key: "k 1", HasKey: true, Value: "it's"},
+	}
+	got, err := sh.parseCompound(quoteCompound(elems))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(got, elems) {
+		t.Errorf("round trip = %+v, want %+v", got, elems)
+	}
+}
@@ -0,0 +1,198 @@
+// cd.go — cd and pwd, and the logical working directory kept in PWD.
+//
+//	cd [-L|-P] [DIR]   DIR defaults to $HOME; "cd -" goes to $OLDPWD and
+//	                   prints it
+//	pwd [-L|-P]        print the working directory
+//
+// Paths are logical by default (-L): cd joins DIR to $PWD and cleans the
+// result lexically, so "cd link/.." comes back to where it started rather
+// than to the parent of link's target, and pwd prints $PWD. -P resolves
+// symbolic links instead.
+//
+// A relative DIR that does not start with "." or ".." is looked up in the
+// colon-separated CDPATH first; an empty entry means the current
+// directory. When a non-empty entry matches, cd prints the new directory.
+// cd sets OLDPWD and PWD after a successful change, and records the new
+// directory for z.
+
+package shell
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// builtinCd implements cd.
+func (sh *interp) builtinCd(args []string) int {
+	physical, args, ok := sh.pathModeOptions("cd", args)
+	if !ok {
+		return 2
+	}
+	if len(args) > 1 {
+		fmt.Fprintln(sh.stderr(), "cd: too many arguments")
+		return 1
+	}
+
+	var dir string
+	show := false
+	switch {
+	case len(args) == 0:
+		if dir = sh.getVar("HOME"); dir == "" {
+			fmt.Fprintln(sh.stderr(), "cd: HOME not set")
+			return 1
+		}
+	case args[0] == "-":
+		if dir = sh.getVar("OLDPWD"); dir == "" {
+			fmt.Fprintln(sh.stderr(), "cd: OLDPWD not set")
+			return 1
+		}
+		show = true
+	default:
+		dir = args[0]
+	}
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		home := sh.getVar("HOME")
+		if home == "" {
+			home, _ = os.UserHomeDir()
+		}
+		dir = home + dir[1:]
+	}
+
+	return sh.cdTo("cd", dir, physical, show)
+}
+
+// cdTo changes to dir for the builtin name (cd or pushd), searching
+// CDPATH, and prints the new directory if show is set or CDPATH found it.
+func (sh *interp) cdTo(name, dir string, physical, show bool) int {
+	target := dir
+	if found, viaCDPATH := sh.searchCDPATH(dir); found != "" {
+		target, show = found, show || viaCDPATH
+	}
+	if err := sh.changeDir(target, physical); err != nil {
+		fmt.Fprintf(sh.stderr(), "%s: %s: %v\n", name, dir, fileError(err))
+		return 1
+	}
+	if show {
+		fmt.Fprintln(sh.stdout(), sh.getVar("PWD"))
+	}
+	return 0
+}
+
+// builtinPwd implements pwd.
+func (sh *interp) builtinPwd(args []string) int {
+	physical, args, ok := sh.pathModeOptions("pwd", args)
+	if !ok {
+		return 2
+	}
+	if len(args) > 0 {
+		fmt.Fprintln(sh.stderr(), "pwd: too many arguments")
+		return 1
+	}
+	dir, err := sh.workingDir(physical)
+	if err != nil {
+		fmt.Fprintf(sh.stderr(), "pwd: %v\n", err)
+		return 1
+	}
+	fmt.Fprintln(sh.stdout(), dir)
+	return 0
+}
+
+// pathModeOptions parses the -L and -P options of cd and pwd, of which the
+// last wins, and reports whether -P is in effect. A lone "-" is an operand.
+func (sh *interp) pathModeOptions(name string, args []string) (physical bool, rest []string, ok bool) {
+	opts, rest, ok := sh.parseOptions(name, "LP", args)
+	for _, opt := range opts {
+		physical = opt.c == 'P'
+	}
+	return physical, rest, ok
+}
+
+// searchCDPATH looks dir up in CDPATH and returns the directory found, if
+// any, and whether it came from a non-empty entry.
+func (sh *interp) searchCDPATH(dir string) (found string, viaCDPATH bool) {
+	cdpath := sh.getVar("CDPATH")
+	if cdpath == "" || filepath.IsAbs(dir) || dir == "." || dir == ".." ||
+		strings.HasPrefix(dir, "./") || strings.HasPrefix(dir, "../") {
+		return "", false
+	}
+	for _, entry := range filepath.SplitList(cdpath) {
+		candidate := filepath.Join(entry, dir)
+		if entry == "" {
+			candidate = dir
+		}
+		if info, err := os.Stat(sh.path(candidate)); err == nil && info.IsDir() {
+			return candidate, entry != ""
+		}
+	}
+	return "", false
+}
+
+// changeDir changes the shell's working directory to dir, logically
+// (relative to $PWD, with ".." taken lexically) or physically, updates
+// OLDPWD and PWD, and records the visit for z (z.go). The process's
+// working directory is left alone: commands are started in the shell's.
+func (sh *interp) changeDir(dir string, physical bool) error {
+	old, err := sh.workingDir(false)
+	if err != nil {
+		old = ""
+	}
+	if !physical {
+		if !filepath.IsAbs(dir) && old != "" {
+			dir = filepath.Join(old, dir)
+		}
+		dir = filepath.Clean(dir)
+	}
+	target := sh.path(dir)
+	info, err := os.Stat(target)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return &fs.PathError{Op: "chdir", Path: dir, Err: syscall.ENOTDIR}
+	}
+	if err := syscall.Access(target, accessExec); err != nil {
+		return &fs.PathError{Op: "chdir", Path: dir, Err: err}
+	}
+	if physical || !filepath.IsAbs(dir) {
+		if dir, err = filepath.EvalSymlinks(target); err != nil {
+			return err
+		}
+	}
+	sh.dir = dir
+	if old != "" {
+		sh.vars.Set("OLDPWD", old)
+	}
+	if err := sh.vars.Set("PWD", dir); err != nil {
+		return err
+	}
+	if sh.params.interactive && !sh.embedded {
+		sh.zRecord(dir)
+	}
+	return nil
+}
+
+// workingDir returns the working directory: $PWD if it is an absolute
+// name for it (the logical directory), otherwise, or when physical, the
+// directory with symbolic links resolved.
+func (sh *interp) workingDir(physical bool) (string, error) {
+	if pwd := sh.getVar("PWD"); !physical && filepath.IsAbs(pwd) {
+		a, errA := os.Stat(pwd)
+		b, errB := os.Stat(sh.dir)
+		if errA == nil && errB == nil && os.SameFile(a, b) {
+			return pwd, nil
+		}
+	}
+	return filepath.EvalSymlinks(sh.dir)
+}
+
+// initPWD sets PWD at startup to the inherited value if it names the
+// working directory, otherwise to the physical directory.
+func (sh *interp) initPWD() {
+	if dir, err := sh.workingDir(false); err == nil {
+		sh.vars.Set("PWD", dir)
+	}
+}
@@ -0,0 +1,60 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCd(t *testing.T) {
+	sh := newTestShell(t)
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"real/sub", "projects/app", "other"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(filepath.Join(root, "real/sub"), filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(root)
+
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "sets PWD and OLDPWD", src: "cd other; echo $PWD $OLDPWD", want: root + "/other " + root + "\n"},
+		{name: "cd - swaps and prints", src: "cd other; cd ../real; cd -; echo $PWD $OLDPWD", want: root + "/other\n" + root + "/other " + root + "/real\n"},
+		{name: "OLDPWD not set", src: "cd -", wantErr: "cd: OLDPWD not set\n", status: 1},
+		{name: "logical", src: "cd link; pwd; pwd -L; pwd -P; cd ..; pwd", want: root + "/link\n" + root + "/link\n" + root + "/real/sub\n" + root + "\n"},
+		{name: "physical", src: "cd -P link; echo $PWD; cd ..; pwd", want: root + "/real/sub\n" + root + "/real\n"},
+		{name: "last option wins", src: "cd -PL link; pwd", want: root + "/link\n"},
+		{name: "CDPATH prints", src: "CDPATH=" + root + "/projects; cd app; pwd", want: root + "/projects/app\n" + root + "/projects/app\n"},
+		{name: "CDPATH empty entry first", src: "CDPATH=:" + root + "/projects; cd other; pwd", want: root + "/other\n"},
+		{name: "CDPATH skips dot paths", src: "CDPATH=" + root + "/projects; cd ./app", wantErr: "cd: ./app: No such file or directory\n", status: 1},
+		{name: "home", src: "HOME=" + root + "/other; cd /; cd; pwd; cd ~/../real; pwd", want: root + "/other\n" + root + "/real\n"},
+		{name: "HOME not set", src: "unset HOME; cd", wantErr: "cd: HOME not set\n", status: 1},
+		{name: "not a directory", src: "cd /dev/null", wantErr: "cd: /dev/null: not a directory\n", status: 1},
+		{name: "too many arguments", src: "cd a b", wantErr: "cd: too many arguments\n", status: 1},
+		{name: "invalid option", src: "cd -x", wantErr: "cd: -x: invalid option\ncd: usage: cd [-L|-P] [dir]\n", status: 2},
+		{name: "subshell", src: "(cd other); pwd", want: root + "\n"},
+		{name: "stale PWD", src: "PWD=/nonexistent; pwd", want: root + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(root)
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
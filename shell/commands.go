@@ -0,0 +1,289 @@
+// commands.go — builtin command registry (echo, exit, history, type in
+// type.go, cd/pwd in cd.go, pushd/popd/dirs in dirstack.go, z in z.go,
+// alias/unalias in alias.go, abbr in abbr.go, help in help.go, the
+// variable builtins in declare.go, the control builtins in interp.go and
+// eval/exec/command in exec.go).
+//
+// newRegistry() builds the map; getCommand() looks up by name. Each command
+// is a simple function value plus its documentation — no interface needed
+// at this scale.
+//
+// lookPath/externalCommand resolve and build external commands against the
+// shell's own PATH and exported variables rather than the process
+// environment, so assignments made in the shell take effect.
+
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// command represents a builtin shell command. Run returns the command's
+// exit status, which becomes $?. The other fields describe the builtin
+// for help, --help and option completion (help.go).
+type command struct {
+	Name         string
+	Synopsis     string // usage line, as in "cd [-L|-P] [dir]"
+	Summary      string // one line, for help -d
+	Description  string // the rest of the help text, in lines
+	Options      []optionSpec
+	NoHelpOption bool     // --help is an ordinary argument (echo, test, ...)
+	Completions  []string // argument words for TAB (plugins, plugin.go)
+	Run          func(sh *interp, args []string) int
+}
+
+// newRegistry builds the builtin command registry, completing each entry
+// with its name and documentation from builtinDocs.
+func (sh *interp) newRegistry() {
+	sh.registry = map[string]command{
+		"cd":      {Run: (*interp).builtinCd},
+		"pwd":     {Run: (*interp).builtinPwd},
+		"pushd":   {Run: (*interp).builtinPushd},
+		"popd":    {Run: (*interp).builtinPopd},
+		"dirs":    {Run: (*interp).builtinDirs},
+		"z":       {Run: (*interp).builtinZ},
+		"alias":   {Run: (*interp).builtinAlias},
+		"unalias": {Run: (*interp).builtinUnalias},
+		"abbr":    {Run: (*interp).builtinAbbr},
+		"echo":    {Run: (*interp).builtinEcho},
+		"exit":    {Run: (*interp).builtinExit},
+		"type":    {Run: (*interp).builtinType},
+		"history": {Run: (*interp).builtinHistory},
+		"getopts": {Run: (*interp).builtinGetopts},
+		"declare": {
+			Run: func(sh *interp, args []string) int {
+				return sh.builtinDeclare("declare", args)
+			},
+		},
+		"typeset": {
+			Run: func(sh *interp, args []string) int {
+				return sh.builtinDeclare("typeset", args)
+			},
+		},
+		"local":    {Run: (*interp).builtinLocal},
+		"export":   {Run: (*interp).builtinExport},
+		"readonly": {Run: (*interp).builtinReadonly},
+		"unset":    {Run: (*interp).builtinUnset},
+		"set":      {Run: (*interp).builtinSet},
+		"shift":    {Run: (*interp).builtinShift},
+		"break": {
+			Run: func(sh *interp, args []string) int { return sh.builtinLoopJump("break", flowBreak, args) },
+		},
+		"continue": {
+			Run: func(sh *interp, args []string) int { return sh.builtinLoopJump("continue", flowContinue, args) },
+		},
+		"return":  {Run: (*interp).builtinReturn},
+		"eval":    {Run: (*interp).builtinEval},
+		"exec":    {Run: (*interp).builtinExec},
+		"command": {Run: (*interp).builtinCommand},
+		"shopt":   {Run: (*interp).builtinShopt},
+		"trap":    {Run: (*interp).builtinTrap},
+		"read":    {Run: (*interp).builtinRead},
+		"printf":  {Run: (*interp).builtinPrintf},
+		"test": {
+			Run: func(sh *interp, args []string) int { return sh.builtinTest("test", args) },
+		},
+		"[": {
+			Run: func(sh *interp, args []string) int { return sh.builtinTest("[", args) },
+		},
+		"source": {
+			Run: func(sh *interp, args []string) int { return sh.builtinSource("source", args) },
+		},
+		".": {
+			Run: func(sh *interp, args []string) int { return sh.builtinSource(".", args) },
+		},
+		":":     {Run: func(*interp, []string) int { return 0 }},
+		"true":  {Run: func(*interp, []string) int { return 0 }},
+		"false": {Run: func(*interp, []string) int { return 1 }},
+		"help":  {Run: (*interp).builtinHelp},
+		"jobs":  {Run: (*interp).builtinJobs},
+		"wait":  {Run: (*interp).builtinWait},
+	}
+	for name, cmd := range sh.registry {
+		doc := builtinDocs[name]
+		doc.Name, doc.Run = name, cmd.Run
+		if !doc.NoHelpOption {
+			doc.Run = helpOption(doc, cmd.Run)
+		}
+		sh.registry[name] = doc
+	}
+}
+
+// builtinEcho implements echo [-neE] [arg ...]: -n drops the newline, -e
+// expands backslash escapes (\c ends the output) and -E, the default
+// unless shopt xpg_echo is on, does not. Only arguments made up entirely
+// of these letters are options; anything else, "--" included, is printed.
+func (sh *interp) builtinEcho(args []string) int {
+	newline, escapes := true, sh.options[optXpgEcho]
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' && strings.Trim(args[0][1:], "neE") == "" {
+		for _, c := range args[0][1:] {
+			switch c {
+			case 'n':
+				newline = false
+			case 'e':
+				escapes = true
+			case 'E':
+				escapes = false
+			}
+		}
+		args = args[1:]
+	}
+	out := strings.Join(args, " ")
+	if escapes {
+		var stop bool
+		if out, stop = expandEscapes(out, false); stop {
+			newline = false
+		}
+	}
+	if newline {
+		out += "\n"
+	}
+	fmt.Fprint(sh.stdout(), out)
+	return 0
+}
+
+// getCommand looks up a builtin command by name.
+func (sh *interp) getCommand(name string) (command, bool) {
+	cmd, ok := sh.registry[name]
+	return cmd, ok
+}
+
+// lookPath searches the shell's PATH variable for an executable named name.
+// Names containing a slash are checked directly. It returns exec.ErrNotFound
+// (wrapped) when nothing matches.
+func (sh *interp) lookPath(name string) (string, error) {
+	return sh.lookPathIn(name, sh.getVar("PATH"))
+}
+
+// lookPathIn is lookPath with an explicit list of directories.
+func (sh *interp) lookPathIn(name, dirs string) (string, error) {
+	if paths := sh.lookPathAll(name, dirs, false); len(paths) > 0 {
+		return paths[0], nil
+	}
+	return "", &exec.Error{Name: name, Err: exec.ErrNotFound}
+}
+
+// lookPathAll returns the executables name resolves to in dirs: the first
+// one, or with all every one, in search order.
+func (sh *interp) lookPathAll(name, dirs string, all bool) []string {
+	if strings.Contains(name, "/") {
+		if sh.isExecutable(name) {
+			return []string{name}
+		}
+		return nil
+	}
+	var paths []string
+	for _, dir := range filepath.SplitList(dirs) {
+		if dir == "" {
+			dir = "."
+		}
+		p := filepath.Join(dir, name)
+		if !strings.Contains(p, "/") {
+			p = "./" + p // keep exec.Command from searching os PATH again
+		}
+		if sh.isExecutable(p) {
+			paths = append(paths, p)
+			if !all {
+				break
+			}
+		}
+	}
+	return paths
+}
+
+// isExecutable reports whether path, relative to the shell's working
+// directory, is a regular file with an execute bit.
+func (sh *interp) isExecutable(path string) bool {
+	info, err := os.Stat(sh.path(path))
+	return err == nil && !info.IsDir() && info.Mode()&0111 != 0
+}
+
+// externalCommand builds an exec.Cmd for name resolved via lookPath, with
+// the environment made of exported shell variables plus assigns.
+func (sh *interp) externalCommand(name string, args []string, assigns []assignment) (*exec.Cmd, error) {
+	var env []string
+	err := sh.withAssignments(assigns, func() {
+		sh.traceCommand(assigns, append([]string{name}, args...))
+		env = sh.vars.Environ()
+	})
+	if err != nil {
+		return nil, err
+	}
+	path, err := sh.lookPath(name)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(sh.ctx, path, args...)
+	cmd.Args[0] = name
+	cmd.Env = env
+	cmd.Dir = sh.dir
+	return cmd, nil
+}
+
+// attachFds gives cmd the file descriptors fds: 0, 1 and 2 become its
+// standard streams (the null device where closed) and the rest are
+// passed on as they are.
+func attachFds(cmd *exec.Cmd, fds []*os.File) {
+	if fds[0] != nil {
+		cmd.Stdin = fds[0]
+	}
+	if fds[1] != nil {
+		cmd.Stdout = fds[1]
+	}
+	if fds[2] != nil {
+		cmd.Stderr = fds[2]
+	}
+	if len(fds) > 3 {
+		cmd.ExtraFiles = fds[3:]
+	}
+}
+
+// runExternal runs the program at path in the foreground under the name
+// name, with the shell's standard streams and exported variables, and
+// returns its status.
+func (sh *interp) runExternal(path, name string, args []string) int {
+	env, err := sh.commandEnv(nil)
+	if err != nil {
+		fmt.Fprintln(sh.stderr(), err)
+		return 1
+	}
+	cmd := exec.CommandContext(sh.ctx, path, args...)
+	cmd.Args[0] = name
+	cmd.Env = env
+	cmd.Dir = sh.dir
+	attachFds(cmd, sh.fds)
+	return sh.runExec(cmd)
+}
+
+// commandError reports a failure to start command name and returns the
+// matching exit status: 127 if it was not found, 126 otherwise.
+func (sh *interp) commandError(name string, err error) int {
+	if errors.Is(err, exec.ErrNotFound) {
+		fmt.Fprintf(sh.stdout(), "%s: command not found\n", name)
+		return 127
+	}
+	fmt.Fprintln(sh.stderr(), err)
+	return 126
+}
+
+// exitStatus converts the error from running an external command into an
+// exit status: its exit code, or 128+n if it was killed by signal n.
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 1
+	}
+	if ws, ok := exitErr.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		return 128 + int(ws.Signal())
+	}
+	return exitErr.ExitCode()
+}
@@ -0,0 +1,215 @@
+package shell
+
+import (
+	"os"
+	"testing"
+)
+
+func TestEchoCommand(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "single word",
+			args: []string{"hello"},
+			want: "hello\n",
+		},
+		{
+			name: "multiple words",
+			args: []string{"hello", "world"},
+			want: "hello world\n",
+		},
+		{
+			name: "no args",
+			args: []string{},
+			want: "\n",
+		},
+		{name: "no newline", args: []string{"-n", "a"}, want: "a"},
+		{name: "escapes", args: []string{"-e", `a\tb\0101\x42`}, want: "a\tbAB\n"},
+		{name: "escapes off by default", args: []string{`a\tb`}, want: "a\\tb\n"},
+		{name: "combined flags", args: []string{"-ne", `a\n`}, want: "a\n"},
+		{name: "last flag wins", args: []string{"-eE", `a\n`}, want: "a\\n\n"},
+		{name: "stop output", args: []string{"-e", `a\cb`, "c"}, want: "a"},
+		{name: "not an option", args: []string{"-x", "-n"}, want: "-x -n\n"},
+		{name: "dash dash is printed", args: []string{"--", "a"}, want: "-- a\n"},
+		{name: "lone dash", args: []string{"-"}, want: "-\n"},
+		{name: "options only first", args: []string{"a", "-n"}, want: "a -n\n"},
+	}
+
+	cmd, ok := sh.getCommand("echo")
+	if !ok {
+		t.Fatal("echo command not found in registry")
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := captureStdout(t, sh, func() {
+				cmd.Run(sh, tt.args)
+			})
+			if got != tt.want {
+				t.Errorf("echo(%v) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+
+	sh.options[optXpgEcho] = true
+	if got := captureStdout(t, sh, func() { cmd.Run(sh, []string{`a\tb`}) }); got != "a\tb\n" {
+		t.Errorf("echo with xpg_echo = %q, want escapes expanded", got)
+	}
+	if got := captureStdout(t, sh, func() { cmd.Run(sh, []string{"-E", `a\tb`}) }); got != "a\\tb\n" {
+		t.Errorf("echo -E with xpg_echo = %q, want escapes kept", got)
+	}
+}
+
+func TestTypeCommand(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name string
+		args []string
+		want string
+	}{
+		{
+			name: "builtin command echo",
+			args: []string{"echo"},
+			want: "echo is a shell builtin\n",
+		},
+		{
+			name: "builtin command type",
+			args: []string{"type"},
+			want: "type is a shell builtin\n",
+		},
+		{
+			name: "builtin command exit",
+			args: []string{"exit"},
+			want: "exit is a shell builtin\n",
+		},
+		{
+			name: "unknown command",
+			args: []string{"foo"},
+			want: "foo: not found\n",
+		},
+	}
+
+	cmd, ok := sh.getCommand("type")
+	if !ok {
+		t.Fatal("type command not found in registry")
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := captureStdout(t, sh, func() {
+				cmd.Run(sh, tt.args)
+			})
+			if got != tt.want {
+				t.Errorf("type(%v) = %q, want %q", tt.args, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	sh := newTestShell(t)
+	_, ok := sh.getCommand("nonexistent")
+	if ok {
+		t.Error("expected nonexistent command to not be found in registry")
+	}
+}
+
+func TestCdCommand(t *testing.T) {
+	sh := newTestShell(t)
+	cmd, ok := sh.getCommand("cd")
+	if !ok {
+		t.Fatal("cd command not found in registry")
+	}
+	origDir := sh.dir
+
+	t.Run("cd to valid directory", func(t *testing.T) {
+		dir := t.TempDir()
+		sh.dir = origDir
+		cmd.Run(sh, []string{dir})
+		if sh.dir != dir {
+			t.Errorf("after cd %q, dir = %q, want %q", dir, sh.dir, dir)
+		}
+		if wd, _ := os.Getwd(); wd != origDir {
+			t.Errorf("after cd %q, process cwd = %q, want it unchanged", dir, wd)
+		}
+	})
+
+	t.Run("cd to invalid directory prints error", func(t *testing.T) {
+		sh.dir = origDir
+		var status int
+		got := captureStderr(t, sh, func() {
+			status = cmd.Run(sh, []string{"/no/such/dir"})
+		})
+		want := "cd: /no/such/dir: No such file or directory\n"
+		if got != want || status != 1 {
+			t.Errorf("cd error output = %q, status %d; want %q, 1", got, status, want)
+		}
+	})
+
+	t.Run("cd with no args goes home", func(t *testing.T) {
+		sh.dir = origDir
+		home, _ := os.UserHomeDir()
+		cmd.Run(sh, nil)
+		if sh.dir != home {
+			t.Errorf("after cd (no args), dir = %q, want %q", sh.dir, home)
+		}
+	})
+
+	t.Run("cd to tilde goes home", func(t *testing.T) {
+		sh.dir = origDir
+		home, _ := os.UserHomeDir()
+		cmd.Run(sh, []string{"~"})
+		if sh.dir != home {
+			t.Errorf("after cd ~, dir = %q, want %q", sh.dir, home)
+		}
+	})
+}
+
+func TestPwdCommand(t *testing.T) {
+	sh := newTestShell(t)
+	cmd, ok := sh.getCommand("pwd")
+	if !ok {
+		t.Fatal("pwd command not found in registry")
+	}
+
+	t.Run("prints current directory", func(t *testing.T) {
+		dir := t.TempDir()
+		sh.dir = dir
+		got := captureStdout(t, sh, func() {
+			cmd.Run(sh, nil)
+		})
+		if got != dir+"\n" {
+			t.Errorf("pwd output = %q, want %q", got, dir+"\n")
+		}
+	})
+}
+
+func TestLookPath(t *testing.T) {
+	sh := newTestShell(t)
+	dir := t.TempDir()
+	exe := dir + "/mytool"
+	if err := os.WriteFile(exe, []byte("#!/bin/sh\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(dir+"/notexec", nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	setupTestVars(t, sh, "PATH="+dir)
+
+	if got, err := sh.lookPath("mytool"); err != nil || got != exe {
+		t.Errorf("lookPath(mytool) = %q, %v; want %q", got, err, exe)
+	}
+	if _, err := sh.lookPath("notexec"); err == nil {
+		t.Error("expected non-executable file to be skipped")
+	}
+	if got, err := sh.lookPath(exe); err != nil || got != exe {
+		t.Errorf("lookPath(%q) = %q, %v", exe, got, err)
+	}
+	if _, err := sh.lookPath("ls"); err == nil {
+		t.Error("lookPath should search the shell's PATH, not the process environment")
+	}
+}
@@ -0,0 +1,195 @@
+// completer.go — TAB completion for the shell prompt.
+//
+// On startup, initCommandTrie populates a prefix trie with all builtin
+// command names, aliases, abbreviations and external executables found on
+// PATH (directories are scanned concurrently via goroutines). Abbreviation
+// expansion (abbr.go) is a readline listener set up alongside.
+//
+// builtinCompleter implements the readline.AutoCompleter interface:
+//
+//	single match   → complete the word + trailing space
+//	multiple matches, LCP longer than prefix → complete to LCP
+//	multiple matches, LCP == prefix:
+//	    first TAB  → bell
+//	    second TAB → list all matches
+//	no matches     → bell
+//
+// Only the command name is completed, except that the words after "z" are
+// completed from the directories z knows, best-ranked first (z.go), a
+// word starting with '-' after a builtin from its documented options
+// (help.go), and other words after a plugin from the completions it
+// declared (plugin.go).
+
+package shell
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// initCommandTrie builds the trie used for TAB completion. Builtins are
+// inserted first, then PATH directories are scanned concurrently. A single
+// goroutine drains the channel and inserts names into the trie (not
+// goroutine-safe) to avoid locking.
+func (sh *interp) initCommandTrie() {
+	sh.commandTrie = newTrie()
+
+	// Builtins and aliases go in first so they're always completable.
+	for name := range sh.registry {
+		sh.commandTrie.Insert(name)
+	}
+	for name := range sh.aliases {
+		sh.commandTrie.Insert(name)
+	}
+	for name := range sh.abbrs {
+		sh.commandTrie.Insert(name)
+	}
+
+	// Scan PATH directories in parallel; feed names through a channel.
+	dirs := filepath.SplitList(sh.getVar("PATH"))
+	names := make(chan string, 64)
+
+	var wg sync.WaitGroup
+	for _, dir := range dirs {
+		wg.Add(1)
+		go func(dir string) {
+			defer wg.Done()
+			entries, err := os.ReadDir(sh.path(dir))
+			if err != nil {
+				return
+			}
+			for _, e := range entries {
+				if !e.IsDir() {
+					names <- e.Name()
+				}
+			}
+		}(dir)
+	}
+
+	go func() {
+		wg.Wait()
+		close(names)
+	}()
+
+	// Single-threaded insert — trie is not goroutine-safe.
+	for name := range names {
+		sh.commandTrie.Insert(name)
+	}
+}
+
+// addCommandName makes name completable, once the trie exists; alias and
+// abbr call it for the names they define.
+func (sh *interp) addCommandName(name string) {
+	if sh.commandTrie != nil {
+		sh.commandTrie.Insert(name)
+	}
+}
+
+// dropCommandName takes name, which unalias or abbr -e removed, out of
+// the trie unless a builtin, another alias or abbreviation, or a PATH
+// command still provides it.
+func (sh *interp) dropCommandName(name string) {
+	if sh.commandTrie == nil {
+		return
+	}
+	_, builtin := sh.registry[name]
+	_, alias := sh.aliases[name]
+	_, abbr := sh.abbrs[name]
+	if builtin || alias || abbr {
+		return
+	}
+	if _, err := sh.lookPath(name); err == nil {
+		return
+	}
+	sh.commandTrie.Delete(name)
+}
+
+// builtinCompleter implements readline.AutoCompleter. It tracks consecutive
+// TAB presses to distinguish "complete" from "list all matches".
+type builtinCompleter struct {
+	sh         *interp
+	lastPrefix string
+	tabCount   int
+}
+
+// Do is called by readline on each TAB press. It returns candidate suffixes
+// to append and the length of the prefix to replace.
+func (b *builtinCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	prefix := string(line[:pos])
+
+	// Only complete the first word (command name), the directory
+	// arguments of z, or the options and plugin arguments of a builtin.
+	word := prefix
+	var matches []string
+	if i := strings.LastIndexByte(prefix, ' '); i < 0 {
+		matches = b.sh.commandTrie.FindByPrefix(prefix)
+	} else if word = prefix[i+1:]; strings.HasPrefix(prefix, "z ") {
+		matches = b.sh.zCompletions(word)
+	} else if name, _, _ := strings.Cut(strings.TrimLeft(prefix, " "), " "); b.sh.registry[name].Run == nil {
+		return nil, 0
+	} else if strings.HasPrefix(word, "-") {
+		matches = optionCompletions(b.sh.registry[name], word)
+	} else if len(b.sh.registry[name].Completions) > 0 {
+		matches = argumentCompletions(b.sh.registry[name], word)
+	} else {
+		return nil, 0
+	}
+
+	// No matches — ring the bell.
+	if len(matches) == 0 {
+		fmt.Fprint(b.sh.stderr(), "\x07")
+		b.lastPrefix = ""
+		b.tabCount = 0
+		return nil, 0
+	}
+
+	// Exact single match — complete with trailing space.
+	if len(matches) == 1 {
+		b.lastPrefix = ""
+		b.tabCount = 0
+		suffix := matches[0][len(word):] + " "
+		return [][]rune{[]rune(suffix)}, len(word)
+	}
+
+	// Multiple matches — compute longest common prefix (LCP).
+	lcp := matches[0]
+	for _, m := range matches[1:] {
+		for !strings.HasPrefix(m, lcp) {
+			lcp = lcp[:len(lcp)-1]
+		}
+	}
+
+	// LCP is longer than what's typed — complete to the LCP.
+	if len(lcp) > len(word) {
+		b.lastPrefix = ""
+		b.tabCount = 0
+		suffix := lcp[len(word):]
+		if lcp == matches[0] && len(matches) == 1 {
+			suffix += " "
+		}
+		return [][]rune{[]rune(suffix)}, len(word)
+	}
+
+	// LCP equals prefix — nothing new to complete; use double-TAB listing.
+	if prefix == b.lastPrefix {
+		b.tabCount++
+	} else {
+		b.tabCount = 1
+	}
+	b.lastPrefix = prefix
+
+	// First TAB with ambiguity — bell.
+	if b.tabCount == 1 {
+		fmt.Fprint(b.sh.stderr(), "\x07")
+		return nil, 0
+	}
+
+	// Second consecutive TAB — list all matches below the prompt.
+	fmt.Fprintf(b.sh.stdout(), "\n%s\n$ %s", strings.Join(matches, "  "), prefix)
+	b.lastPrefix = ""
+	b.tabCount = 0
+	return nil, 0
+}
@@ -1,23 +1,22 @@
-package main
+package shell
 
 import (
 	"strings"
 	"testing"
 )
 
-// setupTestTrie replaces the global commandTrie with a trie containing the
-// given words. Returns a cleanup function that restores the original trie.
-func setupTestTrie(t *testing.T, words []string) {
+// setupTestTrie replaces the shell's commandTrie with a trie containing
+// the given words.
+func setupTestTrie(t *testing.T, sh *interp, words []string) {
 	t.Helper()
-	old := commandTrie
-	commandTrie = newTrie()
+	sh.commandTrie = newTrie()
 	for _, w := range words {
-		commandTrie.Insert(w)
+		sh.commandTrie.Insert(w)
 	}
-	t.Cleanup(func() { commandTrie = old })
 }
 
 func TestCompleterDo(t *testing.T) {
+	sh := newTestShell(t)
 	tests := []struct {
 		name       string
 		trieWords  []string
@@ -79,9 +78,9 @@ func TestCompleterDo(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			setupTestTrie(t, tt.trieWords)
+			setupTestTrie(t, sh, tt.trieWords)
 
-			comp := &builtinCompleter{}
+			comp := &builtinCompleter{sh: sh}
 			tabs := tt.tabCount
 			if tabs == 0 {
 				tabs = 1
@@ -93,8 +92,8 @@ func TestCompleterDo(t *testing.T) {
 				if tt.wantStderr != "" || tt.wantStdout != "" {
 					// Capture stderr/stdout on the last TAB press.
 					if i == tabs-1 {
-						stderrOut := captureStderr(t, func() {
-							stdoutOut := captureStdout(t, func() {
+						stderrOut := captureStderr(t, sh, func() {
+							stdoutOut := captureStdout(t, sh, func() {
 								result, length = comp.Do([]rune(tt.line), tt.pos)
 							})
 							if tt.wantStdout != "" && !strings.Contains(stdoutOut, tt.wantStdout) {
@@ -105,8 +104,8 @@ func TestCompleterDo(t *testing.T) {
 							t.Errorf("stderr = %q, want substring %q", stderrOut, tt.wantStderr)
 						}
 					} else {
-						captureStderr(t, func() {
-							captureStdout(t, func() {
+						captureStderr(t, sh, func() {
+							captureStdout(t, sh, func() {
 								result, length = comp.Do([]rune(tt.line), tt.pos)
 							})
 						})
@@ -0,0 +1,364 @@
+// declare.go — builtins that manage shell variables: declare/typeset,
+// local, export, readonly and unset.
+//
+// All but unset share parseDeclareFlags for their option letters and
+// printDeclaration for "declare -p" style listings, so a variable prints
+// the same way whichever builtin listed it.
+
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// declareFlags holds the attribute changes and listing mode requested on
+// a declare-family command line.
+type declareFlags struct {
+	on, off varAttr
+	print   bool // -p
+	funcs   bool // -f: operate on functions
+	names   bool // -F: list function names only
+	global  bool // -g: do not make variables local inside a function
+	local   bool // make variables local to the current function
+}
+
+// declareAttrLetters maps option letters to attributes.
+var declareAttrLetters = map[byte]varAttr{
+	'a': attrArray,
+	'A': attrAssoc,
+	'i': attrInteger,
+	'l': attrLower,
+	'n': attrNameref,
+	'r': attrReadonly,
+	'u': attrUpper,
+	'x': attrExport,
+}
+
+// parseDeclareFlags parses the -x and +x options of the builtin name;
+// optstring lists the letters it accepts. It returns the parsed flags and
+// the remaining operands. On an error it prints the message and usage
+// line (parseSignedOptions), and ok is false.
+func (sh *interp) parseDeclareFlags(name, optstring string, args []string) (f declareFlags, operands []string, ok bool) {
+	opts, args, ok := sh.parseSignedOptions(name, optstring, args)
+	if !ok {
+		return f, nil, false
+	}
+	for _, opt := range opts {
+		switch opt.c {
+		case 'p':
+			f.print = true
+		case 'f':
+			f.funcs = true
+		case 'F':
+			f.names = true
+		case 'g':
+			f.global = true
+		default:
+			if opt.plus {
+				f.off |= declareAttrLetters[opt.c]
+			} else {
+				f.on |= declareAttrLetters[opt.c]
+			}
+		}
+	}
+	return f, args, true
+}
+
+// builtinDeclare implements declare and typeset.
+func (sh *interp) builtinDeclare(name string, args []string) int {
+	f, args, ok := sh.parseDeclareFlags(name, "aAfFgilnprux", args)
+	if !ok {
+		return 2
+	}
+	if f.funcs || f.names {
+		return sh.printFunctions(name, args, f.names)
+	}
+	if len(args) == 0 {
+		switch {
+		case f.print || f.on != 0:
+			sh.printDeclarations(f.on)
+		default:
+			sh.printAssignments()
+		}
+		return 0
+	}
+	if f.print {
+		status := 0
+		for _, n := range args {
+			if !sh.printDeclaration(n) {
+				fmt.Fprintf(sh.stderr(), "%s: %s: not found\n", name, n)
+				status = 1
+			}
+		}
+		return status
+	}
+	// Inside a function, declare creates local variables unless -g.
+	f.local = sh.flow.funcs > 0 && !f.global
+	return sh.declareNames(name, f, args)
+}
+
+// builtinLocal implements local, which is declare restricted to functions.
+func (sh *interp) builtinLocal(args []string) int {
+	f, args, ok := sh.parseDeclareFlags("local", "aAilnprux", args)
+	if !ok {
+		return 2
+	}
+	if sh.flow.funcs == 0 {
+		fmt.Fprintln(sh.stderr(), "local: can only be used in a function")
+		return 1
+	}
+	f.local = true
+	return sh.declareNames("local", f, args)
+}
+
+// builtinExport implements export.
+func (sh *interp) builtinExport(args []string) int {
+	f, args, ok := sh.parseDeclareFlags("export", "np", args)
+	if !ok {
+		return 2
+	}
+	if len(args) == 0 {
+		sh.printDeclarations(attrExport)
+		return 0
+	}
+	// export -n removes the attribute; parseDeclareFlags treats 'n' as
+	// nameref, so translate it here.
+	if f.on&attrNameref != 0 {
+		return sh.declareNames("export", declareFlags{off: attrExport}, args)
+	}
+	return sh.declareNames("export", declareFlags{on: attrExport}, args)
+}
+
+// builtinReadonly implements readonly.
+func (sh *interp) builtinReadonly(args []string) int {
+	f, args, ok := sh.parseDeclareFlags("readonly", "aAp", args)
+	if !ok {
+		return 2
+	}
+	if len(args) == 0 {
+		sh.printDeclarations(attrReadonly)
+		return 0
+	}
+	return sh.declareNames("readonly", declareFlags{on: attrReadonly | f.on&(attrArray|attrAssoc)}, args)
+}
+
+// builtinUnset implements unset.
+func (sh *interp) builtinUnset(args []string) int {
+	opts, args, ok := sh.parseOptions("unset", "fnv", args)
+	if !ok {
+		return 2
+	}
+	funcs, nameref := false, false
+	for _, opt := range opts {
+		switch opt.c {
+		case 'f':
+			funcs = true
+		case 'n':
+			nameref = true
+		}
+	}
+	status := 0
+	for _, name := range args {
+		// unset -f removes functions; a plain unset does too when there is
+		// no variable of that name.
+		if _, isFunc := sh.functions[name]; funcs || isFunc && sh.vars.vars[name] == nil && !nameref {
+			delete(sh.functions, name)
+			continue
+		}
+		if i := strings.IndexByte(name, '['); i > 0 && strings.HasSuffix(name, "]") && isValidName(name[:i]) {
+			if err := sh.vars.UnsetIndex(name[:i], name[i+1:len(name)-1]); err != nil {
+				fmt.Fprintf(sh.stderr(), "unset: %s\n", err)
+				status = 1
+			}
+			continue
+		}
+		if !isValidName(name) {
+			fmt.Fprintf(sh.stderr(), "unset: `%s': not a valid identifier\n", name)
+			status = 1
+			continue
+		}
+		if nameref {
+			if v, ok := sh.vars.vars[name]; ok && v.Attrs&attrReadonly != 0 {
+				fmt.Fprintf(sh.stderr(), "unset: %s: cannot unset: readonly variable\n", name)
+				status = 1
+			} else {
+				delete(sh.vars.vars, name)
+			}
+			continue
+		}
+		if err := sh.vars.Unset(name); err != nil {
+			fmt.Fprintf(sh.stderr(), "unset: %s\n", err)
+			status = 1
+		}
+	}
+	return status
+}
+
+// declareNames applies f to each "name" or assignment operand. Attributes
+// are set before the value is assigned (so -i, -l/-u and -a/-A shape it),
+// and readonly is applied last so the value can still be written. The
+// status is 1 if any operand failed.
+func (sh *interp) declareNames(builtin string, f declareFlags, args []string) int {
+	status := 0
+	for _, arg := range args {
+		a, hasValue := parseAssignment(arg)
+		if !hasValue {
+			a = assignment{Name: arg}
+		}
+		name := a.Name
+		if !isValidName(name) {
+			fmt.Fprintf(sh.stderr(), "%s: `%s': not a valid identifier\n", builtin, arg)
+			status = 1
+			continue
+		}
+		if f.on&attrNameref != 0 && hasValue && !isValidName(a.Value) {
+			fmt.Fprintf(sh.stderr(), "%s: `%s': invalid variable name for name reference\n", builtin, a.Value)
+			status = 1
+			continue
+		}
+		if f.local {
+			if err := sh.vars.makeLocal(name); err != nil {
+				fmt.Fprintf(sh.stderr(), "%s: %s\n", builtin, err)
+				status = 1
+				continue
+			}
+		}
+		if err := sh.vars.SetAttrs(name, f.on&^attrReadonly, f.off); err != nil {
+			fmt.Fprintf(sh.stderr(), "%s: %s\n", builtin, err)
+			status = 1
+			continue
+		}
+		if hasValue {
+			var err error
+			if v := sh.vars.vars[name]; f.on&attrNameref != 0 {
+				// Set would follow the reference; store the target name itself.
+				if v.Attrs&attrReadonly != 0 {
+					err = fmt.Errorf("%s: readonly variable", name)
+				} else {
+					v.Value, v.IsSet = a.Value, true
+				}
+			} else {
+				err = sh.performAssignment(a, false)
+			}
+			if err != nil {
+				fmt.Fprintf(sh.stderr(), "%s: %s\n", builtin, err)
+				status = 1
+				continue
+			}
+		}
+		if f.on&attrReadonly != 0 {
+			sh.vars.SetAttrs(name, attrReadonly, 0)
+		}
+	}
+	return status
+}
+
+// printAssignments lists every set variable as name=value, quoted so the
+// output can be read back as input.
+func (sh *interp) printAssignments() {
+	for _, name := range sh.vars.Names() {
+		v := sh.vars.vars[name]
+		v.refresh(sh)
+		switch {
+		case v.isArray():
+			fmt.Fprintf(sh.stdout(), "%s=%s\n", name, arrayLiteral(v))
+		case v.IsSet:
+			fmt.Fprintf(sh.stdout(), "%s=%s\n", name, shellQuote(v.Value))
+		}
+	}
+}
+
+// printDeclarations lists, in declare -p form, every variable that has all
+// of the attributes in mask.
+func (sh *interp) printDeclarations(mask varAttr) {
+	for _, name := range sh.vars.Names() {
+		if sh.vars.vars[name].Attrs&mask == mask {
+			sh.printDeclaration(name)
+		}
+	}
+}
+
+// printDeclaration prints one variable as a declare command that would
+// recreate it. It reports false if the variable does not exist.
+func (sh *interp) printDeclaration(name string) bool {
+	v, ok := sh.vars.vars[name]
+	if !ok {
+		return false
+	}
+	v.refresh(sh)
+	switch {
+	case !v.IsSet:
+		fmt.Fprintf(sh.stdout(), "declare %s %s\n", attrFlags(v.Attrs), name)
+	case v.isArray():
+		fmt.Fprintf(sh.stdout(), "declare %s %s=%s\n", attrFlags(v.Attrs), name, arrayLiteral(v))
+	default:
+		fmt.Fprintf(sh.stdout(), "declare %s %s=%s\n", attrFlags(v.Attrs), name, doubleQuote(v.Value))
+	}
+	return true
+}
+
+// arrayLiteral renders an array as a compound value with explicit
+// subscripts: ([0]="a" [1]="b").
+func arrayLiteral(v *variable) string {
+	keys := v.keys()
+	parts := make([]string, len(keys))
+	for i, k := range keys {
+		e, _ := v.element(k)
+		if v.Attrs&attrAssoc != 0 {
+			k = shellQuote(k)
+		}
+		parts[i] = "[" + k + "]=" + doubleQuote(e)
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+// attrFlags renders attributes as declare option letters ("-rx"), or "--"
+// when there are none.
+func attrFlags(a varAttr) string {
+	flags := "-"
+	for _, c := range "aAilnrux" {
+		if a&declareAttrLetters[byte(c)] != 0 {
+			flags += string(c)
+		}
+	}
+	if flags == "-" {
+		return "--"
+	}
+	return flags
+}
+
+// doubleQuote wraps s in double quotes, escaping the characters that stay
+// special inside them.
+func doubleQuote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte("\\\"$`", s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// shellQuote returns s unchanged if it contains only characters that need
+// no quoting, and single-quoted otherwise.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+	safe := true
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !isNameChar(c) && strings.IndexByte("@%+=:,./-", c) < 0 {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,232 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeclareCommand(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name       string
+		args       [][]string // successive declare invocations
+		wantStdout string
+		wantStderr string
+	}{
+		{
+			name:       "print plain variable",
+			args:       [][]string{{"x=1"}, {"-p", "x"}},
+			wantStdout: "declare -- x=\"1\"\n",
+		},
+		{
+			name:       "integer attribute evaluates",
+			args:       [][]string{{"-i", "n=2+3"}, {"-p", "n"}},
+			wantStdout: "declare -i n=\"5\"\n",
+		},
+		{
+			name:       "combined flags",
+			args:       [][]string{{"-rx", "c=v"}, {"-p", "c"}},
+			wantStdout: "declare -rx c=\"v\"\n",
+		},
+		{
+			name:       "declared without value",
+			args:       [][]string{{"-u", "d"}, {"-p", "d"}},
+			wantStdout: "declare -u d\n",
+		},
+		{
+			name:       "value with specials is escaped",
+			args:       [][]string{{`q=a"$b`}, {"-p", "q"}},
+			wantStdout: "declare -- q=\"a\\\"\\$b\"\n",
+		},
+		{
+			name:       "nameref stores target name",
+			args:       [][]string{{"t=val"}, {"-n", "r=t"}, {"-p", "r"}},
+			wantStdout: "declare -n r=\"t\"\n",
+		},
+		{
+			name:       "missing variable",
+			args:       [][]string{{"-p", "missing"}},
+			wantStderr: "declare: missing: not found\n",
+		},
+		{
+			name:       "invalid identifier",
+			args:       [][]string{{"1x=2"}},
+			wantStderr: "declare: `1x=2': not a valid identifier\n",
+		},
+		{
+			name:       "invalid option",
+			args:       [][]string{{"-z", "x"}},
+			wantStderr: "declare: -z: invalid option\ndeclare: usage: declare [-aAfFgilnprux] [name[=value] ...]\n",
+		},
+		{
+			name:       "invalid plus option",
+			args:       [][]string{{"+z", "x"}},
+			wantStderr: "declare: +z: invalid option\ndeclare: usage: declare [-aAfFgilnprux] [name[=value] ...]\n",
+		},
+		{
+			name:       "plus option clusters",
+			args:       [][]string{{"-ux", "p=Ab"}, {"+ux", "p"}, {"-p", "p"}},
+			wantStdout: "declare -- p=\"AB\"\n",
+		},
+		{
+			name:       "cannot remove readonly",
+			args:       [][]string{{"-r", "ro=1"}, {"+r", "ro"}},
+			wantStderr: "declare: ro: readonly variable\n",
+		},
+	}
+
+	cmd, ok := sh.getCommand("declare")
+	if !ok {
+		t.Fatal("declare command not found in registry")
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestVars(t, sh)
+			var stdout string
+			stderr := captureStderr(t, sh, func() {
+				stdout = captureStdout(t, sh, func() {
+					for _, args := range tt.args {
+						cmd.Run(sh, args)
+					}
+				})
+			})
+			if stdout != tt.wantStdout {
+				t.Errorf("stdout = %q, want %q", stdout, tt.wantStdout)
+			}
+			if stderr != tt.wantStderr {
+				t.Errorf("stderr = %q, want %q", stderr, tt.wantStderr)
+			}
+		})
+	}
+}
+
+func TestDeclareListsAssignments(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh, "A=plain", "B=two words")
+	cmd, _ := sh.getCommand("declare")
+	got := captureStdout(t, sh, func() { cmd.Run(sh, nil) })
+	want := "A=plain\nB='two words'\n"
+	if got != want {
+		t.Errorf("declare = %q, want %q", got, want)
+	}
+}
+
+func TestExportCommand(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh)
+	cmd, ok := sh.getCommand("export")
+	if !ok {
+		t.Fatal("export command not found in registry")
+	}
+
+	cmd.Run(sh, []string{"E=1", "local"})
+	sh.vars.Set("local", "2")
+	env := strings.Join(sh.vars.Environ(), " ")
+	if env != "E=1 local=2" {
+		t.Errorf("Environ() = %q, want %q", env, "E=1 local=2")
+	}
+
+	cmd.Run(sh, []string{"-n", "local"})
+	if got := strings.Join(sh.vars.Environ(), " "); got != "E=1" {
+		t.Errorf("after export -n, Environ() = %q, want %q", got, "E=1")
+	}
+
+	got := captureStdout(t, sh, func() { cmd.Run(sh, nil) })
+	if got != "declare -x E=\"1\"\n" {
+		t.Errorf("export listing = %q", got)
+	}
+
+	var status int
+	gotErr := captureStderr(t, sh, func() { status = cmd.Run(sh, []string{"-f", "fn"}) })
+	if gotErr != "export: -f: invalid option\nexport: usage: export [-np] [name[=value] ...]\n" || status != 2 || sh.vars.Lookup("fn") != nil {
+		t.Errorf("export -f fn: stderr %q, status %d; want it rejected", gotErr, status)
+	}
+}
+
+func TestReadonlyCommand(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh)
+	cmd, ok := sh.getCommand("readonly")
+	if !ok {
+		t.Fatal("readonly command not found in registry")
+	}
+
+	cmd.Run(sh, []string{"R=fixed"})
+	if err := sh.vars.Set("R", "changed"); err == nil {
+		t.Error("expected assignment to readonly R to fail")
+	}
+	got := captureStdout(t, sh, func() { cmd.Run(sh, []string{"-p"}) })
+	if got != "declare -r R=\"fixed\"\n" {
+		t.Errorf("readonly -p = %q", got)
+	}
+}
+
+func TestUnsetCommand(t *testing.T) {
+	sh := newTestShell(t)
+	cmd, ok := sh.getCommand("unset")
+	if !ok {
+		t.Fatal("unset command not found in registry")
+	}
+
+	t.Run("removes variables", func(t *testing.T) {
+		setupTestVars(t, sh, "A=1", "B=2")
+		cmd.Run(sh, []string{"A", "B", "C"})
+		if len(sh.vars.Names()) != 0 {
+			t.Errorf("variables left after unset: %v", sh.vars.Names())
+		}
+	})
+
+	t.Run("readonly variable", func(t *testing.T) {
+		setupTestVars(t, sh)
+		sh.vars.Set("R", "1")
+		sh.vars.SetAttrs("R", attrReadonly, 0)
+		got := captureStderr(t, sh, func() { cmd.Run(sh, []string{"R"}) })
+		if got != "unset: R: cannot unset: readonly variable\n" {
+			t.Errorf("stderr = %q", got)
+		}
+	})
+
+	t.Run("nameref with -n removes the reference", func(t *testing.T) {
+		setupTestVars(t, sh, "target=1")
+		sh.vars.vars["ref"] = &variable{Value: "target", Attrs: attrNameref, IsSet: true}
+		cmd.Run(sh, []string{"-n", "ref"})
+		if _, ok := sh.vars.vars["ref"]; ok {
+			t.Error("ref still exists")
+		}
+		if sh.getVar("target") != "1" {
+			t.Error("target should be untouched")
+		}
+	})
+
+	t.Run("invalid option", func(t *testing.T) {
+		setupTestVars(t, sh)
+		got := captureStderr(t, sh, func() { cmd.Run(sh, []string{"-x", "a"}) })
+		if got != "unset: -x: invalid option\nunset: usage: unset [-fnv] [name ...]\n" {
+			t.Errorf("stderr = %q", got)
+		}
+	})
+
+	t.Run("invalid identifier", func(t *testing.T) {
+		setupTestVars(t, sh)
+		got := captureStderr(t, sh, func() { cmd.Run(sh, []string{"a-b"}) })
+		if got != "unset: `a-b': not a valid identifier\n" {
+			t.Errorf("stderr = %q", got)
+		}
+	})
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := map[string]string{
+		"":          "''",
+		"plain":     "plain",
+		"/usr/bin":  "/usr/bin",
+		"two words": "'two words'",
+		"it's":      `'it'\''s'`,
+	}
+	for in, want := range tests {
+		if got := shellQuote(in); got != want {
+			t.Errorf("shellQuote(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+// dirstack.go — the directory stack (pushd, popd, dirs) and tilde
+// expansion, which can refer to it.
+//
+//	pushd [-n] DIR     push the current directory and cd to DIR; -n only
+//	                   adds DIR to the stack
+//	pushd              swap the top two entries
+//	pushd +N | -N      rotate entry N to the top
+//	popd [-n]          drop the top entry and cd to the next; -n drops the
+//	                   second entry instead
+//	popd +N | -N       drop entry N
+//	dirs [-clpv] [+N | -N]
+//	                   print the stack (-l without ~ abbreviation, -p one
+//	                   per line, -v numbered), entry N, or -c clear it
+//
+// Entry 0 is always the current directory ($PWD); dirStack holds the rest,
+// most recent first. +N counts from the left of the dirs listing, -N from
+// the right.
+//
+// Tilde expansion replaces an unquoted ~ prefix of a word (or, in an
+// assignment, of the value and of each part after a ':'), up to the first
+// '/':
+//
+//	~        $HOME            ~+  $PWD       ~N, ~+N  entry N
+//	~user    user's home      ~-  $OLDPWD    ~-N      entry -N
+
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+)
+
+// stackEntries returns the whole stack, the current directory first.
+func (sh *interp) stackEntries() []string {
+	cur, err := sh.workingDir(false)
+	if err != nil {
+		cur = sh.getVar("PWD")
+	}
+	return append([]string{cur}, sh.dirStack...)
+}
+
+// stackIndex parses a +N or -N argument into an index into n entries.
+func stackIndex(arg string, n int) (index int, isIndex, ok bool) {
+	if len(arg) < 2 || (arg[0] != '+' && arg[0] != '-') {
+		return 0, false, false
+	}
+	num, err := strconv.Atoi(arg[1:])
+	if err != nil || num < 0 || arg[1] == '+' || arg[1] == '-' {
+		return 0, false, false
+	}
+	if num >= n {
+		return 0, true, false
+	}
+	if arg[0] == '-' {
+		num = n - 1 - num
+	}
+	return num, true, true
+}
+
+// stackOptions parses the options of pushd, popd or dirs. +N and -N are
+// operands, not options, so they are set aside before parseOptions sees
+// the arguments, and come first among the operands returned.
+func (sh *interp) stackOptions(name, optstring string, args []string) (opts []option, operands []string, ok bool) {
+	var indexes, rest []string
+	for i, arg := range args {
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if _, isIndex, _ := stackIndex(arg, 1); isIndex {
+			indexes = append(indexes, arg)
+		} else {
+			rest = append(rest, arg)
+		}
+	}
+	opts, operands, ok = sh.parseOptions(name, optstring, rest)
+	return opts, append(indexes, operands...), ok
+}
+
+// builtinPushd implements pushd.
+func (sh *interp) builtinPushd(args []string) int {
+	opts, args, ok := sh.stackOptions("pushd", "n", args)
+	if !ok {
+		return 2
+	}
+	noCd := len(opts) > 0
+	if len(args) > 1 {
+		fmt.Fprintln(sh.stderr(), "pushd: too many arguments")
+		return 1
+	}
+
+	entries := sh.stackEntries()
+	if len(args) == 0 {
+		if len(sh.dirStack) == 0 {
+			fmt.Fprintln(sh.stderr(), "pushd: no other directory")
+			return 1
+		}
+		return sh.rotateStack("pushd", []string{entries[1], entries[0]}, entries[2:], noCd)
+	}
+
+	arg := args[0]
+	if i, isIndex, ok := stackIndex(arg, len(entries)); isIndex {
+		if !ok {
+			fmt.Fprintf(sh.stderr(), "pushd: %s: directory stack index out of range\n", arg)
+			return 1
+		}
+		rotated := append(entries[i:len(entries):len(entries)], entries[:i]...)
+		return sh.rotateStack("pushd", rotated[:1], rotated[1:], noCd)
+	}
+
+	if noCd {
+		sh.dirStack = append([]string{arg}, sh.dirStack...)
+	} else {
+		if status := sh.cdTo("pushd", arg, false, false); status != 0 {
+			return status
+		}
+		sh.dirStack = append([]string{entries[0]}, sh.dirStack...)
+	}
+	sh.printStack(false, false, false)
+	return 0
+}
+
+// rotateStack makes top the new first entry (or entries) of the stack,
+// followed by rest, changing to top[0] unless noCd. It prints the stack.
+func (sh *interp) rotateStack(name string, top, rest []string, noCd bool) int {
+	if !noCd {
+		if status := sh.cdTo(name, top[0], false, false); status != 0 {
+			return status
+		}
+	}
+	sh.dirStack = append(append([]string(nil), top[1:]...), rest...)
+	sh.printStack(false, false, false)
+	return 0
+}
+
+// builtinPopd implements popd.
+func (sh *interp) builtinPopd(args []string) int {
+	opts, args, ok := sh.stackOptions("popd", "n", args)
+	if !ok {
+		return 2
+	}
+	noCd := len(opts) > 0
+	if len(args) > 1 {
+		fmt.Fprintln(sh.stderr(), "popd: too many arguments")
+		return 1
+	}
+	if len(sh.dirStack) == 0 {
+		fmt.Fprintln(sh.stderr(), "popd: directory stack empty")
+		return 1
+	}
+
+	entries := sh.stackEntries()
+	i := 0
+	if len(args) == 1 {
+		var isIndex, ok bool
+		if i, isIndex, ok = stackIndex(args[0], len(entries)); !isIndex {
+			fmt.Fprintf(sh.stderr(), "popd: %s: invalid argument\npopd: usage: popd [-n] [+N | -N]\n", args[0])
+			return 2
+		} else if !ok {
+			fmt.Fprintf(sh.stderr(), "popd: %s: directory stack index out of range\n", args[0])
+			return 1
+		}
+	}
+	if i == 0 && noCd {
+		i = 1
+	}
+
+	if i == 0 {
+		if status := sh.cdTo("popd", sh.dirStack[0], false, false); status != 0 {
+			return status
+		}
+		sh.dirStack = sh.dirStack[1:]
+	} else {
+		sh.dirStack = append(sh.dirStack[:i-1:i-1], sh.dirStack[i:]...)
+	}
+	sh.printStack(false, false, false)
+	return 0
+}
+
+// builtinDirs implements dirs.
+func (sh *interp) builtinDirs(args []string) int {
+	var clear, long, perLine, numbered bool
+	opts, args, ok := sh.stackOptions("dirs", "clpv", args)
+	if !ok {
+		return 2
+	}
+	for _, opt := range opts {
+		switch opt.c {
+		case 'c':
+			clear = true
+		case 'l':
+			long = true
+		case 'p':
+			perLine = true
+		case 'v':
+			perLine, numbered = true, true
+		}
+	}
+	entry := ""
+	for _, arg := range args {
+		if _, isIndex, _ := stackIndex(arg, 1); !isIndex {
+			fmt.Fprintf(sh.stderr(), "dirs: %s: invalid argument\ndirs: usage: %s\n", arg, builtinDocs["dirs"].Synopsis)
+			return 2
+		}
+		entry = arg
+	}
+	if clear {
+		sh.dirStack = nil
+		return 0
+	}
+	if entry != "" {
+		entries := sh.stackEntries()
+		i, _, ok := stackIndex(entry, len(entries))
+		if !ok {
+			fmt.Fprintf(sh.stderr(), "dirs: %s: directory stack index out of range\n", entry)
+			return 1
+		}
+		fmt.Fprintln(sh.stdout(), sh.abbreviateHome(entries[i], long))
+		return 0
+	}
+	sh.printStack(long, perLine, numbered)
+	return 0
+}
+
+// printStack prints the directory stack as dirs does.
+func (sh *interp) printStack(long, perLine, numbered bool) {
+	entries := sh.stackEntries()
+	for i, dir := range entries {
+		dir = sh.abbreviateHome(dir, long)
+		switch {
+		case numbered:
+			fmt.Fprintf(sh.stdout(), "%2d  %s\n", i, dir)
+		case perLine:
+			fmt.Fprintln(sh.stdout(), dir)
+		case i < len(entries)-1:
+			fmt.Fprint(sh.stdout(), dir, " ")
+		default:
+			fmt.Fprintln(sh.stdout(), dir)
+		}
+	}
+}
+
+// abbreviateHome writes $HOME at the start of dir as ~, unless long.
+func (sh *interp) abbreviateHome(dir string, long bool) string {
+	home := sh.getVar("HOME")
+	if long || home == "" || home == "/" {
+		return dir
+	}
+	if dir == home {
+		return "~"
+	}
+	if rest, ok := strings.CutPrefix(dir, home+"/"); ok {
+		return "~/" + rest
+	}
+	return dir
+}
+
+// tildeExpand returns the directory the tilde prefix ~prefix stands for.
+func (sh *interp) tildeExpand(prefix string) (string, bool) {
+	switch prefix {
+	case "":
+		if home, ok := sh.vars.Get("HOME"); ok {
+			return home, true
+		}
+		home, err := os.UserHomeDir()
+		return home, err == nil
+	case "+":
+		pwd, ok := sh.vars.Get("PWD")
+		return pwd, ok
+	case "-":
+		old, ok := sh.vars.Get("OLDPWD")
+		return old, ok
+	}
+	if c := prefix[0]; c >= '0' && c <= '9' {
+		prefix = "+" + prefix
+	}
+	entries := sh.stackEntries()
+	if i, isIndex, ok := stackIndex(prefix, len(entries)); isIndex {
+		return entries[i], ok
+	}
+	u, err := user.Lookup(prefix)
+	if err != nil {
+		return "", false
+	}
+	return u.HomeDir, true
+}
@@ -0,0 +1,75 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDirStack(t *testing.T) {
+	sh := newTestShell(t)
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"a", "b", "c"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "pushd prints the stack", src: "pushd a", want: root + "/a " + root + "\n"},
+		{name: "pushd then pwd", src: "pushd a >/dev/null; pushd ../b >/dev/null; pwd; dirs", want: root + "/b\n" + root + "/b " + root + "/a " + root + "\n"},
+		{name: "pushd swaps", src: "pushd a >/dev/null; pushd; pwd", want: root + " " + root + "/a\n" + root + "\n"},
+		{name: "pushd rotates", src: "pushd a >/dev/null; pushd ../b >/dev/null; pushd +2", want: root + " " + root + "/b " + root + "/a\n"},
+		{name: "pushd -N", src: "pushd a >/dev/null; pushd ../b >/dev/null; pushd -1", want: root + "/a " + root + " " + root + "/b\n"},
+		{name: "pushd -n", src: "pushd -n a; pwd", want: root + " a\n" + root + "\n"},
+		{name: "no other directory", src: "pushd", wantErr: "pushd: no other directory\n", status: 1},
+		{name: "pushd out of range", src: "pushd +5", wantErr: "pushd: +5: directory stack index out of range\n", status: 1},
+		{name: "pushd missing dir", src: "pushd nope", wantErr: "pushd: nope: No such file or directory\n", status: 1},
+		{name: "popd", src: "pushd a >/dev/null; popd; pwd", want: root + "\n" + root + "\n"},
+		{name: "popd +N", src: "pushd a >/dev/null; pushd ../b >/dev/null; popd +1; pwd", want: root + "/b " + root + "\n" + root + "/b\n"},
+		{name: "popd -n", src: "pushd a >/dev/null; pushd ../b >/dev/null; popd -n", want: root + "/b " + root + "\n"},
+		{name: "popd empty", src: "popd", wantErr: "popd: directory stack empty\n", status: 1},
+		{name: "dirs -v", src: "pushd a >/dev/null; dirs -v", want: " 0  " + root + "/a\n 1  " + root + "\n"},
+		{name: "dirs -p", src: "pushd a >/dev/null; dirs -p", want: root + "/a\n" + root + "\n"},
+		{name: "dirs entry", src: "pushd a >/dev/null; dirs +1; dirs -0", want: root + "\n" + root + "\n"},
+		{name: "dirs abbreviates HOME", src: "HOME=" + root + "; pushd a >/dev/null; dirs; dirs -l", want: "~/a ~\n" + root + "/a " + root + "\n"},
+		{name: "dirs -c", src: "pushd a >/dev/null; dirs -c; dirs", want: root + "/a\n"},
+		{name: "pushd after --", src: "pushd -- a", want: root + "/a " + root + "\n"},
+		{name: "pushd invalid option", src: "pushd -x", wantErr: "pushd: -x: invalid option\npushd: usage: pushd [-n] [+N | -N | dir]\n", status: 2},
+		{name: "popd invalid option", src: "popd -x", wantErr: "popd: -x: invalid option\npopd: usage: popd [-n] [+N | -N]\n", status: 2},
+		{name: "popd invalid argument", src: "pushd a >/dev/null; popd b", wantErr: "popd: b: invalid argument\npopd: usage: popd [-n] [+N | -N]\n", status: 2},
+		{name: "dirs --", src: "dirs --", want: root + "\n"},
+		{name: "dirs clustered options", src: "pushd a >/dev/null; dirs -lv", want: " 0  " + root + "/a\n 1  " + root + "\n"},
+		{name: "dirs invalid argument", src: "dirs x", wantErr: "dirs: x: invalid argument\ndirs: usage: dirs [-clpv] [+N] [-N]\n", status: 2},
+		{name: "dirs invalid option", src: "dirs -x", wantErr: "dirs: -x: invalid option\ndirs: usage: dirs [-clpv] [+N] [-N]\n", status: 2},
+		{name: "subshell keeps its own stack", src: "(pushd a >/dev/null); dirs", want: root + "\n"},
+		{name: "tilde", src: "HOME=/home/u; echo ~ ~/x a~ '~' \"~\" \\~", want: "/home/u /home/u/x a~ ~ ~ ~\n"},
+		{name: "tilde plus and minus", src: "cd a; cd ../b; echo ~+ ~-/x", want: root + "/b " + root + "/a/x\n"},
+		{name: "tilde stack entries", src: "pushd a >/dev/null; echo ~0 ~1 ~+1 ~-0 ~9", want: root + "/a " + root + " " + root + " " + root + " ~9\n"},
+		{name: "tilde in assignment", src: "HOME=/h; p=~/bin:~/sbin:a~; echo $p", want: "/h/bin:/h/sbin:a~\n"},
+		{name: "tilde unknown user", src: "echo ~nosuchuser9/x", want: "~nosuchuser9/x\n"},
+		{name: "tilde in redirection", src: "HOME=" + root + "; echo hi >~/out; cat out", want: "hi\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(root)
+			sh.dirStack = nil
+			t.Cleanup(func() { sh.dirStack = nil })
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
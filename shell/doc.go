@@ -0,0 +1,21 @@
+// Package shell is gosh, a POSIX-style shell, as a library.
+//
+// Main is the gosh command itself. To run shell code from a Go program,
+// create an Interpreter with New, configured with options for its
+// standard streams, environment, working directory, builtins and how it
+// runs external commands, and call its Run method:
+//
+//	var out bytes.Buffer
+//	sh, err := shell.New(shell.WithStdio(nil, &out, os.Stderr), shell.WithDir("/tmp"))
+//	if err != nil {
+//		return err
+//	}
+//	status, err := sh.Run(ctx, `for f in *.log; do wc -l "$f"; done`)
+//
+// Each Interpreter is an independent shell session: variables,
+// functions, aliases, options, traps and the working directory persist
+// from one Run to the next and are not shared with other instances.
+// Instances run in parallel; the process's working directory and
+// standard streams are never changed. Signals belong to the host
+// program, so an Interpreter's traps do not install signal handlers.
+package shell
@@ -0,0 +1,237 @@
+// exec.go — builtins that run other commands: eval, exec and command.
+//
+//	eval ARGS...            join ARGS with spaces, parse and run the result
+//	exec [-c] [-a NAME] CMD replace the shell with CMD (syscall.Exec); in
+//	                        an Interpreter, run CMD and end the run
+//	exec REDIRECTIONS       apply the redirections to the shell itself
+//	command [-p] NAME ARGS  run NAME as a builtin or external, skipping
+//	                        functions
+//	command -v|-V NAME...   describe how each NAME resolves
+
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// defaultPath is the PATH used by command -p: one that finds the standard
+// utilities whatever the user's PATH holds.
+const defaultPath = "/usr/local/bin:/usr/bin:/bin:/usr/sbin:/sbin"
+
+// builtinEval implements eval.
+func (sh *interp) builtinEval(args []string) int {
+	prog, err := sh.parse(strings.Join(args, " "), sh.params.lineno)
+	if err != nil {
+		fmt.Fprintf(sh.stderr(), "eval: %v\n", err)
+		return 2
+	}
+	return sh.runList(prog)
+}
+
+// builtinExec implements exec with a command; exec with only redirections
+// is handled by runCommand, which owns the redirections. It returns only
+// if the command cannot be run, and then a non-interactive shell exits.
+func (sh *interp) builtinExec(args []string) int {
+	argv0, clearEnv := "", false
+	opts, args, ok := sh.parseOptions("exec", "ca:", args)
+	if !ok {
+		return 2
+	}
+	for _, opt := range opts {
+		switch opt.c {
+		case 'c':
+			clearEnv = true
+		case 'a':
+			argv0 = opt.arg
+		}
+	}
+	if len(args) == 0 {
+		return 0
+	}
+
+	path, err := sh.lookPath(args[0])
+	if err != nil {
+		fmt.Fprintf(sh.stderr(), "exec: %s: not found\n", args[0])
+		return sh.execFailed(127)
+	}
+	var env []string
+	if !clearEnv {
+		if env, err = sh.commandEnv(nil); err != nil {
+			fmt.Fprintln(sh.stderr(), err)
+			return sh.execFailed(1)
+		}
+	}
+	argv := append([]string{args[0]}, args[1:]...)
+	if argv0 != "" {
+		argv[0] = argv0
+	}
+
+	if sh.embedded {
+		// An Interpreter cannot give its process away: run the command
+		// and end the run with its status.
+		cmd := exec.CommandContext(sh.ctx, path)
+		cmd.Args, cmd.Env, cmd.Dir = argv, env, sh.dir
+		attachFds(cmd, sh.fds)
+		sh.params.status = sh.runExec(cmd)
+		sh.flow.kind = flowExit
+		return sh.params.status
+	}
+	if sh.params.interactive {
+		sh.saveHistory()
+	}
+	// The new program inherits the process's file descriptors and working
+	// directory, not the shell's: move the shell's into place, and change
+	// to its directory, first. If the exec fails, an interactive shell
+	// goes on with them as they were.
+	wd, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "exec: %v\n", err)
+		return sh.execFailed(1)
+	}
+	restore, err := placeFds(sh.fds)
+	if err == nil {
+		if err = os.Chdir(sh.dir); err != nil {
+			restore()
+		}
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "exec: %v\n", err)
+		return sh.execFailed(1)
+	}
+	err = syscall.Exec(path, argv, env)
+	restore()
+	os.Chdir(wd)
+	fmt.Fprintf(os.Stderr, "exec: %s: %v\n", args[0], err)
+	return sh.execFailed(126)
+}
+
+// placeFds makes the process's descriptors 0, 1, 2... those of fds, for a
+// program exec starts: fds[n] is duplicated onto n, or n closed for a nil
+// entry below 3, and a file already in place has its close-on-exec flag
+// cleared. restore puts back what was there before.
+func placeFds(fds []*os.File) (restore func(), err error) {
+	var undo []func()
+	restore = func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+	for fd, f := range fds {
+		switch {
+		case f == nil && fd >= 3:
+			continue
+		case f != nil && int(f.Fd()) == fd:
+			if fd >= 3 {
+				syscall.Syscall(syscall.SYS_FCNTL, uintptr(fd), syscall.F_SETFD, 0)
+				undo = append(undo, func() { syscall.CloseOnExec(fd) })
+			}
+			continue
+		}
+		undo = append(undo, saveFd(fd))
+		if f == nil {
+			syscall.Close(fd)
+		} else if err := dupFd(int(f.Fd()), fd); err != nil {
+			restore()
+			return nil, err
+		}
+	}
+	return restore, nil
+}
+
+// saveFd keeps a close-on-exec copy of the process's descriptor fd and
+// returns a function that puts it back, or closes fd if it was not open.
+func saveFd(fd int) (restore func()) {
+	syscall.ForkLock.RLock()
+	saved, err := syscall.Dup(fd)
+	if err == nil {
+		syscall.CloseOnExec(saved)
+	}
+	syscall.ForkLock.RUnlock()
+	if err != nil {
+		return func() { syscall.Close(fd) }
+	}
+	return func() {
+		dupFd(saved, fd)
+		syscall.Close(saved)
+	}
+}
+
+// execFailed ends a non-interactive shell after exec could not run its
+// command, as POSIX requires, and returns status.
+func (sh *interp) execFailed(status int) int {
+	if !sh.params.interactive {
+		sh.flow.kind = flowExit
+	}
+	return status
+}
+
+// builtinCommand implements command.
+func (sh *interp) builtinCommand(args []string) int {
+	describe, verbose, path := false, false, sh.getVar("PATH")
+	opts, args, ok := sh.parseOptions("command", "pVv", args)
+	if !ok {
+		return 2
+	}
+	for _, opt := range opts {
+		switch opt.c {
+		case 'p':
+			path = defaultPath
+		case 'v':
+			describe = true
+		case 'V':
+			describe, verbose = true, true
+		}
+	}
+	if len(args) == 0 {
+		return 0
+	}
+
+	if describe {
+		status := 0
+		for _, name := range args {
+			if !sh.describeCommand(name, path, verbose) {
+				if verbose {
+					fmt.Fprintf(sh.stderr(), "command: %s: not found\n", name)
+				}
+				status = 1
+			}
+		}
+		return status
+	}
+
+	name := args[0]
+	if builtin, ok := sh.getCommand(name); ok {
+		return builtin.Run(sh, args[1:])
+	}
+	p, err := sh.lookPathIn(name, path)
+	if err != nil {
+		return sh.commandError(name, err)
+	}
+	return sh.runExternal(p, name, args[1:])
+}
+
+// describeCommand prints how name resolves, searching dirs (a PATH-style
+// list) for external commands: in the words of type when verbose,
+// otherwise as the command name or path alone, or the alias definition
+// (command -v). It reports false if name is not found.
+func (sh *interp) describeCommand(name, dirs string, verbose bool) bool {
+	matches := sh.resolveCommand(name, dirs, false)
+	if len(matches) == 0 {
+		return false
+	}
+	switch m := matches[0]; {
+	case verbose:
+		m.describe(sh.stdout(), name)
+	case m.kind == "alias":
+		fmt.Fprintln(sh.stdout(), sh.aliasDefinition(name))
+	case m.kind == "file":
+		fmt.Fprintln(sh.stdout(), m.value)
+	default:
+		fmt.Fprintln(sh.stdout(), name)
+	}
+	return true
+}
@@ -0,0 +1,182 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+)
+
+func TestEval(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name   string
+		src    string
+		want   string
+		status int
+	}{
+		{name: "reparses its arguments", src: `cmd='echo a; echo b'; eval $cmd`, want: "a\nb\n"},
+		{name: "joins arguments", src: `eval echo '$((1' '+' '2))'`, want: "3\n"},
+		{name: "indirect variable", src: `name=x; x=value; eval "echo \$$name"`, want: "value\n"},
+		{name: "assignment", src: `eval 'v=1'; echo $v`, want: "1\n"},
+		{name: "break inside eval", src: "for x in a b; do eval 'echo $x; break'; done", want: "a\n"},
+		{name: "status", src: "eval false", status: 1},
+		{name: "no arguments", src: "false; eval", status: 0},
+		{name: "syntax error", src: "eval 'fi'; echo $?", want: "2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || status != tt.status {
+				t.Errorf("output %q, status %d; want %q, %d", got, status, tt.want, tt.status)
+			}
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+	sh := newTestShell(t)
+	dir := t.TempDir()
+	prog := filepath.Join(dir, "prog")
+	if err := os.WriteFile(prog, []byte("#!/bin/sh\necho external\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "skips functions", src: "echo() { printf 'wrapped\\n'; }; command echo plain", want: "plain\n"},
+		{name: "runs externals", src: "PATH=" + dir + "; command prog", want: "external\n"},
+		{name: "not found", src: "command nosuchcmd", want: "nosuchcmd: command not found\n", status: 127},
+		{name: "-v builtin", src: "command -v cd", want: "cd\n"},
+		{name: "-v function", src: "f() { :; }; command -v f", want: "f\n"},
+		{name: "-v external", src: "PATH=" + dir + "; command -v prog", want: prog + "\n"},
+		{name: "-v several names", src: "PATH=" + dir + "; command -v cd missing prog", want: "cd\n" + prog + "\n", status: 1},
+		{name: "-v missing is silent", src: "command -v missing", status: 1},
+		{name: "-V builtin", src: "command -V cd", want: "cd is a shell builtin\n"},
+		{name: "-V function", src: "f() { :; }; command -V f", want: "f is a function\nf() { :; }\n"},
+		{name: "-V external", src: "PATH=" + dir + "; command -V prog", want: "prog is " + prog + "\n"},
+		{name: "-V missing", src: "command -V missing", wantErr: "command: missing: not found\n", status: 1},
+		{name: "-p uses the default PATH", src: "PATH=" + dir + "; command -pv sh >/dev/null && echo found", want: "found\n"},
+		{name: "invalid option", src: "command -x", wantErr: "command: -x: invalid option\ncommand: usage: command [-pVv] command [arg ...]\n", status: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
+
+func TestExecRedirectionsOnly(t *testing.T) {
+	sh := newTestShell(t)
+	out := filepath.Join(t.TempDir(), "out")
+	got, status := runTestScript(t, sh, "echo before; exec >"+out+"; echo after; echo again")
+	if got != "before\n" || status != 0 {
+		t.Errorf("output %q, status %d; want %q, 0", got, status, "before\n")
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "after\nagain\n" {
+		t.Errorf("file contents = %q, want %q", data, "after\nagain\n")
+	}
+}
+
+func TestExecDescriptors(t *testing.T) {
+	sh := newTestShell(t)
+	dir := t.TempDir()
+	out, in := filepath.Join(dir, "out"), filepath.Join(dir, "in")
+	if err := os.WriteFile(in, []byte("l1\nl2\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	src := "exec 3>" + out + " 4<" + in + "\n" +
+		"echo one >&3; sh -c 'echo two >&3'; { echo three; } 1>&3\n" +
+		"read -u 4 a; read b <&4; echo $a $b\n" +
+		"exec 3>&- 4<&-; echo four >&3; read -u 4 c"
+	var got string
+	var status int
+	gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, src) })
+	wantErr := "3: bad file descriptor\nread: 4: invalid file descriptor: bad file descriptor\n"
+	if got != "l1 l2\n" || gotErr != wantErr || status != 1 {
+		t.Errorf("got %q, stderr %q, status %d; want %q, %q, 1", got, gotErr, status, "l1 l2\n", wantErr)
+	}
+	if data, err := os.ReadFile(out); err != nil || string(data) != "one\ntwo\nthree\n" {
+		t.Errorf("out = %q, %v; want %q", data, err, "one\ntwo\nthree\n")
+	}
+	if sh.fd(3) != nil || sh.fd(4) != nil || len(sh.execFiles) != 0 {
+		t.Errorf("fds %v, exec files %v; want 3 and 4 closed", sh.fds, sh.execFiles)
+	}
+}
+
+func TestExecNotFound(t *testing.T) {
+	sh := newTestShell(t)
+	var got string
+	var status int
+	gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, "exec nosuchcmd; echo unreachable") })
+	if got != "" || gotErr != "exec: nosuchcmd: not found\n" || status != 127 {
+		t.Errorf("got %q, stderr %q, status %d; want exec to end the script with 127", got, gotErr, status)
+	}
+}
+
+func TestPlaceFdsRestore(t *testing.T) {
+	dir := t.TempDir()
+	a, err := os.Create(filepath.Join(dir, "a"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer a.Close()
+	b, err := os.Create(filepath.Join(dir, "b"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	ino := func(fd int) uint64 {
+		var st syscall.Stat_t
+		if syscall.Fstat(fd, &st) != nil {
+			return 0
+		}
+		return uint64(st.Ino)
+	}
+	// held will hold a copy of a; unused is not open.
+	held := 200
+	for ino(held) != 0 || ino(held+1) != 0 {
+		held++
+	}
+	unused := held + 1
+	if err := dupFd(int(a.Fd()), held); err != nil {
+		t.Fatal(err)
+	}
+	defer syscall.Close(held)
+	aIno, bIno := ino(int(a.Fd())), ino(int(b.Fd()))
+
+	fds := make([]*os.File, unused+1)
+	fds[0], fds[1], fds[2] = os.Stdin, os.Stdout, os.Stderr
+	fds[held], fds[unused] = b, b
+	restore, err := placeFds(fds)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ino(held) != bIno || ino(unused) != bIno {
+		t.Fatalf("placed: fds %d and %d are not b", held, unused)
+	}
+	restore()
+	if ino(held) != aIno {
+		t.Errorf("restored: fd %d is not a", held)
+	}
+	if ino(unused) != 0 {
+		t.Errorf("restored: fd %d is still open", unused)
+	}
+}
@@ -0,0 +1,1011 @@
+// expand.go — word expansion: parameter substitution and quote removal.
+//
+// A raw word is the text of one token exactly as typed, quotes included.
+// Expansion happens in one left-to-right pass:
+//
+//	scanWord(s, pos, stops)   find where a raw word ends
+//	expandFields(raw)         resolve quotes/escapes and substitute $name,
+//	                          ${...} (outside single quotes); "${a[@]}"
+//	                          yields one field per element; then expand
+//	                          pathnames (glob.go)
+//	expandWord(raw)           the same, joined into one string
+//	expandAssignment(raw)     expandWord with tilde expansion, for the
+//	expandTarget(raw)         value of NAME=value and for redirection
+//	                          targets
+//	expandPattern(raw)        the same, as a glob pattern with quoted
+//	                          characters escaped (for ${var#pat} etc.)
+//
+// The expander writes into a fieldBuilder, which tracks for every field
+// both its value and its pattern form.
+//
+// Inside double quotes a backslash only escapes \ " $ and `; everywhere
+// else outside single quotes it escapes the next character.
+//
+// Words that become fields, redirection targets and assignment values
+// also get tilde expansion (see dirstack.go) of an unquoted leading ~, and
+// in assignment values of a ~ after each ':' as well.
+
+package shell
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// scanWord returns the index just past the raw word starting at s[pos].
+// The word ends at an unquoted blank, newline, or any unquoted byte in
+// stops. Quoted sections, escapes, ${...}, $(...), `...` and the
+// parenthesised value of a compound assignment (a=(x y)) are skipped over
+// intact.
+func scanWord(s string, pos int, stops string) int {
+	var q quoteTracker
+	i := pos
+	for i < len(s) {
+		ch := s[i]
+		if ch == '\\' && !q.inSingle {
+			i += 2
+			continue
+		}
+		if ch == '\'' && !q.inDouble {
+			q.inSingle = !q.inSingle
+			i++
+			continue
+		}
+		if ch == '"' && !q.inSingle {
+			q.inDouble = !q.inDouble
+			i++
+			continue
+		}
+		if ch == '$' && !q.inSingle && i+1 < len(s) && (s[i+1] == '{' || s[i+1] == '(') {
+			i = skipGroup(s, i+1)
+			continue
+		}
+		if ch == '`' && !q.inSingle {
+			i = skipBackquote(s, i)
+			continue
+		}
+		if ch == '(' && !q.IsQuoted() && i > pos && s[i-1] == '=' {
+			i = skipGroup(s, i)
+			continue
+		}
+		if !q.IsQuoted() {
+			if isBlank(ch) || ch == '\n' {
+				break
+			}
+			if strings.IndexByte(stops, ch) >= 0 {
+				break
+			}
+		}
+		i++
+	}
+	if i > len(s) {
+		i = len(s)
+	}
+	return i
+}
+
+// skipGroup returns the index just past the '}' or ')' matching the '{'
+// or '(' at s[i], honouring nesting, quotes and escapes. If there is no
+// match it returns len(s).
+func skipGroup(s string, i int) int {
+	if end := groupEnd(s, i); end >= 0 {
+		return end
+	}
+	return len(s)
+}
+
+// groupEnd is skipGroup, but returns -1 if the group is unterminated.
+func groupEnd(s string, i int) int {
+	open := s[i]
+	closer := byte('}')
+	if open == '(' {
+		closer = ')'
+	}
+	depth := 0
+	var q quoteTracker
+	for ; i < len(s); i++ {
+		ch := s[i]
+		switch {
+		case ch == '\\' && !q.inSingle:
+			i++
+		case ch == '\'' && !q.inDouble:
+			q.inSingle = !q.inSingle
+		case ch == '"' && !q.inSingle:
+			q.inDouble = !q.inDouble
+		case q.IsQuoted():
+		case ch == open:
+			depth++
+		case ch == closer:
+			depth--
+			if depth == 0 {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// skipBackquote returns the index just past the backquote that closes the
+// one at s[i], or len(s) if there is none.
+func skipBackquote(s string, i int) int {
+	for i++; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '`':
+			return i + 1
+		}
+	}
+	return len(s)
+}
+
+// fieldBuilder collects the fields a word expands to. Each field is built
+// twice: its value, and a pattern in which quoted characters are escaped so
+// they match only themselves (glob.go). breakField starts a new field.
+//
+// A field that ends up empty is dropped unless some quoted text (even "")
+// went into it, so $unset disappears but "" and "$unset" remain.
+//
+// With split set, unquoted expansion results are divided into fields at
+// IFS characters (see splitIFS).
+type fieldBuilder struct {
+	vals, pats []string
+	val, pat   strings.Builder
+	keep       bool      // the current field contains quoted text
+	writes     int       // number of appends so far, to detect empty quotes
+	split      bool      // perform field splitting
+	vars       *varTable // the shell's variables, for IFS when splitting
+	pending    bool      // IFS whitespace ended the current field
+
+	quote func(string) string // escapes quoted text in patterns; default escapeGlob
+}
+
+// lit appends unquoted text that came from the word itself.
+func (b *fieldBuilder) lit(s string) {
+	b.flushPending()
+	b.val.WriteString(s)
+	b.pat.WriteString(s)
+	b.writes++
+}
+
+// quoted appends quoted text, which is literal in patterns.
+func (b *fieldBuilder) quoted(s string) {
+	b.flushPending()
+	b.val.WriteString(s)
+	if b.quote != nil {
+		b.pat.WriteString(b.quote(s))
+	} else {
+		b.pat.WriteString(escapeGlob(s))
+	}
+	b.keep = true
+	b.writes++
+}
+
+// expanded appends the result of an expansion.
+func (b *fieldBuilder) expanded(s string, quoted bool) {
+	switch {
+	case quoted:
+		b.quoted(s)
+	case b.split:
+		b.splitIFS(s)
+	default:
+		b.lit(s)
+	}
+}
+
+// splitIFS appends unquoted expansion output, splitting it into fields as
+// POSIX specifies. IFS whitespace (space, tab, newline in IFS) separates
+// fields and runs of it count once; any other IFS character delimits a
+// field on its own, so "a::b" with IFS=: gives a, "" and b. Text that
+// follows IFS whitespace starts a new field. IFS unset means " \t\n";
+// IFS empty disables splitting.
+func (b *fieldBuilder) splitIFS(s string) {
+	ifs, ok := b.vars.Get("IFS")
+	if !ok {
+		ifs = " \t\n"
+	}
+	if ifs == "" {
+		b.lit(s)
+		return
+	}
+	b.writes++
+	for _, r := range s {
+		switch {
+		case !strings.ContainsRune(ifs, r):
+			b.flushPending()
+			b.val.WriteRune(r)
+			b.pat.WriteRune(r)
+		case r == ' ' || r == '\t' || r == '\n':
+			if b.val.Len() > 0 || b.keep {
+				b.pending = true
+			}
+		default:
+			b.pending = false
+			b.keep = true
+			b.breakField()
+		}
+	}
+}
+
+// flushPending ends the current field if IFS whitespace was seen since
+// its last character.
+func (b *fieldBuilder) flushPending() {
+	if b.pending {
+		b.pending = false
+		b.breakField()
+	}
+}
+
+func (b *fieldBuilder) breakField() {
+	if b.val.Len() > 0 || b.keep {
+		b.vals = append(b.vals, b.val.String())
+		b.pats = append(b.pats, b.pat.String())
+	}
+	b.val.Reset()
+	b.pat.Reset()
+	b.keep = false
+}
+
+// finish returns the values and patterns of all fields.
+func (b *fieldBuilder) finish() (vals, pats []string) {
+	b.pending = false
+	b.breakField()
+	return b.vals, b.pats
+}
+
+// expandWord expands a raw word into a single string without field
+// splitting, as for assignments and redirection targets.
+func (sh *interp) expandWord(raw string) (string, error) {
+	e := expander{sh: sh}
+	if err := e.word(raw, false, false); err != nil {
+		return "", err
+	}
+	vals, _ := e.b.finish()
+	return strings.Join(vals, " "), nil
+}
+
+// expandAssignment expands the raw value of an assignment, including a ~
+// at its start or after a ':', as in PATH=~/bin:~/sbin.
+func (sh *interp) expandAssignment(raw string) (string, error) {
+	e := expander{sh: sh, tilde: tildeAssign}
+	if err := e.word(raw, false, false); err != nil {
+		return "", err
+	}
+	vals, _ := e.b.finish()
+	return strings.Join(vals, " "), nil
+}
+
+// expandTarget expands the raw target of a redirection, including a
+// leading ~.
+func (sh *interp) expandTarget(raw string) (string, error) {
+	e := expander{sh: sh, tilde: tildeWord}
+	if err := e.word(raw, false, false); err != nil {
+		return "", err
+	}
+	vals, _ := e.b.finish()
+	return strings.Join(vals, " "), nil
+}
+
+// expandFields expands a raw word into its fields, splitting unquoted
+// expansions at IFS characters, then performs pathname expansion unless
+// set -f is on.
+func (sh *interp) expandFields(raw string) ([]string, error) {
+	e := expander{sh: sh, b: fieldBuilder{split: true, vars: sh.vars}, tilde: tildeWord}
+	if err := e.word(raw, false, false); err != nil {
+		return nil, err
+	}
+	vals, pats := e.b.finish()
+	if sh.options[optNoglob] {
+		return vals, nil
+	}
+	return sh.expandPathnames(vals, pats)
+}
+
+// expandPattern expands a raw word for use as a pattern: quoted parts are
+// escaped so that only unquoted *, ? and [ are special.
+func (sh *interp) expandPattern(raw string) (string, error) {
+	e := expander{sh: sh}
+	if err := e.word(raw, false, false); err != nil {
+		return "", err
+	}
+	_, pats := e.b.finish()
+	return strings.Join(pats, " "), nil
+}
+
+// expandRegex expands the raw operand of =~ in [[ ]]: quoted parts are
+// escaped so that they match literally.
+func (sh *interp) expandRegex(raw string) (string, error) {
+	e := expander{sh: sh, b: fieldBuilder{quote: regexp.QuoteMeta}}
+	if err := e.word(raw, false, false); err != nil {
+		return "", err
+	}
+	_, pats := e.b.finish()
+	return strings.Join(pats, " "), nil
+}
+
+// expander expands raw words into its fieldBuilder. multiQuoted records
+// that a "$@"-style expansion occurred inside the current double quotes:
+// with no elements it produces no field at all, unlike "". tilde says
+// where tilde prefixes are expanded.
+type expander struct {
+	sh          *interp
+	b           fieldBuilder
+	multiQuoted bool
+	tilde       tildeMode
+}
+
+// tildeMode says where an expander expands tilde prefixes.
+type tildeMode int
+
+const (
+	tildeNone   tildeMode = iota
+	tildeWord             // at the start of the word
+	tildeAssign           // at the start and after every ':'
+)
+
+// tildePrefix expands the tilde prefix starting with the '~' at raw[i],
+// if there is one there, and returns the index just past it. The prefix
+// runs to the first '/' (or ':' in an assignment) and must be unquoted;
+// one that names nothing, like ~nosuchuser, is left as it is.
+func (e *expander) tildePrefix(raw string, i int) (int, bool) {
+	if e.tilde == tildeNone || (i > 0 && (e.tilde != tildeAssign || raw[i-1] != ':')) {
+		return i, false
+	}
+	stops := "/"
+	if e.tilde == tildeAssign {
+		stops = "/:"
+	}
+	end := i + 1
+	for end < len(raw) && strings.IndexByte(stops, raw[end]) < 0 {
+		end++
+	}
+	prefix := raw[i+1 : end]
+	if strings.ContainsAny(prefix, "\\'\"$`") {
+		return i, false
+	}
+	dir, ok := e.sh.tildeExpand(prefix)
+	if !ok {
+		return i, false
+	}
+	e.b.quoted(dir)
+	return end, true
+}
+
+// word expands raw. inDouble is the quoting context the text starts in;
+// nested marks the word of a ${name:-word} style operator, whose unquoted
+// text counts as expansion output rather than literal word text.
+func (e *expander) word(raw string, inDouble, nested bool) error {
+	text := func(s string) {
+		switch {
+		case inDouble:
+			e.b.quoted(s)
+		case nested:
+			e.b.expanded(s, false)
+		default:
+			e.b.lit(s)
+		}
+	}
+	quoteStart := -1
+	for i := 0; i < len(raw); i++ {
+		ch := raw[i]
+		switch {
+		case ch == '\\':
+			if i+1 >= len(raw) {
+				text(`\`)
+				continue
+			}
+			if raw[i+1] == '\n' { // line continuation
+				i++
+				continue
+			}
+			if inDouble && strings.IndexByte("\\\"$`", raw[i+1]) < 0 {
+				e.b.quoted(`\`)
+				continue
+			}
+			i++
+			e.b.quoted(raw[i : i+1])
+
+		case ch == '\'' && !inDouble:
+			end := strings.IndexByte(raw[i+1:], '\'')
+			if end < 0 {
+				e.b.quoted(raw[i+1:])
+				return nil
+			}
+			e.b.quoted(raw[i+1 : i+1+end])
+			i += end + 1
+
+		case ch == '"' && !inDouble:
+			inDouble = true
+			quoteStart = e.b.writes
+			e.multiQuoted = false
+
+		case ch == '"':
+			inDouble = false
+			if e.b.writes == quoteStart && !e.multiQuoted {
+				e.b.quoted("")
+			}
+
+		case ch == '`':
+			end := skipBackquote(raw, i)
+			if raw[end-1] != '`' || end-1 == i {
+				return fmt.Errorf("unexpected EOF while looking for matching ``'")
+			}
+			out, err := e.sh.commandSubst(unescapeBackquote(raw[i+1 : end-1]))
+			if err != nil {
+				return err
+			}
+			e.b.expanded(out, inDouble)
+			i = end - 1
+
+		case ch == '$':
+			next, err := e.dollar(raw, i, inDouble)
+			if err != nil {
+				return err
+			}
+			i = next - 1
+
+		case ch == '~' && !inDouble && !nested:
+			if next, ok := e.tildePrefix(raw, i); ok {
+				i = next - 1
+				continue
+			}
+			text("~")
+
+		default:
+			text(raw[i : i+1])
+		}
+	}
+	return nil
+}
+
+// dollar expands the parameter reference starting with the '$' at s[i].
+// quoted reports whether the reference is inside double quotes. It returns
+// the index just past the reference. A '$' that does not start a reference
+// expands to itself.
+func (e *expander) dollar(s string, i int, quoted bool) (int, error) {
+	if i+1 >= len(s) {
+		e.b.expanded("$", quoted)
+		return i + 1, nil
+	}
+	switch c := s[i+1]; {
+	case c == '{':
+		end := skipGroup(s, i+1)
+		if s[end-1] != '}' || end-1 == i+2 {
+			return len(s), fmt.Errorf("%s: bad substitution", s[i:])
+		}
+		return end, e.braced(s[i+2:end-1], quoted)
+	case c == '(':
+		end := skipGroup(s, i+1)
+		if s[end-1] != ')' {
+			return len(s), fmt.Errorf("unexpected EOF while looking for matching `)'")
+		}
+		if i+2 < end && s[i+2] == '(' && skipGroup(s, i+2) == end-1 {
+			return end, e.arith(s[i+3:end-2], quoted)
+		}
+		out, err := e.sh.commandSubst(s[i+2 : end-1])
+		if err != nil {
+			return end, err
+		}
+		e.b.expanded(out, quoted)
+		return end, nil
+	case isNameStart(c):
+		j := i + 1
+		for j < len(s) && isNameChar(s[j]) {
+			j++
+		}
+		val, ok := e.sh.vars.Get(s[i+1 : j])
+		if !ok && e.sh.options[optNounset] {
+			return j, e.sh.unboundVar(s[i+1 : j])
+		}
+		e.b.expanded(val, quoted)
+		return j, nil
+	case c == '@' || c == '*':
+		e.values(e.sh.params.positional, c == '@' || !quoted, quoted)
+		return i + 2, nil
+	case isSpecialParam(s[i+1 : i+2]):
+		vals, ok := e.sh.specialParam(s[i+1 : i+2])
+		if !ok && e.sh.options[optNounset] {
+			return i + 2, e.sh.unboundVar(s[i+1 : i+2])
+		}
+		e.b.expanded(strings.Join(vals, ""), quoted)
+		return i + 2, nil
+	}
+	e.b.expanded("$", quoted)
+	return i + 1, nil
+}
+
+// arith expands $((expr)): parameters and command substitutions in expr
+// are expanded first, then it is evaluated as an integer expression.
+func (e *expander) arith(expr string, quoted bool) error {
+	expr, err := e.sh.expandWord(expr)
+	if err != nil {
+		return err
+	}
+	n, err := e.sh.arithEval(expr)
+	if err != nil {
+		return err
+	}
+	e.b.expanded(strconv.FormatInt(n, 10), quoted)
+	return nil
+}
+
+// unescapeBackquote removes the backslashes that protect \, $ and ` inside
+// `...`, giving the command text to run.
+func unescapeBackquote(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) && strings.IndexByte("\\$`", s[i+1]) >= 0 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// values appends a list of values. With multi each value is its own field
+// ("$@" style); otherwise they are joined with the first character of IFS
+// ("$*" style).
+func (e *expander) values(vals []string, multi, quoted bool) {
+	if !multi {
+		e.b.expanded(strings.Join(vals, e.sh.ifsJoiner()), quoted)
+		return
+	}
+	if quoted {
+		e.multiQuoted = true
+	}
+	for j, v := range vals {
+		if j > 0 {
+			e.b.breakField()
+		}
+		e.b.expanded(v, quoted)
+	}
+}
+
+// paramExp is a parsed ${...} body:
+//
+//	${#name}  ${#name[@]}          length
+//	${!name}                       indirection
+//	${!name[@]}                    array keys
+//	${!prefix*}  ${!prefix@}       names of variables starting with prefix
+//	${name[index]}                 element
+//	${name:-word} ${name-word}     default (also =, ?, + forms)
+//	${name#pat}  ${name##pat}      remove shortest/longest prefix
+//	${name%pat}  ${name%%pat}      remove shortest/longest suffix
+//	${name/pat/rep} ${name//pat/rep}  replace first/all (/#, /% anchor)
+//	${name^pat} ${name^^pat}       uppercase first/all (, and ,, lower)
+//	${name:offset:length}          substring, or slice of name[@]
+type paramExp struct {
+	length   bool
+	indirect bool
+	keys     bool
+	prefix   byte // '*' or '@' for ${!prefix*}; 0 otherwise
+	name     string
+	index    string // raw subscript; "" if none
+	hasIndex bool
+	op       string
+	arg      string // raw text after op
+}
+
+// paramOps lists the operators that may follow a name, longest first.
+var paramOps = []string{
+	":-", ":=", ":?", ":+", "##", "%%", "//", "^^", ",,",
+	"-", "=", "?", "+", "#", "%", "/", "^", ",", ":",
+}
+
+// parseParamExp splits a ${...} body into its parts.
+func parseParamExp(body string) (paramExp, error) {
+	var p paramExp
+	bad := fmt.Errorf("${%s}: bad substitution", body)
+	rest := body
+	// A leading # or ! is an operator unless it is the parameter itself
+	// (${#}, ${!:-none}).
+	if len(rest) > 1 && rest[1] != ':' {
+		switch rest[0] {
+		case '#':
+			p.length = true
+			rest = rest[1:]
+		case '!':
+			p.indirect = true
+			rest = rest[1:]
+		}
+	}
+	i := 0
+	switch {
+	case rest == "":
+	case isDigit(rest[0]):
+		for i < len(rest) && isDigit(rest[i]) {
+			i++
+		}
+	case isSpecialParam(rest[:1]):
+		i = 1
+	default:
+		for i < len(rest) && isNameChar(rest[i]) {
+			i++
+		}
+		if !isValidName(rest[:i]) {
+			return p, bad
+		}
+	}
+	if i == 0 {
+		return p, bad
+	}
+	p.name = rest[:i]
+	rest = rest[i:]
+	if p.indirect && (rest == "*" || rest == "@") {
+		p.indirect, p.prefix = false, rest[0]
+		return p, nil
+	}
+	if rest != "" && rest[0] == '[' && isValidName(p.name) {
+		j := matchingBracket(rest, 0)
+		if j < 1 {
+			return p, bad
+		}
+		p.index, p.hasIndex = rest[1:j], true
+		rest = rest[j+1:]
+	}
+	if p.indirect && (p.index == "@" || p.index == "*") {
+		p.indirect, p.keys = false, true
+	}
+	if rest == "" {
+		return p, nil
+	}
+	if p.length || p.keys {
+		return p, bad
+	}
+	for _, op := range paramOps {
+		if strings.HasPrefix(rest, op) {
+			p.op, p.arg = op, rest[len(op):]
+			return p, nil
+		}
+	}
+	return p, bad
+}
+
+// braced expands the body of a ${...} reference.
+func (e *expander) braced(body string, quoted bool) error {
+	p, err := parseParamExp(body)
+	if err != nil {
+		return err
+	}
+
+	if p.prefix != 0 {
+		var names []string
+		for _, n := range e.sh.vars.Names() {
+			if strings.HasPrefix(n, p.name) {
+				names = append(names, n)
+			}
+		}
+		e.values(names, p.prefix == '@' || !quoted, quoted)
+		return nil
+	}
+
+	name, index, hasIndex := p.name, p.index, p.hasIndex
+	if p.indirect {
+		vals, _, err := e.sh.paramValues(p.name, "", false, false)
+		if err != nil {
+			return err
+		}
+		target := strings.Join(vals, " ")
+		name, index, hasIndex = target, "", false
+		if i := strings.IndexByte(target, '['); i > 0 && strings.HasSuffix(target, "]") {
+			name, index, hasIndex = target[:i], target[i+1:len(target)-1], true
+		}
+		if !isValidName(name) && !isSpecialParam(name) {
+			return fmt.Errorf("%s: invalid indirect expansion", p.name)
+		}
+	}
+	star := index == "*" || name == "*"
+	all := star || index == "@" || name == "@"
+
+	vals, set, err := e.sh.paramValues(name, index, hasIndex, p.keys)
+	if err != nil {
+		return err
+	}
+	if !set && e.sh.options[optNounset] && !all && !p.keys && !strings.ContainsAny(p.op, "-=?+") {
+		if hasIndex {
+			return e.sh.unboundVar(name + "[" + index + "]")
+		}
+		return e.sh.unboundVar(name)
+	}
+	multi := all || p.keys
+	if star && quoted {
+		multi = false
+	}
+
+	if p.length {
+		if all {
+			e.b.expanded(fmt.Sprint(len(vals)), quoted)
+		} else {
+			e.b.expanded(fmt.Sprint(utf8.RuneCountInString(strings.Join(vals, ""))), quoted)
+		}
+		return nil
+	}
+
+	switch p.op {
+	case "-", ":-", "=", ":=", "?", ":?", "+", ":+":
+		empty := !set || (p.op[0] == ':' && strings.Join(vals, "") == "")
+		switch p.op[len(p.op)-1] {
+		case '-':
+			if empty {
+				return e.word(p.arg, quoted, true)
+			}
+		case '=':
+			if empty {
+				if all || p.indirect || isSpecialParam(name) {
+					return fmt.Errorf("$%s: cannot assign in this way", body)
+				}
+				val, err := e.sh.expandNested(p.arg, quoted)
+				if err != nil {
+					return err
+				}
+				if hasIndex {
+					sub, err := e.sh.expandWord(index)
+					if err != nil {
+						return err
+					}
+					err = e.sh.vars.SetIndex(name, sub, val, false)
+				} else {
+					err = e.sh.vars.Set(name, val)
+				}
+				if err != nil {
+					return err
+				}
+				vals, _, _ = e.sh.paramValues(name, index, hasIndex, false)
+			}
+		case '?':
+			if empty {
+				msg, err := e.sh.expandNested(p.arg, quoted)
+				if err != nil {
+					return err
+				}
+				if msg == "" {
+					msg = "parameter null or not set"
+				}
+				return fmt.Errorf("%s: %s", p.name, msg)
+			}
+		case '+':
+			if !empty {
+				return e.word(p.arg, quoted, true)
+			}
+			return nil
+		}
+
+	case "#", "##", "%", "%%":
+		pat, err := e.sh.expandPattern(p.arg)
+		if err != nil {
+			return err
+		}
+		longest := len(p.op) == 2
+		for i, v := range vals {
+			if p.op[0] == '#' {
+				vals[i] = trimPrefixPattern(v, pat, longest)
+			} else {
+				vals[i] = trimSuffixPattern(v, pat, longest)
+			}
+		}
+
+	case "/", "//":
+		patRaw, repRaw, _ := cutUnquoted(p.arg, '/')
+		var anchor byte
+		if p.op == "/" && patRaw != "" && (patRaw[0] == '#' || patRaw[0] == '%') {
+			anchor, patRaw = patRaw[0], patRaw[1:]
+		}
+		pat, err := e.sh.expandPattern(patRaw)
+		if err != nil {
+			return err
+		}
+		rep, err := e.sh.expandNested(repRaw, quoted)
+		if err != nil {
+			return err
+		}
+		for i, v := range vals {
+			vals[i] = replacePattern(v, pat, rep, p.op == "//", anchor)
+		}
+
+	case "^", "^^", ",", ",,":
+		pat, err := e.sh.expandPattern(p.arg)
+		if err != nil {
+			return err
+		}
+		for i, v := range vals {
+			vals[i] = caseModify(v, p.op, pat)
+		}
+
+	case ":":
+		var v *variable
+		switch {
+		case name == "@" || name == "*":
+			// Offsets count from $0: ${@:1} is every positional parameter.
+			v = e.sh.positionalArray()
+			vals = v.values()
+		case all:
+			v = e.sh.vars.Lookup(name)
+		}
+		if vals, err = e.sh.sliceValues(vals, p.arg, v, all); err != nil {
+			return err
+		}
+	}
+
+	e.values(vals, multi, quoted)
+	return nil
+}
+
+// paramValues returns the values a reference selects and whether the
+// parameter counts as set: all elements for [@]/[*], the keys with keys,
+// one element for [index], the scalar value, or a special parameter
+// (params.go).
+func (sh *interp) paramValues(name, index string, hasIndex, keys bool) ([]string, bool, error) {
+	if isSpecialParam(name) {
+		vals, set := sh.specialParam(name)
+		return vals, set, nil
+	}
+	v := sh.vars.Lookup(name)
+	if v == nil {
+		return nil, false, nil
+	}
+	switch {
+	case keys:
+		k := v.keys()
+		return k, len(k) > 0, nil
+	case index == "@" || index == "*":
+		vals := v.values()
+		return vals, len(vals) > 0, nil
+	case hasIndex:
+		sub, err := sh.expandWord(index)
+		if err != nil {
+			return nil, false, err
+		}
+		key, err := v.evalIndex(sh, sub)
+		if err != nil {
+			return nil, false, err
+		}
+		if s, ok := v.element(key); ok {
+			return []string{s}, true, nil
+		}
+		return nil, false, nil
+	}
+	if s, ok := v.scalar(); ok {
+		return []string{s}, true, nil
+	}
+	return nil, false, nil
+}
+
+// expandNested expands the word of an operator such as ${name:=word} to a
+// single string, in the quoting context of the enclosing reference.
+func (sh *interp) expandNested(raw string, quoted bool) (string, error) {
+	e := expander{sh: sh}
+	if err := e.word(raw, quoted, true); err != nil {
+		return "", err
+	}
+	vals, _ := e.b.finish()
+	return strings.Join(vals, " "), nil
+}
+
+// cutUnquoted splits s at the first sep that is not escaped or quoted.
+func cutUnquoted(s string, sep byte) (before, after string, found bool) {
+	var q quoteTracker
+	for i := 0; i < len(s); i++ {
+		switch ch := s[i]; {
+		case ch == '\\' && !q.inSingle:
+			i++
+		case ch == '\'' && !q.inDouble:
+			q.inSingle = !q.inSingle
+		case ch == '"' && !q.inSingle:
+			q.inDouble = !q.inDouble
+		case ch == sep && !q.IsQuoted():
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+// caseModify implements ${name^pat}, ${name^^pat}, ${name,pat} and
+// ${name,,pat}: characters matching pat (any character if pat is empty)
+// are upper- or lowercased, either the first character only or all.
+func caseModify(s, op, pat string) string {
+	if pat == "" {
+		pat = "?"
+	}
+	runes := []rune(s)
+	for i, r := range runes {
+		if i > 0 && len(op) == 1 {
+			break
+		}
+		if !matchPattern(pat, string(r)) {
+			continue
+		}
+		if op[0] == '^' {
+			runes[i] = unicode.ToUpper(r)
+		} else {
+			runes[i] = unicode.ToLower(r)
+		}
+	}
+	return string(runes)
+}
+
+// sliceValues implements ${name:offset:length}. For a whole array (v set
+// when all) the offset selects elements from the first index >= offset;
+// otherwise it selects characters. Negative offsets count from the end; a
+// negative length for a string stops that many characters short of the
+// end.
+func (sh *interp) sliceValues(vals []string, arg string, v *variable, all bool) ([]string, error) {
+	offArg, lenArg, hasLen := strings.Cut(arg, ":")
+	offStr, err := sh.expandWord(offArg)
+	if err != nil {
+		return nil, err
+	}
+	off, err := sh.arithEval(offStr)
+	if err != nil {
+		return nil, err
+	}
+	var length int64 = -1
+	if hasLen {
+		lenStr, err := sh.expandWord(lenArg)
+		if err != nil {
+			return nil, err
+		}
+		if length, err = sh.arithEval(lenStr); err != nil {
+			return nil, err
+		}
+	}
+
+	if all {
+		start := 0
+		if v != nil && v.Attrs&attrArray != 0 {
+			// Offsets are indices in a sparse array, not positions.
+			idx := v.indices()
+			if off < 0 && len(idx) > 0 {
+				off += int64(idx[len(idx)-1]) + 1
+			}
+			for start < len(idx) && int64(idx[start]) < off {
+				start++
+			}
+		} else {
+			if off < 0 {
+				off += int64(len(vals))
+			}
+			start = int(clamp(off, 0, int64(len(vals))))
+		}
+		if start > len(vals) || off < 0 {
+			return nil, nil
+		}
+		vals = vals[start:]
+		if hasLen {
+			if length < 0 {
+				return nil, fmt.Errorf("%s: substring expression < 0", lenArg)
+			}
+			vals = vals[:clamp(length, 0, int64(len(vals)))]
+		}
+		return vals, nil
+	}
+
+	s := []rune(strings.Join(vals, ""))
+	n := int64(len(s))
+	if off < 0 {
+		off += n
+		if off < 0 {
+			return []string{""}, nil
+		}
+	}
+	off = clamp(off, 0, n)
+	end := n
+	if hasLen {
+		if length < 0 {
+			end = n + length
+			if end < off {
+				return nil, fmt.Errorf("%s: substring expression < 0", lenArg)
+			}
+		} else {
+			end = clamp(off+length, off, n)
+		}
+	}
+	return []string{string(s[off:end])}, nil
+}
+
+func clamp(v, lo, hi int64) int64 {
+	return max(lo, min(v, hi))
+}
@@ -0,0 +1,249 @@
+package shell
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestExpandWord(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name    string
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{name: "plain word", raw: "hello", want: "hello"},
+		{name: "simple variable", raw: "$USER", want: "alice"},
+		{name: "braced variable", raw: "${USER}s", want: "alices"},
+		{name: "variable in double quotes", raw: `"hi $USER"`, want: "hi alice"},
+		{name: "variable in single quotes is literal", raw: `'$USER'`, want: "$USER"},
+		{name: "escaped dollar", raw: `\$USER`, want: "$USER"},
+		{name: "escaped dollar in double quotes", raw: `"\$USER"`, want: "$USER"},
+		{name: "unset variable is empty", raw: "a${NOPE}b", want: "ab"},
+		{name: "name stops at non-name char", raw: "$USER.txt", want: "alice.txt"},
+		{name: "lone dollar", raw: "$", want: "$"},
+		{name: "dollar before non-name", raw: "$%", want: "$%"},
+		{name: "unterminated brace", raw: "${USER", wantErr: true},
+		{name: "bad substitution", raw: "${a&b}", wantErr: true},
+	}
+
+	setupTestVars(t, sh, "USER=alice")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := sh.expandWord(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandWord(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("expandWord(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScanWord(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		stops string
+		want  int
+	}{
+		{name: "plain", input: "abc def", want: 3},
+		{name: "quoted space", input: `"a b" c`, want: 5},
+		{name: "braced expansion with space", input: "${a b} c", want: 6},
+		{name: "escaped space", input: `a\ b c`, want: 4},
+		{name: "stop byte", input: "file>x", stops: ">", want: 4},
+		{name: "trailing backslash", input: `ab\`, want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scanWord(tt.input, 0, tt.stops); got != tt.want {
+				t.Errorf("scanWord(%q) = %d, want %d", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandArrays(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh)
+	for _, w := range []string{`a=(x "y z" w)`, "a[6]=six", "s=hello"} {
+		a, _ := parseAssignment(w)
+		if err := sh.performAssignment(a, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{raw: "$a", want: []string{"x"}},
+		{raw: "${a[1]}", want: []string{"y", "z"}},
+		{raw: `"${a[1]}"`, want: []string{"y z"}},
+		{raw: "${a[-1]}", want: []string{"six"}},
+		{raw: `"${a[@]}"`, want: []string{"x", "y z", "w", "six"}},
+		{raw: `"<${a[@]}>"`, want: []string{"<x", "y z", "w", "six>"}},
+		{raw: `"${a[*]}"`, want: []string{"x y z w six"}},
+		{raw: "${#a[@]}", want: []string{"4"}},
+		{raw: "${#a[1]}", want: []string{"3"}},
+		{raw: "${!a[@]}", want: []string{"0", "1", "2", "6"}},
+		{raw: `"${a[@]:1:2}"`, want: []string{"y z", "w"}},
+		{raw: `"${a[@]:3}"`, want: []string{"six"}},
+		{raw: "${s:1:3}", want: []string{"ell"}},
+		{raw: "${s: -3}", want: []string{"llo"}},
+		{raw: "${s:1:-1}", want: []string{"ell"}},
+		{raw: "${#s}", want: []string{"5"}},
+		{raw: `"${none[@]}"`, want: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := sh.expandFields(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expandFields(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandStringOps(t *testing.T) {
+	sh := newTestShell(t)
+	sh.dir = t.TempDir() // an unquoted * result matches no files
+	setupTestVars(t, sh, "p=/usr/local/lib/file.tar.gz", "s=hello world", "U=HELLO", "ref=s", "star=*", "empty=")
+	for _, w := range []string{"a=(alpha beta gamma)"} {
+		a, _ := parseAssignment(w)
+		if err := sh.performAssignment(a, true); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		raw     string
+		want    []string
+		wantErr bool
+	}{
+		{raw: "${p#*/}", want: []string{"usr/local/lib/file.tar.gz"}},
+		{raw: "${p##*/}", want: []string{"file.tar.gz"}},
+		{raw: "${p%.*}", want: []string{"/usr/local/lib/file.tar"}},
+		{raw: "${p%%.*}", want: []string{"/usr/local/lib/file"}},
+		{raw: `${p#"/usr"}`, want: []string{"/local/lib/file.tar.gz"}},
+		{raw: `${p#"*"}`, want: []string{"/usr/local/lib/file.tar.gz"}},
+		{raw: "${star#$star}", want: []string{"*"}},
+		{raw: `${star#"$star"}`, want: nil},
+		{raw: "${p/lib/LIB}", want: []string{"/usr/local/LIB/file.tar.gz"}},
+		{raw: "${p//l/L}", want: []string{"/usr/LocaL/Lib/fiLe.tar.gz"}},
+		{raw: "${p/#\\/usr/X}", want: []string{"X/local/lib/file.tar.gz"}},
+		{raw: "${p/%gz/bz2}", want: []string{"/usr/local/lib/file.tar.bz2"}},
+		{raw: "${p//[aeiou]}", want: []string{"/sr/lcl/lb/fl.tr.gz"}},
+		{raw: `"${s^}"`, want: []string{"Hello world"}},
+		{raw: `"${s^^}"`, want: []string{"HELLO WORLD"}},
+		{raw: `"${s^^[lo]}"`, want: []string{"heLLO wOrLd"}},
+		{raw: "${U,}", want: []string{"hELLO"}},
+		{raw: "${U,,}", want: []string{"hello"}},
+		{raw: "${a[@]#?}", want: []string{"lpha", "eta", "amma"}},
+		{raw: "${a[@]^}", want: []string{"Alpha", "Beta", "Gamma"}},
+		{raw: `"${a[*]/a/A}"`, want: []string{"Alpha betA gAmma"}},
+		{raw: "${!a*}", want: []string{"a"}},
+		{raw: "${!re@}", want: []string{"ref"}},
+		{raw: `"${!ref}"`, want: []string{"hello world"}},
+		{raw: "${none:-def}", want: []string{"def"}},
+		{raw: "${empty:-def}", want: []string{"def"}},
+		{raw: "${empty-def}", want: nil},
+		{raw: `"${none:-a b}"`, want: []string{"a b"}},
+		{raw: "${U:+alt}", want: []string{"alt"}},
+		{raw: "${none:+alt}", want: nil},
+		{raw: "${new:=made}$new", want: []string{"mademade"}},
+		{raw: "${none:?}", wantErr: true},
+		{raw: "${none?gone}", wantErr: true},
+		{raw: "${U?gone}", want: []string{"HELLO"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := sh.expandFields(tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandFields(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if err == nil && !slices.Equal(got, tt.want) {
+				t.Errorf("expandFields(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFieldSplitting(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name string
+		env  []string
+		raw  string
+		want []string
+	}{
+		{name: "default IFS splits blanks and newlines", env: []string{"v= a \t b\nc "}, raw: "$v", want: []string{"a", "b", "c"}},
+		{name: "quoted expansion is not split", env: []string{"v=a  b"}, raw: `"$v"`, want: []string{"a  b"}},
+		{name: "split joins surrounding text", env: []string{"v=a b"}, raw: "<$v>", want: []string{"<a", "b>"}},
+		{name: "leading and trailing blanks separate text", env: []string{"v= a "}, raw: "x${v}y", want: []string{"x", "a", "y"}},
+		{name: "only blanks", env: []string{"v=   "}, raw: "$v", want: nil},
+		{name: "non-whitespace IFS keeps empty fields", env: []string{"IFS=:", "v=a::b"}, raw: "$v", want: []string{"a", "", "b"}},
+		{name: "leading delimiter gives empty field", env: []string{"IFS=:", "v=:a"}, raw: "$v", want: []string{"", "a"}},
+		{name: "trailing delimiter gives no field", env: []string{"IFS=:", "v=a:"}, raw: "$v", want: []string{"a"}},
+		{name: "whitespace around delimiter counts once", env: []string{"IFS=: ", "v=a : b"}, raw: "$v", want: []string{"a", "b"}},
+		{name: "empty IFS disables splitting", env: []string{"IFS=", "v=a b"}, raw: "$v", want: []string{"a b"}},
+		{name: "literal text is never split", env: []string{"IFS=o"}, raw: "foo", want: []string{"foo"}},
+		{name: "default word splits", env: nil, raw: "${u:-a b}", want: []string{"a", "b"}},
+		{name: "quoted default word does not split", env: nil, raw: `"${u:-a b}"`, want: []string{"a b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestVars(t, sh, tt.env...)
+			got, err := sh.expandFields(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expandFields(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("assignments are not split", func(t *testing.T) {
+		setupTestVars(t, sh, "v=a   b")
+		if got, _ := sh.expandWord("$v"); got != "a   b" {
+			t.Errorf("expandWord($v) = %q, want %q", got, "a   b")
+		}
+	})
+}
+
+func TestArithExpansion(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh, "n=4")
+	tests := []struct {
+		raw     string
+		want    string
+		wantErr bool
+	}{
+		{raw: "$((1 + 2))", want: "3"},
+		{raw: "$((n * (n + 1)))", want: "20"},
+		{raw: "$(($n - 1))", want: "3"},
+		{raw: "x$((2**3))y", want: "x8y"},
+		{raw: "$((m = 7))$m", want: "77"},
+		{raw: "$((1 +", wantErr: true},
+		{raw: "$((1 / 0))", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := sh.expandWord(tt.raw)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("expandWord(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			continue
+		}
+		if err == nil && got != tt.want {
+			t.Errorf("expandWord(%q) = %q, want %q", tt.raw, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,36 @@
+package shell
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// The ioctl requests that get and set terminal attributes.
+const (
+	ioctlGetTermios = syscall.TCGETS
+	ioctlSetTermios = syscall.TCSETS
+)
+
+// dupFd makes newfd a copy of oldfd (dup2). Linux ports such as arm64
+// lack the dup2 system call, so use dup3.
+func dupFd(oldfd, newfd int) error {
+	return syscall.Dup3(oldfd, newfd, 0)
+}
+
+// waitReadable waits up to timeout for input on fd and reports whether
+// any arrived.
+func waitReadable(fd int, timeout time.Duration) (bool, error) {
+	var set syscall.FdSet
+	size := int(unsafe.Sizeof(set.Bits[0])) * 8
+	word, shift := fd/size, fd%size
+	tv := syscall.NsecToTimeval(timeout.Nanoseconds())
+	for {
+		set = syscall.FdSet{}
+		set.Bits[word] |= 1 << shift
+		n, err := syscall.Select(fd+1, &set, nil, nil, &tv)
+		if err != syscall.EINTR {
+			return n > 0, err
+		}
+	}
+}
@@ -0,0 +1,39 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package shell
+
+import (
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// The ioctl requests that get and set terminal attributes.
+const (
+	ioctlGetTermios = syscall.TIOCGETA
+	ioctlSetTermios = syscall.TIOCSETA
+)
+
+// dupFd makes newfd a copy of oldfd.
+func dupFd(oldfd, newfd int) error {
+	return syscall.Dup2(oldfd, newfd)
+}
+
+// waitReadable waits up to timeout for input on fd and reports whether
+// any arrived. FdSet differs between the BSDs, so use poll(2) directly.
+func waitReadable(fd int, timeout time.Duration) (bool, error) {
+	const pollIn = 0x1
+	pfd := struct {
+		fd              int32
+		events, revents int16
+	}{fd: int32(fd), events: pollIn}
+	for {
+		n, _, e := syscall.Syscall(syscall.SYS_POLL, uintptr(unsafe.Pointer(&pfd)), 1, uintptr(timeout.Milliseconds()))
+		if e != syscall.EINTR {
+			if e != 0 {
+				return false, e
+			}
+			return n > 0, nil
+		}
+	}
+}
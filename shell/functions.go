@@ -0,0 +1,112 @@
+// functions.go — shell functions.
+//
+// A definition (name() { ...; } or function name { ...; }) stores its
+// parsed body in functions when it runs. Calling a function runs the body
+// in the current shell with its own positional parameters and a new scope
+// for variables declared with local (see varTable.pushScope):
+//
+//	internalCommand(name)   functions first, then builtins
+//	  -> callFunction       set $1..., push scope, run body, consume return,
+//	                        run the function's RETURN trap
+//
+// Calls nest at most maxFuncNest deep, or FUNCNEST deep if that is a
+// smaller positive number; a call beyond the limit fails with status 1
+// rather than letting runaway recursion overflow the stack. Sourced files
+// count towards the limit too.
+
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// maxFuncNest is the deepest functions and sourced files can nest.
+const maxFuncNest = 1000
+
+// internalCommand looks up a command that runs inside the shell: a
+// function, or else a builtin. Functions take precedence, so they can
+// wrap builtins of the same name.
+func (sh *interp) internalCommand(name string) (command, bool) {
+	if fn, ok := sh.functions[name]; ok {
+		return command{Run: func(sh *interp, args []string) int { return sh.callFunction(fn, args) }}, true
+	}
+	return sh.getCommand(name)
+}
+
+// callFunction runs fn with args as its positional parameters and returns
+// its status.
+func (sh *interp) callFunction(fn *funcNode, args []string) int {
+	if !sh.checkFuncNest(fn.name) {
+		return 1
+	}
+	savedArgs, savedLoops := sh.params.positional, sh.flow.loops
+	sh.params.positional = args
+	sh.flow.loops = 0
+	sh.flow.funcs++
+	sh.vars.pushScope()
+	finishReturn := sh.hideReturnTrap()
+	defer func() {
+		sh.vars.popScope()
+		sh.flow.funcs--
+		sh.flow.loops = savedLoops
+		sh.params.positional = savedArgs
+	}()
+
+	status := sh.runCmdNode(fn.body)
+	if sh.flow.kind == flowReturn {
+		sh.flow.kind = flowNone
+	}
+	sh.params.status = status
+	if finishReturn(); sh.flow.kind == flowExit {
+		return sh.params.status
+	}
+	return status
+}
+
+// checkFuncNest reports whether another function or sourced file can
+// start, and if not reports the error for name.
+func (sh *interp) checkFuncNest(name string) bool {
+	limit := maxFuncNest
+	if n, err := strconv.Atoi(sh.getVar("FUNCNEST")); err == nil && n > 0 && n < limit {
+		limit = n
+	}
+	if sh.flow.funcs < limit {
+		return true
+	}
+	fmt.Fprintf(sh.stderr(), "%s: maximum function nesting level exceeded (%d)\n", name, limit)
+	return false
+}
+
+// functionNames returns the names of all defined functions, sorted.
+func (sh *interp) functionNames() []string {
+	names := make([]string, 0, len(sh.functions))
+	for name := range sh.functions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// printFunctions implements declare -f and -F: print the definitions (or
+// just the names) of the named functions, or of all of them.
+func (sh *interp) printFunctions(builtin string, names []string, namesOnly bool) int {
+	if len(names) == 0 {
+		names = sh.functionNames()
+	}
+	status := 0
+	for _, name := range names {
+		fn, ok := sh.functions[name]
+		switch {
+		case !ok:
+			fmt.Fprintf(sh.stderr(), "%s: %s: not found\n", builtin, name)
+			status = 1
+		case namesOnly:
+			fmt.Fprintf(sh.stdout(), "declare -f %s\n", name)
+		default:
+			fmt.Fprintln(sh.stdout(), fn.src)
+		}
+	}
+	return status
+}
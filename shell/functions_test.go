@@ -0,0 +1,77 @@
+package shell
+
+import "testing"
+
+func TestPrintFunctions(t *testing.T) {
+	sh := newTestShell(t)
+	runTestScript(t, sh, "b() { echo b; }\nfunction a {\n  echo a\n}")
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "declare -f", args: []string{"-f"}, want: "function a {\n  echo a\n}\nb() { echo b; }\n"},
+		{name: "declare -f name", args: []string{"-f", "b"}, want: "b() { echo b; }\n"},
+		{name: "declare -F", args: []string{"-F"}, want: "declare -f a\ndeclare -f b\n"},
+		{name: "missing function", args: []string{"-F", "c"}, wantErr: "declare: c: not found\n", status: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var status int
+			var got string
+			gotErr := captureStderr(t, sh, func() {
+				got = captureStdout(t, sh, func() { status = sh.builtinDeclare("declare", tt.args) })
+			})
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("declare %q = %q, stderr %q, status %d; want %q, %q, %d", tt.args, got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+
+	t.Run("type", func(t *testing.T) {
+		got := captureStdout(t, sh, func() { sh.registry["type"].Run(sh, []string{"b"}) })
+		if want := "b is a function\nb() { echo b; }\n"; got != want {
+			t.Errorf("type b = %q, want %q", got, want)
+		}
+	})
+}
+
+func TestFuncNest(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+	}{
+		{
+			name:    "runaway recursion",
+			src:     "f() { f; }; f; echo $?",
+			want:    "1\n",
+			wantErr: "f: maximum function nesting level exceeded (1000)\n",
+		},
+		{
+			name:    "FUNCNEST",
+			src:     "FUNCNEST=3; f() { echo $1; f $(($1 + 1)); }; f 1",
+			want:    "1\n2\n3\n",
+			wantErr: "f: maximum function nesting level exceeded (3)\n",
+		},
+		{
+			name:    "FUNCNEST cannot raise the limit",
+			src:     "FUNCNEST=5000; f() { (($1 <= 1000)) && f $(($1 + 1)); }; f 1; echo $?",
+			want:    "1\n",
+			wantErr: "f: maximum function nesting level exceeded (1000)\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			gotErr := captureStderr(t, sh, func() { got, _ = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr {
+				t.Errorf("got %q, stderr %q; want %q, %q", got, gotErr, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,213 @@
+// getopt.go — POSIX short-option parsing, for builtins and for scripts.
+//
+//	getopts OPTSTRING NAME [ARG...]
+//
+// OPTSTRING lists the option letters; a letter followed by ':' takes an
+// argument, either the rest of its word (-ofile) or the next word (-o
+// file). Letters may be clustered (-rs), "--" ends the options, and so
+// does the first word that does not start with '-' or is "-" alone.
+//
+// Builtins call parseOptions, which reports an unknown letter or a
+// missing argument as
+//
+//	NAME: -x: invalid option
+//	NAME: usage: <synopsis from help.go>
+//
+// and the caller returns status 2. declare and its relatives, which also
+// take +x to turn an attribute off, call parseSignedOptions instead.
+// getopts does one step per call, over
+// the positional parameters or ARGs, keeping its place in OPTIND (the
+// index of the next word, from 1) and, within a cluster, in getoptsPos.
+// Each call sets NAME to the option letter and OPTARG to its argument; at
+// the end it sets NAME to '?' and returns 1. An unknown letter sets NAME
+// to '?', a missing argument too, and both print an error unless
+// OPTSTRING starts with ':' (silent mode: NAME is '?' or ':' and OPTARG
+// the letter) or OPTERR is 0.
+
+package shell
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// optError is an unknown option letter, or one whose argument is missing.
+type optError struct {
+	sign    byte // '-', or '+' for +x
+	c       byte
+	missing bool
+}
+
+func (e *optError) Error() string {
+	if e.missing {
+		return fmt.Sprintf("%c%c: option requires an argument", e.sign, e.c)
+	}
+	return fmt.Sprintf("%c%c: invalid option", e.sign, e.c)
+}
+
+// optParser steps through the options at the start of args.
+type optParser struct {
+	optstring string
+	plus      bool // words starting with '+' hold options too
+	args      []string
+	ind       int  // index in args of the word being parsed
+	pos       int  // index in args[ind] of the next letter; 0 between words
+	sign      byte // '-' or '+', which started the last option's word
+}
+
+// next returns the next option letter and its argument. done reports that
+// the options have run out, and ind is then the index of the first
+// operand. An unknown letter, or one missing its argument, comes with an
+// *optError.
+func (p *optParser) next() (c byte, arg string, done bool, err error) {
+	if p.pos == 0 {
+		if p.ind >= len(p.args) {
+			return 0, "", true, nil
+		}
+		word := p.args[p.ind]
+		if word == "--" {
+			p.ind++
+			return 0, "", true, nil
+		}
+		if len(word) < 2 || word[0] != '-' && !(p.plus && word[0] == '+') {
+			return 0, "", true, nil
+		}
+		p.pos = 1
+	}
+
+	word := p.args[p.ind]
+	p.sign, c = word[0], word[p.pos]
+	p.pos++
+	i := strings.IndexByte(p.optstring, c)
+	takesArg := i >= 0 && i+1 < len(p.optstring) && p.optstring[i+1] == ':'
+	switch {
+	case i < 0 || c == ':':
+		err = &optError{sign: p.sign, c: c}
+	case takesArg && p.pos < len(word):
+		arg = word[p.pos:]
+		p.pos = len(word)
+	case takesArg && p.ind+1 < len(p.args):
+		p.ind++
+		arg = p.args[p.ind]
+		p.pos = len(arg)
+	case takesArg:
+		err = &optError{sign: p.sign, c: c, missing: true}
+	}
+	if p.pos >= len(p.args[p.ind]) {
+		p.ind++
+		p.pos = 0
+	}
+	return c, arg, false, err
+}
+
+// option is a parsed option letter and its argument, if it takes one.
+type option struct {
+	c    byte
+	arg  string
+	plus bool // given as +c (parseSignedOptions)
+}
+
+// parseOptions parses the options of the builtin name in args according
+// to optstring and returns them in order, with the operands that follow.
+// On an error it prints the message and the builtin's usage line, and ok
+// is false: the builtin should return status 2.
+func (sh *interp) parseOptions(name, optstring string, args []string) (opts []option, operands []string, ok bool) {
+	return sh.runOptParser(name, &optParser{optstring: optstring, args: args})
+}
+
+// parseSignedOptions is parseOptions for builtins that also take options
+// as +c, such as declare +x; those come back with plus set.
+func (sh *interp) parseSignedOptions(name, optstring string, args []string) (opts []option, operands []string, ok bool) {
+	return sh.runOptParser(name, &optParser{optstring: optstring, plus: true, args: args})
+}
+
+// runOptParser collects the options p finds, for parseOptions and
+// parseSignedOptions.
+func (sh *interp) runOptParser(name string, p *optParser) (opts []option, operands []string, ok bool) {
+	for {
+		c, arg, done, err := p.next()
+		if done {
+			return opts, p.args[p.ind:], true
+		}
+		if err != nil {
+			fmt.Fprintf(sh.stderr(), "%s: %v\n", name, err)
+			sh.printUsage(name)
+			return nil, nil, false
+		}
+		opts = append(opts, option{c: c, arg: arg, plus: p.sign == '+'})
+	}
+}
+
+// printUsage prints the usage line of the builtin name, from its synopsis
+// in builtinDocs.
+func (sh *interp) printUsage(name string) {
+	fmt.Fprintf(sh.stderr(), "%s: usage: %s\n", name, builtinDocs[name].Synopsis)
+}
+
+// builtinGetopts implements getopts.
+func (sh *interp) builtinGetopts(args []string) int {
+	if len(args) < 2 {
+		sh.printUsage("getopts")
+		return 2
+	}
+	optstring, name := args[0], args[1]
+	if !isValidName(name) {
+		fmt.Fprintf(sh.stderr(), "getopts: `%s': not a valid identifier\n", name)
+		return 1
+	}
+	words := sh.params.positional
+	if len(args) > 2 {
+		words = args[2:]
+	}
+	silent := strings.HasPrefix(optstring, ":")
+
+	optind := sh.getVar("OPTIND")
+	ind, err := strconv.Atoi(optind)
+	if err != nil || ind < 1 {
+		ind = 1
+	}
+	p := optParser{optstring: strings.TrimPrefix(optstring, ":"), args: words, ind: ind - 1}
+	if optind == sh.getoptsLast && p.ind < len(words) && sh.getoptsPos < len(words[p.ind]) {
+		p.pos = sh.getoptsPos
+	}
+
+	c, arg, done, perr := p.next()
+	sh.getoptsLast = strconv.Itoa(p.ind + 1)
+	if err := sh.vars.Set("OPTIND", sh.getoptsLast); err != nil {
+		fmt.Fprintf(sh.stderr(), "getopts: %v\n", err)
+		return 1
+	}
+	sh.getoptsPos = p.pos
+	sh.vars.Unset("OPTARG")
+	var result string
+	switch e, _ := perr.(*optError); {
+	case done:
+		result = "?"
+	case e == nil:
+		result = string(c)
+		if arg != "" || strings.Contains(p.optstring, string(c)+":") {
+			sh.vars.Set("OPTARG", arg)
+		}
+	case silent:
+		result = "?"
+		if e.missing {
+			result = ":"
+		}
+		sh.vars.Set("OPTARG", string(c))
+	default:
+		result = "?"
+		if sh.getVar("OPTERR") != "0" {
+			if e.missing {
+				fmt.Fprintf(sh.stderr(), "%s: option requires an argument -- %c\n", sh.params.argv0, c)
+			} else {
+				fmt.Fprintf(sh.stderr(), "%s: illegal option -- %c\n", sh.params.argv0, c)
+			}
+		}
+	}
+	if err := sh.vars.Set(name, result); err != nil {
+		fmt.Fprintf(sh.stderr(), "getopts: %v\n", err)
+		return 1
+	}
+	return boolStatus(!done)
+}
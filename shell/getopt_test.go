@@ -0,0 +1,151 @@
+package shell
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParseOptions(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name      string
+		optstring string
+		args      []string
+		want      []option
+		operands  []string
+		wantErr   string
+	}{
+		{name: "no options", optstring: "ab", args: []string{"x", "-a"}, operands: []string{"x", "-a"}},
+		{name: "separate", optstring: "ab", args: []string{"-a", "-b", "x"}, want: []option{{c: 'a'}, {c: 'b'}}, operands: []string{"x"}},
+		{name: "clustered", optstring: "ab", args: []string{"-ba"}, want: []option{{c: 'b'}, {c: 'a'}}, operands: []string{}},
+		{name: "argument in word", optstring: "ao:", args: []string{"-aofile", "x"}, want: []option{{c: 'a'}, {c: 'o', arg: "file"}}, operands: []string{"x"}},
+		{name: "argument in next word", optstring: "o:", args: []string{"-o", "-a", "x"}, want: []option{{c: 'o', arg: "-a"}}, operands: []string{"x"}},
+		{name: "empty argument", optstring: "o:", args: []string{"-o", ""}, want: []option{{c: 'o'}}, operands: []string{}},
+		{name: "double dash", optstring: "a", args: []string{"-a", "--", "-a"}, want: []option{{c: 'a'}}, operands: []string{"-a"}},
+		{name: "lone dash is an operand", optstring: "a", args: []string{"-", "-a"}, operands: []string{"-", "-a"}},
+		{name: "invalid option", optstring: "a", args: []string{"-ax"}, wantErr: "type: -x: invalid option\ntype: usage: type [-aptP] name [name ...]\n"},
+		{name: "colon is not an option", optstring: "o:", args: []string{"-:"}, wantErr: "type: -:: invalid option\ntype: usage: type [-aptP] name [name ...]\n"},
+		{name: "missing argument", optstring: "o:", args: []string{"-o"}, wantErr: "type: -o: option requires an argument\ntype: usage: type [-aptP] name [name ...]\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var opts []option
+			var operands []string
+			var ok bool
+			gotErr := captureStderr(t, sh, func() { opts, operands, ok = sh.parseOptions("type", tt.optstring, tt.args) })
+			if gotErr != tt.wantErr || ok != (tt.wantErr == "") {
+				t.Fatalf("stderr %q, ok %v; want %q", gotErr, ok, tt.wantErr)
+			}
+			if ok && (!slices.Equal(opts, tt.want) || !slices.Equal(operands, tt.operands)) {
+				t.Errorf("got %v %q, want %v %q", opts, operands, tt.want, tt.operands)
+			}
+		})
+	}
+}
+
+func TestParseSignedOptions(t *testing.T) {
+	sh := newTestShell(t)
+	var opts []option
+	var operands []string
+	var ok bool
+	gotErr := captureStderr(t, sh, func() { opts, operands, ok = sh.parseSignedOptions("declare", "ix", []string{"-i", "+xi", "+", "-x"}) })
+	want := []option{{c: 'i'}, {c: 'x', plus: true}, {c: 'i', plus: true}}
+	if !ok || gotErr != "" || !slices.Equal(opts, want) || !slices.Equal(operands, []string{"+", "-x"}) {
+		t.Errorf("got %v %q, ok %v, stderr %q; want %v [+ -x]", opts, operands, ok, gotErr, want)
+	}
+
+	gotErr = captureStderr(t, sh, func() { _, _, ok = sh.parseSignedOptions("declare", "ix", []string{"+q"}) })
+	if ok || gotErr != "declare: +q: invalid option\ndeclare: usage: declare [-aAfFgilnprux] [name[=value] ...]\n" {
+		t.Errorf("+q: ok %v, stderr %q", ok, gotErr)
+	}
+
+	if _, operands, _ = sh.parseOptions("type", "a", []string{"+a"}); !slices.Equal(operands, []string{"+a"}) {
+		t.Errorf("parseOptions took +a as an option: operands %q", operands)
+	}
+}
+
+func TestGetopts(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{
+			name: "positional parameters",
+			src:  "set -- -a -b x y\n" + `while getopts ab: o; do echo "$o ${OPTARG-unset} $OPTIND"; done; shift $((OPTIND-1)); echo "$*"`,
+			want: "a unset 2\nb x 4\ny\n",
+		},
+		{
+			name: "clustered with argument",
+			src:  "set -- -abxyz -a\n" + `while getopts ab: o; do echo "$o ${OPTARG-unset} $OPTIND"; done`,
+			want: "a unset 1\nb xyz 2\na unset 3\n",
+		},
+		{
+			name: "explicit args and double dash",
+			src:  `while getopts a o -a -- -a; do echo "$o $OPTIND"; done; echo "end $o $OPTIND"`,
+			want: "a 2\nend ? 3\n",
+		},
+		{
+			name:    "invalid option",
+			src:     `getopts a o -x; echo "$o ${OPTARG-unset} $?"`,
+			want:    "? unset 0\n",
+			wantErr: "gosh: illegal option -- x\n",
+		},
+		{
+			name:    "missing argument",
+			src:     `getopts a: o -a; echo "$o ${OPTARG-unset} $?"`,
+			want:    "? unset 0\n",
+			wantErr: "gosh: option requires an argument -- a\n",
+		},
+		{
+			name: "OPTERR=0 is quiet",
+			src:  `OPTERR=0; getopts a o -x; echo "$o"`,
+			want: "?\n",
+		},
+		{
+			name: "silent invalid option",
+			src:  `getopts :a o -x; echo "$o $OPTARG"`,
+			want: "? x\n",
+		},
+		{
+			name: "silent missing argument",
+			src:  `getopts :a: o -a; echo "$o $OPTARG"`,
+			want: ": a\n",
+		},
+		{
+			name: "resetting OPTIND restarts a cluster",
+			src:  `getopts ab o -ab; echo $o; OPTIND=1; getopts ab o -ab; echo $o`,
+			want: "a\na\n",
+		},
+		{
+			name: "loop over no options",
+			src:  "set -- x\n" + `while getopts a o; do echo "$o"; done; echo "end $o $OPTIND"`,
+			want: "end ? 1\n",
+		},
+		{
+			name:    "bad name",
+			src:     "getopts a 1x -a",
+			wantErr: "getopts: `1x': not a valid identifier\n",
+			status:  1,
+		},
+		{
+			name:    "usage",
+			src:     "getopts a",
+			wantErr: "getopts: usage: getopts optstring name [arg ...]\n",
+			status:  2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
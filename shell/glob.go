@@ -0,0 +1,416 @@
+// glob.go — shell pattern matching (*, ?, [...]) and pathname expansion.
+//
+// matchPattern is the single matcher behind every pattern feature:
+// ${var#pat}, ${var/pat/rep}, case modification, pathname expansion and,
+// later, [[ == ]]. Patterns are matched against whole strings, rune by
+// rune:
+//
+//	a*b      '*' matches any run of characters (including none)
+//	a?b      '?' matches any single character
+//	[abc]    one of the listed characters; ranges (a-z), classes
+//	         ([:alpha:]) and negation ([!...] or [^...]) are supported
+//	\c       the literal character c
+//
+// Quoted text in a word reaches the matcher with its special characters
+// backslash-escaped (see escapeGlob), which is how "*" stays literal.
+//
+// Pathname expansion (expandPathnames) matches a field's pattern one
+// directory level at a time; set -f turns it off and the dotglob,
+// nullglob and failglob shopt options adjust it (options.go).
+
+package shell
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// matchPattern reports whether s matches pat in its entirety.
+func matchPattern(pat, s string) bool {
+	// Iterative matcher with single-star backtracking: on mismatch, resume
+	// after the most recent '*' with one more character consumed.
+	px, sx := 0, 0
+	starP, starS := -1, -1
+	for sx < len(s) || px < len(pat) {
+		if px < len(pat) {
+			switch pat[px] {
+			case '*':
+				starP, starS = px, sx
+				px++
+				continue
+			case '?':
+				if sx < len(s) {
+					_, w := utf8.DecodeRuneInString(s[sx:])
+					px++
+					sx += w
+					continue
+				}
+			case '[':
+				if sx < len(s) {
+					r, w := utf8.DecodeRuneInString(s[sx:])
+					if ok, end := matchBracket(pat, px, r); end > 0 {
+						if ok {
+							px = end
+							sx += w
+							continue
+						}
+						break
+					}
+				}
+				// An unterminated '[' is an ordinary character.
+				if sx < len(s) && s[sx] == '[' {
+					px++
+					sx++
+					continue
+				}
+			default:
+				pc, pw := patternRune(pat, px)
+				if sx < len(s) {
+					r, w := utf8.DecodeRuneInString(s[sx:])
+					if r == pc {
+						px += pw
+						sx += w
+						continue
+					}
+				}
+			}
+		}
+		if starP >= 0 && starS < len(s) {
+			_, w := utf8.DecodeRuneInString(s[starS:])
+			starS += w
+			px, sx = starP+1, starS
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// patternRune returns the literal rune at pat[i], resolving a backslash
+// escape, and the number of bytes it occupies.
+func patternRune(pat string, i int) (rune, int) {
+	if pat[i] == '\\' && i+1 < len(pat) {
+		r, w := utf8.DecodeRuneInString(pat[i+1:])
+		return r, w + 1
+	}
+	return utf8.DecodeRuneInString(pat[i:])
+}
+
+// matchBracket matches r against the bracket expression starting at
+// pat[i] ('['). It returns whether r matched and the index just past the
+// closing ']', or end 0 if the expression is unterminated.
+func matchBracket(pat string, i int, r rune) (matched bool, end int) {
+	i++
+	negate := false
+	if i < len(pat) && (pat[i] == '!' || pat[i] == '^') {
+		negate = true
+		i++
+	}
+	first := true
+	for i < len(pat) {
+		if pat[i] == ']' && !first {
+			return matched != negate, i + 1
+		}
+		first = false
+
+		if strings.HasPrefix(pat[i:], "[:") {
+			if j := strings.Index(pat[i+2:], ":]"); j >= 0 {
+				if matchClass(pat[i+2:i+2+j], r) {
+					matched = true
+				}
+				i += j + 4
+				continue
+			}
+		}
+
+		lo, w := patternRune(pat, i)
+		i += w
+		hi := lo
+		if i+1 < len(pat) && pat[i] == '-' && pat[i+1] != ']' {
+			hi, w = patternRune(pat, i+1)
+			i += w + 1
+		}
+		if lo <= r && r <= hi {
+			matched = true
+		}
+	}
+	return false, 0
+}
+
+// matchClass reports whether r belongs to the named POSIX character class.
+func matchClass(class string, r rune) bool {
+	switch class {
+	case "alnum":
+		return unicode.IsLetter(r) || unicode.IsDigit(r)
+	case "alpha":
+		return unicode.IsLetter(r)
+	case "blank":
+		return r == ' ' || r == '\t'
+	case "cntrl":
+		return unicode.IsControl(r)
+	case "digit":
+		return r >= '0' && r <= '9'
+	case "graph":
+		return unicode.IsGraphic(r) && !unicode.IsSpace(r)
+	case "lower":
+		return unicode.IsLower(r)
+	case "print":
+		return unicode.IsPrint(r)
+	case "punct":
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	case "space":
+		return unicode.IsSpace(r)
+	case "upper":
+		return unicode.IsUpper(r)
+	case "xdigit":
+		return strings.ContainsRune("0123456789abcdefABCDEF", r)
+	}
+	return false
+}
+
+// hasGlobMeta reports whether pat contains an unescaped *, ? or [.
+func hasGlobMeta(pat string) bool {
+	for i := 0; i < len(pat); i++ {
+		switch pat[i] {
+		case '\\':
+			i++
+		case '*', '?', '[':
+			return true
+		}
+	}
+	return false
+}
+
+// escapeGlob backslash-escapes the characters that are special in
+// patterns, so s matches only itself.
+func escapeGlob(s string) string {
+	if !strings.ContainsAny(s, `*?[]\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if strings.IndexByte(`*?[]\`, s[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// runeOffsets returns the byte offset of every rune boundary in s,
+// including len(s), so prefix/suffix searches step by whole characters.
+func runeOffsets(s string) []int {
+	offs := make([]int, 0, len(s)+1)
+	for i := range s {
+		offs = append(offs, i)
+	}
+	return append(offs, len(s))
+}
+
+// trimPrefixPattern removes the shortest (or longest) prefix of s matching
+// pat: ${var#pat} and ${var##pat}.
+func trimPrefixPattern(s, pat string, longest bool) string {
+	offs := runeOffsets(s)
+	if longest {
+		for i := len(offs) - 1; i >= 0; i-- {
+			if matchPattern(pat, s[:offs[i]]) {
+				return s[offs[i]:]
+			}
+		}
+		return s
+	}
+	for _, o := range offs {
+		if matchPattern(pat, s[:o]) {
+			return s[o:]
+		}
+	}
+	return s
+}
+
+// trimSuffixPattern removes the shortest (or longest) suffix of s matching
+// pat: ${var%pat} and ${var%%pat}.
+func trimSuffixPattern(s, pat string, longest bool) string {
+	offs := runeOffsets(s)
+	if longest {
+		for _, o := range offs {
+			if matchPattern(pat, s[o:]) {
+				return s[:o]
+			}
+		}
+		return s
+	}
+	for i := len(offs) - 1; i >= 0; i-- {
+		if matchPattern(pat, s[offs[i]:]) {
+			return s[:offs[i]]
+		}
+	}
+	return s
+}
+
+// replacePattern implements ${var/pat/rep}: the longest match of pat at the
+// leftmost position is replaced by rep. With all, every non-overlapping
+// match is replaced. anchor '#' or '%' restricts the match to the start or
+// end of s.
+func replacePattern(s, pat, rep string, all bool, anchor byte) string {
+	if pat == "" {
+		return s
+	}
+	offs := runeOffsets(s)
+	switch anchor {
+	case '#':
+		for i := len(offs) - 1; i >= 0; i-- {
+			if matchPattern(pat, s[:offs[i]]) {
+				return rep + s[offs[i]:]
+			}
+		}
+		return s
+	case '%':
+		for _, o := range offs {
+			if matchPattern(pat, s[o:]) {
+				return s[:o] + rep
+			}
+		}
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(offs); i++ {
+		start := offs[i]
+		end := -1
+		for j := len(offs) - 1; j > i; j-- {
+			if matchPattern(pat, s[start:offs[j]]) {
+				end = j
+				break
+			}
+		}
+		if end < 0 {
+			if start < len(s) {
+				b.WriteString(s[start:offs[i+1]])
+			}
+			continue
+		}
+		b.WriteString(rep)
+		if !all {
+			b.WriteString(s[offs[end]:])
+			return b.String()
+		}
+		i = end - 1
+	}
+	return b.String()
+}
+
+// expandPathnames performs pathname expansion on the fields vals, whose
+// pattern forms are pats: each field with an unquoted *, ? or [ is
+// replaced by the pathnames it matches. A pattern that matches nothing
+// stays as it is, unless nullglob removes it or failglob makes it an
+// error.
+func (sh *interp) expandPathnames(vals, pats []string) ([]string, error) {
+	var out []string
+	for i, v := range vals {
+		if !hasGlobMeta(pats[i]) {
+			out = append(out, v)
+			continue
+		}
+		matches := sh.globPaths(pats[i])
+		switch {
+		case len(matches) > 0:
+			out = append(out, matches...)
+		case sh.options[optFailglob]:
+			return nil, fmt.Errorf("no match: %s", v)
+		case !sh.options[optNullglob]:
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+// globPaths returns the sorted pathnames matching pat, or nil. Each
+// '/'-separated component is matched against the entries of one
+// directory; a component without special characters must simply exist.
+// A leading '.' in a name must be matched literally unless dotglob is on.
+func (sh *interp) globPaths(pat string) []string {
+	paths := []string{""}
+	if strings.HasPrefix(pat, "/") {
+		paths = []string{"/"}
+		pat = strings.TrimLeft(pat, "/")
+	}
+	for _, comp := range strings.Split(pat, "/") {
+		var next []string
+		for _, dir := range paths {
+			switch {
+			case comp == "":
+				// A trailing (or doubled) slash matches directories only.
+				if fi, err := os.Stat(sh.path(dir)); err == nil && fi.IsDir() {
+					next = append(next, joinPath(dir, ""))
+				}
+			case !hasGlobMeta(comp):
+				p := joinPath(dir, unescapeGlob(comp))
+				if _, err := os.Lstat(sh.path(p)); err == nil {
+					next = append(next, p)
+				}
+			default:
+				next = append(next, sh.globDir(dir, comp)...)
+			}
+		}
+		if paths = next; len(paths) == 0 {
+			return nil
+		}
+	}
+	slices.Sort(paths)
+	return paths
+}
+
+// globDir returns the entries of dir ("" for the current directory)
+// whose names match the pattern comp, joined to dir.
+func (sh *interp) globDir(dir, comp string) []string {
+	d := dir
+	if d == "" {
+		d = "."
+	}
+	entries, err := os.ReadDir(sh.path(d))
+	if err != nil {
+		return nil
+	}
+	explicitDot := strings.HasPrefix(comp, ".") || strings.HasPrefix(comp, `\.`)
+	var out []string
+	for _, e := range entries {
+		name := e.Name()
+		if name[0] == '.' && !explicitDot && !sh.options[optDotglob] {
+			continue
+		}
+		if matchPattern(comp, name) {
+			out = append(out, joinPath(dir, name))
+		}
+	}
+	return out
+}
+
+// joinPath appends name to the pathname dir built so far.
+func joinPath(dir, name string) string {
+	if dir == "" {
+		return name
+	}
+	if strings.HasSuffix(dir, "/") {
+		return dir + name
+	}
+	return dir + "/" + name
+}
+
+// unescapeGlob removes the backslashes escapeGlob adds.
+func unescapeGlob(pat string) string {
+	if !strings.Contains(pat, `\`) {
+		return pat
+	}
+	var b strings.Builder
+	for i := 0; i < len(pat); i++ {
+		if pat[i] == '\\' && i+1 < len(pat) {
+			i++
+		}
+		b.WriteByte(pat[i])
+	}
+	return b.String()
+}
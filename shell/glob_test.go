@@ -0,0 +1,141 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pat, s string
+		want   bool
+	}{
+		{"", "", true},
+		{"abc", "abc", true},
+		{"abc", "abd", false},
+		{"*", "", true},
+		{"*", "anything", true},
+		{"a*c", "abbbc", true},
+		{"a*c", "abbbd", false},
+		{"*.go", "main.go", true},
+		{"*.go", "main.go.bak", false},
+		{"?", "é", true},
+		{"??", "a", false},
+		{"[abc]x", "bx", true},
+		{"[a-c]", "d", false},
+		{"[!a-c]", "d", true},
+		{"[^a-c]", "a", false},
+		{"[]]", "]", true},
+		{"[[:digit:]]*", "9lives", true},
+		{"[[:upper:]]", "q", false},
+		{`\*`, "*", true},
+		{`\*`, "x", false},
+		{"[abc", "[abc", true},
+		{"*a*b*c*", "xxaxxbxxcxx", true},
+	}
+	for _, tt := range tests {
+		if got := matchPattern(tt.pat, tt.s); got != tt.want {
+			t.Errorf("matchPattern(%q, %q) = %v, want %v", tt.pat, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestEscapeGlob(t *testing.T) {
+	for _, s := range []string{"plain", "*", "a?b", "[x]", `back\slash`} {
+		pat := escapeGlob(s)
+		if !matchPattern(pat, s) {
+			t.Errorf("escapeGlob(%q) = %q does not match itself", s, pat)
+		}
+		if hasGlobMeta(pat) {
+			t.Errorf("hasGlobMeta(escapeGlob(%q)) = true", s)
+		}
+	}
+}
+
+func TestTrimAndReplacePattern(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"shortest prefix", trimPrefixPattern("a/b/c", "*/", false), "b/c"},
+		{"longest prefix", trimPrefixPattern("a/b/c", "*/", true), "c"},
+		{"no prefix match", trimPrefixPattern("abc", "x*", true), "abc"},
+		{"shortest suffix", trimSuffixPattern("a.b.c", ".*", false), "a.b"},
+		{"longest suffix", trimSuffixPattern("a.b.c", ".*", true), "a"},
+		{"replace first", replacePattern("aXbXc", "X", "-", false, 0), "a-bXc"},
+		{"replace all", replacePattern("aXbXc", "X", "-", true, 0), "a-b-c"},
+		{"replace longest match", replacePattern("abbbc", "b*", "-", false, 0), "a-"},
+		{"replace anchored start", replacePattern("abab", "ab", "-", false, '#'), "-ab"},
+		{"replace anchored end", replacePattern("abab", "ab", "-", false, '%'), "ab-"},
+		{"anchored no match", replacePattern("abab", "b", "-", false, '#'), "abab"},
+		{"empty pattern", replacePattern("abc", "", "-", true, 0), "abc"},
+		{"multibyte", replacePattern("héllo", "é", "e", false, 0), "hello"},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestPathnameExpansion(t *testing.T) {
+	sh := newTestShell(t)
+	dir := t.TempDir()
+	for _, f := range []string{"a.go", "b.go", "c.txt", ".hidden.go", "sub/d.go", "sub/e.txt", "other/f.go", "x*y"} {
+		p := filepath.Join(dir, f)
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sh.dir = dir
+	setupTestVars(t, sh, "pat=*.txt")
+
+	tests := []struct {
+		name  string
+		raw   string
+		want  []string
+		setup func()
+	}{
+		{name: "star", raw: "*.go", want: []string{"a.go", "b.go"}},
+		{name: "question mark and brackets", raw: "[ab].g?", want: []string{"a.go", "b.go"}},
+		{name: "directories", raw: "*/*.go", want: []string{"other/f.go", "sub/d.go"}},
+		{name: "literal directory", raw: "sub/*", want: []string{"sub/d.go", "sub/e.txt"}},
+		{name: "trailing slash", raw: "*/", want: []string{"other/", "sub/"}},
+		{name: "absolute", raw: dir + "/*.txt", want: []string{dir + "/c.txt"}},
+		{name: "explicit dot", raw: ".*.go", want: []string{".hidden.go"}},
+		{name: "unquoted expansion", raw: "$pat", want: []string{"c.txt"}},
+		{name: "quoted", raw: `"*.go"`, want: []string{"*.go"}},
+		{name: "escaped", raw: `x\*y`, want: []string{"x*y"}},
+		{name: "partly quoted", raw: `x"*"*`, want: []string{"x*y"}},
+		{name: "no match", raw: "*.md", want: []string{"*.md"}},
+		{name: "nullglob", raw: "*.md", want: nil, setup: func() { sh.options[optNullglob] = true }},
+		{name: "dotglob", raw: "*.go", want: []string{".hidden.go", "a.go", "b.go"}, setup: func() { sh.options[optDotglob] = true }},
+		{name: "noglob", raw: "*.go", want: []string{"*.go"}, setup: func() { sh.options[optNoglob] = true }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() { clear(sh.options) })
+			if tt.setup != nil {
+				tt.setup()
+			}
+			got, err := sh.expandFields(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expandFields(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+
+	sh.options[optFailglob] = true
+	if _, err := sh.expandFields("*.md"); err == nil || err.Error() != "no match: *.md" {
+		t.Errorf("failglob error = %v, want no match: *.md", err)
+	}
+}
@@ -0,0 +1,517 @@
+// help.go — what the builtins say about themselves: the help builtin,
+// --help, and option completion.
+//
+//	help [-ds] [PATTERN...]
+//
+//	(no PATTERN)  list the synopsis of every builtin
+//	PATTERN       describe each builtin whose name matches the glob
+//	              PATTERN: synopsis, summary, description and options
+//	-d            only the one-line summary
+//	-s            only the synopsis
+//
+// builtinDocs holds the text; newRegistry copies it into each command.
+// The same entry answers NAME --help (unless the builtin takes --help as
+// an ordinary argument, as echo does) and supplies the option flags that
+// TAB completes after a builtin's name (completer.go).
+
+package shell
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// optionSpec describes one option of a builtin: its flag, the name of its
+// argument if it takes one, and what it does.
+type optionSpec struct {
+	Flag string
+	Arg  string
+	Help string
+}
+
+// builtinDocs documents every builtin; Name and Run are filled in by
+// newRegistry.
+var builtinDocs = map[string]command{
+	":": {
+		Synopsis:     ": [arguments]",
+		Summary:      "Do nothing, successfully.",
+		Description:  "Expands its arguments and returns status 0.",
+		NoHelpOption: true,
+	},
+	".": {
+		Synopsis:    ". filename [arguments]",
+		Summary:     "Run commands from a file in the current shell.",
+		Description: "The same as source.",
+	},
+	"[": {
+		Synopsis:     "[ arg... ]",
+		Summary:      "Evaluate a conditional expression.",
+		Description:  "The same as test, except that the last argument must be ].",
+		NoHelpOption: true,
+	},
+	"abbr": {
+		Synopsis: "abbr [-s] | -a name expansion ... | -e name ...",
+		Summary:  "Define abbreviations that expand as they are typed.",
+		Description: "An abbreviation in command position is replaced by its expansion in\n" +
+			"the line editor when space or Enter follows it. Changes made at the\n" +
+			"prompt are saved to ~/.goshrc.",
+		Options: []optionSpec{
+			{Flag: "-a", Arg: "name", Help: "define name as the remaining words"},
+			{Flag: "-e", Arg: "name", Help: "erase the abbreviations named"},
+			{Flag: "-s", Help: "list the abbreviations (the default)"},
+		},
+	},
+	"alias": {
+		Synopsis: "alias [-p] [name[=value] ... ]",
+		Summary:  "Define or display aliases.",
+		Description: "With name=value, define an alias; with name alone, print its\n" +
+			"definition; with no arguments, print every alias. While shopt\n" +
+			"expand_aliases is on, a command name that is an alias is replaced\n" +
+			"by its value when the line is parsed.",
+		Options: []optionSpec{
+			{Flag: "-p", Help: "print every alias in a reusable form"},
+		},
+	},
+	"break": {
+		Synopsis:    "break [n]",
+		Summary:     "Exit for, while or until loops.",
+		Description: "Leave the n'th enclosing loop (default 1).",
+	},
+	"cd": {
+		Synopsis: "cd [-L|-P] [dir]",
+		Summary:  "Change the shell working directory.",
+		Description: "DIR defaults to $HOME; - means $OLDPWD, which is printed. A relative\n" +
+			"DIR is searched for in CDPATH. PWD and OLDPWD are updated.",
+		Options: []optionSpec{
+			{Flag: "-L", Help: "treat .. logically, relative to $PWD (the default)"},
+			{Flag: "-P", Help: "resolve symbolic links"},
+		},
+	},
+	"command": {
+		Synopsis:    "command [-pVv] command [arg ...]",
+		Summary:     "Run a command, bypassing functions, or describe it.",
+		Description: "Runs COMMAND as a builtin or a file found on PATH.",
+		Options: []optionSpec{
+			{Flag: "-p", Help: "search a default PATH that finds the standard utilities"},
+			{Flag: "-v", Help: "print the name, path or alias that COMMAND resolves to"},
+			{Flag: "-V", Help: "describe COMMAND as type does"},
+		},
+	},
+	"continue": {
+		Synopsis:    "continue [n]",
+		Summary:     "Resume the next iteration of a loop.",
+		Description: "Continue with the next iteration of the n'th enclosing loop (default 1).",
+	},
+	"declare": {
+		Synopsis: "declare [-aAfFgilnprux] [name[=value] ...]",
+		Summary:  "Set variable values and attributes.",
+		Description: "Without names, list variables (or functions) with the given\n" +
+			"attributes. Using + instead of - turns an attribute off. Inside a\n" +
+			"function, declare makes variables local unless -g is given.",
+		Options: declareOptionsFor("aAfFgilnprux"),
+	},
+	"dirs": {
+		Synopsis:    "dirs [-clpv] [+N] [-N]",
+		Summary:     "Display the directory stack.",
+		Description: "+N and -N print the N'th entry counting from the left or the right.",
+		Options: []optionSpec{
+			{Flag: "-c", Help: "clear the directory stack"},
+			{Flag: "-l", Help: "do not abbreviate $HOME as ~"},
+			{Flag: "-p", Help: "print one entry per line"},
+			{Flag: "-v", Help: "print one entry per line, numbered"},
+		},
+	},
+	"echo": {
+		Synopsis: "echo [-neE] [arg ...]",
+		Summary:  "Write arguments to standard output.",
+		Description: "Escapes such as \\n and \\t are expanded with -e, or by default if\n" +
+			"shopt xpg_echo is on; \\c ends the output.",
+		NoHelpOption: true,
+		Options: []optionSpec{
+			{Flag: "-n", Help: "do not output the trailing newline"},
+			{Flag: "-e", Help: "expand backslash escapes"},
+			{Flag: "-E", Help: "do not expand backslash escapes"},
+		},
+	},
+	"eval": {
+		Synopsis:    "eval [arg ...]",
+		Summary:     "Run arguments as a shell command.",
+		Description: "The arguments are joined with spaces, parsed and run.",
+	},
+	"exec": {
+		Synopsis: "exec [-c] [-a name] [command [argument ...]]",
+		Summary:  "Replace the shell with a command.",
+		Description: "Without a command, the redirections of exec apply to the shell\n" +
+			"itself.",
+		Options: []optionSpec{
+			{Flag: "-a", Arg: "name", Help: "pass name as the command's argument 0"},
+			{Flag: "-c", Help: "run the command with an empty environment"},
+		},
+	},
+	"exit": {
+		Synopsis:    "exit [n]",
+		Summary:     "Exit the shell.",
+		Description: "Exit with status n, or that of the last command.",
+	},
+	"export": {
+		Synopsis:    "export [-np] [name[=value] ...]",
+		Summary:     "Mark variables for export to commands.",
+		Description: "Exported variables are passed in the environment of every command run.",
+		Options: []optionSpec{
+			{Flag: "-n", Help: "remove the export attribute"},
+			{Flag: "-p", Help: "list exported variables"},
+		},
+	},
+	"false": {
+		Synopsis:     "false",
+		Summary:      "Return an unsuccessful result.",
+		NoHelpOption: true,
+	},
+	"getopts": {
+		Synopsis: "getopts optstring name [arg ...]",
+		Summary:  "Parse option arguments.",
+		Description: "Each call sets NAME to the next option letter in the positional\n" +
+			"parameters, or in ARGs, and OPTARG to its argument. A letter in\n" +
+			"OPTSTRING followed by : takes an argument; a leading : reports errors\n" +
+			"in NAME and OPTARG instead of printing them. OPTIND indexes the next\n" +
+			"argument. The status is 1 when the options run out.",
+	},
+	"help": {
+		Synopsis: "help [-ds] [pattern ...]",
+		Summary:  "Display information about builtin commands.",
+		Description: "Describe the builtins whose names match PATTERN, or list them all.\n" +
+			"Every builtin that does not take it as an argument also answers --help.",
+		Options: []optionSpec{
+			{Flag: "-d", Help: "print a short description of each topic"},
+			{Flag: "-s", Help: "print only the synopsis of each topic"},
+		},
+	},
+	"history": {
+		Synopsis:    "history [n] | -r file | -w file | -a file",
+		Summary:     "Display or save the command history.",
+		Description: "With n, list only the last n entries.",
+		Options: []optionSpec{
+			{Flag: "-r", Arg: "file", Help: "append the lines of file to the history"},
+			{Flag: "-w", Arg: "file", Help: "write the history to file"},
+			{Flag: "-a", Arg: "file", Help: "append the new history lines to file"},
+		},
+	},
+	"jobs": {
+		Synopsis:    "jobs [-p] [id ...]",
+		Summary:     "List background jobs.",
+		Description: "Show each job's number, state and command. Finished jobs are\nforgotten once listed.",
+		Options: []optionSpec{
+			{Flag: "-p", Help: "list only the process id of each job's last command"},
+		},
+	},
+	"local": {
+		Synopsis:    "local [-aAilnprux] [name[=value] ...]",
+		Summary:     "Define local variables.",
+		Description: "Only valid inside a function; options are those of declare.",
+		Options:     declareOptionsFor("aAilnprux"),
+	},
+	"popd": {
+		Synopsis: "popd [-n] [+N | -N]",
+		Summary:  "Remove directories from the stack.",
+		Description: "Without arguments, remove the top directory and change to the new\n" +
+			"top; +N and -N remove the N'th entry counting from the left or the\n" +
+			"right.",
+		Options: []optionSpec{
+			{Flag: "-n", Help: "do not change directory"},
+		},
+	},
+	"printf": {
+		Synopsis: "printf [-v var] format [arguments]",
+		Summary:  "Format and print arguments.",
+		Description: "FORMAT takes the conversions %s %b %q %c %d %i %u %o %x %X %f %e %g\n" +
+			"with flags, width and precision, and is reused while arguments remain.",
+		Options: []optionSpec{
+			{Flag: "-v", Arg: "var", Help: "assign the output to var"},
+		},
+	},
+	"pushd": {
+		Synopsis: "pushd [-n] [+N | -N | dir]",
+		Summary:  "Add directories to the stack.",
+		Description: "Push the current directory and change to DIR; without arguments,\n" +
+			"swap the top two entries; +N and -N rotate the N'th entry to the top.",
+		Options: []optionSpec{
+			{Flag: "-n", Help: "change the stack only, not the directory"},
+		},
+	},
+	"pwd": {
+		Synopsis: "pwd [-LP]",
+		Summary:  "Print the name of the current working directory.",
+		Options: []optionSpec{
+			{Flag: "-L", Help: "print $PWD if it names the directory (the default)"},
+			{Flag: "-P", Help: "print the directory with symbolic links resolved"},
+		},
+	},
+	"read": {
+		Synopsis: "read [-rs] [-a array] [-d delim] [-n nchars] [-p prompt] [-t timeout] [-u fd] [name ...]",
+		Summary:  "Read a line from standard input and split it into fields.",
+		Description: "Each NAME gets a field and the last NAME the rest of the line; with\n" +
+			"no NAME the line goes to REPLY. The status is 1 at end of input.",
+		Options: []optionSpec{
+			{Flag: "-a", Arg: "array", Help: "assign the fields to the indexed array"},
+			{Flag: "-d", Arg: "delim", Help: "read up to delim instead of newline"},
+			{Flag: "-n", Arg: "nchars", Help: "read at most nchars characters"},
+			{Flag: "-p", Arg: "prompt", Help: "print prompt first, at a terminal"},
+			{Flag: "-r", Help: "do not treat backslash as an escape"},
+			{Flag: "-s", Help: "do not echo input from a terminal"},
+			{Flag: "-t", Arg: "timeout", Help: "give up after timeout seconds"},
+			{Flag: "-u", Arg: "fd", Help: "read from file descriptor fd"},
+		},
+	},
+	"readonly": {
+		Synopsis:    "readonly [-aAp] [name[=value] ...]",
+		Summary:     "Mark variables as unchangeable.",
+		Description: "Without names, list the readonly variables.",
+		Options: []optionSpec{
+			{Flag: "-a", Help: "the names are indexed arrays"},
+			{Flag: "-A", Help: "the names are associative arrays"},
+			{Flag: "-p", Help: "list readonly variables"},
+		},
+	},
+	"return": {
+		Synopsis:    "return [n]",
+		Summary:     "Return from a function or sourced file.",
+		Description: "Return with status n, or that of the last command.",
+	},
+	"set": {
+		Synopsis: "set [-efuvx] [-o option] [--] [arg ...]",
+		Summary:  "Set shell options and positional parameters.",
+		Description: "Using + instead of - turns an option off. Arguments after the\n" +
+			"options replace the positional parameters.",
+		Options: []optionSpec{
+			{Flag: "-e", Help: "exit when a command fails (errexit)"},
+			{Flag: "-f", Help: "disable pathname expansion (noglob)"},
+			{Flag: "-u", Help: "treat unset parameters as an error (nounset)"},
+			{Flag: "-v", Help: "print input lines as they are read (verbose)"},
+			{Flag: "-x", Help: "print commands as they run (xtrace)"},
+			{Flag: "-o", Arg: "option", Help: "set an option by name; alone, list them"},
+		},
+	},
+	"shift": {
+		Synopsis:    "shift [n]",
+		Summary:     "Shift positional parameters.",
+		Description: "Drop the first n positional parameters (default 1).",
+	},
+	"shopt": {
+		Synopsis:    "shopt [-pqsu] [-o] [optname ...]",
+		Summary:     "Set and unset shell options.",
+		Description: "Without -s or -u, print the state of each OPTNAME, or of all options.",
+		Options: []optionSpec{
+			{Flag: "-s", Help: "enable each optname"},
+			{Flag: "-u", Help: "disable each optname"},
+			{Flag: "-q", Help: "print nothing; the status tells whether all are on"},
+			{Flag: "-p", Help: "print in a reusable form"},
+			{Flag: "-o", Help: "use the options of set -o"},
+		},
+	},
+	"source": {
+		Synopsis: "source filename [arguments]",
+		Summary:  "Run commands from a file in the current shell.",
+		Description: "A FILENAME without a slash is looked up in PATH. ARGUMENTS become\n" +
+			"the positional parameters while it runs.",
+	},
+	"test": {
+		Synopsis: "test [expr]",
+		Summary:  "Evaluate a conditional expression.",
+		Description: "File tests (-e -f -d -r -w -x -s ...), string tests (-z -n = !=\n" +
+			"< >) and integer comparisons (-eq -ne -lt -le -gt -ge), combined\n" +
+			"with ! -a -o and parentheses.",
+		NoHelpOption: true,
+	},
+	"trap": {
+		Synopsis: "trap [-lp] [[arg] signal_spec ...]",
+		Summary:  "Run commands when the shell receives signals.",
+		Description: "ARG runs on each SIGNAL_SPEC; '' ignores it and - restores the\n" +
+			"default. EXIT, ERR, DEBUG and RETURN are also accepted.",
+		Options: []optionSpec{
+			{Flag: "-l", Help: "list signal names and numbers"},
+			{Flag: "-p", Help: "print traps as trap commands"},
+		},
+	},
+	"true": {
+		Synopsis:     "true",
+		Summary:      "Return a successful result.",
+		NoHelpOption: true,
+	},
+	"type": {
+		Synopsis:    "type [-aptP] name [name ...]",
+		Summary:     "Describe how names would be run as commands.",
+		Description: "The status is 1 if any NAME is not found.",
+		Options: []optionSpec{
+			{Flag: "-a", Help: "show every match, each file on PATH included"},
+			{Flag: "-p", Help: "print the path of NAME if it would run a file"},
+			{Flag: "-P", Help: "print the path of NAME on PATH"},
+			{Flag: "-t", Help: "print alias, keyword, function, builtin or file"},
+		},
+	},
+	"typeset": {
+		Synopsis:    "typeset [-aAfFgilnprux] [name[=value] ...]",
+		Summary:     "Set variable values and attributes.",
+		Description: "The same as declare.",
+		Options:     declareOptionsFor("aAfFgilnprux"),
+	},
+	"unalias": {
+		Synopsis: "unalias [-a] name [name ...]",
+		Summary:  "Remove aliases.",
+		Options: []optionSpec{
+			{Flag: "-a", Help: "remove every alias"},
+		},
+	},
+	"unset": {
+		Synopsis:    "unset [-fnv] [name ...]",
+		Summary:     "Unset variables or functions.",
+		Description: "Without options, unset the variable NAME, or else the function.",
+		Options: []optionSpec{
+			{Flag: "-f", Help: "the names are functions"},
+			{Flag: "-n", Help: "unset a nameref itself, not its target"},
+			{Flag: "-v", Help: "the names are variables"},
+		},
+	},
+	"wait": {
+		Synopsis: "wait [id ...]",
+		Summary:  "Wait for background jobs to finish.",
+		Description: "Each ID is a process id or a job number (%N). Without IDs, wait for\n" +
+			"every job and return 0; otherwise return the status of the last ID.",
+	},
+	"z": {
+		Synopsis: "z [-l] [word ...]",
+		Summary:  "Jump to a frequently and recently used directory.",
+		Description: "Change to the best-ranked visited directory whose path contains\n" +
+			"the WORDs in order.",
+		Options: []optionSpec{
+			{Flag: "-l", Help: "list the matching directories with their scores"},
+		},
+	},
+}
+
+// declareOptions are the options of declare and typeset.
+var declareOptions = []optionSpec{
+	{Flag: "-a", Help: "indexed array"},
+	{Flag: "-A", Help: "associative array"},
+	{Flag: "-f", Help: "the names are functions"},
+	{Flag: "-F", Help: "list function names only"},
+	{Flag: "-g", Help: "global, even inside a function"},
+	{Flag: "-i", Help: "integer: assignments are evaluated arithmetically"},
+	{Flag: "-l", Help: "convert values to lower case"},
+	{Flag: "-n", Help: "nameref: a reference to the variable named by the value"},
+	{Flag: "-p", Help: "print the variables with their attributes"},
+	{Flag: "-r", Help: "readonly"},
+	{Flag: "-u", Help: "convert values to upper case"},
+	{Flag: "-x", Help: "export"},
+}
+
+// declareOptionsFor returns the declareOptions with the given letters,
+// for the declare-family builtins that accept only some of them.
+func declareOptionsFor(letters string) []optionSpec {
+	var opts []optionSpec
+	for _, opt := range declareOptions {
+		if strings.Contains(letters, opt.Flag[1:]) {
+			opts = append(opts, opt)
+		}
+	}
+	return opts
+}
+
+// helpOption wraps run so that a lone --help argument prints the help of
+// cmd instead of running it.
+func helpOption(cmd command, run func(*interp, []string) int) func(*interp, []string) int {
+	return func(sh *interp, args []string) int {
+		if len(args) == 1 && args[0] == "--help" {
+			sh.printHelp(cmd)
+			return 0
+		}
+		return run(sh, args)
+	}
+}
+
+// builtinHelp implements help.
+func (sh *interp) builtinHelp(args []string) int {
+	var short, synopsis bool
+	opts, args, ok := sh.parseOptions("help", "ds", args)
+	if !ok {
+		return 2
+	}
+	for _, opt := range opts {
+		switch opt.c {
+		case 'd':
+			short = true
+		case 's':
+			synopsis = true
+		}
+	}
+
+	names := slices.Sorted(maps.Keys(sh.registry))
+	if len(args) == 0 {
+		fmt.Fprintln(sh.stdout(), "These shell commands are defined internally. Type `help name' to find")
+		fmt.Fprintln(sh.stdout(), "out more about the command `name'.")
+		fmt.Fprintln(sh.stdout())
+		for _, name := range names {
+			fmt.Fprintln(sh.stdout(), " "+sh.registry[name].Synopsis)
+		}
+		return 0
+	}
+
+	status := 0
+	for _, pattern := range args {
+		found := false
+		for _, name := range names {
+			if !matchPattern(pattern, name) {
+				continue
+			}
+			found = true
+			switch cmd := sh.registry[name]; {
+			case short:
+				fmt.Fprintf(sh.stdout(), "%s - %s\n", name, cmd.Summary)
+			case synopsis:
+				fmt.Fprintf(sh.stdout(), "%s: %s\n", name, cmd.Synopsis)
+			default:
+				sh.printHelp(cmd)
+			}
+		}
+		if !found {
+			fmt.Fprintf(sh.stderr(), "help: no help topics match `%s'.\n", pattern)
+			status = 1
+		}
+	}
+	return status
+}
+
+// printHelp prints the full help of cmd.
+func (sh *interp) printHelp(cmd command) {
+	fmt.Fprintf(sh.stdout(), "%s: %s\n", cmd.Name, cmd.Synopsis)
+	fmt.Fprintf(sh.stdout(), "    %s\n", cmd.Summary)
+	if cmd.Description != "" {
+		fmt.Fprintln(sh.stdout())
+		for _, line := range strings.Split(cmd.Description, "\n") {
+			fmt.Fprintf(sh.stdout(), "    %s\n", line)
+		}
+	}
+	if len(cmd.Options) > 0 {
+		fmt.Fprintln(sh.stdout())
+		fmt.Fprintln(sh.stdout(), "    Options:")
+		for _, opt := range cmd.Options {
+			fmt.Fprintf(sh.stdout(), "      %-10s %s\n", strings.TrimSpace(opt.Flag+" "+opt.Arg), opt.Help)
+		}
+	}
+}
+
+// optionCompletions returns the options of cmd that start with word, for
+// TAB completion, in the order they are documented.
+func optionCompletions(cmd command, word string) []string {
+	var flags []string
+	for _, opt := range cmd.Options {
+		if strings.HasPrefix(opt.Flag, word) {
+			flags = append(flags, opt.Flag)
+		}
+	}
+	if !cmd.NoHelpOption && strings.HasPrefix("--help", word) {
+		flags = append(flags, "--help")
+	}
+	return flags
+}
@@ -0,0 +1,96 @@
+package shell
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinDocs(t *testing.T) {
+	sh := newTestShell(t)
+	for name, cmd := range sh.registry {
+		if cmd.Name != name {
+			t.Errorf("%s: Name = %q", name, cmd.Name)
+		}
+		if cmd.Synopsis != name && !strings.HasPrefix(cmd.Synopsis, name+" ") {
+			t.Errorf("%s: synopsis %q does not start with the name", name, cmd.Synopsis)
+		}
+		if cmd.Summary == "" {
+			t.Errorf("%s: no summary", name)
+		}
+		for _, opt := range cmd.Options {
+			if !strings.HasPrefix(opt.Flag, "-") || opt.Help == "" {
+				t.Errorf("%s: bad option spec %+v", name, opt)
+			}
+		}
+	}
+	for name := range builtinDocs {
+		if _, ok := sh.registry[name]; !ok {
+			t.Errorf("builtinDocs documents %q, which is not a builtin", name)
+		}
+	}
+}
+
+func TestHelp(t *testing.T) {
+	sh := newTestShell(t)
+	cdHelp := "cd: cd [-L|-P] [dir]\n" +
+		"    Change the shell working directory.\n" +
+		"\n" +
+		"    DIR defaults to $HOME; - means $OLDPWD, which is printed. A relative\n" +
+		"    DIR is searched for in CDPATH. PWD and OLDPWD are updated.\n" +
+		"\n" +
+		"    Options:\n" +
+		"      -L         treat .. logically, relative to $PWD (the default)\n" +
+		"      -P         resolve symbolic links\n"
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "topic", src: "help cd", want: cdHelp},
+		{name: "--help", src: "cd --help", want: cdHelp},
+		{name: "option arguments", src: "help -s printf; help printf | tail -n 1", want: "printf: printf [-v var] format [arguments]\n      -v var     assign the output to var\n"},
+		{name: "-d with pattern", src: "help -d 'pu*' 'po*'", want: "pushd - Add directories to the stack.\npopd - Remove directories from the stack.\n"},
+		{name: "-s", src: "help -s z", want: "z: z [-l] [word ...]\n"},
+		{name: "no match", src: "help nothing", wantErr: "help: no help topics match `nothing'.\n", status: 1},
+		{name: "invalid option", src: "help -x", wantErr: "help: -x: invalid option\nhelp: usage: help [-ds] [pattern ...]\n", status: 2},
+		{name: "echo prints --help", src: "echo --help", want: "--help\n"},
+		{name: "--help only alone", src: "printf '%s\\n' --help", want: "--help\n"},
+		{name: "list", src: "help | grep -c '^ '", want: strconv.Itoa(len(sh.registry)) + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
+
+func TestOptionCompletion(t *testing.T) {
+	sh := newTestShell(t)
+	if got, want := optionCompletions(sh.registry["cd"], "-"), []string{"-L", "-P", "--help"}; !slices.Equal(got, want) {
+		t.Errorf("optionCompletions(cd, -) = %q, want %q", got, want)
+	}
+	if got, want := optionCompletions(sh.registry["echo"], "-"), []string{"-n", "-e", "-E"}; !slices.Equal(got, want) {
+		t.Errorf("optionCompletions(echo, -) = %q, want %q", got, want)
+	}
+	if got, want := optionCompletions(sh.registry["local"], "-"), []string{"-a", "-A", "-i", "-l", "-n", "-p", "-r", "-u", "-x", "--help"}; !slices.Equal(got, want) {
+		t.Errorf("optionCompletions(local, -) = %q, want %q", got, want)
+	}
+
+	setupTestTrie(t, sh, []string{"type"})
+	comp := &builtinCompleter{sh: sh}
+	for line, want := range map[string]string{"type -P": " ", "read -u": " ", "cd --h": "elp ", "ls -": ""} {
+		got, _ := comp.Do([]rune(line), len(line))
+		if want == "" && got != nil || want != "" && (len(got) != 1 || string(got[0]) != want) {
+			t.Errorf("Do(%q) = %q, want %q", line, got, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package shell
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"testing"
+)
+
+// newTestShell returns a shell for one test, in the working directory
+// and on the process's standard streams.
+func newTestShell(t *testing.T) *interp {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newInterp(os.Environ(), dir)
+}
+
+// captureStdout runs fn and returns whatever it wrote to the shell's
+// standard output.
+func captureStdout(t *testing.T, sh *interp, fn func()) string {
+	t.Helper()
+	return capture(t, sh, 1, fn)
+}
+
+// captureStderr runs fn and returns whatever it wrote to the shell's
+// standard error.
+func captureStderr(t *testing.T, sh *interp, fn func()) string {
+	t.Helper()
+	return capture(t, sh, 2, fn)
+}
+
+// capture runs fn with file descriptor fd of the shell writing to a pipe
+// and returns what came through it.
+func capture(t *testing.T, sh *interp, fd int, fn func()) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	old := sh.fds[fd]
+	sh.fds[fd] = w
+
+	fn()
+
+	w.Close()
+	sh.fds[fd] = old
+
+	var buf bytes.Buffer
+	io.Copy(&buf, r)
+	return buf.String()
+}
@@ -1,45 +1,49 @@
 // history.go — in-memory command history with file persistence.
 //
-// History tracks entries as a simple []string. File operations:
+// A history keeps its entries as a simple []string. File operations:
 //   - ReadFile:   load from disk, appending to in-memory list
 //   - WriteFile:  overwrite file with all entries
 //   - AppendFile: append only new (unflushed) entries since last call
 //
 // The lastFlushed index tracks the boundary for AppendFile so repeated
-// calls don't duplicate entries.
-package main
+// calls don't duplicate entries. The history builtin reaches them as
+// -r, -w and -a FILE; with a number N it prints the last N entries.
+
+package shell
 
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
 )
 
-// History tracks shell command history in memory with file I/O support.
-type History struct {
+// history tracks shell command history in memory with file I/O support.
+type history struct {
 	entries     []string
 	lastFlushed int
 }
 
-// NewHistory creates an empty history.
-func NewHistory() *History {
-	return &History{}
+// newHistory creates an empty history.
+func newHistory() *history {
+	return &history{}
 }
 
 // MarkFlushed advances the flush cursor to the current end of history,
 // so a subsequent AppendFile only writes entries added after this point.
-func (h *History) MarkFlushed() {
+func (h *history) MarkFlushed() {
 	h.lastFlushed = len(h.entries)
 }
 
 // Record appends a raw input line to the history.
-func (h *History) Record(input string) {
+func (h *history) Record(input string) {
 	h.entries = append(h.entries, input)
 }
 
 // ReadFile reads lines from path and appends them to the in-memory
 // history. Empty lines are skipped.
-func (h *History) ReadFile(path string) error {
+func (h *history) ReadFile(path string) error {
 	f, err := os.Open(path)
 	if err != nil {
 		return err
@@ -58,7 +62,7 @@ func (h *History) ReadFile(path string) error {
 
 // WriteFile writes all in-memory history entries to path,
 // creating the file if it doesn't exist. Each entry is followed by a newline.
-func (h *History) WriteFile(path string) error {
+func (h *history) WriteFile(path string) error {
 	f, err := os.Create(path)
 	if err != nil {
 		return err
@@ -75,7 +79,7 @@ func (h *History) WriteFile(path string) error {
 // AppendFile appends only new (unflushed) in-memory history entries
 // to path, creating the file if it doesn't exist. Trailing blank lines
 // in the existing file are trimmed before appending.
-func (h *History) AppendFile(path string) error {
+func (h *history) AppendFile(path string) error {
 	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
 	if err != nil {
 		return err
@@ -110,13 +114,50 @@ func (h *History) AppendFile(path string) error {
 	return w.Flush()
 }
 
-// Print prints the last n history entries (or all if n <= 0).
-func (h *History) Print(n int) {
+// Print prints the last n history entries (or all if n <= 0) to w.
+func (h *history) Print(w io.Writer, n int) {
 	start := 0
 	if n > 0 && n < len(h.entries) {
 		start = len(h.entries) - n
 	}
 	for i := start; i < len(h.entries); i++ {
-		fmt.Printf("%5d  %s\n", i+1, h.entries[i])
+		fmt.Fprintf(w, "%5d  %s\n", i+1, h.entries[i])
+	}
+}
+
+// builtinHistory implements history.
+func (sh *interp) builtinHistory(args []string) int {
+	opts, args, ok := sh.parseOptions("history", "r:w:a:", args)
+	if !ok {
+		return 2
+	}
+	for _, opt := range opts {
+		var err error
+		switch opt.c {
+		case 'r':
+			err = sh.hist.ReadFile(sh.path(opt.arg))
+		case 'w':
+			err = sh.hist.WriteFile(sh.path(opt.arg))
+		case 'a':
+			err = sh.hist.AppendFile(sh.path(opt.arg))
+		}
+		if err != nil {
+			fmt.Fprintf(sh.stderr(), "history: %s\n", err)
+			return 1
+		}
+	}
+	if len(opts) > 0 {
+		return 0
+	}
+
+	n := 0
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 0 {
+			fmt.Fprintf(sh.stderr(), "history: %s: numeric argument required\n", args[0])
+			return 1
+		}
 	}
+	sh.hist.Print(sh.stdout(), n)
+	return 0
 }
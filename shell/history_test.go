@@ -1,4 +1,4 @@
-package main
+package shell
 
 import (
 	"os"
@@ -7,7 +7,7 @@ import (
 )
 
 func TestRecord(t *testing.T) {
-	h := NewHistory()
+	h := newHistory()
 
 	h.Record("echo hello")
 	h.Record("ls -la")
@@ -25,7 +25,7 @@ func TestRecord(t *testing.T) {
 
 func TestReadFile(t *testing.T) {
 	t.Run("reads non-empty lines", func(t *testing.T) {
-		h := NewHistory()
+		h := newHistory()
 		path := filepath.Join(t.TempDir(), "history")
 		os.WriteFile(path, []byte("echo hello\necho world\n\n"), 0644)
 
@@ -44,7 +44,7 @@ func TestReadFile(t *testing.T) {
 	})
 
 	t.Run("appends to existing history", func(t *testing.T) {
-		h := NewHistory()
+		h := newHistory()
 		h.Record("first")
 		path := filepath.Join(t.TempDir(), "history")
 		os.WriteFile(path, []byte("second\n"), 0644)
@@ -64,7 +64,7 @@ func TestReadFile(t *testing.T) {
 	})
 
 	t.Run("returns error for missing file", func(t *testing.T) {
-		h := NewHistory()
+		h := newHistory()
 		if err := h.ReadFile("/nonexistent/path"); err == nil {
 			t.Error("expected error for missing file")
 		}
@@ -73,7 +73,7 @@ func TestReadFile(t *testing.T) {
 
 func TestWriteFile(t *testing.T) {
 	t.Run("writes all entries with trailing newline", func(t *testing.T) {
-		h := NewHistory()
+		h := newHistory()
 		h.Record("echo hello")
 		h.Record("echo world")
 
@@ -93,7 +93,7 @@ func TestWriteFile(t *testing.T) {
 	})
 
 	t.Run("creates file if it does not exist", func(t *testing.T) {
-		h := NewHistory()
+		h := newHistory()
 		h.Record("cmd")
 		path := filepath.Join(t.TempDir(), "new_history")
 		if err := h.WriteFile(path); err != nil {
@@ -105,7 +105,7 @@ func TestWriteFile(t *testing.T) {
 	})
 
 	t.Run("empty history writes empty file", func(t *testing.T) {
-		h := NewHistory()
+		h := newHistory()
 		path := filepath.Join(t.TempDir(), "history")
 		if err := h.WriteFile(path); err != nil {
 			t.Fatal(err)
@@ -119,7 +119,7 @@ func TestWriteFile(t *testing.T) {
 
 func TestAppendFile(t *testing.T) {
 	t.Run("appends to existing file", func(t *testing.T) {
-		h := NewHistory()
+		h := newHistory()
 		path := filepath.Join(t.TempDir(), "history")
 		os.WriteFile(path, []byte("old command\n"), 0644)
 
@@ -136,7 +136,7 @@ func TestAppendFile(t *testing.T) {
 	})
 
 	t.Run("creates file if it does not exist", func(t *testing.T) {
-		h := NewHistory()
+		h := newHistory()
 		h.Record("cmd")
 		path := filepath.Join(t.TempDir(), "new_history")
 		if err := h.AppendFile(path); err != nil {
@@ -151,7 +151,7 @@ func TestAppendFile(t *testing.T) {
 	})
 
 	t.Run("second append only writes new entries", func(t *testing.T) {
-		h := NewHistory()
+		h := newHistory()
 		path := filepath.Join(t.TempDir(), "history")
 
 		h.Record("first")
@@ -168,7 +168,7 @@ func TestAppendFile(t *testing.T) {
 	})
 
 	t.Run("empty history appends nothing", func(t *testing.T) {
-		h := NewHistory()
+		h := newHistory()
 		path := filepath.Join(t.TempDir(), "history")
 		os.WriteFile(path, []byte("existing\n"), 0644)
 
@@ -185,12 +185,13 @@ func TestAppendFile(t *testing.T) {
 }
 
 func TestPrint(t *testing.T) {
+	sh := newTestShell(t)
 	t.Run("prints all entries", func(t *testing.T) {
-		h := NewHistory()
+		h := newHistory()
 		h.Record("echo hello")
 		h.Record("echo world")
 
-		got := captureStdout(t, func() { h.Print(0) })
+		got := captureStdout(t, sh, func() { h.Print(sh.stdout(), 0) })
 		want := "    1  echo hello\n    2  echo world\n"
 		if got != want {
 			t.Errorf("Print(0) = %q, want %q", got, want)
@@ -198,12 +199,12 @@ func TestPrint(t *testing.T) {
 	})
 
 	t.Run("prints last n entries", func(t *testing.T) {
-		h := NewHistory()
+		h := newHistory()
 		h.Record("first")
 		h.Record("second")
 		h.Record("third")
 
-		got := captureStdout(t, func() { h.Print(2) })
+		got := captureStdout(t, sh, func() { h.Print(sh.stdout(), 2) })
 		want := "    2  second\n    3  third\n"
 		if got != want {
 			t.Errorf("Print(2) = %q, want %q", got, want)
@@ -211,13 +212,46 @@ func TestPrint(t *testing.T) {
 	})
 
 	t.Run("n larger than history prints all", func(t *testing.T) {
-		h := NewHistory()
+		h := newHistory()
 		h.Record("only")
 
-		got := captureStdout(t, func() { h.Print(10) })
+		got := captureStdout(t, sh, func() { h.Print(sh.stdout(), 10) })
 		want := "    1  only\n"
 		if got != want {
 			t.Errorf("Print(10) = %q, want %q", got, want)
 		}
 	})
 }
+
+func TestHistoryBuiltin(t *testing.T) {
+	sh := newTestShell(t)
+	oldHist := sh.hist
+	t.Cleanup(func() { sh.hist = oldHist })
+	path := filepath.Join(t.TempDir(), "history")
+	os.WriteFile(path, []byte("first\nsecond\nthird\n"), 0644)
+
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "-r then n", src: "history -r " + path + "\nhistory 2", want: "    2  second\n    3  third\n"},
+		{name: "clustered -r", src: "history -r" + path + "\nhistory 1", want: "    3  third\n"},
+		{name: "missing file", src: "history -r", wantErr: "history: -r: option requires an argument\nhistory: usage: history [n] | -r file | -w file | -a file\n", status: 2},
+		{name: "invalid option", src: "history -x", wantErr: "history: -x: invalid option\nhistory: usage: history [n] | -r file | -w file | -a file\n", status: 2},
+		{name: "not a number", src: "history x", wantErr: "history: x: numeric argument required\n", status: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sh.hist = newHistory()
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
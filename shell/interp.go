@@ -0,0 +1,421 @@
+// interp.go — executes the syntax tree built by syntax.go.
+//
+//	runList             run each and-or list, recording $?
+//	  -> runAndOr       && and || short-circuiting; '&' backgrounds
+//	    -> runPipe      one command in the foreground, or executePipeline
+//	      -> runCmdNode simple command (runCommand), function definition,
+//	                    or compound command with its redirections
+//	        -> execCompound  if / while / until / for / case / { } / ( ) / (( ))
+//
+// break, continue, return and exit do not unwind the Go stack: they record
+// a pending jump in flow, and every list and loop checks it after each
+// command. Loops consume break and continue, callFunction consumes return,
+// and exit propagates all the way up to the script or prompt loop. Traps
+// (trap.go) run at the same points: signal handlers after each and-or
+// list, ERR after a failure, DEBUG before each pipeline.
+
+package shell
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// flowKind is the kind of jump pending in flow.
+type flowKind int
+
+const (
+	flowNone flowKind = iota
+	flowBreak
+	flowContinue
+	flowReturn
+	flowExit
+)
+
+// flowState tracks pending jumps and how deeply loops and functions are
+// nested, which break, continue and return need to validate themselves.
+type flowState struct {
+	kind  flowKind
+	n     int // loops left to break out of or continue
+	loops int // enclosing loops in the current function
+	funcs int // active function calls
+}
+
+// runList runs the and-or lists of l in order and returns the status of
+// the last one. It stops early when a jump is pending, and exits when the
+// run is cancelled.
+func (sh *interp) runList(l cmdList) int {
+	status := 0
+	for _, ao := range l {
+		if sh.ctx.Err() != nil { // the Interpreter's run was cancelled
+			sh.flow.kind = flowExit
+			break
+		}
+		status = sh.runAndOr(ao)
+		sh.params.status = status
+		sh.runPendingTraps()
+		status = sh.params.status // an exit in a trap sets it
+		if sh.flow.kind != flowNone {
+			break
+		}
+	}
+	return status
+}
+
+// runAndOr runs the pipelines of ao, skipping each one that && or || rules
+// out given the status so far. A background list becomes a job: a single
+// pipeline through executePipeline, a longer list in a subshell. Only
+// the last pipeline, when not negated, is subject to errexit and the ERR
+// trap.
+func (sh *interp) runAndOr(ao *andOr) int {
+	if ao.background {
+		if len(ao.pipes) == 1 && !ao.pipes[0].negate {
+			return sh.runPipe(ao.pipes[0], true)
+		}
+		fg := *ao
+		fg.background = false
+		sub := sh.subshell()
+		sub.async = true
+		sh.startJob(andOrText(ao), 0, true, func() int { return sh.exitSubshell(sub, sub.runAndOr(&fg)) })
+		return 0
+	}
+	status := 0
+	for i, pl := range ao.pipes {
+		if i > 0 {
+			if sh.flow.kind != flowNone {
+				break
+			}
+			if (ao.ops[i-1] == "&&") != (status == 0) {
+				continue
+			}
+			sh.params.status = status
+		}
+		if i < len(ao.pipes)-1 || pl.negate {
+			sh.errexitOff++
+			status = sh.runPipe(pl, false)
+			sh.errexitOff--
+			continue
+		}
+		if status = sh.runPipe(pl, false); sh.flow.kind != flowNone {
+			break
+		}
+		if sh.runErrTrap(status); sh.flow.kind == flowExit {
+			return sh.params.status // exit in the trap
+		}
+		sh.checkErrexit(status)
+	}
+	return status
+}
+
+// runPipe runs a pipeline. A lone foreground command runs directly in the
+// shell so that builtins, assignments and functions affect it; anything
+// else goes through executePipeline, and jumps inside it (break | cat)
+// do not escape.
+func (sh *interp) runPipe(pl *pipeNode, background bool) int {
+	sh.params.lineno = pl.line
+	first := pl.cmds[0].compound
+	if _, arith := first.(*arithNode); first == nil || arith || len(pl.cmds) > 1 {
+		if sh.runPseudoTrap("DEBUG"); sh.flow.kind == flowExit {
+			return sh.params.status
+		}
+	}
+	var status int
+	if len(pl.cmds) == 1 && !background {
+		status = sh.runCmdNode(pl.cmds[0])
+	} else {
+		status = sh.executePipeline(pl.cmds, background)
+		sh.flow.kind, sh.flow.n = flowNone, 0
+	}
+	if pl.negate {
+		status = boolStatus(status != 0)
+	}
+	return status
+}
+
+// runCmdNode runs one pipeline element in the current shell.
+func (sh *interp) runCmdNode(c *cmdNode) int {
+	switch n := c.compound.(type) {
+	case nil:
+		return sh.runCommand(c.raw)
+	case *funcNode:
+		sh.functions[n.name] = n
+		return 0
+	}
+	return sh.withRedirects(c.redirs, func() int { return sh.execCompound(c.compound) })
+}
+
+// withRedirects runs fn with the file descriptors redirected as described
+// by the raw redirection text that followed a compound command.
+func (sh *interp) withRedirects(raw string, fn func() int) int {
+	if raw == "" {
+		return fn()
+	}
+	_, redirects, err := sh.parseRedirection(raw)
+	if err != nil {
+		fmt.Fprintln(sh.stderr(), err)
+		return 1
+	}
+	fds, cleanup, err := sh.redirect(sh.fds, redirects)
+	if err != nil {
+		fmt.Fprintln(sh.stderr(), err)
+		return 1
+	}
+	defer cleanup()
+	return sh.withFds(fds, fn)
+}
+
+// execCompound runs a compound command and returns its status.
+func (sh *interp) execCompound(node any) int {
+	switch n := node.(type) {
+	case *groupNode:
+		if n.subshell {
+			sub := sh.subshell()
+			return sh.exitSubshell(sub, sub.runList(n.body))
+		}
+		return sh.runList(n.body)
+	case *ifNode:
+		return sh.execIf(n)
+	case *loopNode:
+		return sh.execLoop(n)
+	case *forNode:
+		return sh.execFor(n)
+	case *arithForNode:
+		return sh.execArithFor(n)
+	case *caseNode:
+		return sh.execCase(n)
+	case *arithNode:
+		sh.trace("(( " + strings.TrimSpace(n.expr) + " ))")
+		v, err := sh.evalArith(n.expr)
+		if err != nil {
+			return 1
+		}
+		return boolStatus(v != 0)
+	case *condNode:
+		return sh.execCond(n)
+	}
+	panic(fmt.Sprintf("execCompound: unexpected node %T", node))
+}
+
+// boolStatus converts a truth value to an exit status.
+func boolStatus(ok bool) int {
+	if ok {
+		return 0
+	}
+	return 1
+}
+
+// evalArith expands and evaluates an arithmetic command or loop clause,
+// reporting errors the way (( )) does.
+func (sh *interp) evalArith(expr string) (int64, error) {
+	expr, err := sh.expandWord(expr)
+	if err == nil {
+		var v int64
+		if v, err = sh.arithEval(expr); err == nil {
+			return v, nil
+		}
+	}
+	fmt.Fprintf(sh.stderr(), "((: %s\n", err)
+	return 0, err
+}
+
+// runCondition runs the condition of an if, while or until, where errexit
+// does not apply.
+func (sh *interp) runCondition(cond cmdList) int {
+	sh.errexitOff++
+	defer func() { sh.errexitOff-- }()
+	return sh.runList(cond)
+}
+
+func (sh *interp) execIf(n *ifNode) int {
+	for i, cond := range n.conds {
+		status := sh.runCondition(cond)
+		if sh.flow.kind != flowNone {
+			return status
+		}
+		if status == 0 {
+			return sh.runList(n.bodies[i])
+		}
+	}
+	return sh.runList(n.elseBody)
+}
+
+// loopControl consumes a pending break or continue aimed at the innermost
+// loop and reports whether that loop must stop.
+func (sh *interp) loopControl() bool {
+	switch sh.flow.kind {
+	case flowNone:
+		return false
+	case flowBreak, flowContinue:
+		sh.flow.n--
+		if sh.flow.n > 0 {
+			return true
+		}
+		stop := sh.flow.kind == flowBreak
+		sh.flow.kind = flowNone
+		return stop
+	}
+	return true // return or exit: unwind further
+}
+
+func (sh *interp) execLoop(n *loopNode) int {
+	sh.flow.loops++
+	defer func() { sh.flow.loops-- }()
+	status := 0
+	for {
+		cond := sh.runCondition(n.cond)
+		if sh.flow.kind != flowNone {
+			if sh.loopControl() {
+				break
+			}
+			continue
+		}
+		if (cond == 0) == n.until {
+			break
+		}
+		status = sh.runList(n.body)
+		if sh.loopControl() {
+			break
+		}
+	}
+	return status
+}
+
+func (sh *interp) execFor(n *forNode) int {
+	words := slices.Clone(sh.params.positional)
+	if n.hasIn {
+		words = nil
+		for _, raw := range n.words {
+			fields, err := sh.expandFields(raw)
+			if err != nil {
+				fmt.Fprintln(sh.stderr(), err)
+				return 1
+			}
+			words = append(words, fields...)
+		}
+	}
+
+	sh.flow.loops++
+	defer func() { sh.flow.loops-- }()
+	status := 0
+	for _, w := range words {
+		if err := sh.vars.Set(n.name, w); err != nil {
+			fmt.Fprintln(sh.stderr(), err)
+			return 1
+		}
+		status = sh.runList(n.body)
+		if sh.loopControl() {
+			break
+		}
+	}
+	return status
+}
+
+func (sh *interp) execArithFor(n *arithForNode) int {
+	if _, err := sh.evalArith(n.init); err != nil {
+		return 1
+	}
+	sh.flow.loops++
+	defer func() { sh.flow.loops-- }()
+	status := 0
+	for {
+		if strings.TrimSpace(n.cond) != "" {
+			v, err := sh.evalArith(n.cond)
+			if err != nil {
+				return 1
+			}
+			if v == 0 {
+				break
+			}
+		}
+		status = sh.runList(n.body)
+		if sh.loopControl() {
+			break
+		}
+		if _, err := sh.evalArith(n.step); err != nil {
+			return 1
+		}
+	}
+	return status
+}
+
+func (sh *interp) execCase(n *caseNode) int {
+	word, err := sh.expandWord(n.word)
+	if err != nil {
+		fmt.Fprintln(sh.stderr(), err)
+		return 1
+	}
+	for _, item := range n.items {
+		for _, raw := range item.patterns {
+			pat, err := sh.expandPattern(raw)
+			if err != nil {
+				fmt.Fprintln(sh.stderr(), err)
+				return 1
+			}
+			if matchPattern(pat, word) {
+				return sh.runList(item.body)
+			}
+		}
+	}
+	return 0
+}
+
+// builtinLoopJump implements break [n] and continue [n], which leave or
+// restart the n'th enclosing loop.
+func (sh *interp) builtinLoopJump(name string, kind flowKind, args []string) int {
+	n := 1
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil {
+			fmt.Fprintf(sh.stderr(), "%s: %s: numeric argument required\n", name, args[0])
+			return 1
+		}
+		if n < 1 {
+			fmt.Fprintf(sh.stderr(), "%s: %d: loop count out of range\n", name, n)
+			return 1
+		}
+	}
+	if sh.flow.loops == 0 {
+		fmt.Fprintf(sh.stderr(), "%s: only meaningful in a `for', `while', or `until' loop\n", name)
+		return 0
+	}
+	sh.flow.kind, sh.flow.n = kind, min(n, sh.flow.loops)
+	return 0
+}
+
+// builtinReturn implements return [n]: leave the current function with
+// status n, or the status of the last command.
+func (sh *interp) builtinReturn(args []string) int {
+	status := sh.params.status
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(sh.stderr(), "return: %s: numeric argument required\n", args[0])
+			n = 2
+		}
+		status = n & 0xff
+	}
+	if sh.flow.funcs == 0 {
+		fmt.Fprintln(sh.stderr(), "return: can only `return' from a function or sourced script")
+		return 1
+	}
+	sh.flow.kind = flowReturn
+	return status
+}
+
+// builtinExit implements exit [n]: stop the shell (or the subshell) with
+// status n, or the status of the last command.
+func (sh *interp) builtinExit(args []string) int {
+	status := sh.params.status
+	if len(args) > 0 {
+		n, err := strconv.Atoi(args[0])
+		if err != nil {
+			fmt.Fprintf(sh.stderr(), "exit: %s: numeric argument required\n", args[0])
+			n = 2
+		}
+		status = n & 0xff
+	}
+	sh.flow.kind = flowExit
+	return status
+}
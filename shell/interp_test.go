@@ -0,0 +1,157 @@
+package shell
+
+import (
+	"maps"
+	"os"
+	"strings"
+	"testing"
+)
+
+// runTestScript runs src as a script in a fresh shell state, as main
+// does, and returns what it wrote to stdout and its exit status.
+func runTestScript(t *testing.T, sh *interp, src string) (string, int) {
+	t.Helper()
+	return runTestShell(t, sh, src, false)
+}
+
+// runTestShell is runTestScript in a shell that is interactive if
+// interactive is set, though it still reads src rather than a terminal.
+// The shell keeps its file descriptors and options; the options are put
+// back when the test ends.
+func runTestShell(t *testing.T, sh *interp, src string, interactive bool) (string, int) {
+	t.Helper()
+	dir, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sh.dir = dir
+	setupTestVars(t, sh, "PATH="+os.Getenv("PATH"))
+	sh.initDynamicVars()
+	setupTestParams(t, sh)
+	sh.params.interactive = interactive
+	sh.functions, sh.aliases = map[string]*funcNode{}, map[string]string{}
+	savedOptions := maps.Clone(sh.options)
+	t.Cleanup(func() {
+		sh.options = savedOptions
+		sh.flow = flowState{}
+		for name := range sh.traps {
+			delete(sh.traps, name)
+			sh.updateSignal(name)
+		}
+	})
+	var status int
+	out := captureStdout(t, sh, func() {
+		status = sh.runExitTrap(sh.runLoop(lineReader(strings.NewReader(src)), false, "gosh"))
+	})
+	return out, status
+}
+
+func TestRunControlFlow(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name   string
+		src    string
+		want   string
+		status int
+	}{
+		{name: "sequence", src: "echo a; echo b", want: "a\nb\n"},
+		{name: "and", src: "true && echo yes; false && echo no", want: "yes\n", status: 1},
+		{name: "or", src: "false || echo yes; true || echo no", want: "yes\n"},
+		{name: "and-or chain", src: "false && echo a || echo b", want: "b\n"},
+		{name: "negation", src: "! false", want: ""},
+		{name: "negation of success", src: "! true", want: "", status: 1},
+		{name: "if", src: "if true; then echo a; else echo b; fi", want: "a\n"},
+		{name: "elif", src: "if false; then echo a\nelif true; then echo b\nelse echo c\nfi", want: "b\n"},
+		{name: "else", src: "if false; then echo a; else echo c; fi", want: "c\n"},
+		{name: "if without match", src: "if false; then echo a; fi", want: ""},
+		{name: "while", src: "i=0; while ((i < 3)); do echo $i; i=$((i+1)); done", want: "0\n1\n2\n"},
+		{name: "until", src: "i=0; until ((i == 2)); do i=$((i+1)); done; echo $i", want: "2\n"},
+		{name: "for", src: `for x in a "b c" $(echo d e); do echo "<$x>"; done`, want: "<a>\n<b c>\n<d>\n<e>\n"},
+		{name: "for over positional parameters", src: "set -- p q; for x; do echo $x; done", want: "p\nq\n"},
+		{name: "arithmetic for", src: "for ((i = 0; i < 3; i++)); do echo $i; done", want: "0\n1\n2\n"},
+		{name: "break", src: "for x in 1 2 3; do [ $x = 2 ] && break; echo $x; done", want: "1\n"},
+		{name: "continue", src: "for x in 1 2 3; do [ $x = 2 ] && continue; echo $x; done", want: "1\n3\n"},
+		{name: "break 2", src: "for a in 1 2; do for b in x y; do echo $a$b; break 2; done; done", want: "1x\n"},
+		{name: "continue 2", src: "for a in 1 2; do for b in x y; do echo $a$b; continue 2; done; done", want: "1x\n2x\n"},
+		{name: "case", src: "case foo.txt in *.go) echo go;; *.txt|*.md) echo text;; esac", want: "text\n"},
+		{name: "case quoted pattern", src: `p='*'; case abc in "$p") echo star;; $p) echo any;; esac`, want: "any\n"},
+		{name: "brace group", src: "{ echo a; echo b; } | wc -l", want: "2\n"},
+		{name: "subshell keeps state", src: "x=1; (x=2; cd /); echo $x", want: "1\n"},
+		{name: "arithmetic command", src: "((2 > 1)) && echo yes; ((0))", want: "yes\n", status: 1},
+		{name: "status of last command", src: "false; true; false", want: "", status: 1},
+		{name: "exit stops the script", src: "echo a; exit 4; echo b", want: "a\n", status: 4},
+		{name: "exit in a loop", src: "while true; do exit 3; done; echo no", want: "", status: 3},
+		{name: "exit in a subshell", src: "(exit 6); echo $?", want: "6\n"},
+		{name: "multi-line constructs", src: "if true\nthen\n  for x in a b\n  do\n    echo $x\n  done\nfi\n", want: "a\nb\n"},
+		{name: "comments", src: "# comment\necho a # trailing\n", want: "a\n"},
+		{name: "line continuation", src: "echo a \\\n  b\n", want: "a b\n"},
+		{name: "LINENO", src: "echo $LINENO\n\necho $LINENO", want: "1\n3\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, status := runTestScript(t, sh, tt.src)
+			if strings.TrimLeft(got, " ") != tt.want || status != tt.status {
+				t.Errorf("output %q, status %d; want %q, %d", got, status, tt.want, tt.status)
+			}
+		})
+	}
+}
+
+func TestRunFunctions(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name   string
+		src    string
+		want   string
+		status int
+	}{
+		{name: "call with arguments", src: `f() { echo "$# $1 $2"; }; f a "b c"`, want: "2 a b c\n"},
+		{name: "function keyword", src: "function f { echo hi; }; f", want: "hi\n"},
+		{name: "positional parameters restored", src: "set -- x; f() { echo $1; }; f y; echo $1", want: "y\nx\n"},
+		{name: "return status", src: "f() { return 3; echo no; }; f; echo $?", want: "3\n"},
+		{name: "return inside a loop", src: "f() { for x in a b; do return 2; done; }; f", want: "", status: 2},
+		{name: "return defaults to last status", src: "f() { false; return; }; f", want: "", status: 1},
+		{name: "local", src: "x=g; f() { local x=l; echo $x; }; f; echo $x", want: "l\ng\n"},
+		{name: "local unset after return", src: "f() { local y=1; }; f; echo ${y-unset}", want: "unset\n"},
+		{name: "declare is local in functions", src: "f() { declare x=l; declare -g y=g; }; f; echo ${x-unset} $y", want: "unset g\n"},
+		{name: "dynamic scope", src: "g() { echo $x; }; f() { local x=f; g; }; f", want: "f\n"},
+		{name: "global assignment", src: "f() { x=set; }; f; echo $x", want: "set\n"},
+		{name: "recursion", src: "f() { (( $1 > 0 )) || return 0; echo $1; f $(( $1 - 1 )); }; f 2", want: "2\n1\n"},
+		{name: "function in a pipeline", src: "f() { echo a; echo b; }; f | wc -l", want: "2\n"},
+		{name: "function wins over builtin", src: "echo() { printf 'wrapped\\n'; }; echo x", want: "wrapped\n"},
+		{name: "break in function does not leak", src: "f() { break 2>/dev/null; }; for x in a b; do f; echo $x; done", want: "a\nb\n"},
+		{name: "unset -f", src: "f() { echo hi; }; unset -f f; f 2>/dev/null", want: "f: command not found\n", status: 127},
+		{name: "redirected body", src: "f() { echo hidden; } > /dev/null; f", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, status := runTestScript(t, sh, tt.src)
+			if strings.TrimLeft(got, " ") != tt.want || status != tt.status {
+				t.Errorf("output %q, status %d; want %q, %d", got, status, tt.want, tt.status)
+			}
+		})
+	}
+}
+
+func TestControlBuiltinErrors(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		src     string
+		wantErr string
+		status  int
+	}{
+		{src: "break", wantErr: "break: only meaningful in a `for', `while', or `until' loop\n"},
+		{src: "return", wantErr: "return: can only `return' from a function or sourced script\n", status: 1},
+		{src: "local x", wantErr: "local: can only be used in a function\n", status: 1},
+		{src: "for x in a; do break 0; done", wantErr: "break: 0: loop count out of range\n", status: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.src, func(t *testing.T) {
+			var status int
+			got := captureStderr(t, sh, func() { _, status = runTestScript(t, sh, tt.src) })
+			if got != tt.wantErr || status != tt.status {
+				t.Errorf("stderr %q, status %d; want %q, %d", got, status, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,450 @@
+// interpreter.go — the state of a shell, and Interpreter, the shell as
+// an embeddable value.
+//
+//	newInterp           a shell's state: variables from an environment,
+//	                    the standard builtins, a working directory
+//	(*interp).clone     a copy of it, for a subshell
+//	New(options...)     an Interpreter: a fresh shell for Go code to run
+//	                    scripts in
+//	(*Interpreter).Run  run a script in it; state carries over between runs
+//
+// Everything a script can change lives in an *interp: variables,
+// functions, aliases, options, traps, the working directory and the file
+// descriptor table its commands read and write. The builtins and the
+// executor are its methods. Subshells, $(...) and the elements of a
+// pipeline run in clones, so that nothing they do leaks back, and a
+// pipeline's elements can run on their own goroutines.
+//
+// The process's working directory and os.Stdin, os.Stdout and os.Stderr
+// are never changed. A command gets the shell's directory and file
+// descriptors through its exec.Cmd (or, for a builtin, through the
+// interp), and file names are resolved against the shell's directory
+// (path). Main runs one interp on the process's streams; each Interpreter
+// has its own, so instances are independent and run in parallel.
+
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// interp is the state of one shell.
+type interp struct {
+	vars        *varTable
+	params      *shellParams
+	functions   map[string]*funcNode // by name (functions.go)
+	aliases     map[string]string    // alias values by name (alias.go)
+	abbrs       map[string]string    // abbreviations (abbr.go)
+	abbrRCFile  string               // where abbr saves definitions; "" for nowhere
+	registry    map[string]command   // the builtins (commands.go)
+	hist        *history
+	commandTrie *trie                 // command names for TAB (completer.go)
+	options     map[*shellOption]bool // the options that are on
+	dir         string                // working directory, absolute
+	dirStack    []string              // pushd's stack, below dir (dirstack.go)
+	zMemory     []zEntry              // z's records without HISTFILE (z.go)
+	fds         []*os.File            // fds[n] is file descriptor n; nil if closed
+	execFiles   []*os.File            // files exec opened for fds (redirect.go)
+	jobs        []*job                // background jobs (jobs.go)
+
+	flow        flowState
+	errexitOff  int               // positive where errexit does not apply
+	traps       map[string]string // handlers by condition (trap.go)
+	inTrap      bool              // a handler is running; pseudo-signals are off
+	getoptsPos  int               // getopts' place in a cluster (getopt.go)
+	getoptsLast string
+
+	// ctx is the context of the current run. External commands are
+	// killed, and the shell exits between commands, when it is done.
+	ctx context.Context
+
+	// execHook, if set, runs external commands in place of exec.Cmd.Run.
+	execHook ExecHook
+
+	// embedded is set in an Interpreter: exec runs its command and ends
+	// the run rather than replacing the process, traps do not install
+	// signal handlers, and cd does not record visits for z.
+	embedded bool
+
+	// async is set in the subshell of a background job, which runs
+	// alongside the shell and so leaves signals to it.
+	async bool
+}
+
+// newInterp returns a shell that has not run anything yet, in dir (an
+// absolute path), with the variables in env exported, the standard
+// builtins, every option off, and the process's standard streams as file
+// descriptors 0, 1 and 2.
+func newInterp(env []string, dir string) *interp {
+	sh := &interp{
+		params:    &shellParams{argv0: "gosh"},
+		functions: map[string]*funcNode{},
+		aliases:   map[string]string{},
+		abbrs:     map[string]string{},
+		hist:      newHistory(),
+		options:   map[*shellOption]bool{},
+		dir:       dir,
+		fds:       []*os.File{os.Stdin, os.Stdout, os.Stderr},
+		traps:     map[string]string{},
+		ctx:       context.Background(),
+	}
+	sh.vars = newVarTable(env)
+	sh.initDynamicVars()
+	sh.initPWD()
+	sh.newRegistry()
+	return sh
+}
+
+// clone returns a copy of sh that can change without affecting sh, and
+// run on another goroutine. Only the history and the completion trie,
+// which subshells do not change, are shared.
+func (sh *interp) clone() *interp {
+	c := new(interp)
+	*c = *sh
+	c.vars = sh.vars.clone()
+	c.vars.owner = c
+	params := *sh.params
+	params.positional = slices.Clone(params.positional)
+	c.params = &params
+	c.functions = maps.Clone(sh.functions)
+	c.aliases = maps.Clone(sh.aliases)
+	c.abbrs = maps.Clone(sh.abbrs)
+	c.registry = maps.Clone(sh.registry)
+	c.options = maps.Clone(sh.options)
+	c.dirStack = slices.Clone(sh.dirStack)
+	c.zMemory = slices.Clone(sh.zMemory)
+	c.fds = slices.Clone(sh.fds)
+	c.execFiles = nil
+	c.jobs = nil
+	c.traps = maps.Clone(sh.traps)
+	return c
+}
+
+// fd returns the file open as file descriptor n, or nil if it is closed.
+func (sh *interp) fd(n int) *os.File {
+	if n < len(sh.fds) {
+		return sh.fds[n]
+	}
+	return nil
+}
+
+// stdin, stdout and stderr return file descriptors 0, 1 and 2.
+func (sh *interp) stdin() *os.File  { return sh.fd(0) }
+func (sh *interp) stdout() *os.File { return sh.fd(1) }
+func (sh *interp) stderr() *os.File { return sh.fd(2) }
+
+// withFds runs fn with fds as the shell's file descriptor table.
+func (sh *interp) withFds(fds []*os.File, fn func() int) int {
+	saved := sh.fds
+	sh.fds = fds
+	defer func() { sh.fds = saved }()
+	return fn()
+}
+
+// path returns the file name name resolved against the shell's working
+// directory.
+func (sh *interp) path(name string) string {
+	if name == "" || filepath.IsAbs(name) {
+		return name
+	}
+	if strings.HasSuffix(sh.dir, "/") {
+		return sh.dir + name
+	}
+	return sh.dir + "/" + name
+}
+
+// openFile is os.OpenFile for a name relative to the shell's working
+// directory. Errors give the name as it was written.
+func (sh *interp) openFile(name string, flag int, perm os.FileMode) (*os.File, error) {
+	f, err := os.OpenFile(sh.path(name), flag, perm)
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		pe.Path = name
+	}
+	return f, err
+}
+
+// Interpreter is a shell session that Go code can run scripts in.
+type Interpreter struct {
+	mu     sync.Mutex // held by Run
+	sh     *interp
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	in     *os.File // file descriptor 0 of every run, from the first
+
+	// Settings for New.
+	env      []string
+	dir      string
+	builtins map[string]Builtin
+	without  []string
+	execHook ExecHook
+}
+
+// Option configures an Interpreter created by New.
+type Option func(*Interpreter)
+
+// Builtin is a builtin command provided by the program embedding the
+// shell. It is called with the arguments after the command name and the
+// command's standard streams, which reflect pipes and redirections, and
+// returns the exit status.
+type Builtin func(ctx context.Context, stdio Stdio, args []string) int
+
+// Stdio is the standard input, output and error of a command.
+type Stdio struct {
+	In  io.Reader
+	Out io.Writer
+	Err io.Writer
+}
+
+// ExecHook runs an external command for an Interpreter. cmd is ready to
+// run: its Path, Args, Env, Dir and standard streams are set. The hook
+// may change it, run it (cmd.Run), run something else, or refuse. An
+// *exec.ExitError gives the command's exit status; any other error is
+// reported as the command's and the status is 126. The commands of a
+// pipeline run at the same time, so the hook may be called concurrently.
+type ExecHook func(ctx context.Context, cmd *exec.Cmd) error
+
+// WithStdio sets the standard input, output and error of scripts run by
+// the Interpreter. A nil stream reads as empty or discards what is
+// written, which is also the default. Streams that are not *os.File are
+// connected through pipes. Each run reads on from the input where the
+// last one stopped.
+func WithStdio(in io.Reader, out, err io.Writer) Option {
+	return func(i *Interpreter) {
+		i.stdin, i.stdout, i.stderr = in, out, err
+	}
+}
+
+// WithEnv sets the environment the shell starts with, as NAME=value
+// strings; its variables are exported. The default is os.Environ().
+func WithEnv(env []string) Option {
+	return func(i *Interpreter) {
+		i.env = slices.Clone(env)
+	}
+}
+
+// WithDir sets the shell's initial working directory. The default is the
+// process's working directory when New is called.
+func WithDir(dir string) Option {
+	return func(i *Interpreter) {
+		i.dir = dir
+	}
+}
+
+// WithBuiltin adds the builtin name, replacing any standard builtin of
+// that name.
+func WithBuiltin(name string, b Builtin) Option {
+	return func(i *Interpreter) {
+		if i.builtins == nil {
+			i.builtins = map[string]Builtin{}
+		}
+		i.builtins[name] = b
+	}
+}
+
+// WithoutBuiltins removes the named standard builtins, so that the names
+// are looked up as functions and external commands instead.
+func WithoutBuiltins(names ...string) Option {
+	return func(i *Interpreter) {
+		i.without = append(i.without, names...)
+	}
+}
+
+// WithExecHook makes the Interpreter run external commands through hook.
+func WithExecHook(hook ExecHook) Option {
+	return func(i *Interpreter) {
+		i.execHook = hook
+	}
+}
+
+// New returns an Interpreter configured by opts, in the state of a
+// non-interactive shell that has just started: no startup files are read
+// and no plugins loaded.
+func New(opts ...Option) (*Interpreter, error) {
+	i := &Interpreter{env: os.Environ()}
+	for _, opt := range opts {
+		opt(i)
+	}
+	if i.dir == "" {
+		dir, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+		i.dir = dir
+	}
+	dir, err := filepath.Abs(i.dir)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s: not a directory", dir)
+	}
+
+	sh := newInterp(i.env, dir)
+	sh.embedded = true
+	sh.execHook = i.execHook
+	for _, name := range i.without {
+		delete(sh.registry, name)
+	}
+	for name, b := range i.builtins {
+		sh.registry[name] = command{
+			Name:     name,
+			Synopsis: name,
+			Run: func(sh *interp, args []string) int {
+				return b(sh.ctx, Stdio{In: sh.stdin(), Out: sh.stdout(), Err: sh.stderr()}, args)
+			},
+		}
+	}
+	i.sh = sh
+	return i, nil
+}
+
+// Run runs src as a script in the Interpreter and returns the status of
+// the last command, or of exit, which ends the run (after the EXIT trap)
+// but not the session. When ctx is done, external commands are killed
+// and the script stops before its next command; Run then returns
+// ctx.Err() with the status so far. Runs of one Interpreter take turns.
+func (i *Interpreter) Run(ctx context.Context, src string) (int, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	if i.in == nil {
+		in, err := openInput(i.stdin)
+		if err != nil {
+			return 1, err
+		}
+		i.in = in
+	}
+	stdio, closeStdio, err := openOutput(i.stdout, i.stderr)
+	if err != nil {
+		return 1, err
+	}
+	stdio[0] = i.in
+	defer closeStdio()
+	sh := i.sh
+	sh.fds, sh.ctx = stdio[:], ctx
+	defer func() {
+		sh.waitInternalJobs()
+		sh.closeExecFiles()
+		sh.fds, sh.ctx = nil, context.Background()
+	}()
+
+	status := sh.runLoop(lineReader(strings.NewReader(src)), false, sh.params.argv0)
+	if sh.flow.kind == flowExit {
+		status = sh.runExitTrap(status)
+	}
+	sh.flow = flowState{}
+	return status, ctx.Err()
+}
+
+// openInput returns a file for the stream in: in itself if it is an
+// *os.File, the null device if it is nil, and otherwise a pipe fed from it
+// by a goroutine. The Interpreter keeps the file for all its runs, so
+// what one run leaves unread is there for the next, and it is closed
+// only when the Interpreter is garbage collected.
+func openInput(in io.Reader) (*os.File, error) {
+	switch f, ok := in.(*os.File); {
+	case ok:
+		return f, nil
+	case in == nil:
+		return os.Open(os.DevNull)
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	// The copy ends when in does, or fails once r has been closed; a
+	// Read that never returns leaves it blocked.
+	go func() {
+		io.Copy(w, in)
+		w.Close()
+	}()
+	return r, nil
+}
+
+// openOutput returns files for the streams out and errw, as file
+// descriptors 1 and 2: each one itself if it is an *os.File, the null
+// device if it is nil, and otherwise a pipe drained into it by a
+// goroutine. closeAll closes what openOutput opened, once everything
+// written has been copied.
+func openOutput(out, errw io.Writer) (files [3]*os.File, closeAll func(), err error) {
+	var closers []func()
+	closeAll = func() {
+		for _, c := range slices.Backward(closers) {
+			c()
+		}
+	}
+
+	for n, wr := range []io.Writer{out, errw} {
+		if n == 1 && sameWriter(errw, out) {
+			files[2] = files[1] // one pipe, so writes stay in order
+			break
+		}
+		switch f, ok := wr.(*os.File); {
+		case ok:
+			files[n+1] = f
+		case wr == nil:
+			if files[n+1], err = os.OpenFile(os.DevNull, os.O_WRONLY, 0); err != nil {
+				closeAll()
+				return files, func() {}, err
+			}
+			f := files[n+1]
+			closers = append(closers, func() { f.Close() })
+		default:
+			r, w, err := os.Pipe()
+			if err != nil {
+				closeAll()
+				return files, func() {}, err
+			}
+			copied := make(chan struct{})
+			go func() {
+				io.Copy(wr, r)
+				r.Close()
+				close(copied)
+			}()
+			files[n+1] = w
+			closers = append(closers, func() {
+				w.Close()
+				<-copied
+			})
+		}
+	}
+	return files, closeAll, nil
+}
+
+// sameWriter reports whether a and b are the same writer, without
+// panicking on writers that cannot be compared.
+func sameWriter(a, b io.Writer) (same bool) {
+	defer func() { recover() }()
+	return a == b
+}
+
+// runExec runs cmd in the foreground, through the exec hook if there is
+// one, and returns its status. A hook's failure to run it is reported on
+// the command's standard error.
+func (sh *interp) runExec(cmd *exec.Cmd) int {
+	if sh.execHook == nil {
+		return exitStatus(cmd.Run())
+	}
+	err := sh.execHook(sh.ctx, cmd)
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		fmt.Fprintf(cmd.Stderr, "%s: %v\n", cmd.Args[0], err)
+		return 126
+	}
+	return exitStatus(err)
+}
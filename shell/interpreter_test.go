@@ -0,0 +1,308 @@
+package shell
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestInterpreter returns an Interpreter that writes to out and errOut
+// (the same buffer if errOut is nil), with PATH as its only variable.
+func newTestInterpreter(t *testing.T, out, errOut *bytes.Buffer, opts ...Option) *Interpreter {
+	t.Helper()
+	if errOut == nil {
+		errOut = out
+	}
+	opts = append([]Option{WithStdio(nil, out, errOut), WithEnv([]string{"PATH=" + os.Getenv("PATH")})}, opts...)
+	sh, err := New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sh
+}
+
+func TestInterpreter(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		opts   []Option
+		src    string
+		want   string
+		status int
+	}{
+		{name: "output", src: "echo hi; sh -c 'echo err >&2'", want: "hi\nerr\n"},
+		{name: "status", src: "false", status: 1},
+		{name: "exit ends the run", src: "trap 'echo bye' EXIT; exit 3; echo no", want: "bye\n", status: 3},
+		{name: "pipeline", src: "printf 'b\\na\\n' | sort | head -n1", want: "a\n"},
+		{name: "environment", opts: []Option{WithEnv([]string{"PATH=" + os.Getenv("PATH"), "GREETING=hello"})}, src: `echo $GREETING; sh -c 'echo $GREETING'`, want: "hello\nhello\n"},
+		{name: "directory", opts: []Option{WithDir(dir)}, src: "pwd; sh -c pwd", want: dir + "\n" + dir + "\n"},
+		{
+			name: "builtin",
+			opts: []Option{WithBuiltin("shout", func(ctx context.Context, stdio Stdio, args []string) int {
+				fmt.Fprintln(stdio.Out, strings.ToUpper(strings.Join(args, " ")))
+				return len(args)
+			})},
+			src:  "shout a b; echo $?; shout c | tr C D; shout >/dev/null",
+			want: "A B\n2\nD\n",
+		},
+		{name: "without builtins", opts: []Option{WithoutBuiltins("pushd")}, src: "pushd .", want: "pushd: command not found\n", status: 127},
+		{name: "syntax error", src: "if", want: "gosh: line 1: syntax error: unexpected end of file\n", status: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			sh := newTestInterpreter(t, &out, nil, tt.opts...)
+			status, err := sh.Run(context.Background(), tt.src)
+			if err != nil || out.String() != tt.want || status != tt.status {
+				t.Errorf("got %q, status %d, error %v; want %q, %d", out.String(), status, err, tt.want, tt.status)
+			}
+		})
+	}
+}
+
+func TestInterpreterStdin(t *testing.T) {
+	var out bytes.Buffer
+	sh := newTestInterpreter(t, &out, nil, WithStdio(strings.NewReader("line one\n"), &out, &out))
+	if _, err := sh.Run(context.Background(), `read -r x; echo "[$x]"`); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != "[line one]\n" {
+		t.Errorf("got %q", got)
+	}
+
+	// Each run reads on where the last one stopped.
+	out.Reset()
+	sh = newTestInterpreter(t, &out, nil, WithStdio(strings.NewReader("a\nb\n"), &out, &out))
+	for n := 1; n <= 2; n++ {
+		if _, err := sh.Run(context.Background(), fmt.Sprintf("read l; echo %d:$l", n)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := out.String(); got != "1:a\n2:b\n" {
+		t.Errorf("two runs got %q", got)
+	}
+}
+
+func TestInterpreterCancelRead(t *testing.T) {
+	osPipe := func() (io.Reader, io.Writer, func()) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return r, w, func() { r.Close(); w.Close() }
+	}
+	blockingPipe := func() (io.Reader, io.Writer, func()) {
+		r, w, done := osPipe()
+		r.(*os.File).Fd() // puts it in blocking mode: no deadlines
+		return r, w, done
+	}
+	goPipe := func() (io.Reader, io.Writer, func()) {
+		r, w := io.Pipe()
+		return r, w, func() { w.Close() }
+	}
+	for name, newPipe := range map[string]func() (io.Reader, io.Writer, func()){"os pipe": osPipe, "blocking pipe": blockingPipe, "reader": goPipe} {
+		t.Run(name, func(t *testing.T) {
+			r, w, done := newPipe()
+			defer done()
+			var out bytes.Buffer
+			sh := newTestInterpreter(t, &out, nil, WithStdio(r, &out, &out))
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			start := time.Now()
+			_, err := sh.Run(ctx, "read x; echo no")
+			cancel()
+			if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second || out.String() != "" {
+				t.Fatalf("Run = error %v after %v, output %q", err, time.Since(start), out.String())
+			}
+			// The next run reads normally.
+			go io.WriteString(w, "later\n")
+			if _, err := sh.Run(context.Background(), "read x; echo $x"); err != nil || out.String() != "later\n" {
+				t.Errorf("after cancelling, got %q, error %v", out.String(), err)
+			}
+		})
+	}
+}
+
+func TestInterpreterSession(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	cwd, _ := os.Getwd()
+	var outA, outB bytes.Buffer
+	a := newTestInterpreter(t, &outA, nil, WithDir(dir))
+	b := newTestInterpreter(t, &outB, nil, WithDir(dir))
+
+	steps := []struct {
+		sh   *Interpreter
+		src  string
+		out  *bytes.Buffer
+		want string
+	}{
+		{a, "x=1; f() { echo f$1; }; shopt -s expand_aliases; alias l=echo; set -u; cd sub", &outA, ""},
+		{b, `echo "${x-unset}" "$(type -t f l)" "${PWD#` + dir + `}"; echo $y`, &outB, "unset  \n\n"},
+		{a, `echo $x "$(f 2)" "$(l 3)" "${PWD#` + dir + `}"`, &outA, "1 f2 3 /sub\n"},
+		{a, `echo $y`, &outA, "y: unbound variable\n"},
+	}
+	for _, s := range steps {
+		s.out.Reset()
+		if _, err := s.sh.Run(context.Background(), s.src); err != nil {
+			t.Fatal(err)
+		}
+		if got := s.out.String(); got != s.want {
+			t.Errorf("Run(%q) wrote %q, want %q", s.src, got, s.want)
+		}
+	}
+	if now, _ := os.Getwd(); now != cwd {
+		t.Errorf("working directory is %s after the runs, want %s", now, cwd)
+	}
+}
+
+func TestInterpreterExecHook(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	var ran [][]string
+	hook := func(ctx context.Context, cmd *exec.Cmd) error {
+		mu.Lock()
+		ran = append(ran, cmd.Args)
+		mu.Unlock()
+		switch cmd.Args[0] {
+		case "cat":
+			return errors.New("not allowed")
+		case "sh", "tr":
+			return cmd.Run()
+		}
+		fmt.Fprintln(cmd.Stdout, "ran", strings.Join(cmd.Args, " "))
+		return nil
+	}
+	sh := newTestInterpreter(t, &out, nil, WithExecHook(hook))
+	src := "ls -l; ls | tr a-z A-Z; sh -c 'exit 4'; echo $?; cat /nonexistent; echo $?; exec ls x; echo no"
+	status, err := sh.Run(context.Background(), src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "ran ls -l\nRAN LS\n4\ncat: not allowed\n126\nran ls x\n"
+	if out.String() != want || status != 0 {
+		t.Errorf("got %q, status %d; want %q", out.String(), status, want)
+	}
+	if len(ran) != 6 {
+		t.Errorf("hook ran %q", ran)
+	}
+}
+
+func TestInterpreterCancel(t *testing.T) {
+	for _, src := range []string{"while :; do :; done", "sleep 10; echo no"} {
+		var out bytes.Buffer
+		sh := newTestInterpreter(t, &out, nil)
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		start := time.Now()
+		_, err := sh.Run(ctx, src)
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second || out.String() != "" {
+			t.Errorf("Run(%q) = error %v after %v, output %q", src, err, time.Since(start), out.String())
+		}
+		// The session survives.
+		if status, err := sh.Run(context.Background(), "echo ok"); err != nil || status != 0 || out.String() != "ok\n" {
+			t.Errorf("after cancelling, got %q, status %d, error %v", out.String(), status, err)
+		}
+	}
+}
+
+func TestInterpreterParallel(t *testing.T) {
+	for n := range 4 {
+		t.Run(strconv.Itoa(n), func(t *testing.T) {
+			t.Parallel()
+			var out bytes.Buffer
+			sh := newTestInterpreter(t, &out, nil)
+			for range 5 {
+				if _, err := sh.Run(context.Background(), "count=$((count + "+strconv.Itoa(n)+"))"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			sh.Run(context.Background(), "echo $count")
+			if got, want := out.String(), strconv.Itoa(5*n)+"\n"; got != want {
+				t.Errorf("got %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestInterpreterConcurrent(t *testing.T) {
+	// Each run waits in meet until the other has reached it, which only
+	// works if the runs overlap.
+	var met sync.WaitGroup
+	met.Add(2)
+	meet := func(ctx context.Context, stdio Stdio, args []string) int {
+		met.Done()
+		done := make(chan struct{})
+		go func() {
+			met.Wait()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return 0
+		case <-ctx.Done():
+			return 1
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cwd, _ := os.Getwd()
+	dirs := []string{t.TempDir(), t.TempDir()}
+	outs := make([]bytes.Buffer, len(dirs))
+	var wg sync.WaitGroup
+	for i, dir := range dirs {
+		sh := newTestInterpreter(t, &outs[i], nil, WithDir(dir), WithBuiltin("meet", meet))
+		wg.Go(func() {
+			sh.Run(ctx, "mkdir sub && cd sub && meet && echo "+strconv.Itoa(i)+" >f && cat f && pwd")
+		})
+	}
+	wg.Wait()
+	for i, dir := range dirs {
+		if got, want := outs[i].String(), fmt.Sprintf("%d\n%s/sub\n", i, dir); got != want {
+			t.Errorf("instance %d: got %q, want %q", i, got, want)
+		}
+	}
+	if wd, _ := os.Getwd(); wd != cwd {
+		t.Errorf("process working directory changed to %q", wd)
+	}
+}
+
+func TestInterpreterSignalTrap(t *testing.T) {
+	var out bytes.Buffer
+	sh := newTestInterpreter(t, &out, nil)
+	if status, err := sh.Run(context.Background(), "trap 'echo t' TERM; trap -p TERM"); err != nil || status != 0 {
+		t.Fatalf("status %d, error %v", status, err)
+	}
+	if got, want := out.String(), "trap -- 'echo t' SIGTERM\n"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	trapMu.Lock()
+	defer trapMu.Unlock()
+	if trappedSigs["TERM"] {
+		t.Error("an Interpreter's trap installed a signal handler")
+	}
+}
+
+func TestNewBadDir(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{file, filepath.Join(file, "missing")} {
+		if _, err := New(WithDir(dir)); err == nil {
+			t.Errorf("New(WithDir(%q)) succeeded", dir)
+		}
+	}
+}
@@ -0,0 +1,214 @@
+// jobs.go — background jobs: the job table and the jobs and wait builtins.
+//
+//	jobs [-p] [ID...]  list background jobs: number, state and command
+//	                   (-p: only the pid of each job's last process)
+//	wait [ID...]       wait for each job ID (a pid, or %N), or for every
+//	                   job, and return the status of the last one
+//
+// A list ending in '&' becomes a job. Its external commands are
+// processes; its builtins, functions and compound commands run in a
+// subshell on a goroutine, which leaves signals to the main shell. $! is
+// the pid of the job's last external process and stays as it was for a
+// job without one, which wait takes by number instead. Finished jobs stay
+// in the table until jobs or wait has reported them. A job running inside
+// the shell process would not outlive it the way a forked subshell does,
+// so the shell waits for such jobs before it exits.
+
+package shell
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// job is a background list.
+type job struct {
+	id       int
+	text     string        // the command, as jobs shows it
+	pid      int           // its last external process, or 0
+	internal bool          // some of it runs inside the shell process
+	done     chan struct{} // closed once it has finished
+	status   int           // valid once done is closed
+}
+
+// finished reports whether j has finished.
+func (j *job) finished() bool {
+	select {
+	case <-j.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// startJob adds a job for text to the table and runs run, which returns
+// its status, on a goroutine. A job with an external process sets $!.
+func (sh *interp) startJob(text string, pid int, internal bool, run func() int) {
+	j := &job{id: 1, text: text, pid: pid, internal: internal, done: make(chan struct{})}
+	for _, other := range sh.jobs {
+		j.id = max(j.id, other.id+1)
+	}
+	sh.jobs = append(sh.jobs, j)
+	if pid != 0 {
+		sh.params.lastBg = pid
+	}
+	go func() {
+		defer close(j.done)
+		j.status = run()
+	}()
+}
+
+// waitInternalJobs waits for the jobs that run inside the shell process.
+func (sh *interp) waitInternalJobs() {
+	for _, j := range sh.jobs {
+		if j.internal {
+			<-j.done
+		}
+	}
+}
+
+// findJob returns the job that id (%N, %%, %+ or a pid) names, or nil.
+func (sh *interp) findJob(id string) *job {
+	if id == "%%" || id == "%+" {
+		if len(sh.jobs) == 0 {
+			return nil
+		}
+		return sh.jobs[len(sh.jobs)-1]
+	}
+	num, isJobNum := strings.CutPrefix(id, "%")
+	n, err := strconv.Atoi(num)
+	if err != nil {
+		return nil
+	}
+	for _, j := range sh.jobs {
+		if isJobNum && j.id == n || !isJobNum && j.pid == n {
+			return j
+		}
+	}
+	return nil
+}
+
+// forgetJob removes j from the job table.
+func (sh *interp) forgetJob(j *job) {
+	sh.jobs = slices.DeleteFunc(sh.jobs, func(other *job) bool { return other == j })
+}
+
+// builtinJobs implements jobs.
+func (sh *interp) builtinJobs(args []string) int {
+	opts, args, ok := sh.parseOptions("jobs", "p", args)
+	if !ok {
+		return 2
+	}
+	pidsOnly := len(opts) > 0
+	jobs, status := slices.Clone(sh.jobs), 0
+	if len(args) > 0 {
+		jobs = nil
+		for _, arg := range args {
+			if j := sh.findJob(arg); j != nil {
+				jobs = append(jobs, j)
+				continue
+			}
+			fmt.Fprintf(sh.stderr(), "jobs: %s: no such job\n", arg)
+			status = 1
+		}
+	}
+	for _, j := range jobs {
+		if pidsOnly {
+			if j.pid != 0 {
+				fmt.Fprintln(sh.stdout(), j.pid)
+			}
+			continue
+		}
+		state := "Running"
+		if j.finished() {
+			state = "Done"
+			if j.status != 0 {
+				state = fmt.Sprintf("Exit %d", j.status)
+			}
+			sh.forgetJob(j) // reported
+		}
+		fmt.Fprintf(sh.stdout(), "[%d]  %-24s%s &\n", j.id, state, j.text)
+	}
+	return status
+}
+
+// builtinWait implements wait. Cancelling the shell's context stops the
+// wait with status 1.
+func (sh *interp) builtinWait(args []string) int {
+	_, args, ok := sh.parseOptions("wait", "", args)
+	if !ok {
+		return 2
+	}
+	if len(args) == 0 {
+		for _, j := range sh.jobs {
+			if !sh.awaitJob(j) {
+				return 1
+			}
+		}
+		sh.jobs = nil
+		return 0
+	}
+	status := 0
+	for _, arg := range args {
+		j := sh.findJob(arg)
+		switch {
+		case j != nil:
+			if !sh.awaitJob(j) {
+				return 1
+			}
+			status = j.status
+			sh.forgetJob(j)
+		case strings.HasPrefix(arg, "%"):
+			fmt.Fprintf(sh.stderr(), "wait: %s: no such job\n", arg)
+			status = 127
+		case !isAllDigits(arg):
+			fmt.Fprintf(sh.stderr(), "wait: `%s': not a pid or valid job spec\n", arg)
+			status = 2
+		default:
+			fmt.Fprintf(sh.stderr(), "wait: pid %s is not a child of this shell\n", arg)
+			status = 127
+		}
+	}
+	return status
+}
+
+// awaitJob waits for j to finish and reports whether it did before the
+// shell's context was done.
+func (sh *interp) awaitJob(j *job) bool {
+	select {
+	case <-j.done:
+		return true
+	case <-sh.ctx.Done():
+		return false
+	}
+}
+
+// andOrText and pipeText return the source text of a list and a
+// pipeline, for jobs.
+func andOrText(ao *andOr) string {
+	var b strings.Builder
+	for i, pl := range ao.pipes {
+		if i > 0 {
+			b.WriteString(" " + ao.ops[i-1] + " ")
+		}
+		b.WriteString(pipeText(pl.negate, pl.cmds))
+	}
+	return b.String()
+}
+
+func pipeText(negate bool, cmds []*cmdNode) string {
+	texts := make([]string, len(cmds))
+	for i, c := range cmds {
+		texts[i] = strings.TrimSpace(c.raw)
+		if c.redirs != "" {
+			texts[i] += " " + c.redirs
+		}
+	}
+	text := strings.Join(texts, " | ")
+	if negate {
+		text = "! " + text
+	}
+	return text
+}
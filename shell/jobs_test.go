@@ -0,0 +1,46 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestJobs(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "subshell runs in the background", src: "(sleep 0.2; echo x) & echo y; wait", want: "y\nx\n"},
+		{name: "function runs in the background", src: "f() { sleep 0.2; echo f; }; f & echo y; wait", want: "y\nf\n"},
+		{name: "and-or list runs in the background", src: "true && sleep 0.2 && echo a & echo b; wait", want: "b\na\n"},
+		{name: "wait for a job by number", src: "f() { return 3; }; f & wait %1; echo $?", want: "3\n"},
+		{name: "wait for a pid", src: "sh -c 'exit 4' & wait $!", status: 4},
+		{name: "jobs lists and forgets finished jobs", src: "{ false; } & true & sleep 0.1; jobs; jobs", want: "[1]  Exit 1                  { false; } &\n[2]  Done                    true &\n"},
+		{name: "unknown job", src: "wait %3", wantErr: "wait: %3: no such job\n", status: 127},
+		{name: "unknown pid", src: "wait 1", wantErr: "wait: pid 1 is not a child of this shell\n", status: 127},
+		{name: "bad id", src: "wait x", wantErr: "wait: `x': not a pid or valid job spec\n", status: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sh.jobs = nil
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if strings.TrimLeft(got, " ") != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
+
+func TestJobsPids(t *testing.T) {
+	sh := newTestShell(t)
+	got, _ := runTestScript(t, sh, "sleep 0.1 & true & jobs -p; echo $!; wait")
+	if pids := strings.Fields(got); len(pids) != 2 || pids[0] != pids[1] || pids[0] == "0" {
+		t.Errorf("jobs -p and $! = %q, want the same pid twice", got)
+	}
+}
@@ -0,0 +1,285 @@
+// options.go — shell options: set -o (with single-letter forms) and shopt.
+//
+//	set -e  errexit   exit when a command fails, except in conditions
+//	set -f  noglob    no pathname expansion
+//	set -u  nounset   expanding an unset parameter is an error
+//	set -v  verbose   echo input lines to stderr as they are read
+//	set -x  xtrace    print each command to stderr, after PS4, once
+//	                  expanded
+//	set -o pipefail   a pipeline's status is that of its last failing
+//	                  command
+//
+//	shopt -s dotglob   pathname expansion matches names starting with '.'
+//	shopt -s expand_aliases
+//	                   expand aliases (alias.go); on in interactive shells
+//	shopt -s failglob  a pattern that matches nothing is an error
+//	shopt -s nullglob  a pattern that matches nothing expands to nothing
+//	shopt -s xpg_echo  echo expands backslash escapes without -e
+//
+// errexit ignores failures where the status is being tested: the
+// conditions of if, while and until, every pipeline of an && or || list
+// but the last, and pipelines negated with '!'. Commands run from inside
+// such a context (a function called as an if condition, say) are exempt
+// too; errexitOff counts how many of these contexts are active.
+
+package shell
+
+import (
+	"fmt"
+	"strings"
+)
+
+// shellOption is one on/off option.
+type shellOption struct {
+	name string
+	flag byte // letter for set -X and $-; 0 if none
+}
+
+var (
+	optErrexit  = &shellOption{name: "errexit", flag: 'e'}
+	optNoglob   = &shellOption{name: "noglob", flag: 'f'}
+	optNounset  = &shellOption{name: "nounset", flag: 'u'}
+	optPipefail = &shellOption{name: "pipefail"}
+	optVerbose  = &shellOption{name: "verbose", flag: 'v'}
+	optXtrace   = &shellOption{name: "xtrace", flag: 'x'}
+
+	optDotglob       = &shellOption{name: "dotglob"}
+	optExpandAliases = &shellOption{name: "expand_aliases"}
+	optFailglob      = &shellOption{name: "failglob"}
+	optNullglob      = &shellOption{name: "nullglob"}
+	optXpgEcho       = &shellOption{name: "xpg_echo"}
+)
+
+// setOptions are the options of set -o; shoptOptions those of shopt.
+// Both are sorted by name, which is the order they are listed in.
+var (
+	setOptions   = []*shellOption{optErrexit, optNoglob, optNounset, optPipefail, optVerbose, optXtrace}
+	shoptOptions = []*shellOption{optDotglob, optExpandAliases, optFailglob, optNullglob, optXpgEcho}
+)
+
+// findOption returns the option in opts called name, or nil.
+func findOption(opts []*shellOption, name string) *shellOption {
+	for _, o := range opts {
+		if o.name == name {
+			return o
+		}
+	}
+	return nil
+}
+
+// optionFlags returns the letters of the set options that are on.
+func (sh *interp) optionFlags() string {
+	var b strings.Builder
+	for _, o := range setOptions {
+		if sh.options[o] && o.flag != 0 {
+			b.WriteByte(o.flag)
+		}
+	}
+	return b.String()
+}
+
+// checkErrexit ends the shell after a command failed with status when
+// errexit is on and the failure is not being tested.
+func (sh *interp) checkErrexit(status int) {
+	if status != 0 && sh.options[optErrexit] && sh.errexitOff == 0 && sh.flow.kind == flowNone {
+		sh.flow.kind = flowExit
+	}
+}
+
+// unboundVar reports a reference to the unset parameter name under set -u.
+// A non-interactive shell exits, as POSIX requires.
+func (sh *interp) unboundVar(name string) error {
+	if !sh.params.interactive {
+		sh.flow.kind = flowExit
+	}
+	return fmt.Errorf("%s: unbound variable", name)
+}
+
+// traceCommand prints a simple command for set -x: PS4 (default "+ "),
+// then its assignments with their new values and its words, quoted where
+// needed. It is called while the assignments are in effect.
+func (sh *interp) traceCommand(assigns []assignment, words []string) {
+	if !sh.options[optXtrace] {
+		return
+	}
+	var parts []string
+	for _, a := range assigns {
+		if a.Index != "" || a.isCompound() {
+			parts = append(parts, a.Name+"="+a.Value)
+			continue
+		}
+		parts = append(parts, a.Name+"="+shellQuote(sh.getVar(a.Name)))
+	}
+	for _, w := range words {
+		parts = append(parts, shellQuote(w))
+	}
+	sh.trace(strings.Join(parts, " "))
+}
+
+// trace prints line after PS4 when set -x is on.
+func (sh *interp) trace(line string) {
+	if !sh.options[optXtrace] {
+		return
+	}
+	ps4, ok := sh.vars.Get("PS4")
+	if !ok {
+		ps4 = "+ "
+	} else if exp, err := sh.expandWord(ps4); err == nil {
+		ps4 = exp
+	}
+	fmt.Fprintf(sh.stderr(), "%s%s\n", ps4, line)
+}
+
+// printOptions lists opts for set -o and shopt: as a table of names and
+// states, or with asCommands as the commands that would restore them.
+// onlyState, if non-nil, limits the list to options in that state.
+func (sh *interp) printOptions(opts []*shellOption, asCommands bool, cmd string, onlyState *bool) {
+	for _, o := range opts {
+		if onlyState != nil && sh.options[o] != *onlyState {
+			continue
+		}
+		sh.printOption(o, asCommands, cmd)
+	}
+}
+
+// printOption prints one line of printOptions. cmd is "set" or "shopt".
+func (sh *interp) printOption(o *shellOption, asCommands bool, cmd string) {
+	if !asCommands {
+		state := "off"
+		if sh.options[o] {
+			state = "on"
+		}
+		fmt.Fprintf(sh.stdout(), "%-15s\t%s\n", o.name, state)
+		return
+	}
+	switch {
+	case cmd == "set" && sh.options[o]:
+		fmt.Fprintf(sh.stdout(), "set -o %s\n", o.name)
+	case cmd == "set":
+		fmt.Fprintf(sh.stdout(), "set +o %s\n", o.name)
+	case sh.options[o]:
+		fmt.Fprintf(sh.stdout(), "shopt -s %s\n", o.name)
+	default:
+		fmt.Fprintf(sh.stdout(), "shopt -u %s\n", o.name)
+	}
+}
+
+// setFlags applies the option arguments at the start of args, as set and
+// the shell's own command line take them: -x/+x letters (combinable) and
+// -o NAME/+o NAME. A bare -o or +o lists the options. It returns the
+// remaining arguments and whether "--" ended the options, in which case
+// they replace the positional parameters even if there are none.
+func (sh *interp) setFlags(name string, args []string) (rest []string, ended bool, err error) {
+	for len(args) > 0 && len(args[0]) > 0 && (args[0][0] == '-' || args[0][0] == '+') {
+		arg := args[0]
+		args = args[1:]
+		switch arg {
+		case "--":
+			return args, true, nil
+		case "-":
+			// POSIX: "set -" turns off -x and -v and ends the options.
+			sh.options[optXtrace], sh.options[optVerbose] = false, false
+			return args, false, nil
+		case "+":
+			return args, false, nil
+		}
+		on := arg[0] == '-'
+		for i := 1; i < len(arg); i++ {
+			if arg[i] == 'o' {
+				if len(args) == 0 {
+					sh.printOptions(setOptions, !on, "set", nil)
+					continue
+				}
+				o := findOption(setOptions, args[0])
+				if o == nil {
+					return nil, false, fmt.Errorf("%s: %s: invalid option name", name, args[0])
+				}
+				sh.options[o] = on
+				args = args[1:]
+				continue
+			}
+			o := optionByFlag(arg[i])
+			if o == nil {
+				return nil, false, fmt.Errorf("%s: %c%c: invalid option", name, arg[0], arg[i])
+			}
+			sh.options[o] = on
+		}
+	}
+	return args, false, nil
+}
+
+// optionByFlag returns the set option with letter c, or nil.
+func optionByFlag(c byte) *shellOption {
+	for _, o := range setOptions {
+		if o.flag == c {
+			return o
+		}
+	}
+	return nil
+}
+
+// builtinShopt implements shopt [-pqsu] [-o] [optname ...]: -s and -u
+// turn options on and off; otherwise the named options (or all of them)
+// are listed and the status tells whether they are all on. -o works on
+// the set -o options instead, -q suppresses output and -p prints
+// reusable commands.
+func (sh *interp) builtinShopt(args []string) int {
+	var set, unset, quiet, asCommands bool
+	opts, cmd := shoptOptions, "shopt"
+	parsed, args, ok := sh.parseOptions("shopt", "pqsuo", args)
+	if !ok {
+		return 2
+	}
+	for _, opt := range parsed {
+		switch opt.c {
+		case 's':
+			set = true
+		case 'u':
+			unset = true
+		case 'q':
+			quiet = true
+		case 'p':
+			asCommands = true
+		case 'o':
+			opts, cmd = setOptions, "set"
+		}
+	}
+	if set && unset {
+		fmt.Fprintln(sh.stderr(), "shopt: cannot set and unset shell options simultaneously")
+		return 1
+	}
+
+	if len(args) == 0 {
+		switch {
+		case quiet:
+		case set || unset:
+			state := set
+			sh.printOptions(opts, asCommands, cmd, &state)
+		default:
+			sh.printOptions(opts, asCommands, cmd, nil)
+		}
+		return 0
+	}
+
+	status := 0
+	for _, name := range args {
+		o := findOption(opts, name)
+		if o == nil {
+			fmt.Fprintf(sh.stderr(), "shopt: %s: invalid shell option name\n", name)
+			status = 1
+			continue
+		}
+		switch {
+		case set || unset:
+			sh.options[o] = set
+		case !sh.options[o]:
+			status = 1
+			fallthrough
+		default:
+			if !quiet {
+				sh.printOption(o, asCommands, cmd)
+			}
+		}
+	}
+	return status
+}
@@ -0,0 +1,98 @@
+package shell
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSetOptions(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "errexit", src: "set -e; echo a; false; echo b", want: "a\n", status: 1},
+		{name: "errexit ignores conditions", src: "set -e; if false; then :; fi; while false; do :; done; echo ok", want: "ok\n"},
+		{name: "errexit ignores and-or heads", src: "set -e; false && echo no; false || echo yes; echo ok", want: "yes\nok\n"},
+		{name: "errexit on and-or tail", src: "set -e; true && false; echo no", status: 1},
+		{name: "errexit ignores negation", src: "set -e; ! true; echo ok", want: "ok\n"},
+		{name: "errexit exempt inside a tested function", src: "set -e; f() { false; echo in f; }; f || :; echo ok", want: "in f\nok\n"},
+		{name: "errexit in a function", src: "set -e; f() { false; echo no; }; f; echo no", status: 1},
+		{name: "errexit in a subshell", src: "set -e; (false; echo no); echo no", status: 1},
+		{name: "set +e", src: "set -e; set +e; false; echo ok", want: "ok\n"},
+		{name: "nounset", src: "set -u; echo ${x-default}; echo $x; echo no", want: "default\n", wantErr: "x: unbound variable\n", status: 1},
+		{name: "nounset braced", src: "set -u; echo ${#x}", wantErr: "x: unbound variable\n", status: 1},
+		{name: "nounset positional", src: "set -u; echo $1", wantErr: "1: unbound variable\n", status: 1},
+		{name: "nounset allows $@", src: "set -u; echo \"$@\" ok", want: "ok\n"},
+		{name: "pipefail", src: "set -o pipefail; false | true; echo $?; false | (exit 3) | true; echo $?", want: "1\n3\n"},
+		{name: "no pipefail", src: "false | true; echo $?", want: "0\n"},
+		{name: "xtrace", src: "set -x; x=$(echo 1) y='a b'; echo \"$x\" $y; ((x > 0))", want: "1 a b\n", wantErr: "+ echo 1\n+ x=1 y='a b'\n+ echo 1 a b\n+ (( x > 0 ))\n"},
+		{name: "PS4", src: "PS4='[$LINENO] '; set -x; echo hi", want: "hi\n", wantErr: "[1] echo hi\n"},
+		{name: "set - turns off xtrace", src: "set -- a; set -x; set -; echo $1", want: "a\n", wantErr: "+ set -\n"},
+		{name: "verbose", src: "set -v\necho hi\n", want: "hi\n", wantErr: "echo hi\n"},
+		{name: "noglob", src: "cd /; set -f; echo /e*c; set +f; echo /e*c", want: "/e*c\n/etc\n"},
+		{name: "$-", src: "set -eu; echo $-; set +eu -x; echo $-", want: "eu\nx\n", wantErr: "+ echo x\n"},
+		{name: "options and operands", src: "set -e a b; echo $# $-", want: "2 e\n"},
+		{name: "options keep operands", src: "set -- a; set -e; echo $#", want: "1\n"},
+		{name: "subshell keeps options", src: "(set -u); echo $- ${x-ok}", want: "ok\n"},
+		{name: "list", src: "set -o errexit; set -o | grep errexit; set +o | grep pipefail", want: "errexit        \ton\nset +o pipefail\n"},
+		{name: "invalid option", src: "set -q", wantErr: "set: -q: invalid option\nset: usage: set [-efuvx] [-o option] [--] [arg ...]\n", status: 2},
+		{name: "invalid option name", src: "set -o nope", wantErr: "set: nope: invalid option name\nset: usage: set [-efuvx] [-o option] [--] [arg ...]\n", status: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
+
+func TestShopt(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "list", src: "shopt", want: "dotglob        \toff\nexpand_aliases \toff\nfailglob       \toff\nnullglob       \toff\nxpg_echo       \toff\n"},
+		{name: "set and query", src: "shopt -s nullglob; shopt nullglob dotglob", want: "nullglob       \ton\ndotglob        \toff\n", status: 1},
+		{name: "quiet", src: "shopt -s dotglob; shopt -q dotglob && echo on; shopt -u dotglob; shopt -q dotglob || echo off", want: "on\noff\n"},
+		{name: "print commands", src: "shopt -s failglob; shopt -p failglob nullglob", want: "shopt -s failglob\nshopt -u nullglob\n", status: 1},
+		{name: "list set options", src: "shopt -s nullglob; shopt -s", want: "nullglob       \ton\n"},
+		{name: "set -o options", src: "shopt -os errexit; echo $-; shopt -op errexit", want: "e\nset -o errexit\n"},
+		{name: "invalid name", src: "shopt -s nope", wantErr: "shopt: nope: invalid shell option name\n", status: 1},
+		{name: "set and unset", src: "shopt -su dotglob", wantErr: "shopt: cannot set and unset shell options simultaneously\n", status: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
+
+func TestTraceQuoting(t *testing.T) {
+	sh := newTestShell(t)
+	sh.options[optXtrace] = true
+	setupTestVars(t, sh)
+	got := captureStderr(t, sh, func() { sh.traceCommand(nil, []string{"printf", "%s\n", "it's", ""}) })
+	if want := "+ printf '%s\n' 'it'\\''s' ''\n"; got != want {
+		t.Errorf("trace = %q, want %q", got, want)
+	}
+	if strings.Contains(captureStderr(t, sh, func() { sh.options[optXtrace] = false; sh.trace("x") }), "x") {
+		t.Error("trace printed with xtrace off")
+	}
+}
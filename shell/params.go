@@ -0,0 +1,246 @@
+// params.go — special and dynamic parameters.
+//
+// Special parameters are not variables; they are computed from params,
+// the shell's own state:
+//
+//	$0            shell or script name
+//	$1 ... ${10}  positional parameters (set --, shift)
+//	$#            number of positional parameters
+//	$@  $*        all positional parameters
+//	$?            exit status of the last command
+//	$$            process id of the shell
+//	$!            process id of the last background command
+//	$-            current option flags (options.go)
+//
+// Dynamic variables are ordinary entries in the variable table whose
+// value is recomputed on every read (see dynamicVar): RANDOM, SECONDS,
+// LINENO, EPOCHSECONDS and EPOCHREALTIME, and OPTIND, which is stored but
+// resets getopts (getopt.go) when assigned. PPID is a readonly integer set once.
+// As in bash, unsetting a dynamic variable removes its special behaviour.
+
+package shell
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shellParams holds the state behind the special parameters.
+type shellParams struct {
+	argv0       string   // $0
+	positional  []string // $1 ... $N
+	status      int      // $?
+	lastBg      int      // $!; 0 until a background command has started
+	lineno      int      // LINENO: the line being executed
+	interactive bool     // reading commands from a terminal ('i' in $-)
+}
+
+// dynamicVar computes a variable's value whenever it is read, if get is
+// non-nil, from the shell that owns the variable. set, if non-nil, is
+// told about assignments (RANDOM reseeds, SECONDS restarts).
+type dynamicVar struct {
+	get func(sh *interp) string
+	set func(sh *interp, value string)
+}
+
+// refresh recomputes the value of a dynamic variable of the shell sh.
+func (v *variable) refresh(sh *interp) {
+	if v.dyn != nil && v.dyn.get != nil {
+		v.Value = v.dyn.get(sh)
+	}
+}
+
+// initDynamicVars installs RANDOM, SECONDS, LINENO, EPOCHSECONDS,
+// EPOCHREALTIME, OPTIND and PPID in the shell's variables, replacing any
+// imported values. Subshells share the random number generator and the
+// clock of SECONDS, which mu guards.
+func (sh *interp) initDynamicVars() {
+	now := time.Now()
+	var mu sync.Mutex
+	rng := rand.New(rand.NewPCG(uint64(now.UnixNano()), uint64(os.Getpid())))
+	start, base := now, int64(0)
+
+	dynamic := map[string]*dynamicVar{
+		"RANDOM": {
+			get: func(*interp) string {
+				mu.Lock()
+				defer mu.Unlock()
+				return strconv.Itoa(rng.IntN(32768))
+			},
+			set: func(_ *interp, value string) {
+				seed, _ := strconv.ParseInt(value, 10, 64)
+				mu.Lock()
+				rng = rand.New(rand.NewPCG(uint64(seed), 0))
+				mu.Unlock()
+			},
+		},
+		"SECONDS": {
+			get: func(*interp) string {
+				mu.Lock()
+				defer mu.Unlock()
+				return strconv.FormatInt(base+int64(time.Since(start)/time.Second), 10)
+			},
+			set: func(_ *interp, value string) {
+				mu.Lock()
+				base, _ = strconv.ParseInt(value, 10, 64)
+				start = time.Now()
+				mu.Unlock()
+			},
+		},
+		"LINENO": {
+			get: func(sh *interp) string { return strconv.Itoa(sh.params.lineno) },
+			set: func(sh *interp, value string) { sh.params.lineno, _ = strconv.Atoi(value) },
+		},
+		"OPTIND": {
+			set: func(sh *interp, _ string) { sh.getoptsPos = 0 },
+		},
+		"EPOCHSECONDS": {
+			get: func(*interp) string { return strconv.FormatInt(time.Now().Unix(), 10) },
+		},
+		"EPOCHREALTIME": {
+			get: func(*interp) string {
+				t := time.Now()
+				return fmt.Sprintf("%d.%06d", t.Unix(), t.Nanosecond()/1000)
+			},
+		},
+	}
+	t := sh.vars
+	t.owner = sh
+	for name, d := range dynamic {
+		attrs := attrInteger
+		if strings.HasPrefix(name, "EPOCH") {
+			attrs = 0
+		}
+		t.vars[name] = &variable{Attrs: attrs, IsSet: true, dyn: d}
+	}
+	t.vars["OPTIND"].Value = "1"
+	t.vars["PPID"] = &variable{
+		Value: strconv.Itoa(os.Getppid()),
+		Attrs: attrInteger | attrReadonly,
+		IsSet: true,
+	}
+}
+
+// isSpecialParam reports whether name is a special parameter: one of
+// @ * # ? - $ ! or a positional parameter number (including 0).
+func isSpecialParam(name string) bool {
+	switch name {
+	case "@", "*", "#", "?", "-", "$", "!":
+		return true
+	}
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isDigit(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// specialParam returns the value of special parameter name and whether
+// it is set. $@ and $* return one value per positional parameter.
+func (sh *interp) specialParam(name string) ([]string, bool) {
+	switch name {
+	case "@", "*":
+		return slices.Clone(sh.params.positional), len(sh.params.positional) > 0
+	case "#":
+		return []string{strconv.Itoa(len(sh.params.positional))}, true
+	case "?":
+		return []string{strconv.Itoa(sh.params.status)}, true
+	case "-":
+		return []string{sh.flags()}, true
+	case "$":
+		return []string{strconv.Itoa(os.Getpid())}, true
+	case "!":
+		if sh.params.lastBg == 0 {
+			return nil, false
+		}
+		return []string{strconv.Itoa(sh.params.lastBg)}, true
+	case "0":
+		return []string{sh.params.argv0}, true
+	}
+	n, err := strconv.Atoi(name)
+	if err != nil || n < 1 || n > len(sh.params.positional) {
+		return nil, false
+	}
+	return []string{sh.params.positional[n-1]}, true
+}
+
+// flags returns the single-letter options that are in effect, as $-.
+func (sh *interp) flags() string {
+	flags := sh.optionFlags()
+	if sh.params.interactive {
+		flags += "i"
+	}
+	return flags
+}
+
+// positionalArray returns $0 and the positional parameters as an indexed
+// array, so ${@:offset:length} can share array slicing.
+func (sh *interp) positionalArray() *variable {
+	v := &variable{Attrs: attrArray, IsSet: true, Indexed: map[int]string{0: sh.params.argv0}}
+	for i, p := range sh.params.positional {
+		v.Indexed[i+1] = p
+	}
+	return v
+}
+
+// ifsJoiner returns the separator used to join "$*" and "${a[*]}": the
+// first character of IFS, a space if IFS is unset, or nothing if IFS is
+// empty.
+func (sh *interp) ifsJoiner() string {
+	ifs, ok := sh.vars.Get("IFS")
+	if !ok {
+		return " "
+	}
+	for _, r := range ifs {
+		return string(r)
+	}
+	return ""
+}
+
+// builtinSet implements set. With no arguments it lists every variable;
+// otherwise leading options are applied (options.go) and the operands,
+// if any or after "--", replace the positional parameters.
+func (sh *interp) builtinSet(args []string) int {
+	if len(args) == 0 {
+		sh.printAssignments()
+		return 0
+	}
+	args, ended, err := sh.setFlags("set", args)
+	if err != nil {
+		fmt.Fprintf(sh.stderr(), "%v\nset: usage: set [-efuvx] [-o option] [--] [arg ...]\n", err)
+		return 2
+	}
+	if len(args) > 0 || ended {
+		sh.params.positional = slices.Clone(args)
+	}
+	return 0
+}
+
+// builtinShift implements shift [n]: drop the first n (default 1)
+// positional parameters.
+func (sh *interp) builtinShift(args []string) int {
+	n := 1
+	if len(args) > 0 {
+		var err error
+		if n, err = strconv.Atoi(args[0]); err != nil {
+			fmt.Fprintf(sh.stderr(), "shift: %s: numeric argument required\n", args[0])
+			return 1
+		}
+	}
+	if n < 0 || n > len(sh.params.positional) {
+		fmt.Fprintf(sh.stderr(), "shift: %d: shift count out of range\n", n)
+		return 1
+	}
+	sh.params.positional = sh.params.positional[n:]
+	return 0
+}
@@ -0,0 +1,178 @@
+package shell
+
+import (
+	"os"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+// setupTestParams replaces the special-parameter state for one test.
+func setupTestParams(t *testing.T, sh *interp, positional ...string) {
+	t.Helper()
+	sh.params = &shellParams{argv0: "gosh", positional: positional}
+}
+
+func TestSpecialParams(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh)
+	setupTestParams(t, sh, "a", "b c", "d")
+	sh.params.status = 3
+
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{raw: "$#", want: []string{"3"}},
+		{raw: "$?", want: []string{"3"}},
+		{raw: "$0", want: []string{"gosh"}},
+		{raw: "$1-$2", want: []string{"a-b", "c"}},
+		{raw: "${3}", want: []string{"d"}},
+		{raw: "$4", want: nil},
+		{raw: "${10:-ten}", want: []string{"ten"}},
+		{raw: `"$@"`, want: []string{"a", "b c", "d"}},
+		{raw: `"<$@>"`, want: []string{"<a", "b c", "d>"}},
+		{raw: `"$*"`, want: []string{"a b c d"}},
+		{raw: "$@", want: []string{"a", "b", "c", "d"}},
+		{raw: `"${@:2}"`, want: []string{"b c", "d"}},
+		{raw: `"${@:0:2}"`, want: []string{"gosh", "a"}},
+		{raw: `"${@: -1}"`, want: []string{"d"}},
+		{raw: "${#@}", want: []string{"3"}},
+		{raw: "${#1}", want: []string{"1"}},
+		{raw: "${!#}", want: []string{"d"}},
+		{raw: `"${@^}"`, want: []string{"A", "B c", "D"}},
+		{raw: "${!:-none}", want: []string{"none"}},
+		{raw: "$$", want: []string{strconv.Itoa(os.Getpid())}},
+		{raw: "$-", want: nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := sh.expandFields(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expandFields(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmptyPositionalParams(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh)
+	setupTestParams(t, sh)
+
+	for raw, want := range map[string][]string{
+		`"$@"`:  nil,
+		`"$*"`:  {""},
+		`x"$@"`: {"x"},
+		`""`:    {""},
+	} {
+		got, err := sh.expandFields(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("expandFields(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
+
+func TestIFSJoin(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestParams(t, sh, "a", "b")
+	for _, tt := range []struct {
+		env  []string
+		want string
+	}{
+		{env: nil, want: "a b"},
+		{env: []string{"IFS=:,"}, want: "a:b"},
+		{env: []string{"IFS="}, want: "ab"},
+	} {
+		setupTestVars(t, sh, tt.env...)
+		if got, _ := sh.expandWord(`"$*"`); got != tt.want {
+			t.Errorf("with %v: \"$*\" = %q, want %q", tt.env, got, tt.want)
+		}
+	}
+}
+
+func TestSetAndShift(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestParams(t, sh, "old")
+
+	if status := sh.builtinSet([]string{"--", "-x", "y", "z"}); status != 0 {
+		t.Fatalf("set -- status = %d", status)
+	}
+	if want := []string{"-x", "y", "z"}; !slices.Equal(sh.params.positional, want) {
+		t.Errorf("after set: %q, want %q", sh.params.positional, want)
+	}
+	sh.builtinShift(nil)
+	if want := []string{"y", "z"}; !slices.Equal(sh.params.positional, want) {
+		t.Errorf("after shift: %q, want %q", sh.params.positional, want)
+	}
+	sh.builtinShift([]string{"2"})
+	if len(sh.params.positional) != 0 {
+		t.Errorf("after shift 2: %q, want none", sh.params.positional)
+	}
+
+	captureStderr(t, sh, func() {
+		if status := sh.builtinShift(nil); status != 1 {
+			t.Errorf("shift past end status = %d, want 1", status)
+		}
+		if status := sh.builtinSet([]string{"-q"}); status != 2 {
+			t.Errorf("set -q status = %d, want 2", status)
+		}
+	})
+}
+
+func TestDynamicVars(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh)
+	sh.initDynamicVars()
+
+	t.Run("RANDOM is reproducible after seeding", func(t *testing.T) {
+		sh.vars.Set("RANDOM", "42")
+		a := sh.getVar("RANDOM")
+		sh.vars.Set("RANDOM", "42")
+		if b := sh.getVar("RANDOM"); a != b {
+			t.Errorf("RANDOM after reseeding = %s, want %s", b, a)
+		}
+		n, err := strconv.Atoi(a)
+		if err != nil || n < 0 || n > 32767 {
+			t.Errorf("RANDOM = %q, want 0..32767", a)
+		}
+	})
+
+	t.Run("SECONDS counts from the last assignment", func(t *testing.T) {
+		sh.vars.Set("SECONDS", "100")
+		if got := sh.getVar("SECONDS"); got != "100" {
+			t.Errorf("SECONDS = %s, want 100", got)
+		}
+	})
+
+	t.Run("LINENO follows params", func(t *testing.T) {
+		setupTestParams(t, sh)
+		sh.params.lineno = 7
+		if got := sh.getVar("LINENO"); got != "7" {
+			t.Errorf("LINENO = %s, want 7", got)
+		}
+	})
+
+	t.Run("PPID is readonly", func(t *testing.T) {
+		if got := sh.getVar("PPID"); got != strconv.Itoa(os.Getppid()) {
+			t.Errorf("PPID = %s", got)
+		}
+		if err := sh.vars.Set("PPID", "1"); err == nil {
+			t.Error("assigning PPID succeeded")
+		}
+	})
+
+	t.Run("unset removes the dynamic behaviour", func(t *testing.T) {
+		sh.vars.Unset("EPOCHSECONDS")
+		sh.vars.Set("EPOCHSECONDS", "5")
+		if got := sh.getVar("EPOCHSECONDS"); got != "5" {
+			t.Errorf("EPOCHSECONDS = %s, want 5", got)
+		}
+	})
+}
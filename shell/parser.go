@@ -0,0 +1,309 @@
+// parser.go — simple command parsing: redirections and tokenization.
+//
+// Parsing layers (top-down):
+//
+//	parseCommand(input)        entry point for a single command segment
+//	  -> parseRedirection      extract >, >>, <, 2>&1, ... operators
+//	  -> splitAssignments      peel off leading NAME=value words
+//	  -> trimInput             tokenize command text into name + args
+//	       -> nextToken        resolve quotes/escapes/expansions for one token
+//
+// Lists, pipelines and compound commands are split up earlier, by the
+// grammar in syntax.go; parseCommand sees one simple command at a time.
+//
+// Quote handling has two modes:
+//   - quoteTracker: preserves raw quote chars (used by parseRedirection)
+//   - nextToken: resolves/strips quotes, interprets escapes and expands
+//     parameters (used by trimInput; built on scanWord/expandWord)
+
+package shell
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// parsedCommand holds the result of parsing a single command segment.
+type parsedCommand struct {
+	Assigns   []assignment // leading NAME=value words, unexpanded
+	Name      string
+	Args      []string
+	Redirects []redirection
+}
+
+// parseCommand parses a raw input segment into variable assignments, a
+// command name, arguments, and any I/O redirections.
+func (sh *interp) parseCommand(input string) (parsedCommand, error) {
+	cmdPart, redirects, err := sh.parseRedirection(input)
+	if err != nil {
+		return parsedCommand{}, err
+	}
+	assigns, rest := splitAssignments(cmdPart)
+	name, args, err := sh.trimInput(rest)
+	if err != nil {
+		return parsedCommand{}, err
+	}
+	return parsedCommand{Assigns: assigns, Name: name, Args: args, Redirects: redirects}, nil
+}
+
+// splitAssignments peels leading NAME=value words off a command and
+// returns them along with the remaining command text.
+func splitAssignments(s string) ([]assignment, string) {
+	var assigns []assignment
+	i := 0
+	for {
+		for i < len(s) && isBlank(s[i]) {
+			i++
+		}
+		end := scanWord(s, i, "")
+		a, ok := parseAssignment(s[i:end])
+		if !ok {
+			return assigns, s[i:]
+		}
+		assigns = append(assigns, a)
+		i = end
+	}
+}
+
+// parseRedirection separates redirect operators (>, >>, 2>&1, <, 3<>, ...)
+// from the command text, returning the command portion and a slice of
+// Redirects. A number directly before an operator, as its own word, is
+// the descriptor it redirects; &>file and >&file are >file 2>&1.
+func (sh *interp) parseRedirection(s string) (string, []redirection, error) {
+	var (
+		q         quoteTracker
+		cmdPart   strings.Builder
+		redirects []redirection
+	)
+
+	for i := 0; i < len(s); i++ {
+		if newI, ok := q.skipEscape(s, i, &cmdPart); ok {
+			i = newI
+			continue
+		}
+		if newI, ok := q.skipSubst(s, i, &cmdPart); ok {
+			i = newI
+			continue
+		}
+		if q.toggleQuote(s[i], &cmdPart) {
+			continue
+		}
+
+		// Check for redirect operator (only outside quotes)
+		op := redirOperator(s[i:])
+		if op == "" || q.IsQuoted() {
+			cmdPart.WriteByte(s[i])
+			continue
+		}
+		if op == "<<" {
+			return "", nil, fmt.Errorf("<<: here-documents are not supported")
+		}
+
+		fd, explicitFd := 1, false
+		if op[0] == '<' {
+			fd = 0
+		}
+		// Check if preceded by a descriptor number
+		cmdStr := cmdPart.String()
+		digits := len(cmdStr)
+		for digits > 0 && isDigit(cmdStr[digits-1]) {
+			digits--
+		}
+		if op[0] != '&' && digits < len(cmdStr) && (digits == 0 || isBlank(cmdStr[digits-1])) {
+			n, err := strconv.Atoi(cmdStr[digits:])
+			if err != nil || n >= maxFd {
+				return "", nil, fmt.Errorf("%s: %v", cmdStr[digits:], syscall.EBADF)
+			}
+			fd, explicitFd = n, true
+			cmdPart.Reset()
+			cmdPart.WriteString(cmdStr[:digits])
+		}
+
+		// Skip spaces after operator
+		i += len(op)
+		for i < len(s) && isBlank(s[i]) {
+			i++
+		}
+
+		filePath, newPos, err := sh.nextToken(s, i, "<>")
+		if err != nil {
+			return "", nil, err
+		}
+		i = newPos - 1 // compensate for outer loop increment
+
+		if filePath == "" {
+			return "", nil, fmt.Errorf("syntax error near unexpected token 'newline'")
+		}
+
+		switch {
+		case op == "&>" || op == "&>>":
+			redirects = append(redirects,
+				redirection{Fd: 1, Op: op[1:], File: filePath},
+				redirection{Fd: 2, Op: ">&", File: "1"})
+		case op == ">&" && !explicitFd && filePath != "-" && !isAllDigits(filePath):
+			redirects = append(redirects,
+				redirection{Fd: 1, Op: ">", File: filePath},
+				redirection{Fd: 2, Op: ">&", File: "1"})
+		default:
+			redirects = append(redirects, redirection{Fd: fd, Op: op, File: filePath})
+		}
+	}
+
+	if len(redirects) == 0 {
+		return s, nil, nil
+	}
+
+	return cmdPart.String(), redirects, nil
+}
+
+// redirOperators are the redirection operators, longest first.
+var redirOperators = []string{"&>>", "&>", ">>", ">|", ">&", "<>", "<&", "<<", ">", "<"}
+
+// redirOperator returns the redirection operator s starts with, or "".
+func redirOperator(s string) string {
+	for _, op := range redirOperators {
+		if strings.HasPrefix(s, op) {
+			return op
+		}
+	}
+	return ""
+}
+
+// trimInput splits a command string into the command name and its arguments,
+// resolving quotes, escapes and expansions via scanWord/expandFields.
+// Words are separated by blanks. A word may expand to several arguments
+// ("${a[@]}", or unquoted $var split at IFS) or to none ($unset), while a
+// quoted empty string ("" or "$unset") stays an empty argument.
+//
+// Operands of declaration builtins that are compound array assignments
+// (declare -a a=(x "y z")) are expanded element by element and passed on
+// re-quoted as a single argument.
+func (sh *interp) trimInput(s string) (string, []string, error) {
+	s = strings.TrimSpace(s)
+	var args []string
+	i := 0
+	for i < len(s) {
+		for i < len(s) && isBlank(s[i]) {
+			i++
+		}
+		if i >= len(s) {
+			break
+		}
+		end := scanWord(s, i, "")
+		raw := s[i:end]
+		i = end
+
+		if len(args) > 0 && declarationBuiltins[args[0]] {
+			if a, ok := parseAssignment(raw); ok && a.isCompound() {
+				elems, err := sh.parseCompound(a.Value)
+				if err != nil {
+					return "", nil, err
+				}
+				op := "="
+				if a.Append {
+					op = "+="
+				}
+				args = append(args, a.Name+op+quoteCompound(elems))
+				continue
+			}
+		}
+
+		fields, err := sh.expandFields(raw)
+		if err != nil {
+			return "", nil, err
+		}
+		args = append(args, fields...)
+	}
+	if len(args) == 0 {
+		return "", nil, nil
+	}
+	return args[0], args[1:], nil
+}
+
+// declarationBuiltins take NAME=(...) compound assignments as operands.
+var declarationBuiltins = map[string]bool{
+	"declare":  true,
+	"typeset":  true,
+	"export":   true,
+	"readonly": true,
+}
+
+// quoteTracker tracks single/double quote state while scanning a shell string.
+// Used by parseRedirection and the grammar (syntax.go) to share quoting logic.
+type quoteTracker struct {
+	inSingle bool
+	inDouble bool
+}
+
+func (q *quoteTracker) IsQuoted() bool {
+	return q.inSingle || q.inDouble
+}
+
+// skipEscape handles a backslash at s[i] outside single quotes. It writes the
+// raw backslash and escaped character to buf and returns the updated index.
+// Returns false if s[i] is not an escape sequence.
+func (q *quoteTracker) skipEscape(s string, i int, buf *strings.Builder) (int, bool) {
+	if s[i] != '\\' || q.inSingle {
+		return i, false
+	}
+	buf.WriteByte(s[i])
+	if i+1 < len(s) {
+		i++
+		buf.WriteByte(s[i])
+	}
+	return i, true
+}
+
+// skipSubst handles an expansion that may contain operators or quotes of
+// its own — $(...), ${...} or `...` — starting at s[i] outside single
+// quotes. It writes the raw text to buf and returns the index of its last
+// byte. Returns false if s[i] does not start one.
+func (q *quoteTracker) skipSubst(s string, i int, buf *strings.Builder) (int, bool) {
+	if q.inSingle {
+		return i, false
+	}
+	var end int
+	switch {
+	case s[i] == '$' && i+1 < len(s) && (s[i+1] == '(' || s[i+1] == '{'):
+		end = skipGroup(s, i+1)
+	case s[i] == '`':
+		end = skipBackquote(s, i)
+	default:
+		return i, false
+	}
+	buf.WriteString(s[i:end])
+	return end - 1, true
+}
+
+// toggleQuote handles quote characters. If ch is a quote that should toggle
+// state, it writes the char to buf and returns true.
+func (q *quoteTracker) toggleQuote(ch byte, buf *strings.Builder) bool {
+	if ch == '\'' && !q.inDouble {
+		q.inSingle = !q.inSingle
+		buf.WriteByte(ch)
+		return true
+	}
+	if ch == '"' && !q.inSingle {
+		q.inDouble = !q.inDouble
+		buf.WriteByte(ch)
+		return true
+	}
+	return false
+}
+
+// nextToken parses one shell token starting at s[pos] and expands it,
+// resolving single quotes, double quotes, backslash escapes, parameter
+// references and a leading ~ (see expand.go). Returns the resolved value and the position
+// where scanning stopped. Stops at unquoted space, newline, or any unquoted
+// byte in stops.
+//
+// NOTE: nextToken resolves quotes/escapes (strips quote chars, interprets
+// backslash sequences) unlike quoteTracker which preserves them raw. The two
+// serve different purposes so nextToken keeps its own state.
+func (sh *interp) nextToken(s string, pos int, stops string) (string, int, error) {
+	end := scanWord(s, pos, stops)
+	tok, err := sh.expandTarget(s[pos:end])
+	return tok, end, err
+}
@@ -1,16 +1,18 @@
-package main
+package shell
 
 import (
+	"slices"
 	"testing"
 )
 
 func TestParseCommand(t *testing.T) {
+	sh := newTestShell(t)
 	tests := []struct {
 		name          string
 		input         string
 		wantName      string
 		wantArgs      []string
-		wantRedirects []Redirect
+		wantRedirects []redirection
 		wantErr       bool
 	}{
 		{
@@ -24,14 +26,14 @@ func TestParseCommand(t *testing.T) {
 			input:         "echo hello > file.txt",
 			wantName:      "echo",
 			wantArgs:      []string{"hello"},
-			wantRedirects: []Redirect{{Fd: 1, Op: ">", File: "file.txt"}},
+			wantRedirects: []redirection{{Fd: 1, Op: ">", File: "file.txt"}},
 		},
 		{
 			name:     "command with quoted args and redirect",
 			input:    `echo "hello world" > file.txt`,
 			wantName: "echo",
 			wantArgs: []string{"hello world"},
-			wantRedirects: []Redirect{{Fd: 1, Op: ">", File: "file.txt"}},
+			wantRedirects: []redirection{{Fd: 1, Op: ">", File: "file.txt"}},
 		},
 		{
 			name:    "missing redirect target is error",
@@ -46,7 +48,7 @@ func TestParseCommand(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := parseCommand(tt.input)
+			got, err := sh.parseCommand(tt.input)
 			if (err != nil) != tt.wantErr {
 				t.Fatalf("parseCommand() error = %v, wantErr %v", err, tt.wantErr)
 			}
@@ -77,60 +79,8 @@ func TestParseCommand(t *testing.T) {
 	}
 }
 
-func TestParsePipeline(t *testing.T) {
-	tests := []struct {
-		name  string
-		input string
-		want  []string
-	}{
-		{
-			name:  "no pipe",
-			input: "echo hello",
-			want:  []string{"echo hello"},
-		},
-		{
-			name:  "single pipe",
-			input: "cat file | wc",
-			want:  []string{"cat file ", " wc"},
-		},
-		{
-			name:  "pipe inside double quotes",
-			input: `echo "a|b"`,
-			want:  []string{`echo "a|b"`},
-		},
-		{
-			name:  "pipe inside single quotes",
-			input: "echo 'a|b'",
-			want:  []string{"echo 'a|b'"},
-		},
-		{
-			name:  "escaped pipe",
-			input: `echo a\|b`,
-			want:  []string{`echo a\|b`},
-		},
-		{
-			name:  "multiple pipes",
-			input: "a | b | c",
-			want:  []string{"a ", " b ", " c"},
-		},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := parsePipeline(tt.input)
-			if len(got) != len(tt.want) {
-				t.Fatalf("parsePipeline(%q) returned %d segments, want %d\n  got:  %q\n  want: %q", tt.input, len(got), len(tt.want), got, tt.want)
-			}
-			for i := range got {
-				if got[i] != tt.want[i] {
-					t.Errorf("parsePipeline(%q)[%d] = %q, want %q", tt.input, i, got[i], tt.want[i])
-				}
-			}
-		})
-	}
-}
-
 func TestTrimInput(t *testing.T) {
+	sh := newTestShell(t)
 	tests := []struct {
 		name     string
 		input    string
@@ -184,10 +134,10 @@ func TestTrimInput(t *testing.T) {
 			wantArgs: []string{"hello", "big world", "foo"},
 		},
 		{
-			name:     "empty single-quoted string is dropped",
+			name:     "empty single-quoted string is an argument",
 			input:    "echo '' foo\n",
 			wantCmd:  "echo",
-			wantArgs: []string{"foo"},
+			wantArgs: []string{"", "foo"},
 		},
 		{
 			name:     "adjacent quotes concatenate",
@@ -232,10 +182,22 @@ func TestTrimInput(t *testing.T) {
 			wantArgs: []string{"say \"hi\""},
 		},
 		{
-			name:     "empty double-quoted string is dropped",
+			name:     "tabs separate arguments",
+			input:    "echo a\tb  \t c\n",
+			wantCmd:  "echo",
+			wantArgs: []string{"a", "b", "c"},
+		},
+		{
+			name:     "unquoted expansion is split",
+			input:    "echo $(echo 'x  y')\n",
+			wantCmd:  "echo",
+			wantArgs: []string{"x", "y"},
+		},
+		{
+			name:     "empty double-quoted string is an argument",
 			input:    "echo \"\" foo\n",
 			wantCmd:  "echo",
-			wantArgs: []string{"foo"},
+			wantArgs: []string{"", "foo"},
 		},
 		{
 			name:     "double-quoted preserves tabs",
@@ -304,10 +266,10 @@ func TestTrimInput(t *testing.T) {
 			wantArgs: []string{"test\\nvalue"},
 		},
 		{
-			name:     "backslash in double quotes literal for dollar",
+			name:     "backslash in double quotes escapes dollar",
 			input:    "echo \"price\\$5\"\n",
 			wantCmd:  "echo",
-			wantArgs: []string{"price\\$5"},
+			wantArgs: []string{"price$5"},
 		},
 		{
 			name:  "empty input",
@@ -317,7 +279,10 @@ func TestTrimInput(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotCmd, gotArgs := trimInput(tt.input)
+			gotCmd, gotArgs, err := sh.trimInput(tt.input)
+			if err != nil {
+				t.Fatalf("trimInput() error = %v", err)
+			}
 			if gotCmd != tt.wantCmd {
 				t.Errorf("trimInput() cmd = %q, want %q", gotCmd, tt.wantCmd)
 			}
@@ -335,54 +300,55 @@ func TestTrimInput(t *testing.T) {
 }
 
 func TestParseRedirection(t *testing.T) {
+	sh := newTestShell(t)
 	tests := []struct {
 		name          string
 		input         string
 		wantCmd       string
-		wantRedirects []Redirect
+		wantRedirects []redirection
 		wantErr       bool
 	}{
 		{
 			name:          "stdout truncate",
 			input:         "echo hello > file.txt",
 			wantCmd:       "echo hello ",
-			wantRedirects: []Redirect{{Fd: 1, Op: ">", File: "file.txt"}},
+			wantRedirects: []redirection{{Fd: 1, Op: ">", File: "file.txt"}},
 		},
 		{
 			name:          "stdout append",
 			input:         "echo hello >> file.txt",
 			wantCmd:       "echo hello ",
-			wantRedirects: []Redirect{{Fd: 1, Op: ">>", File: "file.txt"}},
+			wantRedirects: []redirection{{Fd: 1, Op: ">>", File: "file.txt"}},
 		},
 		{
 			name:          "explicit stdout truncate 1>",
 			input:         "echo hello 1> file.txt",
 			wantCmd:       "echo hello ",
-			wantRedirects: []Redirect{{Fd: 1, Op: ">", File: "file.txt"}},
+			wantRedirects: []redirection{{Fd: 1, Op: ">", File: "file.txt"}},
 		},
 		{
 			name:          "explicit stdout append 1>>",
 			input:         "echo hello 1>> file.txt",
 			wantCmd:       "echo hello ",
-			wantRedirects: []Redirect{{Fd: 1, Op: ">>", File: "file.txt"}},
+			wantRedirects: []redirection{{Fd: 1, Op: ">>", File: "file.txt"}},
 		},
 		{
 			name:          "stderr truncate 2>",
 			input:         "cmd 2> err.txt",
 			wantCmd:       "cmd ",
-			wantRedirects: []Redirect{{Fd: 2, Op: ">", File: "err.txt"}},
+			wantRedirects: []redirection{{Fd: 2, Op: ">", File: "err.txt"}},
 		},
 		{
 			name:          "stderr append 2>>",
 			input:         "cmd 2>> err.txt",
 			wantCmd:       "cmd ",
-			wantRedirects: []Redirect{{Fd: 2, Op: ">>", File: "err.txt"}},
+			wantRedirects: []redirection{{Fd: 2, Op: ">>", File: "err.txt"}},
 		},
 		{
 			name:    "multiple redirects stdout and stderr",
 			input:   "cmd > out.txt 2> err.txt",
 			wantCmd: "cmd  ",
-			wantRedirects: []Redirect{
+			wantRedirects: []redirection{
 				{Fd: 1, Op: ">", File: "out.txt"},
 				{Fd: 2, Op: ">", File: "err.txt"},
 			},
@@ -407,6 +373,62 @@ func TestParseRedirection(t *testing.T) {
 			input:   `echo hello \> world`,
 			wantCmd: `echo hello \> world`,
 		},
+		{
+			name:          "stderr to stdout 2>&1",
+			input:         "cmd 2>&1",
+			wantCmd:       "cmd ",
+			wantRedirects: []redirection{{Fd: 2, Op: ">&", File: "1"}},
+		},
+		{
+			name:          "stdin from file",
+			input:         "sort < in.txt",
+			wantCmd:       "sort ",
+			wantRedirects: []redirection{{Fd: 0, Op: "<", File: "in.txt"}},
+		},
+		{
+			name:    "any descriptor",
+			input:   "cmd 3> out.txt 10<> rw.txt 4<&-",
+			wantCmd: "cmd   ",
+			wantRedirects: []redirection{
+				{Fd: 3, Op: ">", File: "out.txt"},
+				{Fd: 10, Op: "<>", File: "rw.txt"},
+				{Fd: 4, Op: "<&", File: "-"},
+			},
+		},
+		{
+			name:    "stdout and stderr &>",
+			input:   "cmd &> all.txt",
+			wantCmd: "cmd ",
+			wantRedirects: []redirection{
+				{Fd: 1, Op: ">", File: "all.txt"},
+				{Fd: 2, Op: ">&", File: "1"},
+			},
+		},
+		{
+			name:    "stdout and stderr >&file",
+			input:   "cmd >& all.txt",
+			wantCmd: "cmd ",
+			wantRedirects: []redirection{
+				{Fd: 1, Op: ">", File: "all.txt"},
+				{Fd: 2, Op: ">&", File: "1"},
+			},
+		},
+		{
+			name:          "digits inside a word are not a descriptor",
+			input:         "echo a2> file.txt",
+			wantCmd:       "echo a2",
+			wantRedirects: []redirection{{Fd: 1, Op: ">", File: "file.txt"}},
+		},
+		{
+			name:    "descriptor out of range",
+			input:   "cmd 99999> file.txt",
+			wantErr: true,
+		},
+		{
+			name:    "here-document",
+			input:   "cat << EOF",
+			wantErr: true,
+		},
 		{
 			name:    "no redirect",
 			input:   "echo hello world",
@@ -416,7 +438,7 @@ func TestParseRedirection(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			cmdPart, redirects, err := parseRedirection(tt.input)
+			cmdPart, redirects, err := sh.parseRedirection(tt.input)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseRedirection() error = %v, wantErr %v", err, tt.wantErr)
 				return
@@ -442,6 +464,7 @@ func TestParseRedirection(t *testing.T) {
 }
 
 func TestNextToken(t *testing.T) {
+	sh := newTestShell(t)
 	tests := []struct {
 		name    string
 		input   string
@@ -525,7 +548,10 @@ func TestNextToken(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotTok, gotPos := nextToken(tt.input, tt.pos, tt.stops)
+			gotTok, gotPos, err := sh.nextToken(tt.input, tt.pos, tt.stops)
+			if err != nil {
+				t.Fatalf("nextToken() error = %v", err)
+			}
 			if gotTok != tt.wantTok {
 				t.Errorf("nextToken() token = %q, want %q", gotTok, tt.wantTok)
 			}
@@ -535,3 +561,97 @@ func TestNextToken(t *testing.T) {
 		})
 	}
 }
+
+func TestSplitAssignments(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		wantAssigns []assignment
+		wantRest    string
+	}{
+		{
+			name:     "no assignments",
+			input:    "echo a=b",
+			wantRest: "echo a=b",
+		},
+		{
+			name:        "bare assignment",
+			input:       "FOO=bar",
+			wantAssigns: []assignment{{Name: "FOO", Value: "bar"}},
+		},
+		{
+			name:        "prefix assignments keep raw values",
+			input:       `A=1 B="$A x" env`,
+			wantAssigns: []assignment{{Name: "A", Value: "1"}, {Name: "B", Value: `"$A x"`}},
+			wantRest:    "env",
+		},
+		{
+			name:     "quoted name is not an assignment",
+			input:    `"A"=1 cmd`,
+			wantRest: `"A"=1 cmd`,
+		},
+		{
+			name:     "invalid name",
+			input:    "1A=1",
+			wantRest: "1A=1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assigns, rest := splitAssignments(tt.input)
+			if rest != tt.wantRest {
+				t.Errorf("splitAssignments(%q) rest = %q, want %q", tt.input, rest, tt.wantRest)
+			}
+			if len(assigns) != len(tt.wantAssigns) {
+				t.Fatalf("splitAssignments(%q) = %+v, want %+v", tt.input, assigns, tt.wantAssigns)
+			}
+			for i := range assigns {
+				if assigns[i] != tt.wantAssigns[i] {
+					t.Errorf("assigns[%d] = %+v, want %+v", i, assigns[i], tt.wantAssigns[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTrimInputArrays(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh)
+	a, _ := parseAssignment(`a=(one "two words" three)`)
+	sh.performAssignment(a, true)
+
+	tests := []struct {
+		name     string
+		input    string
+		wantArgs []string
+	}{
+		{
+			name:     "quoted all-elements expands to separate args",
+			input:    `echo "${a[@]}"`,
+			wantArgs: []string{"one", "two words", "three"},
+		},
+		{
+			name:     "compound operand of declare is re-quoted",
+			input:    `declare -a b=(x "$a y")`,
+			wantArgs: []string{"-a", "b=(x 'one y')"},
+		},
+		{
+			name:     "compound-looking operand of other commands is plain",
+			input:    `echo b=(x)`,
+			wantArgs: []string{"b=(x)"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, args, err := sh.trimInput(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(args, tt.wantArgs) {
+				t.Errorf("trimInput(%q) args = %q, want %q", tt.input, args, tt.wantArgs)
+			}
+		})
+	}
+}
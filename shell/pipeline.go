@@ -0,0 +1,296 @@
+// pipeline.go — multi-command pipe execution (cmd1 | cmd2 | ...).
+//
+// Flow:
+//
+//	executePipeline(segments)
+//	  -> createPipes          allocate N-1 os.Pipe pairs
+//	  -> for each segment:
+//	       startSegment       parse, wire I/O, dispatch
+//	         -> startInternal run a builtin, function or compound
+//	                          command in a subshell on its own goroutine
+//	         -> startExternal cmd.Start (non-blocking), or the
+//	                          Interpreter's exec hook in a goroutine
+//	  -> wait                 wait for all procs/goroutines to finish;
+//	                          the last segment's status is the result
+//	                          (the last failing one's with pipefail)
+//
+// A background pipeline ("cmd &") does not wait at all: it becomes a job
+// (jobs.go) whose goroutine waits for every segment, and the last external
+// process's pid becomes $!.
+//
+// Pipe ownership: the parent closes its copy of each pipe end after the
+// child process/goroutine has inherited it (closeParentEnds).
+
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+)
+
+// proc tracks a single pipeline segment — either an external process
+// (cmd) or a builtin running in a goroutine (done channel).
+type proc struct {
+	cmd    *exec.Cmd
+	done   chan struct{}
+	status int // builtin exit status, valid once done is closed
+}
+
+// pipeline holds the state for a multi-segment pipe execution: the pipe
+// file descriptors and the processes/goroutines spawned for each segment.
+type pipeline struct {
+	sh         *interp    // the shell running the pipeline
+	pipefail   bool       // set -o pipefail, as the pipeline started
+	background bool       // a job: its subshells leave signals alone
+	n          int        // number of segments
+	pipeR      []*os.File // read ends between segments
+	pipeW      []*os.File // write ends between segments
+	procs      []proc
+}
+
+// executePipeline creates pipes, starts every segment, then waits for all
+// to finish, or with background starts a job that does. It returns the
+// pipeline's exit status, or 0 for a job.
+func (sh *interp) executePipeline(segments []*cmdNode, background bool) int {
+	p := &pipeline{sh: sh, pipefail: sh.options[optPipefail], background: background, n: len(segments)}
+	if err := p.createPipes(); err != nil {
+		fmt.Fprintln(sh.stderr(), err)
+		return 1
+	}
+
+	var cleanups []func()
+	defer func() {
+		for _, cl := range cleanups {
+			cl()
+		}
+	}()
+
+	for i, seg := range segments {
+		cl, err := p.startSegment(i, seg)
+		if cl != nil {
+			cleanups = append(cleanups, cl)
+		}
+		if err != nil {
+			p.closePipes()
+			if errors.Is(err, exec.ErrNotFound) {
+				return 127
+			}
+			return 1
+		}
+	}
+
+	if background {
+		// The job closes the redirected files once it is done with them.
+		jobCleanups := cleanups
+		cleanups = nil
+		internal := false
+		for i := range p.procs {
+			internal = internal || p.procs[i].done != nil
+		}
+		sh.startJob(pipeText(false, segments), p.lastPid(), internal, func() int {
+			status := p.wait()
+			for _, cl := range jobCleanups {
+				cl()
+			}
+			return status
+		})
+		return 0
+	}
+	return p.wait()
+}
+
+// startSegment parses, wires I/O, and launches segment i. It returns an
+// optional redirect cleanup function and any error that prevents execution.
+func (p *pipeline) startSegment(i int, seg *cmdNode) (cleanup func(), err error) {
+	sh := p.sh
+	fds := p.segmentFds(i)
+	if seg.compound != nil {
+		run := func(sh *interp) int { return sh.runCmdNode(seg) }
+		p.startInternal(i, run, fds)
+		return nil, nil
+	}
+
+	parsed, err := sh.parseCommand(seg.raw)
+	if err != nil {
+		fmt.Fprintln(sh.stderr(), err)
+		return nil, err
+	}
+
+	// Apply redirections (typically only on the last segment).
+	if len(parsed.Redirects) > 0 {
+		if fds, cleanup, err = sh.redirect(fds, parsed.Redirects); err != nil {
+			fmt.Fprintln(sh.stderr(), err)
+			return nil, err
+		}
+	}
+
+	// Assignments alone run in the segment's own context and have no
+	// lasting effect.
+	if parsed.Name == "" {
+		p.closeParentEnds(i)
+		return cleanup, nil
+	}
+
+	if cmd, ok := sh.internalCommand(parsed.Name); ok {
+		run := func(sh *interp) int {
+			status := 0
+			err := sh.withAssignments(parsed.Assigns, func() {
+				sh.traceCommand(parsed.Assigns, append([]string{parsed.Name}, parsed.Args...))
+				status = cmd.Run(sh, parsed.Args)
+			})
+			if err != nil {
+				fmt.Fprintln(sh.stderr(), err)
+				return 1
+			}
+			return status
+		}
+		p.startInternal(i, run, fds)
+		return cleanup, nil
+	}
+
+	if err := p.startExternal(i, parsed, fds); err != nil {
+		return cleanup, err
+	}
+	return cleanup, nil
+}
+
+// startInternal runs a command that lives inside the shell (a builtin,
+// function or compound command) in a subshell with the file descriptors
+// fds, on its own goroutine.
+func (p *pipeline) startInternal(i int, run func(sh *interp) int, fds []*os.File) {
+	done := make(chan struct{})
+	p.procs[i] = proc{done: done}
+	sub := p.sh.subshell()
+	sub.fds = fds
+	sub.async = sub.async || p.background
+	go func() {
+		defer close(done)
+		p.procs[i].status = p.sh.exitSubshell(sub, run(sub))
+		p.closeParentEnds(i)
+	}()
+}
+
+// startExternal spawns an external process with cmd.Start (non-blocking).
+// Exported shell variables and the segment's prefix assignments form its
+// environment.
+func (p *pipeline) startExternal(i int, parsed parsedCommand, fds []*os.File) error {
+	sh := p.sh
+	c, err := sh.externalCommand(parsed.Name, parsed.Args, parsed.Assigns)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			fmt.Fprintf(sh.stdout(), "%s: command not found\n", parsed.Name)
+		}
+		return err
+	}
+	attachFds(c, fds)
+	if sh.execHook != nil {
+		// The hook runs the command to completion, so it waits in a
+		// goroutine; c already has the segment's streams.
+		done := make(chan struct{})
+		p.procs[i] = proc{done: done}
+		go func() {
+			defer close(done)
+			p.procs[i].status = sh.runExec(c)
+			p.closeParentEnds(i)
+		}()
+		return nil
+	}
+	if err := c.Start(); err != nil {
+		return err
+	}
+	p.procs[i] = proc{cmd: c}
+	p.closeParentEnds(i)
+	return nil
+}
+
+// createPipes allocates N-1 os.Pipe pairs to connect adjacent segments.
+func (p *pipeline) createPipes() error {
+	p.pipeR = make([]*os.File, p.n-1)
+	p.pipeW = make([]*os.File, p.n-1)
+	p.procs = make([]proc, p.n)
+	for i := 0; i < p.n-1; i++ {
+		r, w, err := os.Pipe()
+		if err != nil {
+			p.closePipes()
+			return err
+		}
+		p.pipeR[i] = r
+		p.pipeW[i] = w
+	}
+	return nil
+}
+
+// closePipes closes all remaining open pipe file descriptors (error cleanup).
+func (p *pipeline) closePipes() {
+	for i := range p.pipeR {
+		if p.pipeR[i] != nil {
+			p.pipeR[i].Close()
+		}
+		if p.pipeW[i] != nil {
+			p.pipeW[i].Close()
+		}
+	}
+}
+
+// segmentFds returns the file descriptor table for segment i: the
+// shell's, with stdin and stdout wired to the pipes.
+func (p *pipeline) segmentFds(i int) []*os.File {
+	fds := slices.Clone(p.sh.fds)
+	if i > 0 {
+		fds[0] = p.pipeR[i-1]
+	}
+	if i < p.n-1 {
+		fds[1] = p.pipeW[i]
+	}
+	return fds
+}
+
+// closeParentEnds closes the parent process's copies of pipe ends that
+// segment i now owns (via fork or goroutine).
+func (p *pipeline) closeParentEnds(i int) {
+	if i < p.n-1 {
+		p.pipeW[i].Close()
+		p.pipeW[i] = nil
+	}
+	if i > 0 {
+		p.pipeR[i-1].Close()
+		p.pipeR[i-1] = nil
+	}
+}
+
+// wait blocks until every segment has finished (cmd.Wait or channel recv)
+// and returns the exit status of the last one, or with set -o pipefail
+// that of the last one to fail.
+func (p *pipeline) wait() int {
+	status, failed := 0, 0
+	for i := range p.procs {
+		pr := &p.procs[i]
+		status = 0
+		if pr.cmd != nil {
+			status = exitStatus(pr.cmd.Wait())
+		} else if pr.done != nil {
+			<-pr.done
+			status = pr.status
+		}
+		if status != 0 {
+			failed = status
+		}
+	}
+	if p.pipefail {
+		return failed
+	}
+	return status
+}
+
+// lastPid returns the process id of the last external segment, or 0.
+func (p *pipeline) lastPid() int {
+	for i := len(p.procs) - 1; i >= 0; i-- {
+		if c := p.procs[i].cmd; c != nil && c.Process != nil {
+			return c.Process.Pid
+		}
+	}
+	return 0
+}
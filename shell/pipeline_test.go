@@ -0,0 +1,148 @@
+package shell
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSegmentFds(t *testing.T) {
+	p := &pipeline{sh: newTestShell(t), n: 3}
+	if err := p.createPipes(); err != nil {
+		t.Fatal(err)
+	}
+	defer p.closePipes()
+
+	t.Run("first segment reads from stdin writes to pipe", func(t *testing.T) {
+		fds := p.segmentFds(0)
+		if fds[0] != os.Stdin {
+			t.Error("expected stdin to be os.Stdin")
+		}
+		if fds[1] != p.pipeW[0] {
+			t.Error("expected stdout to be pipeW[0]")
+		}
+		if fds[2] != os.Stderr {
+			t.Error("expected stderr to be os.Stderr")
+		}
+	})
+
+	t.Run("middle segment reads from pipe writes to pipe", func(t *testing.T) {
+		fds := p.segmentFds(1)
+		if fds[0] != p.pipeR[0] {
+			t.Error("expected stdin to be pipeR[0]")
+		}
+		if fds[1] != p.pipeW[1] {
+			t.Error("expected stdout to be pipeW[1]")
+		}
+		if fds[2] != os.Stderr {
+			t.Error("expected stderr to be os.Stderr")
+		}
+	})
+
+	t.Run("last segment reads from pipe writes to stdout", func(t *testing.T) {
+		fds := p.segmentFds(2)
+		if fds[0] != p.pipeR[1] {
+			t.Error("expected stdin to be pipeR[1]")
+		}
+		if fds[1] != os.Stdout {
+			t.Error("expected stdout to be os.Stdout")
+		}
+		if fds[2] != os.Stderr {
+			t.Error("expected stderr to be os.Stderr")
+		}
+	})
+}
+
+func TestExecutePipeline(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name     string
+		segments []string
+		want     string
+	}{
+		{
+			name:     "builtin to external",
+			segments: []string{"echo hello", " cat"},
+			want:     "hello",
+		},
+		{
+			name:     "three stage pipeline",
+			segments: []string{"echo hello", " cat", " cat"},
+			want:     "hello",
+		},
+		{
+			name:     "pipe to wc",
+			segments: []string{"echo hello world", " wc -w"},
+			want:     "2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := captureStdout(t, sh, func() {
+				sh.executePipeline(simpleCommands(tt.segments...), false)
+			})
+			got = strings.TrimSpace(got)
+			want := strings.TrimSpace(tt.want)
+			if got != want {
+				t.Errorf("executePipeline() output = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestPipelineStatus(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name     string
+		segments []string
+		want     int
+	}{
+		{name: "last segment succeeds", segments: []string{"false", " true"}, want: 0},
+		{name: "last segment fails", segments: []string{"true", " false"}, want: 1},
+		{name: "exit code is preserved", segments: []string{"echo", " sh -c 'exit 7'"}, want: 7},
+		{name: "builtin status", segments: []string{"true", " type nosuchcommand"}, want: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got int
+			captureStdout(t, sh, func() { got = sh.executePipeline(simpleCommands(tt.segments...), false) })
+			if got != tt.want {
+				t.Errorf("executePipeline(%q) = %d, want %d", tt.segments, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackgroundPipeline(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestParams(t, sh)
+	if status := sh.executePipeline(simpleCommands("sleep 0.01"), true); status != 0 {
+		t.Errorf("background status = %d, want 0", status)
+	}
+	if sh.params.lastBg == 0 {
+		t.Error("$! not set after starting a background command")
+	}
+}
+
+func TestPipelineCompound(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh, "PATH="+os.Getenv("PATH"))
+	prog, err := parseProgram("echo a | { cat; echo b; } | for x in 1; do sort -r; done", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := captureStdout(t, sh, func() { sh.runList(prog) })
+	if want := "b\na\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+// simpleCommands wraps raw command texts as pipeline elements.
+func simpleCommands(raws ...string) []*cmdNode {
+	cmds := make([]*cmdNode, len(raws))
+	for i, raw := range raws {
+		cmds[i] = &cmdNode{raw: raw}
+	}
+	return cmds
+}
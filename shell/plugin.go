@@ -0,0 +1,328 @@
+// plugin.go — builtins provided by executables in a plugin directory.
+//
+// After the startup files have run, each executable in $GOSH_PLUGIN_DIR
+// (default ~/.gosh_plugins) joins the registry as a builtin, as it
+// describes itself. A plugin cannot replace a builtin of the shell.
+// Descriptions are cached in $XDG_CACHE_HOME/gosh/plugins.json (default
+// ~/.cache/gosh/plugins.json) by file size and modification time, so a
+// plugin is only asked again once it changes and startup does not wait
+// for plugins that have not. --noplugins skips plugins altogether.
+//
+// Every request is one line of JSON on the plugin's stdin; the plugin
+// answers with one JSON object on stdout and exits. Its stderr is the
+// shell's, and its environment the exported variables.
+//
+//	{"request": "describe"}
+//	  -> {"name": "deploy", "synopsis": "deploy [-n] env",
+//	      "summary": "one line", "description": "more lines",
+//	      "options": [{"flag": "-n", "help": "dry run"}],
+//	      "completions": ["staging", "production"]}
+//
+//	{"request": "run", "args": ["-n", "staging"],
+//	 "env": {"HOME": "/home/me", ...}, "cwd": "/work"}
+//	  -> {"status": 0, "stdout": "text", "stderr": "text",
+//	      "env": {"DEPLOYED": "staging", "STALE": null},
+//	      "cwd": "/work/staging"}
+//
+// Only name is required in a description; the synopsis, summary,
+// description and options make up the help text, as for other builtins,
+// and the completions are the words TAB offers for arguments. In a
+// response, every field may be omitted. The shell writes stdout and
+// stderr through its own (redirected) streams, sets each variable in env
+// or unsets it if null, changes directory to cwd as cd does, and returns
+// status. A plugin that exits without a valid response fails with its
+// own exit status, or 1.
+
+package shell
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// pluginDescribeTimeout bounds how long a plugin may take to describe
+// itself, so that a broken one cannot hang startup.
+const pluginDescribeTimeout = 5 * time.Second
+
+// pluginDescription is a plugin's answer to describe.
+type pluginDescription struct {
+	Name        string       `json:"name"`
+	Synopsis    string       `json:"synopsis"`
+	Summary     string       `json:"summary"`
+	Description string       `json:"description"`
+	Options     []optionSpec `json:"options"`
+	Completions []string     `json:"completions"`
+}
+
+// pluginRun is the request to run a plugin.
+type pluginRun struct {
+	Request string            `json:"request"`
+	Args    []string          `json:"args"`
+	Env     map[string]string `json:"env"`
+	Cwd     string            `json:"cwd"`
+}
+
+// pluginCacheEntry is what describing the plugin file of a given size
+// and modification time gave: a description, or an error.
+type pluginCacheEntry struct {
+	Size        int64             `json:"size"`
+	ModTime     int64             `json:"mtime"` // in nanoseconds
+	Description pluginDescription `json:"description"`
+	Error       string            `json:"error,omitempty"`
+}
+
+// pluginResult is a plugin's answer to run.
+type pluginResult struct {
+	Status int                `json:"status"`
+	Stdout string             `json:"stdout"`
+	Stderr string             `json:"stderr"`
+	Env    map[string]*string `json:"env"`
+	Cwd    string             `json:"cwd"`
+}
+
+// pluginDir returns the directory plugins are loaded from.
+func (sh *interp) pluginDir() string {
+	if dir := sh.getVar("GOSH_PLUGIN_DIR"); dir != "" {
+		return dir
+	}
+	home := sh.getVar("HOME")
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".gosh_plugins")
+}
+
+// pluginCacheFile returns the file plugin descriptions are cached in, or
+// "" if there is no home directory.
+func (sh *interp) pluginCacheFile() string {
+	dir := sh.getVar("XDG_CACHE_HOME")
+	if dir == "" {
+		home := sh.getVar("HOME")
+		if home == "" {
+			home, _ = os.UserHomeDir()
+		}
+		if home == "" {
+			return ""
+		}
+		dir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(dir, "gosh", "plugins.json")
+}
+
+// loadPlugins adds a builtin to the registry for each executable in dir.
+// Plugins whose cached description is out of date describe themselves,
+// concurrently; they are registered in name order, and any that fails is
+// reported and skipped. A missing directory is not an error.
+func (sh *interp) loadPlugins(dir string) {
+	dir = sh.path(dir)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	var paths []string
+	var infos []os.FileInfo
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() && info.Mode()&0o111 != 0 {
+			paths = append(paths, path)
+			infos = append(infos, info)
+		}
+	}
+
+	cacheFile := sh.pluginCacheFile()
+	cache := readPluginCache(cacheFile)
+	changed := false
+	for path := range cache {
+		if filepath.Dir(path) == dir && !slices.Contains(paths, path) {
+			delete(cache, path)
+			changed = true
+		}
+	}
+	results := make([]pluginCacheEntry, len(paths))
+	fresh := make([]bool, len(paths))
+	env := sh.vars.Environ()
+	var wg sync.WaitGroup
+	for i, path := range paths {
+		size, modTime := infos[i].Size(), infos[i].ModTime().UnixNano()
+		if e, ok := cache[path]; ok && e.Size == size && e.ModTime == modTime {
+			results[i] = e
+			continue
+		}
+		wg.Go(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), pluginDescribeTimeout)
+			defer cancel()
+			e := pluginCacheEntry{Size: size, ModTime: modTime}
+			if err := sh.callPlugin(ctx, path, map[string]string{"request": "describe"}, env, &e.Description); err != nil {
+				e.Error = err.Error()
+			}
+			// A plugin that timed out may just have been slow this once.
+			results[i], fresh[i] = e, ctx.Err() == nil
+		})
+	}
+	wg.Wait()
+
+	for i, path := range paths {
+		if fresh[i] {
+			cache[path] = results[i]
+			changed = true
+		}
+		var err error
+		if results[i].Error != "" {
+			err = errors.New(results[i].Error)
+		} else {
+			err = sh.registerPlugin(path, results[i].Description)
+		}
+		if err != nil {
+			fmt.Fprintf(sh.stderr(), "%s: plugin %s: %v\n", sh.params.argv0, path, err)
+		}
+	}
+	if changed && cacheFile != "" {
+		writePluginCache(cacheFile, cache)
+	}
+}
+
+// readPluginCache returns the cached plugin descriptions, by path. A
+// missing or unreadable cache is empty.
+func readPluginCache(file string) map[string]pluginCacheEntry {
+	cache := map[string]pluginCacheEntry{}
+	if data, err := os.ReadFile(file); err == nil {
+		if json.Unmarshal(data, &cache) != nil {
+			return map[string]pluginCacheEntry{}
+		}
+	}
+	return cache
+}
+
+// writePluginCache saves the plugin descriptions. Errors are ignored: the
+// plugins are then asked again next time.
+func writePluginCache(file string, cache map[string]pluginCacheEntry) {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(file), 0o755) == nil {
+		replaceFile(file, data, 0o644)
+	}
+}
+
+// registerPlugin adds the plugin at path, as described by d, to the
+// registry.
+func (sh *interp) registerPlugin(path string, d pluginDescription) error {
+	if !validAliasName(d.Name) {
+		return fmt.Errorf("`%s': invalid builtin name", d.Name)
+	}
+	if _, ok := sh.registry[d.Name]; ok {
+		return fmt.Errorf("%s: cannot replace a builtin", d.Name)
+	}
+	cmd := command{
+		Name:        d.Name,
+		Synopsis:    d.Synopsis,
+		Summary:     d.Summary,
+		Description: d.Description,
+		Options:     d.Options,
+		Completions: d.Completions,
+	}
+	if cmd.Synopsis == "" {
+		cmd.Synopsis = d.Name
+	}
+	cmd.Run = helpOption(cmd, func(sh *interp, args []string) int { return sh.runPlugin(d.Name, path, args) })
+	sh.registry[d.Name] = cmd
+	return nil
+}
+
+// runPlugin runs the plugin at path as the builtin name and applies its
+// response.
+func (sh *interp) runPlugin(name, path string, args []string) int {
+	env := sh.vars.Environ()
+	req := pluginRun{Request: "run", Args: args, Env: map[string]string{}}
+	if req.Args == nil {
+		req.Args = []string{}
+	}
+	for _, kv := range env {
+		k, v, _ := strings.Cut(kv, "=")
+		req.Env[k] = v
+	}
+	req.Cwd, _ = sh.workingDir(false)
+
+	var res pluginResult
+	if err := sh.callPlugin(context.Background(), path, req, env, &res); err != nil {
+		// A plugin that exits with an error has had its say on stderr.
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			fmt.Fprintf(sh.stderr(), "%s: %v\n", name, err)
+		}
+		return exitStatus(err)
+	}
+	fmt.Fprint(sh.stdout(), res.Stdout)
+	fmt.Fprint(sh.stderr(), res.Stderr)
+
+	status := res.Status
+	for _, k := range slices.Sorted(maps.Keys(res.Env)) {
+		var err error
+		if v := res.Env[k]; v != nil {
+			err = sh.vars.Set(k, *v)
+		} else {
+			err = sh.vars.Unset(k)
+		}
+		if err != nil {
+			fmt.Fprintf(sh.stderr(), "%s: %v\n", name, err)
+			status = max(status, 1)
+		}
+	}
+	if res.Cwd != "" {
+		if err := sh.changeDir(res.Cwd, false); err != nil {
+			fmt.Fprintf(sh.stderr(), "%s: %s: %v\n", name, res.Cwd, fileError(err))
+			status = max(status, 1)
+		}
+	}
+	return status
+}
+
+// callPlugin sends req to the plugin at path, with env as its
+// environment, and decodes its response into res. If the plugin gives no
+// valid response the error is that of the process, if it failed.
+func (sh *interp) callPlugin(ctx context.Context, path string, req any, env []string, res any) error {
+	data, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	cmd := exec.CommandContext(ctx, path)
+	cmd.Stdin = bytes.NewReader(append(data, '\n'))
+	cmd.Stderr = sh.stderr()
+	cmd.Env = env
+	cmd.Dir = sh.dir
+	out, runErr := cmd.Output()
+	if err := json.Unmarshal(out, res); err != nil {
+		if runErr != nil {
+			return runErr
+		}
+		return fmt.Errorf("invalid response: %v", err)
+	}
+	return nil
+}
+
+// argumentCompletions returns the completions of cmd that start with
+// word.
+func argumentCompletions(cmd command, word string) []string {
+	var words []string
+	for _, w := range cmd.Completions {
+		if strings.HasPrefix(w, word) {
+			words = append(words, w)
+		}
+	}
+	return words
+}
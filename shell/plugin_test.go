@@ -0,0 +1,167 @@
+package shell
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testPlugins are the plugin scripts of TestPlugins, by file name. Each
+// answers describe from the first line of its request.
+var testPlugins = map[string]string{
+	"greet": `read -r req
+case $req in
+*'"describe"'*) echo '{"name":"greet","synopsis":"greet [-l] name","summary":"Say hello.","options":[{"flag":"-l","help":"loudly"}],"completions":["alice","bob"]}' ;;
+*) printf '%s\n' "$req" >"$GREET_LOG"
+   printf '%s\n' '{"status":3,"stdout":"hello\n","stderr":"warn\n","env":{"GREETED":"yes","GONE":null},"cwd":"'"$GREET_DIR"'"}' ;;
+esac`,
+	"fail": `read -r req
+case $req in
+*'"describe"'*) echo '{"name":"fail","summary":"Fail."}' ;;
+*) echo oops >&2; exit 4 ;;
+esac`,
+	"broken":  `echo not json`,
+	"shadow":  `echo '{"name":"cd","summary":"Not cd."}'`,
+	"badname": `echo '{"name":"a b","summary":"Bad."}'`,
+}
+
+func TestPlugins(t *testing.T) {
+	sh := newTestShell(t)
+	dir := t.TempDir()
+	for name, src := range testPlugins {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+src+"\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notexec"), []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	oldRegistry := maps.Clone(sh.registry)
+	t.Cleanup(func() { sh.registry = oldRegistry })
+	setupTestVars(t, sh, "PATH="+os.Getenv("PATH"), "XDG_CACHE_HOME="+t.TempDir())
+	setupTestParams(t, sh)
+
+	loadErr := captureStderr(t, sh, func() { sh.loadPlugins(dir) })
+	for _, want := range []string{
+		"plugin " + filepath.Join(dir, "broken") + ": invalid response",
+		"plugin " + filepath.Join(dir, "shadow") + ": cd: cannot replace a builtin",
+		"plugin " + filepath.Join(dir, "badname") + ": `a b': invalid builtin name",
+	} {
+		if !strings.Contains(loadErr, want) {
+			t.Errorf("load errors %q do not contain %q", loadErr, want)
+		}
+	}
+	for name, want := range map[string]bool{"greet": true, "fail": true, "notexec": false, "broken": false} {
+		if _, ok := sh.registry[name]; ok != want {
+			t.Errorf("registry[%q] present = %v, want %v", name, ok, want)
+		}
+	}
+	if sh.registry["cd"].Summary == "Not cd." {
+		t.Error("plugin replaced cd")
+	}
+
+	t.Run("run", func(t *testing.T) {
+		t.Chdir(t.TempDir())
+		log, newDir := filepath.Join(t.TempDir(), "request"), t.TempDir()
+		src := "export GREET_LOG=" + log + " GREET_DIR=" + newDir + "\nGONE=1\ngreet -l bob\n" +
+			`echo "$? $GREETED ${GONE-unset} $PWD"`
+		var got string
+		gotErr := captureStderr(t, sh, func() { got, _ = runTestScript(t, sh, src) })
+		if want := "hello\n3 yes unset " + newDir + "\n"; got != want || gotErr != "warn\n" {
+			t.Errorf("got %q, stderr %q; want %q, %q", got, gotErr, want, "warn\n")
+		}
+
+		data, err := os.ReadFile(log)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var req pluginRun
+		if err := json.Unmarshal(data, &req); err != nil {
+			t.Fatal(err)
+		}
+		if req.Request != "run" || !slices.Equal(req.Args, []string{"-l", "bob"}) || req.Env["GREET_LOG"] != log || req.Cwd == "" {
+			t.Errorf("request = %+v", req)
+		}
+		if _, ok := req.Env["GONE"]; ok {
+			t.Error("request env has the unexported GONE")
+		}
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		var got string
+		var status int
+		gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, "fail") })
+		if got != "" || gotErr != "oops\n" || status != 4 {
+			t.Errorf("got %q, stderr %q, status %d", got, gotErr, status)
+		}
+	})
+
+	t.Run("help", func(t *testing.T) {
+		got, _ := runTestScript(t, sh, "greet --help")
+		want := "greet: greet [-l] name\n    Say hello.\n\n    Options:\n      -l         loudly\n"
+		if got != want {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	})
+
+	t.Run("completion", func(t *testing.T) {
+		for line, want := range map[string]string{"greet b": "ob ", "greet --": "help ", "greet x": ""} {
+			got, _ := (&builtinCompleter{sh: sh}).Do([]rune(line), len(line))
+			if len(got) > 1 || len(got) == 1 && string(got[0]) != want || len(got) == 0 && want != "" {
+				t.Errorf("Do(%q) = %q, want %q", line, got, want)
+			}
+		}
+	})
+}
+
+func TestPluginCache(t *testing.T) {
+	sh := newTestShell(t)
+	dir, log := t.TempDir(), filepath.Join(t.TempDir(), "describes")
+	path := filepath.Join(dir, "greet")
+	src := "#!/bin/sh\necho describe >>" + log + "\n" + `echo '{"name":"greet","summary":"Say hello."}'` + "\n"
+	if err := os.WriteFile(path, []byte(src), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	oldRegistry := maps.Clone(sh.registry)
+	t.Cleanup(func() { sh.registry = oldRegistry })
+	cache := t.TempDir()
+	setupTestVars(t, sh, "PATH="+os.Getenv("PATH"), "HOME="+cache)
+	setupTestParams(t, sh)
+
+	describes := func() int {
+		data, _ := os.ReadFile(log)
+		return strings.Count(string(data), "describe\n")
+	}
+	load := func() {
+		t.Helper()
+		sh.registry = maps.Clone(oldRegistry)
+		if msg := captureStderr(t, sh, func() { sh.loadPlugins(dir) }); msg != "" {
+			t.Fatalf("loadPlugins: %s", msg)
+		}
+		if sh.registry["greet"].Summary != "Say hello." {
+			t.Fatal("greet is not registered")
+		}
+	}
+
+	load()
+	if _, err := os.Stat(filepath.Join(cache, ".cache", "gosh", "plugins.json")); err != nil {
+		t.Fatal(err)
+	}
+	load()
+	if n := describes(); n != 1 {
+		t.Fatalf("described %d times for two loads of an unchanged plugin, want 1", n)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	load()
+	if n := describes(); n != 2 {
+		t.Errorf("described %d times after the plugin changed, want 2", n)
+	}
+}
@@ -0,0 +1,453 @@
+// printf.go — the printf builtin.
+//
+//	printf [-v VAR] FORMAT [ARG...]
+//
+//	%s %b %q %c        string; with escapes expanded; quoted for reuse;
+//	                   first character
+//	%d %i              signed decimal
+//	%u %o %x %X        unsigned decimal, octal, hex
+//	%f %F %e %E %g %G  floating point
+//	%%                 a literal %
+//
+// Specifications take the flags - + space # 0, a width and a precision,
+// either of which may be * to take it from the next argument. FORMAT is
+// reused while arguments remain; missing ones count as "" or 0. Numeric
+// arguments may be decimal, 0-prefixed octal, 0x hex, each with a sign, or
+// a quote followed by a character, which stands for its code; one out of
+// range, like a width or precision over maxFieldWidth, is clamped and
+// makes the status 1. Escapes in FORMAT are expanded as in C; those of %b
+// follow echo -e, where \c ends all output.
+//
+// -v VAR assigns the output to VAR instead of printing it.
+
+package shell
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// printfState is one run of printf: the arguments still to consume and
+// the output so far.
+type printfState struct {
+	sh     *interp
+	args   []string
+	pos    int
+	out    strings.Builder
+	status int
+	stop   bool // \c in a %b argument ended the output
+}
+
+// builtinPrintf implements printf.
+func (sh *interp) builtinPrintf(args []string) int {
+	opts, args, ok := sh.parseOptions("printf", "v:", args)
+	if !ok {
+		return 2
+	}
+	varName := ""
+	for _, opt := range opts {
+		varName = opt.arg
+		if !isValidName(varName) {
+			fmt.Fprintf(sh.stderr(), "printf: `%s': not a valid identifier\n", varName)
+			return 1
+		}
+	}
+	if len(args) == 0 {
+		fmt.Fprintln(sh.stderr(), "printf: usage: printf [-v var] format [arguments]")
+		return 2
+	}
+
+	p := &printfState{sh: sh, args: args[1:]}
+	for {
+		start := p.pos
+		if err := p.format(args[0]); err != nil {
+			fmt.Fprintf(sh.stderr(), "printf: %v\n", err)
+			p.status = 1
+			break
+		}
+		if p.stop || p.pos >= len(p.args) || p.pos == start {
+			break
+		}
+	}
+
+	if varName != "" {
+		if err := sh.vars.Set(varName, p.out.String()); err != nil {
+			fmt.Fprintf(sh.stderr(), "printf: %v\n", err)
+			return 1
+		}
+		return p.status
+	}
+	fmt.Fprint(sh.stdout(), p.out.String())
+	return p.status
+}
+
+// next returns the next argument, or "" when they have run out.
+func (p *printfState) next() string {
+	if p.pos >= len(p.args) {
+		return ""
+	}
+	p.pos++
+	return p.args[p.pos-1]
+}
+
+// format writes one pass over the format string.
+func (p *printfState) format(f string) error {
+	for i := 0; i < len(f) && !p.stop; {
+		switch f[i] {
+		case '\\':
+			text, next, _ := escapeAt(f, i, true)
+			p.out.WriteString(text)
+			i = next
+		case '%':
+			next, err := p.spec(f, i)
+			if err != nil {
+				return err
+			}
+			i = next
+		default:
+			p.out.WriteByte(f[i])
+			i++
+		}
+	}
+	return nil
+}
+
+// spec formats the conversion specification starting at f[i], a '%', and
+// returns the index just past it.
+func (p *printfState) spec(f string, i int) (int, error) {
+	i++
+	if i < len(f) && f[i] == '%' {
+		p.out.WriteByte('%')
+		return i + 1, nil
+	}
+
+	var flags strings.Builder
+	for i < len(f) && strings.IndexByte("-+ #0", f[i]) >= 0 {
+		flags.WriteByte(f[i])
+		i++
+	}
+	width, i := p.specNumber(f, i)
+	if strings.HasPrefix(width, "-") {
+		flags.WriteByte('-')
+		width = width[1:]
+	}
+	precision, hasPrecision := "", false
+	if i < len(f) && f[i] == '.' {
+		precision, i = p.specNumber(f, i+1)
+		hasPrecision = true
+		if strings.HasPrefix(precision, "-") {
+			precision, hasPrecision = "", false
+		} else if precision == "" {
+			precision = "0"
+		}
+	}
+	if i >= len(f) {
+		return i, fmt.Errorf("`%s': missing format character", f[strings.LastIndexByte(f[:i], '%'):])
+	}
+
+	verb := f[i]
+	flagText := flags.String()
+	if strings.IndexByte("sbqc", verb) >= 0 {
+		// Strings are padded with spaces, whatever the 0 flag says.
+		flagText = strings.ReplaceAll(flagText, "0", "")
+	}
+	goFmt := "%" + flagText + width
+	if hasPrecision {
+		goFmt += "." + precision
+	}
+	switch verb {
+	case 's':
+		p.out.WriteString(fmt.Sprintf(goFmt+"s", p.next()))
+	case 'b':
+		s, stop := expandEscapes(p.next(), false)
+		p.out.WriteString(fmt.Sprintf(goFmt+"s", s))
+		p.stop = stop
+	case 'q':
+		p.out.WriteString(fmt.Sprintf(goFmt+"s", shellQuote(p.next())))
+	case 'c':
+		arg := p.next()
+		if arg != "" {
+			_, size := utf8.DecodeRuneInString(arg)
+			arg = arg[:size]
+		}
+		p.out.WriteString(fmt.Sprintf("%"+flagText+width+"s", arg))
+	case 'd', 'i':
+		p.out.WriteString(fmt.Sprintf(goFmt+"d", p.integer()))
+	case 'u':
+		p.out.WriteString(fmt.Sprintf(goFmt+"d", p.unsigned()))
+	case 'o', 'x', 'X':
+		p.out.WriteString(fmt.Sprintf(goFmt+string(verb), p.unsigned()))
+	case 'f', 'F', 'e', 'E', 'g', 'G':
+		if !hasPrecision && (verb == 'g' || verb == 'G') {
+			// C's %g has a default precision of 6; Go's is the shortest
+			// representation.
+			goFmt += ".6"
+		}
+		if verb == 'F' {
+			verb = 'f'
+		}
+		p.out.WriteString(fmt.Sprintf(goFmt+string(verb), p.float()))
+	default:
+		return i, fmt.Errorf("`%c': invalid format character", verb)
+	}
+	return i + 1, nil
+}
+
+// specNumber reads the width or precision at f[i]: digits, or * for the
+// next argument as an integer. One larger than maxFieldWidth is reported
+// and clamped.
+func (p *printfState) specNumber(f string, i int) (string, int) {
+	var n string
+	if i < len(f) && f[i] == '*' {
+		n, i = strconv.FormatInt(p.integer(), 10), i+1
+	} else {
+		start := i
+		for i < len(f) && f[i] >= '0' && f[i] <= '9' {
+			i++
+		}
+		n = f[start:i]
+	}
+	sign, digits := "", n
+	if strings.HasPrefix(n, "-") {
+		sign, digits = "-", n[1:]
+	}
+	if v, err := strconv.Atoi(digits); digits != "" && (err != nil || v > maxFieldWidth) {
+		p.rangeError(n)
+		n = sign + strconv.Itoa(maxFieldWidth)
+	}
+	return n, i
+}
+
+// maxFieldWidth is the largest width or precision printf takes, the
+// largest fmt accepts.
+const maxFieldWidth = 1_000_000
+
+// integer consumes the next argument as a signed integer for %d, %i and
+// *. A value out of range is reported and clamped.
+func (p *printfState) integer() int64 {
+	arg, mag, neg, overflow := p.number()
+	switch {
+	case overflow || neg && mag > 1<<63:
+		p.rangeError(arg)
+		if neg {
+			return math.MinInt64
+		}
+		return math.MaxInt64
+	case !neg && mag > math.MaxInt64:
+		p.rangeError(arg)
+		return math.MaxInt64
+	case neg:
+		return int64(-mag)
+	}
+	return int64(mag)
+}
+
+// unsigned consumes the next argument as an unsigned integer for %u, %o,
+// %x and %X. A negative value wraps around, as in C.
+func (p *printfState) unsigned() uint64 {
+	arg, mag, neg, overflow := p.number()
+	switch {
+	case overflow:
+		p.rangeError(arg)
+		return math.MaxUint64
+	case neg:
+		return -mag
+	}
+	return mag
+}
+
+// number consumes the next argument as an integer and returns it with its
+// magnitude and sign, as C's strtoumax reads it: leading blanks, an
+// optional sign, then 0x and hex digits, 0 and octal digits, or decimal
+// digits. Trailing text is reported, and the digits before it used.
+// overflow is set if the magnitude does not fit in 64 bits.
+func (p *printfState) number() (arg string, mag uint64, neg, overflow bool) {
+	arg = p.next()
+	if n, ok := charCode(arg); ok {
+		return arg, uint64(n), false, false
+	}
+	s := strings.TrimLeft(arg, " \t\n")
+	if s == "" {
+		return arg, 0, false, false
+	}
+	if s[0] == '+' || s[0] == '-' {
+		neg, s = s[0] == '-', s[1:]
+	}
+	base := uint64(10)
+	switch {
+	case len(s) > 2 && (s[:2] == "0x" || s[:2] == "0X") && hexDigit(s[2]) < 16:
+		base, s = 16, s[2:]
+	case len(s) > 1 && s[0] == '0':
+		base = 8
+	}
+	i := 0
+	for ; i < len(s) && hexDigit(s[i]) < base; i++ {
+		d := hexDigit(s[i])
+		if mag > (math.MaxUint64-d)/base {
+			overflow = true
+		}
+		mag = mag*base + d
+	}
+	if i == 0 || i < len(s) {
+		p.invalidNumber(arg)
+	}
+	return arg, mag, neg, overflow
+}
+
+// hexDigit returns the value of the hex digit c, or 16 if it is not one.
+func hexDigit(c byte) uint64 {
+	switch {
+	case c >= '0' && c <= '9':
+		return uint64(c - '0')
+	case c >= 'a' && c <= 'f':
+		return uint64(c-'a') + 10
+	case c >= 'A' && c <= 'F':
+		return uint64(c-'A') + 10
+	}
+	return 16
+}
+
+// float consumes the next argument as a floating-point number.
+func (p *printfState) float() float64 {
+	arg := p.next()
+	if n, ok := charCode(arg); ok {
+		return float64(n)
+	}
+	s := strings.TrimLeft(arg, " \t\n")
+	if s == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		p.invalidNumber(arg)
+	}
+	return f
+}
+
+// invalidNumber reports a numeric argument that does not parse; printf
+// carries on, but its status is 1.
+func (p *printfState) invalidNumber(arg string) {
+	fmt.Fprintf(p.sh.stderr(), "printf: %s: invalid number\n", arg)
+	p.status = 1
+}
+
+// rangeError reports a number printf had to clamp; its status is 1.
+func (p *printfState) rangeError(arg string) {
+	fmt.Fprintf(p.sh.stderr(), "printf: %s: Result too large\n", arg)
+	p.status = 1
+}
+
+// charCode returns the character code a numeric argument like 'a or "a
+// stands for.
+func charCode(arg string) (int64, bool) {
+	if arg == "" || (arg[0] != '\'' && arg[0] != '"') {
+		return 0, false
+	}
+	r, _ := utf8.DecodeRuneInString(arg[1:])
+	if len(arg) == 1 {
+		r = 0
+	}
+	return int64(r), true
+}
+
+// expandEscapes expands the backslash escapes in s, as echo -e and %b do
+// (forFormat false) or in a printf format (forFormat true). It reports
+// whether a \c, which only echo -e and %b know, ended the text.
+func expandEscapes(s string, forFormat bool) (string, bool) {
+	if !strings.Contains(s, `\`) {
+		return s, false
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			i++
+			continue
+		}
+		text, next, stop := escapeAt(s, i, forFormat)
+		if stop {
+			return b.String(), true
+		}
+		b.WriteString(text)
+		i = next
+	}
+	return b.String(), false
+}
+
+// escapeAt expands the escape at s[i], a backslash, and returns its text
+// and the index just past it. stop is set for \c outside a format.
+// Unknown escapes are kept as they are.
+func escapeAt(s string, i int, forFormat bool) (text string, next int, stop bool) {
+	if i+1 >= len(s) {
+		return `\`, i + 1, false
+	}
+	c := s[i+1]
+	switch c {
+	case 'a':
+		return "\a", i + 2, false
+	case 'b':
+		return "\b", i + 2, false
+	case 'e', 'E':
+		return "\x1b", i + 2, false
+	case 'f':
+		return "\f", i + 2, false
+	case 'n':
+		return "\n", i + 2, false
+	case 'r':
+		return "\r", i + 2, false
+	case 't':
+		return "\t", i + 2, false
+	case 'v':
+		return "\v", i + 2, false
+	case '\\':
+		return `\`, i + 2, false
+	case 'c':
+		if !forFormat {
+			return "", i + 2, true
+		}
+	case '"', '\'', '?':
+		if forFormat {
+			return string(c), i + 2, false
+		}
+	case 'x':
+		if n, end := escapeNumber(s, i+2, 16, 2); end > i+2 {
+			return string([]byte{byte(n)}), end, false
+		}
+	case 'u', 'U':
+		digits := 4
+		if c == 'U' {
+			digits = 8
+		}
+		if n, end := escapeNumber(s, i+2, 16, digits); end > i+2 {
+			return string(rune(n)), end, false
+		}
+	}
+	if c >= '0' && c <= '7' {
+		// \NNN; %b and echo -e also take \0NNN.
+		start := i + 1
+		if c == '0' && !forFormat {
+			start++
+		}
+		n, end := escapeNumber(s, start, 8, 3)
+		return string([]byte{byte(n)}), end, false
+	}
+	return s[i : i+2], i + 2, false
+}
+
+// escapeNumber parses up to limit digits in base from s[i:] and returns
+// the value and the index after the digits.
+func escapeNumber(s string, i, base, limit int) (int, int) {
+	n, end := 0, i
+	for end < len(s) && end-i < limit {
+		d := int(hexDigit(s[end]))
+		if d >= base {
+			break
+		}
+		n = n*base + d
+		end++
+	}
+	return n, end
+}
@@ -0,0 +1,106 @@
+package shell
+
+import "testing"
+
+func TestPrintf(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name    string
+		args    []string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "plain", args: []string{`a\tb\n`}, want: "a\tb\n"},
+		{name: "strings", args: []string{"%s|%5s|%-5s|%.2s|", "a", "b", "c", "defg"}, want: "a|    b|c    |de|"},
+		{name: "zero flag on strings", args: []string{"%03s", "a"}, want: "  a"},
+		{name: "integers", args: []string{"%d %i %+d % d %05d %-3d|", "42", "-7", "3", "3", "42", "1"}, want: "42 -7 +3  3 00042 1  |"},
+		{name: "unsigned", args: []string{"%x %X %#x %o %#o %u", "255", "255", "255", "8", "8", "-1"}, want: "ff FF 0xff 10 010 18446744073709551615"},
+		{name: "number forms", args: []string{"%d %d %d %d", "0x10", "010", "'A", " 5"}, want: "16 8 65 5"},
+		{name: "floats", args: []string{"%f %.2f %e %8.3f|", "3.14159", "2.5", "12345.678", "3.14159"}, want: "3.141590 2.50 1.234568e+04    3.142|"},
+		{name: "g like C", args: []string{"%g %g %G", "0.0001", "1234567", "1e-10"}, want: "0.0001 1.23457e+06 1E-10"},
+		{name: "star", args: []string{"%*d|%-*d|%.*f", "5", "1", "4", "2", "2", "3.14159"}, want: "    1|2   |3.14"},
+		{name: "negative star width", args: []string{"%*s|", "-3", "a"}, want: "a  |"},
+		{name: "char", args: []string{"%c%c|%3c", "hello", "W", "x"}, want: "hW|  x"},
+		{name: "b", args: []string{"%b|", `a\tb\x41\0101`}, want: "a\tbAA|"},
+		{name: "b stops at c", args: []string{"%b after", `a\cb`, "more"}, want: "a"},
+		{name: "q", args: []string{"%q %q", "it's", "plain"}, want: `'it'\''s' plain`},
+		{name: "percent", args: []string{"100%%"}, want: "100%"},
+		{name: "recycling", args: []string{"%s=%s;", "a", "1", "b", "2", "c"}, want: "a=1;b=2;c=;"},
+		{name: "no specifiers with args", args: []string{"x", "extra"}, want: "x"},
+		{name: "missing arguments", args: []string{"[%s][%d]"}, want: "[][0]"},
+		{name: "format escapes", args: []string{`\101\x42é\"\q`}, want: `ABé"\q`},
+		{name: "invalid number", args: []string{"%d|", "abc"}, want: "0|", wantErr: "printf: abc: invalid number\n", status: 1},
+		{name: "trailing text", args: []string{"%d|", "12abc"}, want: "12|", wantErr: "printf: 12abc: invalid number\n", status: 1},
+		{name: "no binary or underscores", args: []string{"%d %d|", "0b11", "1_000"}, want: "0 1|", wantErr: "printf: 0b11: invalid number\nprintf: 1_000: invalid number\n", status: 1},
+		{name: "octal needs octal digits", args: []string{"%d|", "09"}, want: "0|", wantErr: "printf: 09: invalid number\n", status: 1},
+		{name: "signed hex and octal", args: []string{"%d %d %d", "-0x10", "+010", "0X1f"}, want: "-16 8 31"},
+		{name: "largest integers", args: []string{"%d %d %u", "9223372036854775807", "-9223372036854775808", "18446744073709551615"}, want: "9223372036854775807 -9223372036854775808 18446744073709551615"},
+		{name: "signed overflow clamps", args: []string{"%d %d|", "9223372036854775808", "-99999999999999999999"}, want: "9223372036854775807 -9223372036854775808|", wantErr: "printf: 9223372036854775808: Result too large\nprintf: -99999999999999999999: Result too large\n", status: 1},
+		{name: "unsigned overflow clamps", args: []string{"%x|", "0x10000000000000000"}, want: "ffffffffffffffff|", wantErr: "printf: 0x10000000000000000: Result too large\n", status: 1},
+		{name: "invalid conversion", args: []string{"a%zb"}, want: "a", wantErr: "printf: `z': invalid format character\n", status: 1},
+		{name: "missing conversion", args: []string{"a%5"}, want: "a", wantErr: "printf: `%5': missing format character\n", status: 1},
+		{name: "no format", wantErr: "printf: usage: printf [-v var] format [arguments]\n", status: 2},
+		{name: "dash dash", args: []string{"--", "-%s", "x"}, want: "-x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() {
+				got = captureStdout(t, sh, func() { status = sh.builtinPrintf(tt.args) })
+			})
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("printf %q: got %q, stderr %q, status %d; want %q, %q, %d", tt.args, got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
+
+func TestPrintfAssign(t *testing.T) {
+	sh := newTestShell(t)
+	got, status := runTestScript(t, sh, `printf -v out '%03d-%s' 7 x; echo "$out"`)
+	if got != "007-x\n" || status != 0 {
+		t.Errorf("printf -v: got %q, status %d", got, status)
+	}
+}
+
+func TestPrintfFieldWidthBound(t *testing.T) {
+	sh := newTestShell(t)
+	var got string
+	var status int
+	gotErr := captureStderr(t, sh, func() {
+		got, status = runTestScript(t, sh, `printf -v w '%99999999999s|' x; printf -v p '%.*f' 2000000 1; echo ${#w} ${#p}`)
+	})
+	wantErr := "printf: 99999999999: Result too large\nprintf: 2000000: Result too large\n"
+	if got != "1000001 1000002\n" || gotErr != wantErr || status != 0 {
+		t.Errorf("got %q, stderr %q, status %d", got, gotErr, status)
+	}
+}
+
+func TestExpandEscapes(t *testing.T) {
+	tests := []struct {
+		in        string
+		forFormat bool
+		want      string
+		stop      bool
+	}{
+		{`a\nb`, false, "a\nb", false},
+		{`\0101\101`, false, "AA", false},
+		{`\0101`, true, "\x08" + "1", false},
+		{`\e[1m`, false, "\x1b[1m", false},
+		{`a\cb`, false, "a", true},
+		{`a\cb`, true, `a\cb`, false},
+		{`\'`, false, `\'`, false},
+		{`\'`, true, `'`, false},
+		{`\x4g`, false, "\x04g", false},
+		{`\xg`, false, `\xg`, false},
+		{`tail\`, false, `tail\`, false},
+	}
+	for _, tt := range tests {
+		got, stop := expandEscapes(tt.in, tt.forFormat)
+		if got != tt.want || stop != tt.stop {
+			t.Errorf("expandEscapes(%q, %v) = %q, %v; want %q, %v", tt.in, tt.forFormat, got, stop, tt.want, tt.stop)
+		}
+	}
+}
@@ -0,0 +1,404 @@
+// read.go — the read builtin: read a line of input into variables.
+//
+//	read [-rs] [-a ARRAY] [-d DELIM] [-n COUNT] [-p PROMPT] [-t TIMEOUT]
+//	     [-u FD] [NAME...]
+//
+//	-r         backslash is an ordinary character, not an escape
+//	-s         do not echo input typed at a terminal
+//	-a ARRAY   store every field in the indexed array ARRAY
+//	-d DELIM   stop at the first character of DELIM instead of newline
+//	           ("" means NUL)
+//	-n COUNT   stop after COUNT characters
+//	-p PROMPT  print PROMPT to stderr first, if input is a terminal
+//	-t TIMEOUT give up after TIMEOUT seconds (status 142); -t 0 only
+//	           reports whether input is waiting
+//	-u FD      read from file descriptor FD
+//
+// The line is split at IFS like an unquoted expansion: each NAME gets a
+// field and the last NAME the rest of the line, less surrounding IFS
+// whitespace. With no NAME the whole line goes to REPLY unsplit. Without
+// -r a backslash quotes the next character (keeping an IFS character in
+// its field) and a backslash-newline continues the line.
+//
+// Input is read a byte at a time from standard input, which is the pipe
+// in a pipeline, so that "cat f | while read -r l; do ...; done" leaves
+// the rest of the input for the next read. Status is 1 at end of input,
+// and when the run of an Interpreter is cancelled while read waits.
+
+package shell
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+	"unsafe"
+
+	"github.com/chzyer/readline"
+)
+
+// readTimeoutStatus is the status of read when -t expires: 128+SIGALRM.
+const readTimeoutStatus = 128 + 14
+
+var errReadTimeout = errors.New("timed out")
+
+// readOptions are the parsed options of read.
+type readOptions struct {
+	raw, silent bool
+	array       string
+	delim       byte
+	count       int // -1: no limit
+	prompt      string
+	timeout     time.Duration
+	timed       bool
+	fd          int // -1: standard input
+}
+
+// readChar is a character of input and whether a backslash quoted it.
+type readChar struct {
+	r      rune
+	quoted bool
+}
+
+// builtinRead implements read.
+func (sh *interp) builtinRead(args []string) int {
+	parsed, names, ok := sh.parseOptions("read", "rsa:d:n:p:t:u:", args)
+	if !ok {
+		return 2
+	}
+	opts := readOptions{delim: '\n', count: -1, fd: -1}
+	for _, opt := range parsed {
+		if err := opts.set(opt.c, opt.arg); err != nil {
+			fmt.Fprintf(sh.stderr(), "read: %v\n", err)
+			return 1
+		}
+	}
+	for _, name := range append([]string{opts.array}, names...) {
+		if name != "" && !isValidName(name) {
+			fmt.Fprintf(sh.stderr(), "read: `%s': not a valid identifier\n", name)
+			return 1
+		}
+	}
+
+	in := readInput{file: sh.stdin(), ctx: sh.ctx}
+	if opts.fd >= 0 {
+		if in.file = sh.fd(opts.fd); in.file == nil {
+			fmt.Fprintf(sh.stderr(), "read: %d: invalid file descriptor: %v\n", opts.fd, syscall.EBADF)
+			return 1
+		}
+	}
+	if opts.timed {
+		if opts.timeout == 0 {
+			ready, err := waitReadable(in.descriptor(), 0)
+			return boolStatus(err == nil && ready)
+		}
+		in.deadline = time.Now().Add(opts.timeout)
+	}
+
+	if opts.prompt != "" || opts.silent {
+		fd := in.descriptor()
+		if readline.IsTerminal(fd) {
+			fmt.Fprint(sh.stderr(), opts.prompt)
+			if opts.silent {
+				if restore, err := echoOff(fd); err == nil {
+					defer restore()
+				}
+			}
+		}
+	}
+
+	stop := in.interruptOnDone()
+	line, err := readLine(&in, opts)
+	stop()
+	status := 0
+	switch {
+	case sh.ctx.Err() != nil:
+		return 1 // the run was cancelled
+	case errors.Is(err, errReadTimeout):
+		status = readTimeoutStatus
+	case errors.Is(err, io.EOF):
+		status = 1
+	case err != nil:
+		fmt.Fprintf(sh.stderr(), "read: read error: %d: %v\n", max(opts.fd, 0), err)
+		return 1
+	}
+
+	if opts.array != "" {
+		fields := sh.splitRead(line, -1)
+		elems := make([]arrayElem, len(fields))
+		for i, f := range fields {
+			elems[i] = arrayElem{Value: f}
+		}
+		if err := sh.vars.SetArray(opts.array, elems, false); err != nil {
+			fmt.Fprintf(sh.stderr(), "read: %v\n", err)
+			return 1
+		}
+		return status
+	}
+	if len(names) == 0 {
+		var b strings.Builder
+		for _, c := range line {
+			b.WriteRune(c.r)
+		}
+		if err := sh.vars.Set("REPLY", b.String()); err != nil {
+			fmt.Fprintf(sh.stderr(), "read: %v\n", err)
+			return 1
+		}
+		return status
+	}
+	fields := sh.splitRead(line, len(names))
+	for i, name := range names {
+		value := ""
+		if i < len(fields) {
+			value = fields[i]
+		}
+		if err := sh.vars.Set(name, value); err != nil {
+			fmt.Fprintf(sh.stderr(), "read: %v\n", err)
+			return 1
+		}
+	}
+	return status
+}
+
+// set applies an option of read.
+func (o *readOptions) set(c byte, arg string) error {
+	switch c {
+	case 'r':
+		o.raw = true
+	case 's':
+		o.silent = true
+	case 'a':
+		o.array = arg
+	case 'd':
+		o.delim = 0
+		if arg != "" {
+			o.delim = arg[0]
+		}
+	case 'n':
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%s: invalid number", arg)
+		}
+		o.count = n
+	case 'p':
+		o.prompt = arg
+	case 't':
+		secs, err := strconv.ParseFloat(arg, 64)
+		if err != nil || secs < 0 {
+			return fmt.Errorf("%s: invalid timeout specification", arg)
+		}
+		o.timeout, o.timed = time.Duration(secs*float64(time.Second)), true
+	case 'u':
+		fd, err := strconv.Atoi(arg)
+		if err != nil || fd < 0 {
+			return fmt.Errorf("%s: invalid file descriptor specification", arg)
+		}
+		o.fd = fd
+	}
+	return nil
+}
+
+// readInput reads single bytes from one of the shell's file descriptors,
+// giving up at deadline if it is set, and when ctx is done.
+type readInput struct {
+	file     *os.File
+	deadline time.Time
+	ctx      context.Context
+	poll     bool // file cannot be interrupted: wait for input in slices
+}
+
+// cancelPoll is how long a read from a file that has no deadlines waits
+// for input before it checks whether the run has been cancelled.
+const cancelPoll = 100 * time.Millisecond
+
+// interruptOnDone makes a Read of in.file that is blocked when in.ctx is
+// done return, by setting the file's read deadline; for a file without
+// deadlines, such as a terminal, byte polls instead. stop undoes it.
+func (in *readInput) interruptOnDone() (stop func()) {
+	if in.ctx.Done() == nil {
+		return func() {}
+	}
+	if !in.nonBlocking() {
+		in.poll = true
+		return func() {}
+	}
+	interrupted := make(chan struct{})
+	stopAfter := context.AfterFunc(in.ctx, func() {
+		in.file.SetReadDeadline(time.Now())
+		close(interrupted)
+	})
+	return func() {
+		if !stopAfter() {
+			<-interrupted
+			in.file.SetReadDeadline(time.Time{})
+		}
+	}
+}
+
+// nonBlocking reports whether in.file is in non-blocking mode, where the
+// runtime's poller reads it and a read deadline interrupts a Read. (Fd
+// would put it in blocking mode, so look through SyscallConn.)
+func (in *readInput) nonBlocking() bool {
+	rc, err := in.file.SyscallConn()
+	if err != nil {
+		return false
+	}
+	nonBlocking := false
+	rc.Control(func(fd uintptr) {
+		flags, _, e := syscall.Syscall(syscall.SYS_FCNTL, fd, syscall.F_GETFL, 0)
+		nonBlocking = e == 0 && flags&syscall.O_NONBLOCK != 0
+	})
+	return nonBlocking
+}
+
+// descriptor returns the file descriptor being read.
+func (in *readInput) descriptor() int {
+	return int(in.file.Fd())
+}
+
+// byte reads one byte.
+func (in *readInput) byte() (byte, error) {
+	for in.poll || !in.deadline.IsZero() {
+		wait := cancelPoll
+		if !in.deadline.IsZero() {
+			wait = max(time.Until(in.deadline), 0)
+			if in.poll {
+				wait = min(wait, cancelPoll)
+			}
+		}
+		ready, err := waitReadable(in.descriptor(), wait)
+		if err != nil {
+			return 0, err
+		}
+		if ready {
+			break
+		}
+		if err := in.ctx.Err(); err != nil {
+			return 0, err
+		}
+		if !in.deadline.IsZero() && !time.Now().Before(in.deadline) {
+			return 0, errReadTimeout
+		}
+	}
+	var buf [1]byte
+	for {
+		n, err := in.file.Read(buf[:])
+		switch {
+		case n == 1:
+			return buf[0], nil
+		case err == syscall.EINTR:
+			continue
+		case errors.Is(err, os.ErrDeadlineExceeded) && in.ctx.Err() != nil:
+			err = in.ctx.Err()
+		case err == nil:
+			err = io.EOF
+		}
+		return 0, err
+	}
+}
+
+// readLine reads up to the delimiter (which is dropped), or COUNT
+// characters, handling backslashes unless -r. It returns what was read
+// even when it also returns an error, such as io.EOF.
+func readLine(in *readInput, opts readOptions) ([]readChar, error) {
+	var line []readChar
+	var pending []byte // bytes of an incomplete UTF-8 character
+	quoted := false
+	for opts.count < 0 || len(line) < opts.count {
+		b, err := in.byte()
+		if err != nil {
+			return line, err
+		}
+		if len(pending) == 0 {
+			switch {
+			case quoted && b == '\n':
+				quoted = false
+				continue
+			case !quoted && !opts.raw && b == '\\':
+				quoted = true
+				continue
+			case !quoted && b == opts.delim:
+				return line, nil
+			}
+		}
+		pending = append(pending, b)
+		if !utf8.FullRune(pending) {
+			continue
+		}
+		r, _ := utf8.DecodeRune(pending)
+		line = append(line, readChar{r: r, quoted: quoted})
+		pending, quoted = pending[:0], false
+	}
+	return line, nil
+}
+
+// splitRead splits line into at most n fields (all of them if n < 0) at
+// unquoted IFS characters, as field splitting does; the last of n fields
+// holds the rest of the line with trailing IFS whitespace removed.
+func (sh *interp) splitRead(line []readChar, n int) []string {
+	ifs, ok := sh.vars.Get("IFS")
+	if !ok {
+		ifs = " \t\n"
+	}
+	isSep := func(c readChar) bool { return !c.quoted && strings.ContainsRune(ifs, c.r) }
+	isSpace := func(c readChar) bool { return isSep(c) && strings.ContainsRune(" \t\n", c.r) }
+	skipSpace := func(i int) int {
+		for i < len(line) && isSpace(line[i]) {
+			i++
+		}
+		return i
+	}
+	text := func(cs []readChar) string {
+		var b strings.Builder
+		for _, c := range cs {
+			b.WriteRune(c.r)
+		}
+		return b.String()
+	}
+
+	var fields []string
+	i := skipSpace(0)
+	for i < len(line) && (n < 0 || len(fields) < n-1) {
+		start := i
+		for i < len(line) && !isSep(line[i]) {
+			i++
+		}
+		fields = append(fields, text(line[start:i]))
+		// A separator is IFS whitespace around at most one other IFS
+		// character.
+		if i = skipSpace(i); i < len(line) && isSep(line[i]) {
+			i = skipSpace(i + 1)
+		}
+	}
+	if n > 0 && i < len(line) {
+		end := len(line)
+		for end > i && isSpace(line[end-1]) {
+			end--
+		}
+		fields = append(fields, text(line[i:end]))
+	}
+	return fields
+}
+
+// echoOff turns off echo on the terminal fd and returns a function that
+// turns it back on.
+func echoOff(fd int) (restore func(), err error) {
+	var old syscall.Termios
+	if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), ioctlGetTermios, uintptr(unsafe.Pointer(&old))); e != 0 {
+		return nil, e
+	}
+	t := old
+	t.Lflag &^= syscall.ECHO
+	if _, _, e := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), ioctlSetTermios, uintptr(unsafe.Pointer(&t))); e != 0 {
+		return nil, e
+	}
+	return func() {
+		syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), ioctlSetTermios, uintptr(unsafe.Pointer(&old)))
+	}, nil
+}
@@ -0,0 +1,109 @@
+package shell
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+// withStdin runs fn with the shell's standard input reading input.
+func withStdin(t *testing.T, sh *interp, input string, fn func()) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		w.WriteString(input)
+		w.Close()
+	}()
+	old := sh.fds[0]
+	sh.fds[0] = r
+	defer func() {
+		sh.fds[0] = old
+		r.Close()
+	}()
+	fn()
+}
+
+func TestRead(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name    string
+		input   string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "names", input: "one two three four\n", src: "read a b; echo \"$a|$b\"", want: "one|two three four\n"},
+		{name: "missing fields", input: "one\n", src: "read a b; echo \"$a|$b|\"", want: "one||\n"},
+		{name: "trims IFS whitespace", input: "  a  b  \n", src: "read a b; echo \"[$a][$b]\"", want: "[a][b]\n"},
+		{name: "REPLY keeps whitespace", input: "  a b  \n", src: "read; echo \"[$REPLY]\"", want: "[  a b  ]\n"},
+		{name: "backslash", input: "a\\ b c\\\nd\n", src: "read x y; echo \"$x|$y\"", want: "a b|cd\n"},
+		{name: "raw", input: "a\\ b\n", src: "read -r x y; echo \"$x|$y\"", want: "a\\|b\n"},
+		{name: "IFS", input: "a:b::c\n", src: "IFS=: read w x y z; echo \"$w|$x|$y|$z\"", want: "a|b||c\n"},
+		{name: "array", input: "x  y z\n", src: "read -a arr; echo ${#arr[@]} ${arr[2]}", want: "3 z\n"},
+		{name: "delimiter", input: "a,b", src: "read -d , x; echo $x $?", want: "a 0\n"},
+		{name: "count", input: "abcdef\n", src: "read -n 4 x; echo $x", want: "abcd\n"},
+		{name: "combined options", input: "a\\b\n", src: "read -rn2 x; echo $x", want: "a\\\n"},
+		{name: "end of input", input: "partial", src: "read x; echo $? $x", want: "1 partial\n"},
+		{name: "loop", input: "1\n2\n3\n", src: "while read -r n; do echo line $n; done", want: "line 1\nline 2\nline 3\n"},
+		{name: "pipe", src: "printf 'a\\nb\\n' | while read -r l; do echo got $l; done", want: "got a\ngot b\n"},
+		{name: "leaves the rest", input: "a\nb\n", src: "read x; read y; echo $y$x", want: "ba\n"},
+		{name: "timeout", src: "sleep 1 | { read -t 0.1 x; echo $?; }", want: "142\n"},
+		{name: "nothing waiting", src: "sleep 1 | { read -t 0 x; echo $?; }", want: "1\n"},
+		{name: "no prompt without terminal", input: "v\n", src: "read -p 'name? ' x; echo $x", want: "v\n"},
+		{name: "bad descriptor", src: "read -u 99999999 x", wantErr: "read: 99999999: invalid file descriptor: bad file descriptor\n", status: 1},
+		{name: "invalid name", src: "read 1x", wantErr: "read: `1x': not a valid identifier\n", status: 1},
+		{name: "invalid option", src: "read -q", wantErr: "read: -q: invalid option\nread: usage: read [-rs] [-a array] [-d delim] [-n nchars] [-p prompt] [-t timeout] [-u fd] [name ...]\n", status: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() {
+				withStdin(t, sh, tt.input, func() { got, status = runTestScript(t, sh, tt.src) })
+			})
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
+
+func TestSplitRead(t *testing.T) {
+	sh := newTestShell(t)
+	chars := func(s string) []readChar {
+		var cs []readChar
+		for _, r := range s {
+			cs = append(cs, readChar{r: r})
+		}
+		return cs
+	}
+	tests := []struct {
+		ifs, line string
+		n         int
+		want      []string
+	}{
+		{" \t\n", "  a b  c  ", -1, []string{"a", "b", "c"}},
+		{" \t\n", "  a b  c  ", 2, []string{"a", "b  c"}},
+		{" \t\n", "", 2, nil},
+		{":", "a::b:", -1, []string{"a", "", "b"}},
+		{": ", "a : b", -1, []string{"a", "b"}},
+		{"", "a b", 2, []string{"a b"}},
+	}
+	setupTestVars(t, sh)
+	for _, tt := range tests {
+		sh.vars.Set("IFS", tt.ifs)
+		if got := sh.splitRead(chars(tt.line), tt.n); !slices.Equal(got, tt.want) {
+			t.Errorf("splitRead(%q, %d) with IFS %q = %q, want %q", tt.line, tt.n, tt.ifs, got, tt.want)
+		}
+	}
+
+	sh.vars.Set("IFS", " \t\n")
+	quoted := []readChar{{r: 'a'}, {r: ' ', quoted: true}, {r: 'b'}, {r: ' '}, {r: 'c'}}
+	if got := sh.splitRead(quoted, -1); !slices.Equal(got, []string{"a b", "c"}) {
+		t.Errorf("quoted blank split: %q", got)
+	}
+}
@@ -0,0 +1,123 @@
+// redirect.go — I/O redirection: >, >>, >|, <, <>, >&, <& and &>, on any
+// file descriptor.
+//
+// openRedirects opens target files and returns a new file descriptor
+// table for the command; redirect adds a cleanup function that closes
+// the files. applyRedirects (exec with no command) makes the table the
+// shell's own instead.
+
+package shell
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"syscall"
+)
+
+// redirection describes a single I/O redirection (e.g. "> file", "2>> err.log",
+// "2>&1", "3<&-").
+type redirection struct {
+	Fd   int    // the descriptor redirected: 0 = stdin, 1 = stdout, 2 = stderr, ...
+	Op   string // ">", ">>", ">|", "<", "<>", ">&" or "<&"
+	File string // target file path; for >& and <&, a descriptor or "-" to close
+}
+
+// maxFd bounds the descriptors a redirection may name.
+const maxFd = 1024
+
+// redirectFlags are the flags each operator opens its file with.
+var redirectFlags = map[string]int{
+	">":  os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+	">|": os.O_WRONLY | os.O_CREATE | os.O_TRUNC,
+	">>": os.O_WRONLY | os.O_CREATE | os.O_APPEND,
+	"<":  os.O_RDONLY,
+	"<>": os.O_RDWR | os.O_CREATE,
+}
+
+// redirect returns a copy of the file descriptor table fds with the
+// redirects applied, in order, and a cleanup function that closes the
+// files it opened. Names are relative to the shell's working directory.
+func (sh *interp) redirect(fds []*os.File, redirects []redirection) (newFds []*os.File, cleanup func(), err error) {
+	newFds, files, err := sh.openRedirects(fds, redirects)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newFds, func() { closeFiles(files) }, nil
+}
+
+// openRedirects returns a copy of fds with the redirects applied and the
+// files it opened for them.
+func (sh *interp) openRedirects(fds []*os.File, redirects []redirection) (newFds, files []*os.File, err error) {
+	newFds = slices.Clone(fds)
+	for _, r := range redirects {
+		var f *os.File
+		switch r.Op {
+		case ">&", "<&":
+			if r.File != "-" {
+				n, err := strconv.Atoi(r.File)
+				if err != nil || n < 0 {
+					closeFiles(files)
+					return nil, nil, fmt.Errorf("%s: ambiguous redirect", r.File)
+				}
+				if n >= len(newFds) || newFds[n] == nil {
+					closeFiles(files)
+					return nil, nil, fmt.Errorf("%d: %v", n, syscall.EBADF)
+				}
+				f = newFds[n]
+			}
+		default:
+			flag, ok := redirectFlags[r.Op]
+			if !ok {
+				closeFiles(files)
+				return nil, nil, fmt.Errorf("%s: unsupported redirection", r.Op)
+			}
+			if f, err = sh.openFile(r.File, flag, 0o644); err != nil {
+				closeFiles(files)
+				return nil, nil, err
+			}
+			files = append(files, f)
+		}
+		for len(newFds) <= r.Fd {
+			newFds = append(newFds, nil)
+		}
+		newFds[r.Fd] = f
+	}
+	for len(newFds) > 3 && newFds[len(newFds)-1] == nil {
+		newFds = newFds[:len(newFds)-1]
+	}
+	return newFds, files, nil
+}
+
+// applyRedirects opens the redirect targets and makes them the shell's own
+// file descriptors from now on, as "exec >file 3<input 4>&-" does. Files
+// opened this way are closed once no descriptor refers to them.
+func (sh *interp) applyRedirects(redirects []redirection) error {
+	fds, files, err := sh.openRedirects(sh.fds, redirects)
+	if err != nil {
+		return err
+	}
+	sh.fds = fds
+	sh.execFiles = slices.DeleteFunc(append(sh.execFiles, files...), func(f *os.File) bool {
+		if slices.Contains(sh.fds, f) {
+			return false
+		}
+		f.Close()
+		return true
+	})
+	return nil
+}
+
+// closeExecFiles closes the files that exec opened for the shell.
+func (sh *interp) closeExecFiles() {
+	closeFiles(sh.execFiles)
+	sh.execFiles = nil
+}
+
+// closeFiles closes each of files.
+func closeFiles(files []*os.File) {
+	for _, f := range files {
+		f.Close()
+	}
+}
@@ -0,0 +1,160 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRedirect(t *testing.T) {
+	sh := newTestShell(t)
+
+	t.Run("no redirects returns the same table", func(t *testing.T) {
+		fds, cleanup, err := sh.redirect(sh.fds, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cleanup()
+		if fds[1] != os.Stdout {
+			t.Error("expected stdout to be os.Stdout")
+		}
+		if fds[2] != os.Stderr {
+			t.Error("expected stderr to be os.Stderr")
+		}
+	})
+
+	t.Run("stdout truncate redirect creates file", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.txt")
+		redirects := []redirection{{Fd: 1, Op: ">", File: path}}
+
+		fds, cleanup, err := sh.redirect(sh.fds, redirects)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fds[2] != os.Stderr {
+			t.Error("expected stderr to remain os.Stderr")
+		}
+		fds[1].WriteString("hello\n")
+		cleanup()
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "hello\n" {
+			t.Errorf("file content = %q, want %q", string(data), "hello\n")
+		}
+	})
+
+	t.Run("stderr redirect creates file", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "err.txt")
+		redirects := []redirection{{Fd: 2, Op: ">", File: path}}
+
+		fds, cleanup, err := sh.redirect(sh.fds, redirects)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fds[2].WriteString("oops\n")
+		cleanup()
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "oops\n" {
+			t.Errorf("file content = %q, want %q", string(data), "oops\n")
+		}
+	})
+
+	t.Run("append mode appends to existing file", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "out.txt")
+		os.WriteFile(path, []byte("first\n"), 0644)
+
+		redirects := []redirection{{Fd: 1, Op: ">>", File: path}}
+		fds, cleanup, err := sh.redirect(sh.fds, redirects)
+		if err != nil {
+			t.Fatal(err)
+		}
+		fds[1].WriteString("second\n")
+		cleanup()
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != "first\nsecond\n" {
+			t.Errorf("file content = %q, want %q", string(data), "first\nsecond\n")
+		}
+	})
+
+	t.Run("relative names are in the shell's directory", func(t *testing.T) {
+		sh := newTestShell(t)
+		sh.dir = t.TempDir()
+		fds, cleanup, err := sh.redirect(sh.fds, []redirection{{Fd: 1, Op: ">", File: "out.txt"}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		fds[1].WriteString("here\n")
+		cleanup()
+
+		if data, err := os.ReadFile(filepath.Join(sh.dir, "out.txt")); err != nil || string(data) != "here\n" {
+			t.Errorf("out.txt = %q, %v; want %q", data, err, "here\n")
+		}
+	})
+
+	t.Run("input, dup and close", func(t *testing.T) {
+		dir := t.TempDir()
+		in, out := filepath.Join(dir, "in.txt"), filepath.Join(dir, "out.txt")
+		os.WriteFile(in, []byte("input\n"), 0644)
+		redirects := []redirection{
+			{Fd: 0, Op: "<", File: in},
+			{Fd: 3, Op: ">", File: out},
+			{Fd: 2, Op: ">&", File: "3"},
+			{Fd: 1, Op: ">&", File: "-"},
+		}
+		fds, cleanup, err := sh.redirect(sh.fds, redirects)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer cleanup()
+		if len(fds) != 4 || fds[0].Name() != in || fds[1] != nil || fds[2] != fds[3] || fds[3].Name() != out {
+			t.Errorf("fds = %v, want [%s <nil> %s %s]", fds, in, out, out)
+		}
+	})
+
+	t.Run("bad descriptors return errors", func(t *testing.T) {
+		for _, r := range []redirection{{Fd: 1, Op: ">&", File: "7"}, {Fd: 2, Op: ">&", File: "x"}} {
+			if _, _, err := sh.redirect(sh.fds, []redirection{r}); err == nil {
+				t.Errorf("%+v: expected an error", r)
+			}
+		}
+	})
+
+	t.Run("invalid file path returns error", func(t *testing.T) {
+		redirects := []redirection{{Fd: 1, Op: ">", File: "/no/such/dir/file.txt"}}
+		_, _, err := sh.redirect(sh.fds, redirects)
+		if err == nil {
+			t.Error("expected error for invalid file path")
+		}
+	})
+
+	t.Run("the shell's table is left alone", func(t *testing.T) {
+		dir := t.TempDir()
+		redirects := []redirection{
+			{Fd: 1, Op: ">", File: filepath.Join(dir, "out.txt")},
+			{Fd: 2, Op: ">", File: filepath.Join(dir, "err.txt")},
+		}
+		_, cleanup, err := sh.redirect(sh.fds, redirects)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cleanup()
+
+		if sh.fds[1] != os.Stdout || sh.fds[2] != os.Stderr {
+			t.Error("expected the shell's stdout and stderr to be unchanged")
+		}
+	})
+}
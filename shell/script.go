@@ -0,0 +1,385 @@
+// script.go — how the shell is invoked, the read-parse-run loop shared by
+// the prompt and by scripts, and source, which runs a file in the current
+// shell.
+//
+//	gosh                      interactive if stdin is a terminal,
+//	                          otherwise read commands from stdin
+//	gosh -s [args...]         read commands from stdin
+//	gosh -c CMD [name args]   run CMD; $0 is name, $1... are args
+//	gosh FILE [args...]       run the script FILE; $0 is FILE
+//
+// -l (--login), or an argv[0] starting with '-', makes a login shell;
+// --norc and --noprofile skip startup files (startup.go), and --noplugins
+// skips plugins (plugin.go). The options of set (-e, -u, -x, -o pipefail
+// ...) may be given too (options.go).
+//
+// runLoop reads a line at a time. A line that leaves a construct open (an
+// if without fi, an unclosed quote) is kept and the next line appended,
+// with the continuation prompt "> " at a terminal. Each complete chunk is
+// parsed and run before the next line is read, so a script may define a
+// function and call it further down. Outside interactive mode a syntax
+// error stops the script with status 2. A script on standard input is
+// read a byte at a time, so that its commands (read, cat) get the lines
+// after the one that runs them.
+//
+// source FILE (or . FILE) feeds the file through runLoop without starting
+// a subshell, so its variables, functions and cd persist. return at its
+// top level ends the file early.
+
+package shell
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// invocation is the parsed command line of the shell itself.
+type invocation struct {
+	command    string // -c: the command string
+	hasCommand bool
+	file       string // script to run; "" reads stdin
+	argv0      string // $0
+	args       []string
+	login      bool     // -l, --login, or argv[0] starting with '-'
+	noRC       bool     // --norc
+	noProfile  bool     // --noprofile
+	noPlugins  bool     // --noplugins
+	options    []string // set options such as -e or -o pipefail, for setFlags
+}
+
+// parseShellArgs parses the shell's own arguments (os.Args).
+func parseShellArgs(argv []string) (invocation, error) {
+	inv := invocation{argv0: argv[0], login: strings.HasPrefix(argv[0], "-")}
+	args := argv[1:]
+	stdin := false
+options:
+	for len(args) > 0 && len(args[0]) > 1 && args[0][0] == '-' {
+		arg := args[0]
+		args = args[1:]
+		switch arg {
+		case "--":
+			break options
+		case "--login":
+			inv.login = true
+			continue
+		case "--norc":
+			inv.noRC = true
+			continue
+		case "--noprofile":
+			inv.noProfile = true
+			continue
+		case "--noplugins":
+			inv.noPlugins = true
+			continue
+		}
+		if strings.HasPrefix(arg, "--") {
+			return inv, fmt.Errorf("%s: %s: invalid option\n%s", inv.argv0, arg, usage(inv.argv0))
+		}
+		for _, c := range arg[1:] {
+			switch {
+			case c == 'c':
+				inv.hasCommand = true
+			case c == 's':
+				stdin = true
+			case c == 'l':
+				inv.login = true
+			case c == 'o':
+				if len(args) == 0 {
+					return inv, fmt.Errorf("%s: -o: option requires an argument", inv.argv0)
+				}
+				if findOption(setOptions, args[0]) == nil {
+					return inv, fmt.Errorf("%s: %s: invalid option name", inv.argv0, args[0])
+				}
+				inv.options = append(inv.options, "-o", args[0])
+				args = args[1:]
+			case c < 0x80 && optionByFlag(byte(c)) != nil:
+				inv.options = append(inv.options, "-"+string(c))
+			default:
+				return inv, fmt.Errorf("%s: -%c: invalid option\n%s", inv.argv0, c, usage(inv.argv0))
+			}
+		}
+		if inv.hasCommand {
+			break
+		}
+	}
+
+	switch {
+	case inv.hasCommand:
+		if len(args) == 0 {
+			return inv, fmt.Errorf("%s: -c: option requires an argument", inv.argv0)
+		}
+		inv.command, args = args[0], args[1:]
+		if len(args) > 0 {
+			inv.argv0, args = args[0], args[1:]
+		}
+	case !stdin && len(args) > 0:
+		inv.file, args = args[0], args[1:]
+		inv.argv0 = inv.file
+	}
+	inv.args = args
+	return inv, nil
+}
+
+// usage returns the shell's usage line.
+func usage(argv0 string) string {
+	return "usage: " + argv0 + " [--login] [--norc] [--noprofile] [--noplugins] [-lsefuvx] [-o option] [-c command [name]] [file] [args ...]"
+}
+
+// interactive reports whether the shell should prompt with readline:
+// there is no command string or script, and stdin is a terminal.
+func (inv invocation) interactive(stdinIsTerminal bool) bool {
+	return !inv.hasCommand && inv.file == "" && stdinIsTerminal
+}
+
+// source opens the commands a non-interactive shell runs.
+func (inv invocation) source() (io.Reader, error) {
+	switch {
+	case inv.hasCommand:
+		return strings.NewReader(inv.command), nil
+	case inv.file != "":
+		f, err := os.Open(inv.file)
+		if err != nil {
+			return nil, err
+		}
+		if info, err := f.Stat(); err != nil || info.IsDir() {
+			f.Close()
+			if err == nil {
+				err = &fs.PathError{Op: "read", Path: inv.file, Err: syscall.EISDIR}
+			}
+			return nil, err
+		}
+		return f, nil
+	}
+	return os.Stdin, nil
+}
+
+// scriptError reports a failure to open the script file, prefixed with
+// the shell's name, and returns the exit status for it: 127 if it does
+// not exist, 126 otherwise.
+func (sh *interp) scriptError(shell, file string, err error) int {
+	status, msg := 126, fileError(err).Error()
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		status = 127
+	case errors.Is(err, syscall.EISDIR):
+		msg = "Is a directory"
+	}
+	fmt.Fprintf(sh.stderr(), "%s: %s: %s\n", shell, file, msg)
+	return status
+}
+
+// lineReader returns a runLoop input function that reads r a line at a
+// time and ignores the prompt. It reads ahead, so the script must have r
+// to itself; see stdinLineReader.
+func lineReader(r io.Reader) func(prompt string) (string, error) {
+	br := bufio.NewReader(r)
+	return func(string) (string, error) {
+		line, err := br.ReadString('\n')
+		if err == io.EOF && line != "" {
+			err = nil
+		}
+		return strings.TrimSuffix(line, "\n"), err
+	}
+}
+
+// stdinLineReader is lineReader for a script read from standard input,
+// which the script's commands read too: it reads a byte at a time, up to
+// the newline, so that none of their input is taken early.
+func stdinLineReader(f *os.File) func(prompt string) (string, error) {
+	return func(string) (string, error) {
+		var line []byte
+		var b [1]byte
+		for {
+			n, err := f.Read(b[:])
+			switch {
+			case n == 1 && b[0] == '\n':
+				return string(line), nil
+			case n == 1:
+				line = append(line, b[0])
+			case err == io.EOF && len(line) > 0:
+				return string(line), nil
+			case err != nil:
+				return string(line), err
+			}
+		}
+	}
+}
+
+// runLoop reads commands with next until EOF, exit or return and returns
+// the status of the last command run. Interactive loops record history and
+// keep going after syntax errors; otherwise errors are labelled with name.
+func (sh *interp) runLoop(next func(prompt string) (string, error), interactive bool, name string) int {
+	var pending string // lines of an incomplete command
+	first, lines := 1, 0
+	for {
+		prompt := "$ "
+		if pending != "" {
+			prompt = "> "
+		}
+		line, err := next(prompt)
+		if err != nil { // EOF, or ^C at the prompt
+			if !interactive && !errors.Is(err, io.EOF) {
+				fmt.Fprintf(sh.stderr(), "%s: %v\n", name, fileError(err))
+				return 126
+			}
+			if pending != "" && !interactive {
+				sh.reportSyntaxError(errIncomplete, name, lines, interactive)
+				return 2
+			}
+			break
+		}
+		lines++
+		if sh.options[optVerbose] {
+			fmt.Fprintln(sh.stderr(), line)
+		}
+		if pending == "" {
+			first = lines
+		}
+		src := pending + line + "\n"
+		prog, err := sh.parse(src, first)
+		if errors.Is(err, errIncomplete) {
+			pending = src
+			continue
+		}
+		pending = ""
+		if interactive && strings.TrimSpace(src) != "" {
+			sh.hist.Record(strings.TrimSuffix(src, "\n"))
+		}
+		if err != nil {
+			sh.reportSyntaxError(err, name, lines, interactive)
+			sh.params.status = 2
+			if !interactive {
+				return 2
+			}
+			continue
+		}
+		sh.runList(prog)
+		if sh.flow.kind != flowNone {
+			break
+		}
+	}
+	return sh.params.status
+}
+
+// reportSyntaxError prints a parse error, prefixed with the script name
+// and line number outside interactive mode.
+func (sh *interp) reportSyntaxError(err error, name string, line int, interactive bool) {
+	var se *syntaxError
+	if errors.As(err, &se) {
+		line = se.line
+	}
+	if interactive {
+		fmt.Fprintln(sh.stderr(), err)
+		return
+	}
+	fmt.Fprintf(sh.stderr(), "%s: line %d: %v\n", name, line, err)
+}
+
+// runSource parses and runs src as a whole, returning its status. It is
+// used where the text to run is already complete, such as $(...).
+func (sh *interp) runSource(src string) int {
+	prog, err := sh.parse(src, sh.params.lineno)
+	if err != nil {
+		sh.reportSyntaxError(err, sh.params.argv0, sh.params.lineno, sh.params.interactive)
+		return 2
+	}
+	return sh.runList(prog)
+}
+
+// builtinSource implements source and ".": run a file in the current
+// shell. Arguments after the file name temporarily replace the positional
+// parameters.
+func (sh *interp) builtinSource(name string, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintf(sh.stderr(), "%s: filename argument required\n%s: usage: %s filename [arguments]\n", name, name, name)
+		return 2
+	}
+	status, err := sh.sourceFile(sh.sourcePath(args[0]), args[1:])
+	if err != nil {
+		fmt.Fprintf(sh.stderr(), "%s: %s: %v\n", name, args[0], fileError(err))
+		return 1
+	}
+	return status
+}
+
+// sourceFile runs the file at path in the current shell, with args (if
+// any) as its positional parameters. The error is from opening the file.
+func (sh *interp) sourceFile(path string, args []string) (int, error) {
+	f, err := os.Open(sh.path(path))
+	if err != nil {
+		return 1, err
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err != nil {
+		return 1, err
+	} else if info.IsDir() {
+		return 1, errors.New("is a directory")
+	}
+
+	if !sh.checkFuncNest(path) {
+		return 1, nil
+	}
+	savedArgs, savedLoops := sh.params.positional, sh.flow.loops
+	if len(args) > 0 {
+		sh.params.positional = args
+	}
+	sh.flow.loops = 0
+	sh.flow.funcs++ // return may end the file
+	finishReturn := sh.hideReturnTrap()
+	defer func() {
+		sh.flow.funcs--
+		sh.flow.loops = savedLoops
+		if len(args) > 0 {
+			sh.params.positional = savedArgs
+		}
+	}()
+
+	status := sh.runLoop(lineReader(f), false, path)
+	if sh.flow.kind == flowReturn {
+		sh.flow.kind = flowNone
+	}
+	sh.params.status = status
+	if finishReturn(); sh.flow.kind == flowExit {
+		return sh.params.status, nil
+	}
+	return status, nil
+}
+
+// fileError strips the operation and path from a file error, leaving the
+// reason as the shell reports it.
+func fileError(err error) error {
+	var pe *fs.PathError
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return errors.New("No such file or directory")
+	case errors.As(err, &pe):
+		return pe.Err
+	}
+	return err
+}
+
+// sourcePath resolves the file argument of source: names without a slash
+// are looked up in PATH (they need not be executable), falling back to
+// the current directory.
+func (sh *interp) sourcePath(name string) string {
+	if strings.Contains(name, "/") {
+		return name
+	}
+	for _, dir := range filepath.SplitList(sh.getVar("PATH")) {
+		if dir == "" {
+			dir = "."
+		}
+		p := filepath.Join(dir, name)
+		if info, err := os.Stat(sh.path(p)); err == nil && info.Mode().IsRegular() {
+			return p
+		}
+	}
+	return name
+}
@@ -0,0 +1,219 @@
+package shell
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestParseShellArgs(t *testing.T) {
+	tests := []struct {
+		name    string
+		argv    []string
+		want    invocation
+		wantErr bool
+	}{
+		{name: "no arguments", argv: []string{"gosh"}, want: invocation{argv0: "gosh"}},
+		{name: "script", argv: []string{"gosh", "run.sh", "a", "b"}, want: invocation{argv0: "run.sh", file: "run.sh", args: []string{"a", "b"}}},
+		{name: "command", argv: []string{"gosh", "-c", "echo hi"}, want: invocation{argv0: "gosh", command: "echo hi", hasCommand: true}},
+		{name: "command with name and args", argv: []string{"gosh", "-c", "echo $0", "me", "x"}, want: invocation{argv0: "me", command: "echo $0", hasCommand: true, args: []string{"x"}}},
+		{name: "stdin with args", argv: []string{"gosh", "-s", "a", "b"}, want: invocation{argv0: "gosh", args: []string{"a", "b"}}},
+		{name: "end of options", argv: []string{"gosh", "--", "-script"}, want: invocation{argv0: "-script", file: "-script"}},
+		{name: "set options", argv: []string{"gosh", "-eu", "-o", "pipefail", "run.sh"}, want: invocation{argv0: "run.sh", file: "run.sh", options: []string{"-e", "-u", "-o", "pipefail"}}},
+		{name: "bad option name", argv: []string{"gosh", "-o", "nope"}, wantErr: true},
+		{name: "missing command", argv: []string{"gosh", "-c"}, wantErr: true},
+		{name: "invalid option", argv: []string{"gosh", "-q"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseShellArgs(tt.argv)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseShellArgs(%q) error = %v, wantErr %v", tt.argv, err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got.argv0 != tt.want.argv0 || got.file != tt.want.file || got.command != tt.want.command ||
+				got.hasCommand != tt.want.hasCommand || !slices.Equal(got.args, tt.want.args) ||
+				!slices.Equal(got.options, tt.want.options) {
+				t.Errorf("parseShellArgs(%q) = %+v, want %+v", tt.argv, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvocationInteractive(t *testing.T) {
+	if !(invocation{}).interactive(true) {
+		t.Error("no arguments at a terminal should be interactive")
+	}
+	if (invocation{}).interactive(false) {
+		t.Error("no arguments with piped stdin should run as a script")
+	}
+	if (invocation{file: "x.sh"}).interactive(true) {
+		t.Error("a script file should never be interactive")
+	}
+	if (invocation{hasCommand: true}).interactive(true) {
+		t.Error("-c should never be interactive")
+	}
+}
+
+func TestInvocationSource(t *testing.T) {
+	sh := newTestShell(t)
+	path := filepath.Join(t.TempDir(), "script.sh")
+	if err := os.WriteFile(path, []byte("#!/usr/bin/env gosh\necho $0 $1\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	inv, err := parseShellArgs([]string{"gosh", path, "arg"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := inv.source()
+	if err != nil {
+		t.Fatal(err)
+	}
+	setupTestVars(t, sh)
+	setupTestParams(t, sh, inv.args...)
+	sh.params.argv0 = inv.argv0
+	got := captureStdout(t, sh, func() { sh.runLoop(lineReader(r), false, sh.params.argv0) })
+	if want := path + " arg\n"; got != want {
+		t.Errorf("script output = %q, want %q", got, want)
+	}
+
+	_, err = invocation{file: filepath.Join(t.TempDir(), "missing")}.source()
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("missing script error = %v", err)
+	}
+	var status int
+	captureStderr(t, sh, func() { status = sh.scriptError("gosh", "missing", err) })
+	if status != 127 {
+		t.Errorf("missing script status = %d, want 127", status)
+	}
+
+	dir := t.TempDir()
+	_, err = invocation{file: dir}.source()
+	gotErr := captureStderr(t, sh, func() { status = sh.scriptError("gosh", dir, err) })
+	if want := "gosh: " + dir + ": Is a directory\n"; gotErr != want || status != 126 {
+		t.Errorf("directory script: stderr %q, status %d; want %q, 126", gotErr, status, want)
+	}
+}
+
+func TestStdinLineReader(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh)
+	var got string
+	withStdin(t, sh, "read x; echo got:$x\nhello\necho after", func() {
+		next := stdinLineReader(sh.stdin())
+		got = captureStdout(t, sh, func() { sh.runLoop(next, false, "gosh") })
+	})
+	if want := "got:hello\nafter\n"; got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
+
+func TestRunLoopErrors(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "syntax error stops the script", src: "echo a\nfi\necho b\n", want: "a\n", wantErr: "gosh: line 2: syntax error near unexpected token `fi'\n", status: 2},
+		{name: "unexpected end of file", src: "echo a\nif true; then\n", want: "a\n", wantErr: "gosh: line 2: syntax error: unexpected end of file\n", status: 2},
+		{name: "last line without newline", src: "echo a\necho b", want: "a\nb\n"},
+		{name: "open quote spans lines", src: "echo 'a\nb'\n", want: "a\nb\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
+
+func TestRunLoopInteractive(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh)
+	setupTestParams(t, sh)
+	oldHist := sh.hist
+	sh.hist = newHistory()
+	t.Cleanup(func() { sh.hist = oldHist })
+
+	lines := []string{"if true", "then echo a", "fi", "fi", "echo b"}
+	var prompts []string
+	next := func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		if len(lines) == 0 {
+			return "", io.EOF
+		}
+		line := lines[0]
+		lines = lines[1:]
+		return line, nil
+	}
+	var status int
+	var out string
+	captureStderr(t, sh, func() { out = captureStdout(t, sh, func() { status = sh.runLoop(next, true, "gosh") }) })
+
+	if out != "a\nb\n" || status != 0 {
+		t.Errorf("output %q, status %d; want %q, 0", out, status, "a\nb\n")
+	}
+	if want := []string{"$ ", "> ", "> ", "$ ", "$ ", "$ "}; !slices.Equal(prompts, want) {
+		t.Errorf("prompts = %q, want %q", prompts, want)
+	}
+	if want := []string{"if true\nthen echo a\nfi", "fi", "echo b"}; !slices.Equal(sh.hist.entries, want) {
+		t.Errorf("history = %q, want %q", sh.hist.entries, want)
+	}
+}
+
+func TestSource(t *testing.T) {
+	sh := newTestShell(t)
+	dir := t.TempDir()
+	files := map[string]string{
+		"lib.sh":   "v=set\nf() { echo \"f $1\"; }\ncd " + dir + "\n",
+		"args.sh":  "echo \"$# $1\"\n",
+		"early.sh": "echo one\nif true; then return 3; fi\necho two\n",
+		"bad.sh":   "echo a\nfi\n",
+	}
+	for name, body := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Chdir(t.TempDir())
+	lib := filepath.Join(dir, "lib.sh")
+
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "state persists", src: "source " + lib + "; echo $v; f x; pwd", want: "set\nf x\n" + dir + "\n"},
+		{name: "dot", src: ". " + lib + "; echo $v", want: "set\n"},
+		{name: "PATH lookup", src: "PATH=" + dir + "; . args.sh", want: "0 \n"},
+		{name: "temporary positional parameters", src: "set -- outer; . " + dir + "/args.sh a b; echo $1", want: "2 a\nouter\n"},
+		{name: "return ends the file", src: ". " + dir + "/early.sh; echo status $?", want: "one\nstatus 3\n"},
+		{name: "syntax error", src: ". " + dir + "/bad.sh; echo status $?", want: "a\nstatus 2\n", wantErr: dir + "/bad.sh: line 2: syntax error near unexpected token `fi'\n"},
+		{name: "missing file", src: ". ./missing.sh", wantErr: ".: ./missing.sh: No such file or directory\n", status: 1},
+		{name: "no file name", src: "source", wantErr: "source: filename argument required\nsource: usage: source filename [arguments]\n", status: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+// shell.go — the gosh command: startup, the interactive prompt, and
+// running one simple command.
+
+package shell
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/chzyer/readline"
+)
+
+// Main runs the shell with the command-line arguments args (os.Args): it
+// parses them, reads the startup files and loads plugin builtins, then
+// either runs a script (file, -c string or stdin) or, at a terminal,
+// enters the interactive read-eval loop. It returns the shell's exit
+// status, that of the last command. Main uses the process's standard
+// streams, working directory and signals; to embed the shell, use an
+// Interpreter instead.
+func Main(args []string) int {
+	dir, err := os.Getwd()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		return 1
+	}
+	sh := newInterp(os.Environ(), dir)
+	defer sh.waitInternalJobs()
+
+	inv, err := parseShellArgs(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	sh.setFlags(inv.argv0, inv.options)
+	sh.params.argv0 = inv.argv0
+	sh.params.positional = inv.args
+	sh.params.interactive = inv.interactive(readline.IsTerminal(int(os.Stdin.Fd())))
+	sh.options[optExpandAliases] = sh.params.interactive
+
+	sh.runStartupFiles(inv, sh.params.interactive)
+	if sh.flow.kind == flowExit {
+		return sh.runExitTrap(sh.params.status)
+	}
+	if dir := sh.pluginDir(); dir != "" && !inv.noPlugins {
+		sh.loadPlugins(dir)
+	}
+	if sh.params.interactive {
+		if !inv.noRC {
+			sh.abbrRCFile = sh.rcFile()
+		}
+		return sh.runInteractive()
+	}
+	src, err := inv.source()
+	if err != nil {
+		return sh.runExitTrap(sh.scriptError(args[0], inv.file, err))
+	}
+	next := lineReader(src)
+	if src == io.Reader(os.Stdin) {
+		next = stdinLineReader(os.Stdin)
+	}
+	return sh.runExitTrap(sh.runLoop(next, false, inv.argv0))
+}
+
+// runInteractive loads history, populates the command trie for TAB
+// completion, sets up readline (with abbreviation expansion) and runs the
+// prompt loop.
+func (sh *interp) runInteractive() int {
+	if path := sh.getVar("HISTFILE"); path != "" {
+		sh.hist.ReadFile(sh.path(path))
+		sh.hist.MarkFlushed() // don't re-append loaded entries on exit
+	}
+	sh.initCommandTrie()
+	rl, err := readline.NewEx(&readline.Config{
+		Prompt:       "$ ",
+		AutoComplete: &builtinCompleter{sh: sh},
+		Listener:     abbrListener{sh: sh},
+	})
+	if err != nil {
+		fmt.Fprintln(sh.stdout(), err)
+		return 1
+	}
+	defer rl.Close()
+
+	// SIGHUP and SIGTERM without a trap close readline, which ends the
+	// loop; the queued signal then exits the shell below.
+	trapMu.Lock()
+	signalPrompt = func() { rl.Close() }
+	trapMu.Unlock()
+	sh.updateSignal("HUP")
+	sh.updateSignal("TERM")
+
+	status := sh.runLoop(func(prompt string) (string, error) {
+		rl.SetPrompt(prompt)
+		line, err := rl.Readline()
+		return sh.expandLastAbbr(line), err
+	}, true, sh.params.argv0)
+	if sh.flow.kind == flowNone {
+		if sh.runPendingTraps(); sh.flow.kind == flowExit {
+			status = sh.params.status
+		}
+	}
+	status = sh.runExitTrap(status)
+	sh.saveHistory()
+	return status
+}
+
+// saveHistory appends new (unflushed) history entries to HISTFILE if set.
+func (sh *interp) saveHistory() {
+	if path := sh.getVar("HISTFILE"); path != "" {
+		sh.hist.AppendFile(sh.path(path))
+	}
+}
+
+// runCommand runs a single command in the foreground and returns its exit
+// status:
+//
+//	-> parseCommand        parse redirections + tokenize into name/args
+//	   no name?  ----yes----> applyAssignments (NAME=value on its own)
+//	-> redirect            open redirect targets: the command's fd table
+//	-> getCommand          look up builtin; if found, run in-process
+//	                       with prefix assignments applied temporarily
+//	   or externalCommand  otherwise spawn an external process with
+//	                       exported variables as its environment
+func (sh *interp) runCommand(input string) int {
+	// Parse into name, args, and redirections.
+	parsed, err := sh.parseCommand(input)
+	if err != nil {
+		fmt.Fprintln(sh.stderr(), err)
+		return 1
+	}
+
+	// Bare assignments (FOO=bar) set shell variables. Their status is that
+	// of the last command substitution in them, if any.
+	if parsed.Name == "" {
+		sh.params.status = 0
+		if err := sh.applyAssignments(parsed.Assigns); err != nil {
+			fmt.Fprintln(sh.stderr(), err)
+			return 1
+		}
+		sh.traceCommand(parsed.Assigns, nil)
+		return sh.params.status
+	}
+
+	// exec with only redirections applies them to the shell itself.
+	if parsed.Name == "exec" && len(parsed.Args) == 0 {
+		if err := sh.applyRedirects(parsed.Redirects); err != nil {
+			fmt.Fprintln(sh.stderr(), err)
+			return 1
+		}
+		return 0
+	}
+
+	// Open redirect targets; cleanup closes them.
+	fds, cleanup, err := sh.redirect(sh.fds, parsed.Redirects)
+	if err != nil {
+		fmt.Fprintln(sh.stderr(), err)
+		return 1
+	}
+	defer cleanup()
+
+	// Try functions and builtins first (cd, echo, pwd, type, exit, ...).
+	if cmd, ok := sh.internalCommand(parsed.Name); ok {
+		return sh.withFds(fds, func() int {
+			status := 0
+			err := sh.withAssignments(parsed.Assigns, func() {
+				sh.traceCommand(parsed.Assigns, append([]string{parsed.Name}, parsed.Args...))
+				status = cmd.Run(sh, parsed.Args)
+			})
+			if err != nil {
+				fmt.Fprintln(sh.stderr(), err)
+				return 1
+			}
+			return status
+		})
+	}
+
+	// Fall back to external command lookup via PATH.
+	cmd, err := sh.externalCommand(parsed.Name, parsed.Args, parsed.Assigns)
+	if err != nil {
+		return sh.commandError(parsed.Name, err)
+	}
+	attachFds(cmd, fds)
+	return sh.runExec(cmd)
+}
@@ -0,0 +1,75 @@
+// startup.go — files read when the shell starts.
+//
+//	login shell (-l, --login, argv[0] "-gosh"), unless --noprofile:
+//	  /etc/gosh/profile
+//	  ~/.gosh_profile
+//	interactive shell, unless --norc:
+//	  ~/.goshrc
+//	  $ENV (after parameter expansion), as POSIX specifies
+//
+// Each file runs in the current shell, like source, before history is
+// loaded and before the command trie is built, so rc files can set
+// HISTFILE and PATH. Missing files are skipped silently.
+
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// systemProfile is read first by every login shell.
+const systemProfile = "/etc/gosh/profile"
+
+// startupFiles lists, in order, the files a shell started as inv reads.
+func (sh *interp) startupFiles(inv invocation, interactive bool) []string {
+	var files []string
+	home := sh.getVar("HOME")
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+	if inv.login && !inv.noProfile {
+		files = append(files, systemProfile)
+		if home != "" {
+			files = append(files, filepath.Join(home, ".gosh_profile"))
+		}
+	}
+	if interactive && !inv.noRC {
+		if rc := sh.rcFile(); rc != "" {
+			files = append(files, rc)
+		}
+		if env, err := sh.expandWord(sh.getVar("ENV")); err == nil && env != "" {
+			files = append(files, env)
+		}
+	}
+	return files
+}
+
+// rcFile returns the path of ~/.goshrc, or "" if there is no home
+// directory.
+func (sh *interp) rcFile() string {
+	home := sh.getVar("HOME")
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+	if home == "" {
+		return ""
+	}
+	return filepath.Join(home, ".goshrc")
+}
+
+// runStartupFiles sources the startup files for inv. It stops early if
+// one of them runs exit.
+func (sh *interp) runStartupFiles(inv invocation, interactive bool) {
+	for _, path := range sh.startupFiles(inv, interactive) {
+		if _, err := sh.sourceFile(path, nil); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(sh.stderr(), "%s: %s: %v\n", sh.params.argv0, path, fileError(err))
+		}
+		if sh.flow.kind == flowExit {
+			return
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestStartupFiles(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name        string
+		inv         invocation
+		interactive bool
+		env         []string
+		want        []string
+	}{
+		{name: "script", inv: invocation{file: "x.sh"}, want: nil},
+		{name: "interactive", interactive: true, want: []string{"/home/u/.goshrc"}},
+		{name: "interactive with ENV", interactive: true, env: []string{"ENV=$HOME/.env"}, want: []string{"/home/u/.goshrc", "/home/u/.env"}},
+		{name: "norc", inv: invocation{noRC: true}, interactive: true, env: []string{"ENV=/x"}, want: nil},
+		{name: "login script", inv: invocation{login: true, file: "x.sh"}, want: []string{systemProfile, "/home/u/.gosh_profile"}},
+		{name: "interactive login", inv: invocation{login: true}, interactive: true, want: []string{systemProfile, "/home/u/.gosh_profile", "/home/u/.goshrc"}},
+		{name: "noprofile", inv: invocation{login: true, noProfile: true}, interactive: true, want: []string{"/home/u/.goshrc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestVars(t, sh, append([]string{"HOME=/home/u"}, tt.env...)...)
+			if got := sh.startupFiles(tt.inv, tt.interactive); !slices.Equal(got, tt.want) {
+				t.Errorf("startupFiles() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoginInvocation(t *testing.T) {
+	for _, argv := range [][]string{{"-gosh"}, {"gosh", "-l"}, {"gosh", "--login", "-c", "true"}} {
+		inv, err := parseShellArgs(argv)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !inv.login {
+			t.Errorf("parseShellArgs(%q) is not a login shell", argv)
+		}
+	}
+	inv, err := parseShellArgs([]string{"gosh", "--norc", "--noprofile", "--noplugins"})
+	if err != nil || !inv.noRC || !inv.noProfile || !inv.noPlugins {
+		t.Errorf("parseShellArgs(--norc --noprofile --noplugins) = %+v, %v", inv, err)
+	}
+	if _, err := parseShellArgs([]string{"gosh", "--bogus"}); err == nil {
+		t.Error("parseShellArgs(--bogus) should fail")
+	}
+}
+
+func TestRunStartupFiles(t *testing.T) {
+	sh := newTestShell(t)
+	home := t.TempDir()
+	write := func(name, body string) {
+		if err := os.WriteFile(filepath.Join(home, name), []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".gosh_profile", "PATH=/profile:$PATH\n")
+	write(".goshrc", "greet() { echo hi; }\nrc=yes\n")
+
+	runTestScript(t, sh, "")
+	sh.vars.Set("HOME", home)
+	sh.vars.Set("PATH", "/bin")
+	sh.runStartupFiles(invocation{login: true}, true)
+	if got := sh.getVar("PATH"); got != "/profile:/bin" {
+		t.Errorf("PATH = %q, want %q", got, "/profile:/bin")
+	}
+	if sh.getVar("rc") != "yes" || sh.functions["greet"] == nil {
+		t.Error("~/.goshrc was not read into the shell")
+	}
+
+	write(".goshrc", "exit 4\nrc=no\n")
+	sh.runStartupFiles(invocation{}, true)
+	if sh.flow.kind != flowExit || sh.params.status != 4 || sh.getVar("rc") != "yes" {
+		t.Errorf("exit in ~/.goshrc: flow %v, status %d, rc %q", sh.flow.kind, sh.params.status, sh.getVar("rc"))
+	}
+}
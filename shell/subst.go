@@ -0,0 +1,40 @@
+// subst.go — command substitution: $(cmd) and `cmd`.
+//
+// The command runs inside the shell process, in a subshell: a copy of the
+// shell's state (interp.clone), so assignments, cd, set and exit inside
+// $(...) do not leak out. Its standard output is captured through a pipe
+// and trailing newlines are removed; its exit status becomes $?.
+
+package shell
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+)
+
+// commandSubst runs src and returns what it wrote to standard output,
+// without trailing newlines.
+func (sh *interp) commandSubst(src string) (string, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return "", err
+	}
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		io.Copy(&out, r)
+		r.Close()
+		close(done)
+	}()
+
+	sub := sh.subshell()
+	sub.fds[1] = w
+	status := sh.exitSubshell(sub, sub.runSource(src))
+	w.Close()
+	<-done
+
+	sh.params.status = status
+	return strings.TrimRight(out.String(), "\n"), nil
+}
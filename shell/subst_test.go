@@ -0,0 +1,72 @@
+package shell
+
+import (
+	"os"
+	"slices"
+	"testing"
+)
+
+func TestCommandSubst(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh, "PATH="+os.Getenv("PATH"))
+	setupTestParams(t, sh)
+
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{raw: "$(echo hi)", want: []string{"hi"}},
+		{raw: "`echo hi`", want: []string{"hi"}},
+		{raw: "$(echo a b)", want: []string{"a", "b"}},
+		{raw: `"$(echo a b)"`, want: []string{"a b"}},
+		{raw: `"$(printf 'x\n\n')"`, want: []string{"x"}},
+		{raw: "$(echo $(echo nested))", want: []string{"nested"}},
+		{raw: "`echo \\`echo inner\\``", want: []string{"inner"}},
+		{raw: `"$(echo 'a | b')"`, want: []string{"a | b"}},
+		{raw: "$(echo x | tr x y)", want: []string{"y"}},
+		{raw: "$(true)", want: nil},
+		{raw: `"$(true)"`, want: []string{""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			got, err := sh.expandFields(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("expandFields(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCommandSubstIsolation(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh, "PATH="+os.Getenv("PATH"), "v=outer")
+	setupTestParams(t, sh, "p1")
+	dir, _ := os.Getwd()
+
+	got, err := sh.expandWord("$(v=inner)$(set -- x y)$(cd /)$v $1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "outer p1" {
+		t.Errorf("got %q, want %q", got, "outer p1")
+	}
+	if now, _ := os.Getwd(); now != dir {
+		t.Errorf("working directory changed to %s", now)
+	}
+}
+
+func TestCommandSubstStatus(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh, "PATH="+os.Getenv("PATH"))
+	setupTestParams(t, sh)
+
+	if status := sh.runSource("x=$(false)"); status != 1 {
+		t.Errorf("x=$(false) status = %d, want 1", status)
+	}
+	if status := sh.runSource("x=$(true)"); status != 0 {
+		t.Errorf("x=$(true) status = %d, want 0", status)
+	}
+}
@@ -0,0 +1,992 @@
+// syntax.go — the shell grammar: lists, and-or lists, pipelines and
+// compound commands.
+//
+// parseProgram turns source text into a cmdList. Simple commands are not
+// tokenized here: each is kept as its raw source text (words and
+// redirections, quotes intact) and handed to parseCommand when it runs, so
+// expansion still happens at execution time.
+//
+//	list       and-or lists separated by ';', '&' or newlines
+//	and-or     pipelines joined by && and ||
+//	pipeline   [!] command | command ...
+//	command    simple command, compound command [redirections], or
+//	           function definition: name() compound, function name compound
+//	compound   { list; }   ( list )   (( expr ))   [[ expr ]]
+//	           if list; then list; [elif list; then list;] [else list;] fi
+//	           while|until list; do list; done
+//	           for name [in words]; do list; done
+//	           for ((init; cond; step)); do list; done
+//	           case word in [(]pat[|pat]) list ;; ... esac
+//
+// Aliases are expanded as commands are parsed (alias.go).
+//
+// Comments run from an unquoted '#' at the start of a word to the end of
+// the line; backslash-newline joins lines. Input that ends inside a
+// construct (an open if, quote or $( ) yields errIncomplete, which the
+// interactive loop answers with a continuation prompt.
+
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// errIncomplete reports that the input ended in the middle of a command.
+var errIncomplete = errors.New("syntax error: unexpected end of file")
+
+// syntaxError reports an unexpected token.
+type syntaxError struct {
+	line int
+	near string
+}
+
+func (e *syntaxError) Error() string {
+	return fmt.Sprintf("syntax error near unexpected token `%s'", e.near)
+}
+
+// cmdList is a sequence of and-or lists.
+type cmdList []*andOr
+
+// andOr is a chain of pipelines joined by && and ||; ops[i] sits between
+// pipes[i] and pipes[i+1]. A trailing '&' sets background.
+type andOr struct {
+	pipes      []*pipeNode
+	ops        []string
+	background bool
+}
+
+// pipeNode is a pipeline, possibly negated with '!'.
+type pipeNode struct {
+	negate bool
+	cmds   []*cmdNode
+	line   int
+}
+
+// cmdNode is one element of a pipeline. A simple command has only raw;
+// a compound command has compound and, in redirs, the raw redirections
+// that follow it.
+type cmdNode struct {
+	raw      string
+	compound any // *groupNode, *ifNode, *loopNode, ...; nil if simple
+	redirs   string
+	line     int
+}
+
+type (
+	// groupNode is { list; } or, with subshell, ( list ).
+	groupNode struct {
+		body     cmdList
+		subshell bool
+	}
+	// ifNode holds each if/elif condition with its body, and the else body.
+	ifNode struct {
+		conds, bodies []cmdList
+		elseBody      cmdList
+	}
+	// loopNode is a while loop, or an until loop.
+	loopNode struct {
+		until      bool
+		cond, body cmdList
+	}
+	// forNode iterates name over words (raw), or over "$@" without in.
+	forNode struct {
+		name  string
+		words []string
+		hasIn bool
+		body  cmdList
+	}
+	// arithForNode is for ((init; cond; step)).
+	arithForNode struct {
+		init, cond, step string
+		body             cmdList
+	}
+	// caseNode matches word (raw) against each item's patterns (raw).
+	caseNode struct {
+		word  string
+		items []caseItem
+	}
+	caseItem struct {
+		patterns []string
+		body     cmdList
+	}
+	// arithNode is (( expr )).
+	arithNode struct {
+		expr string
+	}
+	// condNode is [[ expr ]]; src is the text between the brackets.
+	condNode struct {
+		expr *condExpr
+		src  string
+	}
+	// condExpr is a node of a [[ ]] expression: && or || joining left
+	// and right, ! negating left, or a test (op "" for a lone word, a
+	// unary or binary operator otherwise) on raw words.
+	condExpr struct {
+		op          string
+		left, right *condExpr
+		words       []string
+	}
+	// funcNode defines a function; src is its text for declare -f.
+	funcNode struct {
+		name string
+		body *cmdNode
+		src  string
+	}
+)
+
+// reservedWords open or close compound commands when they appear where a
+// command name is expected.
+var reservedWords = map[string]bool{
+	"if": true, "then": true, "elif": true, "else": true, "fi": true,
+	"while": true, "until": true, "do": true, "done": true, "for": true,
+	"case": true, "esac": true, "{": true, "}": true, "!": true,
+	"function": true, "[[": true, "]]": true,
+}
+
+type tokKind int
+
+const (
+	tokEOF tokKind = iota
+	tokWord
+	tokNewline
+	tokOp    // ; ;; & && | || ( )
+	tokRedir // < > >> >& <& <> >| &>
+)
+
+type token struct {
+	kind     tokKind
+	text     string
+	pos, end int
+	line     int
+}
+
+// parser is a recursive-descent parser with one token of lookahead.
+type parser struct {
+	src  string
+	pos  int
+	line int
+	tok  token
+	err  error // first lexical error (errIncomplete for open quotes)
+
+	aliases    map[string]string // the aliases to expand (alias.go)
+	aliasSpans []aliasSpan       // expanded aliases
+	aliasNext  int               // if > 0, a word from here on is an alias candidate
+}
+
+// parseProgram parses src, whose first line is line number firstLine,
+// expanding aliases (nil for none).
+func parseProgram(src string, firstLine int, aliases map[string]string) (cmdList, error) {
+	p := &parser{src: src, line: firstLine, aliases: aliases}
+	p.advance()
+	l, err := p.list()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, p.unexpected()
+	}
+	return l, nil
+}
+
+// parse parses src, whose first line is line number firstLine, as the
+// shell would run it: with its aliases expanded if expand_aliases is on.
+func (sh *interp) parse(src string, firstLine int) (cmdList, error) {
+	var aliases map[string]string
+	if sh.options[optExpandAliases] {
+		aliases = sh.aliases
+	}
+	return parseProgram(src, firstLine, aliases)
+}
+
+// advance reads the next token into p.tok.
+func (p *parser) advance() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		switch {
+		case isBlank(c):
+			p.pos++
+			continue
+		case c == '\\' && p.pos+1 < len(p.src) && p.src[p.pos+1] == '\n':
+			p.pos += 2
+			p.line++
+			if p.pos == len(p.src) {
+				p.setErr(errIncomplete)
+			}
+			continue
+		case c == '#':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+			continue
+		}
+		break
+	}
+
+	start := p.pos
+	p.tok = token{pos: start, end: start, line: p.line}
+	if start >= len(p.src) {
+		p.tok.kind = tokEOF
+		return
+	}
+	rest := p.src[start:]
+	switch c := rest[0]; {
+	case c == '\n':
+		p.tok.kind, p.pos = tokNewline, start+1
+		p.line++
+	case strings.HasPrefix(rest, "&>"):
+		p.tok.kind, p.pos = tokRedir, start+2
+		if strings.HasPrefix(rest, "&>>") {
+			p.pos++
+		}
+	case c == '<' || c == '>':
+		p.tok.kind, p.pos = tokRedir, start+1
+		if len(rest) > 1 && strings.IndexByte("<>&|", rest[1]) >= 0 {
+			p.pos++
+		}
+	case strings.IndexByte(";&|()", c) >= 0:
+		p.tok.kind, p.pos = tokOp, start+1
+		if len(rest) > 1 && rest[1] == c && c != '(' && c != ')' {
+			p.pos++
+		}
+	case c == '\\' && len(rest) == 1:
+		// A trailing backslash continues the line.
+		p.tok.kind, p.pos = tokEOF, start+1
+		p.setErr(errIncomplete)
+	default:
+		end := scanWord(p.src, start, ";&|()<>")
+		if unterminatedWord(p.src[start:end]) || end == len(p.src) && strings.HasSuffix(p.src[:end], "\\\n") {
+			p.setErr(errIncomplete)
+		}
+		p.tok.kind, p.pos = tokWord, end
+		p.line += strings.Count(p.src[start:end], "\n")
+	}
+	p.tok.end = p.pos
+	p.tok.text = p.src[start:p.pos]
+}
+
+func (p *parser) setErr(err error) {
+	if p.err == nil {
+		p.err = err
+	}
+}
+
+// unterminatedWord reports whether a raw word ends inside a quote, $(...),
+// ${...} or `...`.
+func unterminatedWord(w string) bool {
+	var q quoteTracker
+	for i := 0; i < len(w); i++ {
+		c := w[i]
+		switch {
+		case c == '\\' && !q.inSingle:
+			i++
+		case c == '\'' && !q.inDouble:
+			q.inSingle = !q.inSingle
+		case c == '"' && !q.inSingle:
+			q.inDouble = !q.inDouble
+		case q.inSingle:
+		case c == '$' && i+1 < len(w) && (w[i+1] == '(' || w[i+1] == '{'):
+			end := groupEnd(w, i+1)
+			if end < 0 {
+				return true
+			}
+			i = end - 1
+		case c == '`':
+			end := skipBackquote(w, i)
+			if w[end-1] != '`' || end-1 == i {
+				return true
+			}
+			i = end - 1
+		}
+	}
+	return q.IsQuoted()
+}
+
+// isWord reports whether the current token is the unquoted word w.
+func (p *parser) isWord(w string) bool {
+	return p.tok.kind == tokWord && p.tok.text == w
+}
+
+// isOp reports whether the current token is the operator op.
+func (p *parser) isOp(op string) bool {
+	return p.tok.kind == tokOp && p.tok.text == op
+}
+
+// unexpected returns the error for the current token: errIncomplete at the
+// end of input, a syntax error otherwise.
+func (p *parser) unexpected() error {
+	if p.err != nil {
+		return p.err
+	}
+	switch p.tok.kind {
+	case tokEOF:
+		return errIncomplete
+	case tokNewline:
+		return &syntaxError{line: p.tok.line, near: "newline"}
+	}
+	return &syntaxError{line: p.tok.line, near: p.tok.text}
+}
+
+// expectWord consumes the reserved word w or fails.
+func (p *parser) expectWord(w string) error {
+	if !p.isWord(w) {
+		return p.unexpected()
+	}
+	p.advance()
+	return nil
+}
+
+// expectOp consumes the operator op or fails.
+func (p *parser) expectOp(op string) error {
+	if !p.isOp(op) {
+		return p.unexpected()
+	}
+	p.advance()
+	return nil
+}
+
+func (p *parser) skipNewlines() {
+	for p.tok.kind == tokNewline {
+		p.advance()
+	}
+}
+
+// atListEnd reports whether the current token ends a list: end of input,
+// a closing operator, or one of the reserved words in stops.
+func (p *parser) atListEnd(stops []string) bool {
+	switch {
+	case p.tok.kind == tokEOF, p.isOp(")"), p.isOp(";;"):
+		return true
+	case p.tok.kind == tokWord:
+		for _, s := range stops {
+			if p.tok.text == s {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// body parses the list inside a compound command, which must not be
+// empty.
+func (p *parser) body(stops ...string) (cmdList, error) {
+	l, err := p.list(stops...)
+	if err == nil && len(l) == 0 {
+		err = p.unexpected()
+	}
+	return l, err
+}
+
+// list parses and-or lists until the end of input or a token that closes
+// the enclosing construct (see atListEnd).
+func (p *parser) list(stops ...string) (cmdList, error) {
+	var l cmdList
+	for {
+		p.skipNewlines()
+		if p.err != nil {
+			return nil, p.err
+		}
+		if p.atListEnd(stops) {
+			return l, nil
+		}
+		ao, err := p.andOr()
+		if err != nil {
+			return nil, err
+		}
+		l = append(l, ao)
+		switch {
+		case p.isOp("&"):
+			ao.background = true
+			p.advance()
+		case p.isOp(";"), p.tok.kind == tokNewline:
+			p.advance()
+		case p.atListEnd(stops):
+			return l, nil
+		default:
+			return nil, p.unexpected()
+		}
+	}
+}
+
+func (p *parser) andOr() (*andOr, error) {
+	pl, err := p.pipeline()
+	if err != nil {
+		return nil, err
+	}
+	ao := &andOr{pipes: []*pipeNode{pl}}
+	for p.isOp("&&") || p.isOp("||") {
+		ao.ops = append(ao.ops, p.tok.text)
+		p.advance()
+		p.skipNewlines()
+		if pl, err = p.pipeline(); err != nil {
+			return nil, err
+		}
+		ao.pipes = append(ao.pipes, pl)
+	}
+	return ao, nil
+}
+
+func (p *parser) pipeline() (*pipeNode, error) {
+	pl := &pipeNode{line: p.tok.line}
+	if p.isWord("!") {
+		pl.negate = true
+		p.advance()
+	}
+	for {
+		c, err := p.command()
+		if err != nil {
+			return nil, err
+		}
+		pl.cmds = append(pl.cmds, c)
+		if !p.isOp("|") {
+			return pl, nil
+		}
+		p.advance()
+		p.skipNewlines()
+	}
+}
+
+// command parses one pipeline element.
+func (p *parser) command() (*cmdNode, error) {
+	if p.err != nil {
+		return nil, p.err
+	}
+	p.aliasNext = 0
+	p.expandAlias()
+	start, line := p.tok.pos, p.tok.line
+	var c any
+	var err error
+	switch {
+	case p.isOp("(") && strings.HasPrefix(p.src[start:], "(("):
+		if c, err = p.arithCommand(); errors.Is(err, errNotArith) {
+			c, err = p.group("(", ")")
+		}
+	case p.isOp("("):
+		c, err = p.group("(", ")")
+	case p.isWord("{"):
+		c, err = p.group("{", "}")
+	case p.isWord("if"):
+		c, err = p.ifCommand()
+	case p.isWord("while"), p.isWord("until"):
+		c, err = p.loop()
+	case p.isWord("for"):
+		c, err = p.forCommand()
+	case p.isWord("case"):
+		c, err = p.caseCommand()
+	case p.isWord("[["):
+		c, err = p.condCommand()
+	case p.isWord("function"):
+		return p.function(start, line)
+	case p.tok.kind == tokWord && reservedWords[p.tok.text],
+		p.tok.kind == tokOp, p.tok.kind == tokNewline, p.tok.kind == tokEOF:
+		return nil, p.unexpected()
+	default:
+		return p.simple()
+	}
+	if err != nil {
+		return nil, err
+	}
+	redirs := p.redirections()
+	return &cmdNode{raw: p.src[start:p.tok.pos], compound: c, redirs: redirs, line: line}, nil
+}
+
+// simple parses a simple command, or a function definition name() ....
+func (p *parser) simple() (*cmdNode, error) {
+	first := p.tok
+	if first.kind == tokWord && isFuncName(first.text) {
+		p.advance()
+		if p.isOp("(") {
+			p.advance()
+			if err := p.expectOp(")"); err != nil {
+				return nil, err
+			}
+			return p.functionBody(first.text, first.pos, first.line)
+		}
+		p.expandNextAlias()
+	}
+	end := first.end
+	for p.tok.kind == tokWord || p.tok.kind == tokRedir {
+		end = p.tok.end
+		p.advance()
+		p.expandNextAlias()
+	}
+	if p.err != nil {
+		return nil, p.err
+	}
+	return &cmdNode{raw: p.src[first.pos:end], line: first.line}, nil
+}
+
+// redirections consumes the redirections that follow a compound command
+// and returns their raw text.
+func (p *parser) redirections() string {
+	start, end := -1, -1
+	for {
+		fd := p.tok.kind == tokWord && isAllDigits(p.tok.text) &&
+			p.tok.end < len(p.src) && strings.IndexByte("<>", p.src[p.tok.end]) >= 0
+		if p.tok.kind != tokRedir && !fd {
+			break
+		}
+		if start < 0 {
+			start = p.tok.pos
+		}
+		if fd {
+			p.advance()
+		}
+		p.advance() // operator
+		if p.tok.kind == tokWord {
+			end = p.tok.end
+			p.advance()
+		}
+	}
+	if start < 0 || end < 0 {
+		return ""
+	}
+	return p.src[start:end]
+}
+
+// errNotArith reports that "((" starts nested subshells, not (( expr )).
+var errNotArith = errors.New("not an arithmetic command")
+
+// arithCommand parses (( expr )) starting at the current "(" token.
+func (p *parser) arithCommand() (any, error) {
+	start := p.tok.pos
+	end := groupEnd(p.src, start)
+	if end < 0 {
+		return nil, errIncomplete
+	}
+	if groupEnd(p.src, start+1) != end-1 {
+		return nil, errNotArith
+	}
+	expr := p.src[start+2 : end-2]
+	p.line += strings.Count(expr, "\n")
+	p.pos = end
+	p.advance()
+	return &arithNode{expr: expr}, nil
+}
+
+// condCommand parses [[ expr ]] starting at the current "[[" word. The
+// expression is split into words here rather than by advance, because
+// inside [[ ]] newlines are blanks, < and > compare strings and the
+// regex after =~ may contain unquoted ( ) and |.
+func (p *parser) condCommand() (any, error) {
+	var words []string
+	pos := p.tok.end
+	for {
+		for pos < len(p.src) && (isBlank(p.src[pos]) || p.src[pos] == '\n') {
+			if p.src[pos] == '\n' {
+				p.line++
+			}
+			pos++
+		}
+		if pos >= len(p.src) {
+			return nil, errIncomplete
+		}
+		rest := p.src[pos:]
+		var end int
+		switch {
+		case len(words) > 0 && words[len(words)-1] == "=~" && !strings.HasPrefix(rest, "]]"):
+			end = scanRegexWord(p.src, pos)
+		case strings.HasPrefix(rest, "&&"), strings.HasPrefix(rest, "||"):
+			end = pos + 2
+		case strings.IndexByte("()<>", rest[0]) >= 0:
+			end = pos + 1
+		case strings.IndexByte(";&|", rest[0]) >= 0:
+			return nil, &syntaxError{line: p.line, near: rest[:1]}
+		default:
+			end = scanWord(p.src, pos, ";&|()<>")
+		}
+		w := p.src[pos:end]
+		if unterminatedWord(w) {
+			return nil, errIncomplete
+		}
+		p.line += strings.Count(w, "\n")
+		pos = end
+		if w == "]]" {
+			break
+		}
+		words = append(words, w)
+	}
+	src := strings.TrimSpace(p.src[p.tok.end : pos-2])
+	line := p.line
+	p.pos = pos
+	p.advance()
+
+	cp := &condParser{words: words, line: line}
+	expr, err := cp.or()
+	if err == nil && cp.pos < len(words) {
+		err = cp.unexpected()
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &condNode{expr: expr, src: src}, nil
+}
+
+// scanRegexWord returns the end of the word after =~ in [[ ]], which may
+// contain unquoted parentheses and | and ends at a blank outside them.
+func scanRegexWord(s string, pos int) int {
+	depth := 0
+	for i := pos; i < len(s); {
+		switch c := s[i]; {
+		case c == '(':
+			depth++
+			i++
+		case c == ')':
+			if depth == 0 {
+				return i
+			}
+			depth--
+			i++
+		case c == '\n', isBlank(c) && depth == 0:
+			return i
+		case isBlank(c):
+			i++
+		case c == '|':
+			i++
+		default:
+			i = max(scanWord(s, i, "()|"), i+1)
+		}
+	}
+	return len(s)
+}
+
+// condParser parses the words of a [[ ]] expression:
+//
+//	or       and { || and }
+//	and      not { && not }
+//	not      ! not | primary
+//	primary  ( or ) | unary-op word | word binary-op word | word
+type condParser struct {
+	words []string
+	pos   int
+	line  int
+}
+
+// condBinaryOp reports whether w is a binary operator in [[ ]]: those
+// of test and =~.
+func condBinaryOp(w string) bool {
+	return testBinaryOps[w] || w == "=~"
+}
+
+func (cp *condParser) peek(off int) string {
+	if cp.pos+off < len(cp.words) {
+		return cp.words[cp.pos+off]
+	}
+	return ""
+}
+
+func (cp *condParser) unexpected() error {
+	if cp.pos >= len(cp.words) {
+		return &syntaxError{line: cp.line, near: "]]"}
+	}
+	return &syntaxError{line: cp.line, near: cp.words[cp.pos]}
+}
+
+func (cp *condParser) or() (*condExpr, error) {
+	left, err := cp.and()
+	for err == nil && cp.peek(0) == "||" {
+		cp.pos++
+		var right *condExpr
+		if right, err = cp.and(); err == nil {
+			left = &condExpr{op: "||", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (cp *condParser) and() (*condExpr, error) {
+	left, err := cp.not()
+	for err == nil && cp.peek(0) == "&&" {
+		cp.pos++
+		var right *condExpr
+		if right, err = cp.not(); err == nil {
+			left = &condExpr{op: "&&", left: left, right: right}
+		}
+	}
+	return left, err
+}
+
+func (cp *condParser) not() (*condExpr, error) {
+	if cp.peek(0) == "!" && cp.pos+1 < len(cp.words) {
+		cp.pos++
+		e, err := cp.not()
+		return &condExpr{op: "!", left: e}, err
+	}
+	return cp.primary()
+}
+
+func (cp *condParser) primary() (*condExpr, error) {
+	w := cp.peek(0)
+	switch {
+	case cp.pos >= len(cp.words), w == "&&", w == "||", w == ")":
+		return nil, cp.unexpected()
+	case w == "(":
+		cp.pos++
+		e, err := cp.or()
+		if err != nil {
+			return nil, err
+		}
+		if cp.peek(0) != ")" {
+			return nil, cp.unexpected()
+		}
+		cp.pos++
+		return e, nil
+	case testUnaryOps[w] && cp.pos+1 < len(cp.words) && !condBinaryOp(cp.peek(1)):
+		cp.pos += 2
+		return &condExpr{op: w, words: []string{cp.peek(-1)}}, nil
+	case condBinaryOp(cp.peek(1)):
+		if cp.pos+2 >= len(cp.words) {
+			cp.pos += 2
+			return nil, cp.unexpected()
+		}
+		cp.pos += 3
+		return &condExpr{op: cp.peek(-2), words: []string{w, cp.peek(-1)}}, nil
+	}
+	cp.pos++
+	return &condExpr{words: []string{w}}, nil
+}
+
+// group parses { list; } or ( list ).
+func (p *parser) group(open, closer string) (any, error) {
+	p.advance()
+	body, err := p.body(closer)
+	if err != nil {
+		return nil, err
+	}
+	if closer == ")" {
+		err = p.expectOp(")")
+	} else {
+		err = p.expectWord("}")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &groupNode{body: body, subshell: open == "("}, nil
+}
+
+func (p *parser) ifCommand() (any, error) {
+	n := &ifNode{}
+	for {
+		p.advance() // if or elif
+		cond, err := p.body("then")
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expectWord("then"); err != nil {
+			return nil, err
+		}
+		body, err := p.body("elif", "else", "fi")
+		if err != nil {
+			return nil, err
+		}
+		n.conds = append(n.conds, cond)
+		n.bodies = append(n.bodies, body)
+		if !p.isWord("elif") {
+			break
+		}
+	}
+	if p.isWord("else") {
+		p.advance()
+		body, err := p.body("fi")
+		if err != nil {
+			return nil, err
+		}
+		n.elseBody = body
+	}
+	return n, p.expectWord("fi")
+}
+
+func (p *parser) loop() (any, error) {
+	n := &loopNode{until: p.tok.text == "until"}
+	p.advance()
+	cond, err := p.body("do")
+	if err != nil {
+		return nil, err
+	}
+	n.cond = cond
+	if n.body, err = p.doGroup(); err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// doGroup parses do list done.
+func (p *parser) doGroup() (cmdList, error) {
+	if err := p.expectWord("do"); err != nil {
+		return nil, err
+	}
+	body, err := p.body("done")
+	if err != nil {
+		return nil, err
+	}
+	return body, p.expectWord("done")
+}
+
+func (p *parser) forCommand() (any, error) {
+	p.advance()
+	if p.isOp("(") && strings.HasPrefix(p.src[p.tok.pos:], "((") {
+		return p.arithFor()
+	}
+	if p.tok.kind != tokWord || !isValidName(p.tok.text) {
+		return nil, p.unexpected()
+	}
+	n := &forNode{name: p.tok.text}
+	p.advance()
+	p.skipNewlines()
+	if p.isWord("in") {
+		n.hasIn = true
+		p.advance()
+		for p.tok.kind == tokWord {
+			n.words = append(n.words, p.tok.text)
+			p.advance()
+		}
+		if !p.isOp(";") && p.tok.kind != tokNewline {
+			return nil, p.unexpected()
+		}
+		p.advance()
+	} else if p.isOp(";") {
+		p.advance()
+	}
+	p.skipNewlines()
+	body, err := p.doGroup()
+	if err != nil {
+		return nil, err
+	}
+	n.body = body
+	return n, nil
+}
+
+// arithFor parses ((init; cond; step)) [;] do list done.
+func (p *parser) arithFor() (any, error) {
+	start := p.tok.pos
+	end := groupEnd(p.src, start)
+	if end < 0 {
+		return nil, errIncomplete
+	}
+	parts := strings.Split(p.src[start+2:end-2], ";")
+	if len(parts) != 3 || groupEnd(p.src, start+1) != end-1 {
+		return nil, &syntaxError{line: p.tok.line, near: "(("}
+	}
+	p.pos = end
+	p.advance()
+	if p.isOp(";") {
+		p.advance()
+	}
+	p.skipNewlines()
+	body, err := p.doGroup()
+	if err != nil {
+		return nil, err
+	}
+	return &arithForNode{init: parts[0], cond: parts[1], step: parts[2], body: body}, nil
+}
+
+func (p *parser) caseCommand() (any, error) {
+	p.advance()
+	if p.tok.kind != tokWord {
+		return nil, p.unexpected()
+	}
+	n := &caseNode{word: p.tok.text}
+	p.advance()
+	p.skipNewlines()
+	if err := p.expectWord("in"); err != nil {
+		return nil, err
+	}
+	for {
+		p.skipNewlines()
+		if p.isWord("esac") {
+			break
+		}
+		if p.isOp("(") {
+			p.advance()
+		}
+		var item caseItem
+		for {
+			if p.tok.kind != tokWord {
+				return nil, p.unexpected()
+			}
+			item.patterns = append(item.patterns, p.tok.text)
+			p.advance()
+			if !p.isOp("|") {
+				break
+			}
+			p.advance()
+		}
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+		body, err := p.list("esac")
+		if err != nil {
+			return nil, err
+		}
+		item.body = body
+		n.items = append(n.items, item)
+		if !p.isOp(";;") {
+			break
+		}
+		p.advance()
+	}
+	return n, p.expectWord("esac")
+}
+
+// function parses function name [()] compound.
+func (p *parser) function(start, line int) (*cmdNode, error) {
+	p.advance()
+	if p.tok.kind != tokWord || !isFuncName(p.tok.text) {
+		return nil, p.unexpected()
+	}
+	name := p.tok.text
+	p.advance()
+	if p.isOp("(") {
+		p.advance()
+		if err := p.expectOp(")"); err != nil {
+			return nil, err
+		}
+	}
+	return p.functionBody(name, start, line)
+}
+
+// functionBody parses the compound command that forms a function body.
+func (p *parser) functionBody(name string, start, line int) (*cmdNode, error) {
+	p.skipNewlines()
+	switch {
+	case p.isWord("{"), p.isOp("("), p.isWord("if"), p.isWord("while"),
+		p.isWord("until"), p.isWord("for"), p.isWord("case"):
+	default:
+		return nil, p.unexpected()
+	}
+	body, err := p.command()
+	if err != nil {
+		return nil, err
+	}
+	fn := &funcNode{name: name, body: body, src: p.src[start:p.tok.pos]}
+	fn.src = strings.TrimRight(fn.src, " \t\n;&")
+	return &cmdNode{raw: fn.src, compound: fn, line: line}, nil
+}
+
+// isFuncName reports whether w can name a function: an unquoted word made
+// of characters that need no quoting.
+func isFuncName(w string) bool {
+	if w == "" || reservedWords[w] || isAllDigits(w) {
+		return false
+	}
+	for i := 0; i < len(w); i++ {
+		if !isNameChar(w[i]) && strings.IndexByte("-.:@+%,", w[i]) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// isAllDigits reports whether s is a non-empty string of ASCII digits.
+func isAllDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		if !isDigit(s[i]) {
+			return false
+		}
+	}
+	return true
+}
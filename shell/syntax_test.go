@@ -0,0 +1,189 @@
+package shell
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestParseProgramPipelines(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		want       []string // raw text of each command in the first pipeline
+		background bool
+	}{
+		{name: "no pipe", input: "echo hello", want: []string{"echo hello"}},
+		{name: "single pipe", input: "cat file | wc", want: []string{"cat file", "wc"}},
+		{name: "pipe inside double quotes", input: `echo "a|b"`, want: []string{`echo "a|b"`}},
+		{name: "pipe inside command substitution", input: "echo $(a | b) `c | d` | wc", want: []string{"echo $(a | b) `c | d`", "wc"}},
+		{name: "pipe inside single quotes", input: "echo 'a|b'", want: []string{"echo 'a|b'"}},
+		{name: "escaped pipe", input: `echo a\|b`, want: []string{`echo a\|b`}},
+		{name: "multiple pipes", input: "a | b | c", want: []string{"a", "b", "c"}},
+		{name: "pipe continues on next line", input: "a |\n b", want: []string{"a", "b"}},
+		{name: "redirections stay with the command", input: "a 2>err | b >out", want: []string{"a 2>err", "b >out"}},
+		{name: "background", input: "sleep 1 &", want: []string{"sleep 1"}, background: true},
+		{name: "background with trailing blanks", input: "sleep 1 &  ", want: []string{"sleep 1"}, background: true},
+		{name: "quoted ampersand", input: "echo '&'", want: []string{"echo '&'"}},
+		{name: "escaped ampersand", input: `echo \&`, want: []string{`echo \&`}},
+		{name: "comment", input: "echo a # b | c", want: []string{"echo a"}},
+		{name: "hash inside a word", input: "echo a#b", want: []string{"echo a#b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			prog, err := parseProgram(tt.input, 1, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(prog) != 1 {
+				t.Fatalf("parseProgram(%q) returned %d lists, want 1", tt.input, len(prog))
+			}
+			var got []string
+			for _, c := range prog[0].pipes[0].cmds {
+				got = append(got, c.raw)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("parseProgram(%q) commands = %q, want %q", tt.input, got, tt.want)
+			}
+			if prog[0].background != tt.background {
+				t.Errorf("parseProgram(%q) background = %v, want %v", tt.input, prog[0].background, tt.background)
+			}
+		})
+	}
+}
+
+func TestParseProgramLists(t *testing.T) {
+	prog, err := parseProgram("a; b & c && d || ! e\nf", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(prog) != 4 {
+		t.Fatalf("got %d and-or lists, want 4", len(prog))
+	}
+	if !prog[1].background || prog[0].background {
+		t.Error("only the second list should be in the background")
+	}
+	if got := prog[2].ops; !slices.Equal(got, []string{"&&", "||"}) {
+		t.Errorf("ops = %q, want [&& ||]", got)
+	}
+	if !prog[2].pipes[2].negate {
+		t.Error("! e is not negated")
+	}
+	if line := prog[3].pipes[0].line; line != 2 {
+		t.Errorf("f is on line %d, want 2", line)
+	}
+}
+
+func TestParseProgramCompound(t *testing.T) {
+	tests := []struct {
+		input string
+		want  any // type of the first command's compound node
+	}{
+		{input: "{ a; b; }", want: &groupNode{}},
+		{input: "(a; b)", want: &groupNode{}},
+		{input: "((x = 1 + 2))", want: &arithNode{}},
+		{input: "((a) | b)", want: &groupNode{}},
+		{input: "[[ -n $x && ( a < b || ! c ) ]]", want: &condNode{}},
+		{input: "[[ $x =~ ^(a|b)+$ ]] && d", want: &condNode{}},
+		{input: "if a; then b; elif c; then d; else e; fi", want: &ifNode{}},
+		{input: "while a; do b; done", want: &loopNode{}},
+		{input: "until a\ndo\n  b\ndone", want: &loopNode{}},
+		{input: "for x in a b; do c; done", want: &forNode{}},
+		{input: "for x; do c; done", want: &forNode{}},
+		{input: "for ((i = 0; i < 3; i++)); do c; done", want: &arithForNode{}},
+		{input: "case $x in a|b) c;; (*) d;; esac", want: &caseNode{}},
+		{input: "f() { a; }", want: &funcNode{}},
+		{input: "function f { a; }", want: &funcNode{}},
+		{input: "{ a; } > out", want: &groupNode{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			prog, err := parseProgram(tt.input, 1, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := prog[0].pipes[0].cmds[0].compound
+			if got == nil || typeName(got) != typeName(tt.want) {
+				t.Errorf("parseProgram(%q) = %T, want %T", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseProgramErrors(t *testing.T) {
+	tests := []struct {
+		input      string
+		incomplete bool
+		near       string
+	}{
+		{input: "if a; then b", incomplete: true},
+		{input: "while a; do", incomplete: true},
+		{input: "echo 'abc", incomplete: true},
+		{input: "echo $(ls", incomplete: true},
+		{input: "a |", incomplete: true},
+		{input: "a &&", incomplete: true},
+		{input: "f() {", incomplete: true},
+		{input: `echo \`, incomplete: true},
+		{input: "[[ a &&", incomplete: true},
+		{input: "fi", near: "fi"},
+		{input: "a; ; b", near: ";"},
+		{input: "if a; then fi", near: "fi"},
+		{input: "a | | b", near: "|"},
+		{input: "( )", near: ")"},
+		{input: "for 1x in a; do b; done", near: "1x"},
+		{input: "case x in a) b;; c", near: "newline"},
+		{input: "[[ a b ]]", near: "b"},
+		{input: "[[ a == ]]", near: "]]"},
+		{input: "[[ a ; ]]", near: ";"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := parseProgram(tt.input+"\n", 1, nil)
+			if tt.incomplete {
+				if !errors.Is(err, errIncomplete) {
+					t.Errorf("parseProgram(%q) error = %v, want errIncomplete", tt.input, err)
+				}
+				return
+			}
+			var se *syntaxError
+			if !errors.As(err, &se) || se.near != tt.near {
+				t.Errorf("parseProgram(%q) error = %v, want syntax error near %q", tt.input, err, tt.near)
+			}
+		})
+	}
+}
+
+func TestFunctionSource(t *testing.T) {
+	prog, err := parseProgram("greet() {\n  echo hi\n}; x", 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fn := prog[0].pipes[0].cmds[0].compound.(*funcNode)
+	if fn.name != "greet" || fn.src != "greet() {\n  echo hi\n}" {
+		t.Errorf("function = %q %q", fn.name, fn.src)
+	}
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case *groupNode:
+		return "group"
+	case *arithNode:
+		return "arith"
+	case *condNode:
+		return "cond"
+	case *ifNode:
+		return "if"
+	case *loopNode:
+		return "loop"
+	case *forNode:
+		return "for"
+	case *arithForNode:
+		return "arithFor"
+	case *caseNode:
+		return "case"
+	case *funcNode:
+		return "func"
+	}
+	return "?"
+}
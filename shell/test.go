@@ -0,0 +1,442 @@
+// test.go — conditional expressions: the test and [ builtins and the
+// [[ ]] compound command.
+//
+//	files     -e -f -d -h/-L -p -S -b -c  type; -r -w -x  access;
+//	          -s  non-empty; -u -g -k  mode bits; -O -G  owner;
+//	          A -nt B, A -ot B  modification time; A -ef B  same file
+//	strings   -z -n  length; = == !=  equality; < >  byte order
+//	integers  -eq -ne -lt -le -gt -ge
+//	other     -v NAME  variable set; -o OPT  option on; -t FD  terminal
+//	logic     test:  ! EXPR   EXPR -a EXPR   EXPR -o EXPR   ( EXPR )
+//	          [[ ]]: ! EXPR   EXPR && EXPR   EXPR || EXPR   ( EXPR )
+//
+// test follows POSIX: with up to four arguments the count decides how
+// they are read (so "test -n" is true: one non-empty argument); beyond
+// that a recursive-descent parser applies, -o binding looser than -a.
+//
+// [[ ]] is parsed by syntax.go, so && || < > ( ) need no quoting, and its
+// words are expanded without field splitting or pathname expansion. The
+// right side of == and != is a pattern and that of =~ an extended
+// regular expression, in both cases with quoted parts matched literally;
+// =~ stores the match and its groups in the BASH_REMATCH array. Integer
+// operands are arithmetic expressions.
+
+package shell
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/chzyer/readline"
+)
+
+// testUnaryOps and testBinaryOps are the operators of test, and with =~
+// those of [[ ]]. In test, -a and -o are also connectives.
+var (
+	testUnaryOps = map[string]bool{
+		"-a": true, "-b": true, "-c": true, "-d": true, "-e": true, "-f": true,
+		"-g": true, "-G": true, "-h": true, "-k": true, "-L": true, "-n": true,
+		"-o": true, "-O": true, "-p": true, "-r": true, "-s": true, "-S": true,
+		"-t": true, "-u": true, "-v": true, "-w": true, "-x": true, "-z": true,
+	}
+	testBinaryOps = map[string]bool{
+		"=": true, "==": true, "!=": true, "<": true, ">": true,
+		"-eq": true, "-ne": true, "-lt": true, "-le": true, "-gt": true, "-ge": true,
+		"-nt": true, "-ot": true, "-ef": true,
+	}
+)
+
+// intOps are the integer comparisons.
+var intOps = map[string]func(a, b int64) bool{
+	"-eq": func(a, b int64) bool { return a == b },
+	"-ne": func(a, b int64) bool { return a != b },
+	"-lt": func(a, b int64) bool { return a < b },
+	"-le": func(a, b int64) bool { return a <= b },
+	"-gt": func(a, b int64) bool { return a > b },
+	"-ge": func(a, b int64) bool { return a >= b },
+}
+
+// Access modes for syscall.Access.
+const (
+	accessRead  = 4
+	accessWrite = 2
+	accessExec  = 1
+)
+
+// builtinTest implements test and [ (name); [ requires a closing ].
+func (sh *interp) builtinTest(name string, args []string) int {
+	if name == "[" {
+		if len(args) == 0 || args[len(args)-1] != "]" {
+			fmt.Fprintln(sh.stderr(), "[: missing `]'")
+			return 2
+		}
+		args = args[:len(args)-1]
+	}
+	ok, err := sh.evalTest(args)
+	if err != nil {
+		fmt.Fprintf(sh.stderr(), "%s: %v\n", name, err)
+		return 2
+	}
+	return boolStatus(ok)
+}
+
+// evalTest evaluates the arguments of test by the POSIX rules for their
+// count, falling back to the full grammar.
+func (sh *interp) evalTest(args []string) (bool, error) {
+	switch len(args) {
+	case 0:
+		return false, nil
+	case 1:
+		return args[0] != "", nil
+	case 2:
+		switch {
+		case args[0] == "!":
+			return args[1] == "", nil
+		case testUnaryOps[args[0]]:
+			return sh.testUnary(args[0], args[1]), nil
+		}
+		return false, fmt.Errorf("%s: unary operator expected", args[0])
+	case 3:
+		switch {
+		case testBinaryOps[args[1]]:
+			return sh.testBinary(args[1], args[0], args[2])
+		case args[1] == "-a":
+			return args[0] != "" && args[2] != "", nil
+		case args[1] == "-o":
+			return args[0] != "" || args[2] != "", nil
+		case args[0] == "!":
+			ok, err := sh.evalTest(args[1:])
+			return !ok, err
+		case args[0] == "(" && args[2] == ")":
+			return args[1] != "", nil
+		}
+		return false, fmt.Errorf("%s: binary operator expected", args[1])
+	case 4:
+		switch {
+		case args[0] == "!":
+			ok, err := sh.evalTest(args[1:])
+			return !ok, err
+		case args[0] == "(" && args[3] == ")":
+			return sh.evalTest(args[1:3])
+		}
+	}
+	tp := &testParser{sh: sh, args: args}
+	ok, err := tp.or()
+	if err == nil && tp.pos < len(args) {
+		err = fmt.Errorf("%s: unexpected argument", args[tp.pos])
+	}
+	return ok, err
+}
+
+// testParser evaluates test arguments as it parses them:
+//
+//	or       and { -o and }
+//	and      not { -a not }
+//	not      ! not | primary
+//	primary  ( or ) | unary-op arg | arg binary-op arg | arg
+type testParser struct {
+	sh   *interp
+	args []string
+	pos  int
+}
+
+func (tp *testParser) peek(off int) string {
+	if tp.pos+off < len(tp.args) {
+		return tp.args[tp.pos+off]
+	}
+	return ""
+}
+
+func (tp *testParser) or() (bool, error) {
+	ok, err := tp.and()
+	for err == nil && tp.peek(0) == "-o" {
+		tp.pos++
+		var right bool
+		right, err = tp.and()
+		ok = ok || right
+	}
+	return ok, err
+}
+
+func (tp *testParser) and() (bool, error) {
+	ok, err := tp.not()
+	for err == nil && tp.peek(0) == "-a" {
+		tp.pos++
+		var right bool
+		right, err = tp.not()
+		ok = ok && right
+	}
+	return ok, err
+}
+
+func (tp *testParser) not() (bool, error) {
+	if tp.peek(0) == "!" && tp.pos+1 < len(tp.args) {
+		tp.pos++
+		ok, err := tp.not()
+		return !ok, err
+	}
+	return tp.primary()
+}
+
+func (tp *testParser) primary() (bool, error) {
+	if tp.pos >= len(tp.args) {
+		return false, errors.New("argument expected")
+	}
+	w := tp.peek(0)
+	switch {
+	case testBinaryOps[tp.peek(1)] && tp.pos+2 < len(tp.args):
+		tp.pos += 3
+		return tp.sh.testBinary(tp.peek(-2), w, tp.peek(-1))
+	case w == "(":
+		tp.pos++
+		ok, err := tp.or()
+		if err != nil {
+			return false, err
+		}
+		if tp.peek(0) != ")" {
+			return false, errors.New("`)' expected")
+		}
+		tp.pos++
+		return ok, nil
+	case testUnaryOps[w] && tp.pos+1 < len(tp.args):
+		tp.pos += 2
+		return tp.sh.testUnary(w, tp.peek(-1)), nil
+	}
+	tp.pos++
+	return w != "", nil
+}
+
+// testUnary applies a unary operator to arg.
+func (sh *interp) testUnary(op, arg string) bool {
+	switch op {
+	case "-z":
+		return arg == ""
+	case "-n":
+		return arg != ""
+	case "-v":
+		_, ok := sh.vars.Get(arg)
+		return ok
+	case "-o":
+		o := findOption(setOptions, arg)
+		return o != nil && sh.options[o]
+	case "-t":
+		fd, err := strconv.Atoi(arg)
+		return err == nil && readline.IsTerminal(fd)
+	}
+	return sh.testFile(op, arg)
+}
+
+// testFile applies a file operator to the file name.
+func (sh *interp) testFile(op, name string) bool {
+	name = sh.path(name)
+	stat := os.Stat
+	if op == "-h" || op == "-L" {
+		stat = os.Lstat
+	}
+	info, err := stat(name)
+	if err != nil {
+		return false
+	}
+	mode := info.Mode()
+	switch op {
+	case "-a", "-e":
+		return true
+	case "-b":
+		return mode&os.ModeDevice != 0 && mode&os.ModeCharDevice == 0
+	case "-c":
+		return mode&os.ModeCharDevice != 0
+	case "-d":
+		return mode.IsDir()
+	case "-f":
+		return mode.IsRegular()
+	case "-h", "-L":
+		return mode&os.ModeSymlink != 0
+	case "-p":
+		return mode&os.ModeNamedPipe != 0
+	case "-S":
+		return mode&os.ModeSocket != 0
+	case "-s":
+		return info.Size() > 0
+	case "-u":
+		return mode&os.ModeSetuid != 0
+	case "-g":
+		return mode&os.ModeSetgid != 0
+	case "-k":
+		return mode&os.ModeSticky != 0
+	case "-r":
+		return syscall.Access(name, accessRead) == nil
+	case "-w":
+		return syscall.Access(name, accessWrite) == nil
+	case "-x":
+		return syscall.Access(name, accessExec) == nil
+	case "-O", "-G":
+		st, ok := info.Sys().(*syscall.Stat_t)
+		if !ok {
+			return false
+		}
+		if op == "-O" {
+			return int(st.Uid) == os.Geteuid()
+		}
+		return int(st.Gid) == os.Getegid()
+	}
+	return false
+}
+
+// testBinary applies a binary operator of test to a and b. Integer
+// operands must be decimal integers.
+func (sh *interp) testBinary(op, a, b string) (bool, error) {
+	cmp, isInt := intOps[op]
+	if !isInt {
+		return sh.compareStrings(op, a, b), nil
+	}
+	x, err := testInt(a)
+	if err != nil {
+		return false, err
+	}
+	y, err := testInt(b)
+	if err != nil {
+		return false, err
+	}
+	return cmp(x, y), nil
+}
+
+// testInt parses an integer operand of test, which may have surrounding
+// blanks.
+func testInt(s string) (int64, error) {
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%s: integer expression expected", s)
+	}
+	return n, nil
+}
+
+// compareStrings applies a string or file comparison to a and b.
+func (sh *interp) compareStrings(op, a, b string) bool {
+	switch op {
+	case "=", "==":
+		return a == b
+	case "!=":
+		return a != b
+	case "<":
+		return a < b
+	case ">":
+		return a > b
+	case "-nt", "-ot":
+		if op == "-ot" {
+			a, b = b, a
+		}
+		ai, err := os.Stat(sh.path(a))
+		if err != nil {
+			return false
+		}
+		bi, err := os.Stat(sh.path(b))
+		return err != nil || ai.ModTime().After(bi.ModTime())
+	case "-ef":
+		ai, err := os.Stat(sh.path(a))
+		if err != nil {
+			return false
+		}
+		bi, err := os.Stat(sh.path(b))
+		return err == nil && os.SameFile(ai, bi)
+	}
+	return false
+}
+
+// execCond runs [[ expr ]]: status 0 if it is true, 1 if false and 2 if
+// it cannot be evaluated.
+func (sh *interp) execCond(n *condNode) int {
+	sh.trace("[[ " + n.src + " ]]")
+	ok, err := sh.evalCond(n.expr)
+	if err != nil {
+		if err != errBadRegex {
+			fmt.Fprintf(sh.stderr(), "[[: %v\n", err)
+		}
+		return 2
+	}
+	return boolStatus(ok)
+}
+
+// errBadRegex reports a =~ pattern that does not compile; like bash, the
+// shell only sets status 2 for it.
+var errBadRegex = errors.New("invalid regular expression")
+
+// evalCond evaluates a [[ ]] expression, expanding words as it goes so
+// that && and || skip the expansions of the side they do not evaluate.
+func (sh *interp) evalCond(e *condExpr) (bool, error) {
+	switch e.op {
+	case "&&", "||":
+		ok, err := sh.evalCond(e.left)
+		if err != nil || ok == (e.op == "||") {
+			return ok, err
+		}
+		return sh.evalCond(e.right)
+	case "!":
+		ok, err := sh.evalCond(e.left)
+		return !ok, err
+	}
+
+	left, err := sh.expandWord(e.words[0])
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case e.op == "":
+		return left != "", nil
+	case len(e.words) == 1:
+		return sh.testUnary(e.op, left), nil
+	case e.op == "=~":
+		return sh.matchRegex(left, e.words[1])
+	}
+
+	if e.op == "=" || e.op == "==" || e.op == "!=" {
+		pat, err := sh.expandPattern(e.words[1])
+		if err != nil {
+			return false, err
+		}
+		return matchPattern(pat, left) == (e.op != "!="), nil
+	}
+	right, err := sh.expandWord(e.words[1])
+	if err != nil {
+		return false, err
+	}
+	if cmp, isInt := intOps[e.op]; isInt {
+		x, err := sh.arithEval(left)
+		if err != nil {
+			return false, err
+		}
+		y, err := sh.arithEval(right)
+		if err != nil {
+			return false, err
+		}
+		return cmp(x, y), nil
+	}
+	return sh.compareStrings(e.op, left, right), nil
+}
+
+// matchRegex matches s against the raw =~ operand and sets BASH_REMATCH
+// to the match and its groups, or unsets it when there is none.
+func (sh *interp) matchRegex(s, raw string) (bool, error) {
+	expr, err := sh.expandRegex(raw)
+	if err != nil {
+		return false, err
+	}
+	re, err := regexp.CompilePOSIX(expr)
+	if err != nil {
+		return false, errBadRegex
+	}
+	m := re.FindStringSubmatch(s)
+	if m == nil {
+		sh.vars.Unset("BASH_REMATCH")
+		return false, nil
+	}
+	elems := make([]arrayElem, len(m))
+	for i, v := range m {
+		elems[i] = arrayElem{Value: v}
+	}
+	return true, sh.vars.SetArray("BASH_REMATCH", elems, false)
+}
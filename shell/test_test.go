@@ -0,0 +1,145 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTestBuiltin(t *testing.T) {
+	sh := newTestShell(t)
+	dir := t.TempDir()
+	file := filepath.Join(dir, "file")
+	if err := os.WriteFile(file, []byte("data"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, nil, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "link")
+	if err := os.Symlink(file, link); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{nil, 1},
+		{[]string{""}, 1},
+		{[]string{"-n"}, 0},
+		{[]string{"!", ""}, 0},
+		{[]string{"-z", ""}, 0},
+		{[]string{"-n", ""}, 1},
+		{[]string{"a", "=", "a"}, 0},
+		{[]string{"a", "!=", "a"}, 1},
+		{[]string{"a", "<", "b"}, 0},
+		{[]string{"10", "-gt", "9"}, 0},
+		{[]string{" 3 ", "-eq", "3"}, 0},
+		{[]string{"a", "-eq", "1"}, 2},
+		{[]string{"-f", file}, 0},
+		{[]string{"-d", file}, 1},
+		{[]string{"-d", dir}, 0},
+		{[]string{"-e", filepath.Join(dir, "missing")}, 1},
+		{[]string{"-s", file}, 0},
+		{[]string{"-s", empty}, 1},
+		{[]string{"-x", empty}, 0},
+		{[]string{"-x", file}, 1},
+		{[]string{"-h", link}, 0},
+		{[]string{"-L", file}, 1},
+		{[]string{"-O", file}, 0},
+		{[]string{link, "-ef", file}, 0},
+		{[]string{file, "-ef", empty}, 1},
+		{[]string{file, "-nt", filepath.Join(dir, "missing")}, 0},
+		{[]string{"-o", "noglob"}, 1},
+		{[]string{"-v", "NO_SUCH_VARIABLE"}, 1},
+		{[]string{"!", "a", "=", "b"}, 0},
+		{[]string{"(", "-n", "x", ")"}, 0},
+		{[]string{"a", "-a", ""}, 1},
+		{[]string{"", "-o", "a"}, 0},
+		{[]string{"-n", "a", "-a", "!", "-z", "a", "-o", "x", "=", "y"}, 0},
+		{[]string{"(", "a", "=", "b", "-o", "c", ")", "-a", "-d", dir}, 0},
+		{[]string{"a", "b"}, 2},
+		{[]string{"(", "a", "=", "b"}, 2},
+		{[]string{"a", "=", "b", "-a"}, 2},
+	}
+	setupTestVars(t, sh)
+	for _, tt := range tests {
+		var got int
+		captureStderr(t, sh, func() { got = sh.builtinTest("test", tt.args) })
+		if got != tt.want {
+			t.Errorf("test %q = %d, want %d", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestBracket(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "true", src: "[ a = a ] && echo yes", want: "yes\n"},
+		{name: "false", src: "[ -z a ]", status: 1},
+		{name: "missing bracket", src: "[ a = a", wantErr: "[: missing `]'\n", status: 2},
+		{name: "error", src: "[ 1 -lt x ]", wantErr: "[: x: integer expression expected\n", status: 2},
+		{name: "unquoted empty", src: "x=; [ -n $x ] && echo quirk", want: "quirk\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
+
+func TestCondCommand(t *testing.T) {
+	sh := newTestShell(t)
+	t.Chdir(t.TempDir())
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "word", src: "x=; [[ $x ]] || echo empty", want: "empty\n"},
+		{name: "no splitting", src: "x='a b'; [[ $x == 'a b' ]] && echo same", want: "same\n"},
+		{name: "no globbing", src: "touch f1; x='f*'; [[ $x == f1 ]] || echo literal", want: "literal\n"},
+		{name: "pattern", src: "[[ abc == a* ]] && [[ abc != b* ]] && echo match", want: "match\n"},
+		{name: "quoted pattern", src: "[[ abc == 'a*' ]]", status: 1},
+		{name: "pattern variable", src: "p='a?c'; [[ abc == $p ]] && [[ abc != \"$p\" ]] && echo ok", want: "ok\n"},
+		{name: "lexical", src: "[[ apple < banana && b > a ]] && echo ordered", want: "ordered\n"},
+		{name: "and or not", src: "[[ -n a && ( -z a || ! -z a ) ]] && echo yes", want: "yes\n"},
+		{name: "short circuit", src: "[[ -n a || $(echo side >&2) ]] && echo done", want: "done\n"},
+		{name: "integers are arithmetic", src: "n=4; [[ n*2 -eq 8 && 010 -lt 9 ]] && echo yes", want: "yes\n"},
+		{name: "regex", src: "[[ key=value =~ ^([a-z]+)=(.*)$ ]] && echo ${#BASH_REMATCH[@]} ${BASH_REMATCH[1]} ${BASH_REMATCH[2]}", want: "3 key value\n"},
+		{name: "quoted regex is literal", src: "[[ axc =~ 'a.c' ]] || [[ a.c =~ a\".\"c ]] && echo lit", want: "lit\n"},
+		{name: "regex variable", src: "re='^[0-9]+$'; [[ 123 =~ $re ]] && echo num", want: "num\n"},
+		{name: "no match unsets", src: "[[ ab =~ b ]]; [[ ab =~ c ]]; echo ${BASH_REMATCH-unset}", want: "unset\n"},
+		{name: "bad regex", src: "[[ a =~ a{2,1} ]]", status: 2},
+		{name: "arithmetic error", src: "[[ 1 -eq 1+ ]]", wantErr: "[[: 1+: arithmetic syntax error (error token is \"end of expression\")\n", status: 2},
+		{name: "spans lines", src: "[[ a &&\n  b ]] && echo yes", want: "yes\n"},
+		{name: "xtrace", src: "x=1; set -x; [[ $x ]]", wantErr: "+ [[ $x ]]\n"},
+		{name: "variable set", src: "x=; [[ -v x && ! -v y ]] && echo set", want: "set\n"},
+		{name: "in if", src: "if [[ -d / ]]; then echo dir; fi", want: "dir\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
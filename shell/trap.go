@@ -0,0 +1,358 @@
+// trap.go — the trap builtin: shell code to run on signals and on the
+// EXIT, ERR, DEBUG and RETURN pseudo-signals.
+//
+//	trap 'cmd' SIG...   run cmd when SIG arrives; '' ignores SIG
+//	trap - SIG...       restore the default action
+//	trap [-p] [SIG...]  print traps as trap commands
+//	trap -l             list signal names and numbers
+//
+//	EXIT (0)  when the shell, or a ( ) or $( ) subshell, exits
+//	ERR       after a command fails where errexit would apply
+//	DEBUG     before each pipeline or (( )) command
+//	RETURN    when a function or sourced file finishes; a function's
+//	          RETURN trap is not seen by the functions it calls
+//
+// Signals are not handled on the goroutine that receives them: it only
+// queues them, and runPendingTraps runs their handlers between commands
+// (after each and-or list). A command therefore finishes before a trap
+// for a signal that arrived while it ran. An interactive shell catches
+// SIGHUP and SIGTERM even without a trap: they are queued the same way,
+// and also end the prompt (signalPrompt), so that the shell runs its
+// EXIT trap, saves history and exits with status 128+signal number.
+
+package shell
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+// signals are the signals trap accepts, by name without "SIG".
+var signals = map[string]syscall.Signal{
+	"HUP": syscall.SIGHUP, "INT": syscall.SIGINT, "QUIT": syscall.SIGQUIT,
+	"ILL": syscall.SIGILL, "TRAP": syscall.SIGTRAP, "ABRT": syscall.SIGABRT,
+	"BUS": syscall.SIGBUS, "FPE": syscall.SIGFPE, "KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1, "SEGV": syscall.SIGSEGV, "USR2": syscall.SIGUSR2,
+	"PIPE": syscall.SIGPIPE, "ALRM": syscall.SIGALRM, "TERM": syscall.SIGTERM,
+	"CHLD": syscall.SIGCHLD, "CONT": syscall.SIGCONT, "STOP": syscall.SIGSTOP,
+	"TSTP": syscall.SIGTSTP, "TTIN": syscall.SIGTTIN, "TTOU": syscall.SIGTTOU,
+	"URG": syscall.SIGURG, "XCPU": syscall.SIGXCPU, "XFSZ": syscall.SIGXFSZ,
+	"VTALRM": syscall.SIGVTALRM, "PROF": syscall.SIGPROF, "WINCH": syscall.SIGWINCH,
+	"IO": syscall.SIGIO, "SYS": syscall.SIGSYS,
+}
+
+// pseudoSignals are the conditions that are not signals, in the order
+// trap lists them after the real signals.
+var pseudoSignals = []string{"DEBUG", "ERR", "RETURN"}
+
+// Signals belong to the process, so only Main's shell and its subshells
+// handle them; an Interpreter's traps never install handlers. What the
+// shell shares with the goroutine that receives signals is guarded by
+// trapMu.
+var (
+	trapMu      sync.Mutex
+	sigCh       = make(chan os.Signal, 8)
+	sigOnce     sync.Once
+	pendingSigs []string        // names of signals not yet handled
+	trappedSigs map[string]bool // signals that have a handler
+
+	// signalPrompt, if set, interrupts the prompt when a signal that
+	// ends the shell arrives.
+	signalPrompt func()
+)
+
+// startSignals starts the goroutine that receives signals the shell has
+// asked for, once. It only queues them, and interrupts the prompt for
+// SIGHUP and SIGTERM without a trap.
+func startSignals() {
+	sigOnce.Do(func() {
+		go func() {
+			for sig := range sigCh {
+				name := signalName(sig.(syscall.Signal))
+				trapMu.Lock()
+				pendingSigs = append(pendingSigs, name)
+				trapped := trappedSigs[name]
+				interrupt := signalPrompt
+				trapMu.Unlock()
+				if !trapped && interrupt != nil {
+					interrupt()
+				}
+			}
+		}()
+	})
+}
+
+// updateSignal makes the process's handling of sig match its trap. It
+// does nothing in an Interpreter or a background job.
+func (sh *interp) updateSignal(name string) {
+	sig, ok := signals[name]
+	if !ok || sh.embedded || sh.async {
+		return
+	}
+	handler, trapped := sh.traps[name]
+	trapMu.Lock()
+	if trappedSigs == nil {
+		trappedSigs = map[string]bool{}
+	}
+	trappedSigs[name] = trapped
+	trapMu.Unlock()
+	signal.Reset(sig)
+	switch {
+	case trapped && handler == "":
+		signal.Ignore(sig)
+	case trapped, sh.params.interactive && (sig == syscall.SIGHUP || sig == syscall.SIGTERM):
+		startSignals()
+		signal.Notify(sigCh, sig)
+	}
+}
+
+// signalName returns the trap name of sig, such as "INT".
+func signalName(sig syscall.Signal) string {
+	for name, s := range signals {
+		if s == sig {
+			return name
+		}
+	}
+	return strconv.Itoa(int(sig))
+}
+
+// trapCondition resolves a trap argument (INT, SIGINT, int, 2, EXIT, 0,
+// ERR, ...) to the name traps uses.
+func trapCondition(arg string) (string, bool) {
+	if n, err := strconv.Atoi(arg); err == nil {
+		if n == 0 {
+			return "EXIT", true
+		}
+		for name, s := range signals {
+			if int(s) == n {
+				return name, true
+			}
+		}
+		return "", false
+	}
+	name := strings.TrimPrefix(strings.ToUpper(arg), "SIG")
+	if _, ok := signals[name]; ok || name == "EXIT" || slices.Contains(pseudoSignals, name) {
+		return name, true
+	}
+	return "", false
+}
+
+// trapOrder returns the names of all conditions in the order trap lists
+// them: EXIT, the signals by number, then the other pseudo-signals.
+func trapOrder() []string {
+	names := make([]string, 0, len(signals))
+	for name := range signals {
+		names = append(names, name)
+	}
+	slices.SortFunc(names, func(a, b string) int { return int(signals[a]) - int(signals[b]) })
+	return slices.Concat([]string{"EXIT"}, names, pseudoSignals)
+}
+
+// builtinTrap implements trap.
+func (sh *interp) builtinTrap(args []string) int {
+	printMode := false
+	opts, args, ok := sh.parseOptions("trap", "lp", args)
+	if !ok {
+		return 2
+	}
+	for _, opt := range opts {
+		switch opt.c {
+		case 'p':
+			printMode = true
+		case 'l':
+			sh.listSignals()
+			return 0
+		}
+	}
+	if printMode || len(args) == 0 {
+		return sh.printTraps(args)
+	}
+
+	// A lone condition, or a first operand that is a number, resets.
+	handler, reset := args[0], args[0] == "-"
+	if _, isCond := trapCondition(args[0]); len(args) == 1 && isCond {
+		reset = true
+	} else if _, err := strconv.Atoi(args[0]); err == nil {
+		reset = true
+	} else {
+		args = args[1:]
+	}
+	status := 0
+	for _, arg := range args {
+		name, ok := trapCondition(arg)
+		if !ok {
+			fmt.Fprintf(sh.stderr(), "trap: %s: invalid signal specification\n", arg)
+			status = 1
+			continue
+		}
+		if reset {
+			delete(sh.traps, name)
+		} else {
+			sh.traps[name] = handler
+		}
+		sh.updateSignal(name)
+	}
+	return status
+}
+
+// printTraps prints the traps for the named conditions, or all that are
+// set, as trap commands that would recreate them.
+func (sh *interp) printTraps(args []string) int {
+	names := trapOrder()
+	if len(args) > 0 {
+		names = names[:0:0]
+		for _, arg := range args {
+			name, ok := trapCondition(arg)
+			if !ok {
+				fmt.Fprintf(sh.stderr(), "trap: %s: invalid signal specification\n", arg)
+				return 1
+			}
+			names = append(names, name)
+		}
+	}
+	for _, name := range names {
+		if handler, ok := sh.traps[name]; ok {
+			if _, isSig := signals[name]; isSig {
+				name = "SIG" + name
+			}
+			fmt.Fprintf(sh.stdout(), "trap -- %s %s\n", shellQuote(handler), name)
+		}
+	}
+	return 0
+}
+
+// listSignals prints the signal numbers and names for trap -l.
+func (sh *interp) listSignals() {
+	names := trapOrder()
+	names = names[1 : len(names)-len(pseudoSignals)]
+	for i, name := range names {
+		sep := "\t"
+		if (i+1)%5 == 0 || i == len(names)-1 {
+			sep = "\n"
+		}
+		fmt.Fprintf(sh.stdout(), "%2d) SIG%s%s", int(signals[name]), name, sep)
+	}
+}
+
+// runTrap runs a handler. $? is preserved across it unless it exits.
+func (sh *interp) runTrap(handler string) {
+	if handler == "" {
+		return
+	}
+	saved, wasInTrap := sh.params.status, sh.inTrap
+	sh.inTrap = true
+	status := sh.runSource(handler)
+	sh.inTrap = wasInTrap
+	if sh.flow.kind == flowExit {
+		sh.params.status = status
+		return
+	}
+	sh.params.status = saved
+}
+
+// runPendingTraps runs the handlers of signals that arrived since the
+// last call. It is called between commands. A signal without a trap
+// (SIGHUP or SIGTERM at the prompt) exits with status 128+its number.
+func (sh *interp) runPendingTraps() {
+	if sh.embedded || sh.async {
+		return
+	}
+	trapMu.Lock()
+	pending := pendingSigs
+	pendingSigs = nil
+	trapMu.Unlock()
+	for _, name := range pending {
+		handler, trapped := sh.traps[name]
+		if !trapped {
+			sh.params.status = 128 + int(signals[name])
+			sh.flow = flowState{kind: flowExit}
+			return
+		}
+		sh.runTrap(handler)
+	}
+}
+
+// runPseudoTrap runs the handler for ERR, DEBUG or RETURN, if any. Pseudo
+// traps do not fire inside a handler.
+func (sh *interp) runPseudoTrap(name string) {
+	if handler, ok := sh.traps[name]; ok && !sh.inTrap {
+		sh.runTrap(handler)
+	}
+}
+
+// runErrTrap runs the ERR trap after a command failed with status where
+// errexit would apply.
+func (sh *interp) runErrTrap(status int) {
+	if status != 0 && sh.errexitOff == 0 {
+		sh.params.status = status
+		sh.runPseudoTrap("ERR")
+	}
+}
+
+// runExitTrap runs the EXIT trap, once, as the shell (or subshell) exits
+// with status, and returns the final status: that of an exit in the
+// handler, otherwise status.
+func (sh *interp) runExitTrap(status int) int {
+	handler, ok := sh.traps["EXIT"]
+	if !ok {
+		return status
+	}
+	delete(sh.traps, "EXIT")
+	sh.flow = flowState{}
+	sh.params.status = status
+	sh.runTrap(handler)
+	if sh.flow.kind == flowExit {
+		status = sh.params.status
+	}
+	sh.flow.kind = flowNone
+	return status
+}
+
+// hideReturnTrap removes the RETURN trap while a function runs, so that
+// only a trap the function sets itself fires when it returns. The
+// returned function runs that trap and brings back the caller's.
+func (sh *interp) hideReturnTrap() (finish func()) {
+	saved, had := sh.traps["RETURN"]
+	delete(sh.traps, "RETURN")
+	return func() {
+		sh.runPseudoTrap("RETURN")
+		if had {
+			sh.traps["RETURN"] = saved
+		}
+	}
+}
+
+// subshell returns a copy of the shell to run a subshell in (clone),
+// without the parent's EXIT trap. The subshell ends with exitSubshell.
+func (sh *interp) subshell() *interp {
+	sub := sh.clone()
+	delete(sub.traps, "EXIT")
+	return sub
+}
+
+// exitSubshell ends the subshell sub of sh, which finished with status:
+// it runs the subshell's EXIT trap, closes the files its exec opened, and
+// gives the signals whose traps the subshell changed back to sh's
+// handling. It returns the final status.
+func (sh *interp) exitSubshell(sub *interp, status int) int {
+	status = sub.runExitTrap(status)
+	sub.closeExecFiles()
+	if sub.async {
+		return status // a job leaves signals alone, and sh runs on
+	}
+	for _, t := range []map[string]string{sub.traps, sh.traps} {
+		for name := range t {
+			handler, ok := sh.traps[name]
+			if subHandler, subOK := sub.traps[name]; subOK != ok || subHandler != handler {
+				sh.updateSignal(name)
+			}
+		}
+	}
+	return status
+}
@@ -0,0 +1,93 @@
+package shell
+
+import "testing"
+
+func TestTrap(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "EXIT", src: "trap 'echo bye $?' EXIT; echo hi; false", want: "hi\nbye 1\n", status: 1},
+		{name: "EXIT with exit", src: "trap 'echo bye' 0; exit 3", want: "bye\n", status: 3},
+		{name: "exit in EXIT trap", src: "trap 'exit 5' EXIT; true", status: 5},
+		{name: "ERR", src: "trap 'echo err $?' ERR; false; true; echo $?", want: "err 1\n0\n"},
+		{name: "ERR skips conditions", src: "trap 'echo err' ERR; if false; then :; fi; false || true; ! true", want: "", status: 1},
+		{name: "ERR before errexit", src: "set -e; trap 'echo err' ERR; false; echo no", want: "err\n", status: 1},
+		{name: "ERR preserves status", src: "trap 'true' ERR; false; echo $?", want: "1\n"},
+		{name: "DEBUG", src: "trap 'echo debug' DEBUG; echo a; ((1))", want: "debug\na\ndebug\n"},
+		{name: "RETURN", src: "f() { trap 'echo cleanup' RETURN; echo in f; }; f; echo after", want: "in f\ncleanup\nafter\n"},
+		{name: "RETURN not inherited", src: "g() { echo g; }; f() { trap 'echo ret f' RETURN; g; }; f", want: "g\nret f\n"},
+		{name: "subshell EXIT", src: "trap 'echo outer' EXIT; (trap 'echo inner' EXIT; exit 2); echo $?", want: "inner\n2\nouter\n"},
+		{name: "command substitution EXIT", src: "x=$(trap 'echo inner' EXIT; echo a); echo $x", want: "a inner\n"},
+		{name: "signal", src: "trap 'echo usr1' USR1; kill -USR1 $$; sleep 0.1; echo after", want: "usr1\nafter\n"},
+		{name: "signal trap exits", src: "trap 'exit 9' SIGUSR2; kill -USR2 $$; sleep 0.1; echo no", status: 9},
+		{name: "ignored signal", src: "trap '' USR1; kill -USR1 $$; sleep 0.1; echo alive", want: "alive\n"},
+		{name: "reset", src: "trap 'echo bye' EXIT; trap - EXIT", want: ""},
+		{name: "reset with a lone condition", src: "trap 'echo bye' EXIT; trap EXIT", want: ""},
+		{name: "print", src: "trap \"echo it's\" ERR; trap 'echo a' INT; trap : EXIT; trap -- '' 15; trap", want: "trap -- : EXIT\ntrap -- 'echo a' SIGINT\ntrap -- '' SIGTERM\ntrap -- 'echo it'\\''s' ERR\n"},
+		{name: "print named", src: "trap : ERR USR1; trap -p ERR", want: "trap -- : ERR\n"},
+		{name: "invalid signal", src: "trap : NOPE INT; trap", want: "trap -- : SIGINT\n", wantErr: "trap: NOPE: invalid signal specification\n"},
+		{name: "invalid option", src: "trap -x", wantErr: "trap: -x: invalid option\ntrap: usage: trap [-lp] [[arg] signal_spec ...]\n", status: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
+
+func TestTrapList(t *testing.T) {
+	sh := newTestShell(t)
+	got := captureStdout(t, sh, func() { sh.builtinTrap([]string{"-l"}) })
+	if want := " 1) SIGHUP\t 2) SIGINT\t"; len(got) < len(want) || got[:len(want)] != want {
+		t.Errorf("trap -l starts %q, want %q", got, want)
+	}
+}
+
+func TestTrapCondition(t *testing.T) {
+	tests := []struct {
+		arg, want string
+		ok        bool
+	}{
+		{"EXIT", "EXIT", true},
+		{"0", "EXIT", true},
+		{"int", "INT", true},
+		{"SIGTERM", "TERM", true},
+		{"2", "INT", true},
+		{"err", "ERR", true},
+		{"RETURN", "RETURN", true},
+		{"999", "", false},
+		{"FOO", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := trapCondition(tt.arg)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("trapCondition(%q) = %q, %v; want %q, %v", tt.arg, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestUntrappedSignalExits(t *testing.T) {
+	sh := newTestShell(t)
+	// SIGTERM without a trap, queued while an interactive shell prompted.
+	runTestScript(t, sh, "")
+	var status int
+	out := captureStdout(t, sh, func() {
+		sh.traps["EXIT"] = "echo bye $?"
+		pendingSigs = []string{"TERM"}
+		sh.runPendingTraps()
+		status = sh.runExitTrap(sh.params.status)
+	})
+	if out != "bye 143\n" || status != 143 {
+		t.Errorf("got %q, status %d; want %q, 143", out, status, "bye 143\n")
+	}
+}
@@ -1,9 +1,10 @@
 // trie.go — prefix trie for fast command name lookup.
 //
 // Used by the TAB completer to find all commands sharing a common prefix.
-// Insert is O(k) where k is word length; FindByPrefix collects all
-// descendants and returns them sorted.
-package main
+// Insert and Delete are O(k) where k is word length; FindByPrefix
+// collects all descendants and returns them sorted.
+
+package shell
 
 import "sort"
 
@@ -39,6 +40,20 @@ func (t *trie) Insert(word string) {
 	node.isEnd = true
 }
 
+// Delete removes a word from the trie. Its nodes stay, since other words
+// may pass through them.
+func (t *trie) Delete(word string) {
+	node := t.root
+	for _, ch := range word {
+		child, ok := node.children[ch]
+		if !ok {
+			return
+		}
+		node = child
+	}
+	node.isEnd = false
+}
+
 // FindByPrefix returns all words in the trie that start with prefix, sorted.
 func (t *trie) FindByPrefix(prefix string) []string {
 	node := t.root
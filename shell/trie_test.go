@@ -1,4 +1,4 @@
-package main
+package shell
 
 import (
 	"testing"
@@ -80,3 +80,15 @@ func TestTrieInsertAndFind(t *testing.T) {
 		})
 	}
 }
+
+func TestTrieDelete(t *testing.T) {
+	tr := newTrie()
+	for _, w := range []string{"ab", "abc", "b"} {
+		tr.Insert(w)
+	}
+	tr.Delete("ab")
+	tr.Delete("missing")
+	if got := tr.FindByPrefix("a"); len(got) != 1 || got[0] != "abc" {
+		t.Errorf("after Delete(ab), FindByPrefix(a) = %v, want [abc]", got)
+	}
+}
@@ -0,0 +1,125 @@
+// type.go — the type builtin, and how command names resolve.
+//
+//	type [-aptP] NAME...
+//
+//	(no option)  describe how each NAME would be run
+//	-a           every way: aliases, keyword, function, builtin and each
+//	             executable on PATH, in that (lookup) order
+//	-t           print only the kind: alias, keyword, function, builtin or
+//	             file
+//	-p           print the path NAME runs, if it would run a file
+//	-P           print the path of NAME on PATH, even if it is an alias,
+//	             function or builtin
+//
+// The status is 1 if any NAME is not found. command -v and -V (exec.go)
+// describe names the same way, via resolveCommand.
+
+package shell
+
+import (
+	"fmt"
+	"io"
+)
+
+// commandMatch is one way a command name can resolve. value is the alias
+// value, the function source or the file path.
+type commandMatch struct {
+	kind  string // alias, keyword, function, builtin or file
+	value string
+}
+
+// resolveCommand returns how name resolves, searching dirs (a PATH-style
+// list) for files: the match a command would use or, with all, every
+// match in lookup order.
+func (sh *interp) resolveCommand(name, dirs string, all bool) []commandMatch {
+	var matches []commandMatch
+	if value, ok := sh.aliases[name]; ok {
+		matches = append(matches, commandMatch{"alias", value})
+	}
+	if reservedWords[name] {
+		matches = append(matches, commandMatch{"keyword", name})
+	}
+	if fn, ok := sh.functions[name]; ok {
+		matches = append(matches, commandMatch{"function", fn.src})
+	}
+	if _, ok := sh.registry[name]; ok {
+		matches = append(matches, commandMatch{"builtin", name})
+	}
+	if !all && len(matches) > 0 {
+		return matches[:1]
+	}
+	for _, p := range sh.lookPathAll(name, dirs, all) {
+		matches = append(matches, commandMatch{"file", p})
+	}
+	return matches
+}
+
+// describe prints m to w in the words of type.
+func (m commandMatch) describe(w io.Writer, name string) {
+	switch m.kind {
+	case "alias":
+		fmt.Fprintf(w, "%s is aliased to `%s'\n", name, m.value)
+	case "keyword":
+		fmt.Fprintf(w, "%s is a shell keyword\n", name)
+	case "function":
+		fmt.Fprintf(w, "%s is a function\n%s\n", name, m.value)
+	case "builtin":
+		fmt.Fprintf(w, "%s is a shell builtin\n", name)
+	default:
+		fmt.Fprintf(w, "%s is %s\n", name, m.value)
+	}
+}
+
+// builtinType implements type.
+func (sh *interp) builtinType(args []string) int {
+	var all, kindOnly, pathOnly, forcePath bool
+	opts, args, ok := sh.parseOptions("type", "aptP", args)
+	if !ok {
+		return 2
+	}
+	for _, opt := range opts {
+		switch opt.c {
+		case 'a':
+			all = true
+		case 't':
+			kindOnly = true
+		case 'p':
+			pathOnly = true
+		case 'P':
+			forcePath = true
+		}
+	}
+
+	status := 0
+	dirs := sh.getVar("PATH")
+	for _, name := range args {
+		var matches []commandMatch
+		if forcePath {
+			for _, p := range sh.lookPathAll(name, dirs, all) {
+				matches = append(matches, commandMatch{"file", p})
+			}
+		} else {
+			matches = sh.resolveCommand(name, dirs, all)
+		}
+		if len(matches) == 0 {
+			if !kindOnly && !pathOnly && !forcePath {
+				fmt.Fprintf(sh.stdout(), "%s: not found\n", name)
+			}
+			status = 1
+			continue
+		}
+		for _, m := range matches {
+			switch {
+			case kindOnly:
+				fmt.Fprintln(sh.stdout(), m.kind)
+			case pathOnly || forcePath:
+				if m.kind == "file" {
+					fmt.Fprintln(sh.stdout(), m.value)
+				}
+			default:
+				m.describe(sh.stdout(), name)
+			}
+		}
+	}
+	return status
+}
@@ -0,0 +1,54 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestType(t *testing.T) {
+	sh := newTestShell(t)
+	root := t.TempDir()
+	for _, p := range []string{"a/tool", "b/tool", "b/echo"} {
+		path := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, b := filepath.Join(root, "a"), filepath.Join(root, "b")
+	setup := "PATH=" + a + ":" + b + "\nalias ll='ls -l'\nf() { :; }\n"
+
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+	}{
+		{name: "several names", src: setup + "type cd tool", want: "cd is a shell builtin\ntool is " + a + "/tool\n"},
+		{name: "missing name fails", src: setup + "type cd nope tool", want: "cd is a shell builtin\nnope: not found\ntool is " + a + "/tool\n", status: 1},
+		{name: "kinds", src: setup + "type ll if f echo tool", want: "ll is aliased to `ls -l'\nif is a shell keyword\nf is a function\nf() { :; }\necho is a shell builtin\ntool is " + a + "/tool\n"},
+		{name: "-a", src: setup + "type -a tool echo", want: "tool is " + a + "/tool\ntool is " + b + "/tool\necho is a shell builtin\necho is " + b + "/echo\n"},
+		{name: "-t", src: setup + "type -t ll if f cd tool nope", want: "alias\nkeyword\nfunction\nbuiltin\nfile\n", status: 1},
+		{name: "-at", src: setup + "type -at echo", want: "builtin\nfile\n"},
+		{name: "-p", src: setup + "type -p tool echo", want: a + "/tool\n"},
+		{name: "-P", src: setup + "type -P tool echo", want: a + "/tool\n" + b + "/echo\n"},
+		{name: "-aP", src: setup + "type -aP tool", want: a + "/tool\n" + b + "/tool\n"},
+		{name: "-P not found", src: setup + "type -P cd", status: 1},
+		{name: "invalid option", src: "type -x", wantErr: "type: -x: invalid option\ntype: usage: type [-aptP] name [name ...]\n", status: 2},
+		{name: "command -v", src: setup + "command -v ll if cd tool nope", want: "alias ll='ls -l'\nif\ncd\n" + a + "/tool\n", status: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestScript(t, sh, tt.src) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
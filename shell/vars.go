@@ -0,0 +1,450 @@
+// vars.go — the shell's variable table.
+//
+// Every variable of a shell lives in its varTable, seeded from the
+// process environment at startup. Each variable carries attribute flags:
+//
+//	export    passed to child processes (Environ)
+//	readonly  cannot be assigned or unset
+//	integer   assignments are evaluated arithmetically (arith.go)
+//	lower     assignments are lowercased
+//	upper     assignments are uppercased
+//	nameref   the value names another variable; reads and writes follow it
+//	array     an indexed array (arrays.go)
+//	assoc     an associative array (arrays.go)
+//
+// Some variables are dynamic (RANDOM, SECONDS, ...): their value is
+// computed when read; see params.go.
+//
+// A variable can exist without a value ("declare -x FOO" before any
+// assignment); such variables are listed by declare -p but are not set.
+//
+// Scoping is dynamic, as in bash: local (or declare inside a function)
+// saves the caller's variable in the function's scope and starts afresh;
+// popScope puts the saved variables back when the function returns.
+
+package shell
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// varAttr is a bit set of variable attributes.
+type varAttr uint16
+
+const (
+	attrExport varAttr = 1 << iota
+	attrReadonly
+	attrInteger
+	attrLower
+	attrUpper
+	attrNameref
+	attrArray
+	attrAssoc
+)
+
+// maxNamerefDepth bounds nameref chains so a cycle (declare -n a=b b=a)
+// cannot loop forever.
+const maxNamerefDepth = 8
+
+// variable is a single shell variable. Arrays keep their elements in
+// Indexed or Assoc and leave Value empty.
+type variable struct {
+	Value   string
+	Attrs   varAttr
+	IsSet   bool              // false for declared-only variables (declare x, export x)
+	Indexed map[int]string    // elements of an indexed array
+	Assoc   map[string]string // elements of an associative array
+	dyn     *dynamicVar       // computes the value on read (params.go)
+}
+
+// varTable maps variable names to their values and attributes.
+type varTable struct {
+	vars   map[string]*variable
+	scopes []map[string]*variable // per function call: shadowed variables, nil if none
+	owner  *interp                // the shell dynamic variables read (params.go)
+}
+
+// newVarTable creates a table populated from environ ("NAME=value"
+// strings, as from os.Environ). Imported variables are exported.
+func newVarTable(environ []string) *varTable {
+	t := &varTable{vars: make(map[string]*variable)}
+	for _, kv := range environ {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || !isValidName(name) {
+			continue
+		}
+		t.vars[name] = &variable{Value: value, Attrs: attrExport, IsSet: true}
+	}
+	return t
+}
+
+// clone returns a deep copy of t.
+func (t *varTable) clone() *varTable {
+	cp := &varTable{vars: make(map[string]*variable, len(t.vars))}
+	for name, v := range t.vars {
+		cp.vars[name] = v.clone()
+	}
+	for _, scope := range t.scopes {
+		saved := make(map[string]*variable, len(scope))
+		for name, v := range scope {
+			if v != nil {
+				v = v.clone()
+			}
+			saved[name] = v
+		}
+		cp.scopes = append(cp.scopes, saved)
+	}
+	return cp
+}
+
+// pushScope starts a function's variable scope.
+func (t *varTable) pushScope() {
+	t.scopes = append(t.scopes, map[string]*variable{})
+}
+
+// popScope ends the innermost scope, restoring the variables its locals
+// shadowed.
+func (t *varTable) popScope() {
+	scope := t.scopes[len(t.scopes)-1]
+	t.scopes = t.scopes[:len(t.scopes)-1]
+	for name, v := range scope {
+		if v == nil {
+			delete(t.vars, name)
+		} else {
+			t.vars[name] = v
+		}
+	}
+}
+
+// makeLocal makes name local to the innermost scope: the current variable
+// is set aside until the scope ends and name starts out unset. Outside
+// any function it does nothing.
+func (t *varTable) makeLocal(name string) error {
+	if len(t.scopes) == 0 {
+		return nil
+	}
+	scope := t.scopes[len(t.scopes)-1]
+	if _, done := scope[name]; done {
+		return nil
+	}
+	v := t.vars[name]
+	if v != nil && v.Attrs&attrReadonly != 0 {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+	scope[name] = v
+	delete(t.vars, name)
+	return nil
+}
+
+// resolve follows nameref chains starting at name and returns the name of
+// the variable that is ultimately referenced.
+func (t *varTable) resolve(name string) (string, error) {
+	for i := 0; i < maxNamerefDepth; i++ {
+		v, ok := t.vars[name]
+		if !ok || v.Attrs&attrNameref == 0 || !v.IsSet || v.Value == "" {
+			return name, nil
+		}
+		name = v.Value
+	}
+	return "", fmt.Errorf("%s: circular name reference", name)
+}
+
+// Lookup returns the variable name refers to (following namerefs), or nil.
+func (t *varTable) Lookup(name string) *variable {
+	name, err := t.resolve(name)
+	if err != nil {
+		return nil
+	}
+	v := t.vars[name]
+	if v != nil {
+		v.refresh(t.owner)
+	}
+	return v
+}
+
+// Get returns the value of name and whether it is set. For an array this
+// is element 0.
+func (t *varTable) Get(name string) (string, bool) {
+	v := t.Lookup(name)
+	if v == nil {
+		return "", false
+	}
+	return v.scalar()
+}
+
+// Set assigns value to name, creating the variable if needed. The value is
+// transformed according to the variable's attributes; assigning to a
+// readonly variable is an error. Setting an array sets element 0.
+func (t *varTable) Set(name, value string) error {
+	v, err := t.writable(name)
+	if err != nil {
+		return err
+	}
+	if v.isArray() {
+		return v.setElement(t.owner, "0", value)
+	}
+	value, err = v.transform(t.owner, value)
+	if err != nil {
+		return err
+	}
+	v.Value = value
+	v.IsSet = true
+	if v.dyn != nil && v.dyn.set != nil {
+		v.dyn.set(t.owner, value)
+	}
+	return nil
+}
+
+// transform applies the integer and case attributes to a value being
+// assigned.
+func (v *variable) transform(sh *interp, value string) (string, error) {
+	if v.Attrs&attrInteger != 0 {
+		n, err := sh.arithEval(value)
+		if err != nil {
+			return "", err
+		}
+		value = strconv.FormatInt(n, 10)
+	}
+	switch {
+	case v.Attrs&attrLower != 0:
+		value = strings.ToLower(value)
+	case v.Attrs&attrUpper != 0:
+		value = strings.ToUpper(value)
+	}
+	return value, nil
+}
+
+// Unset removes name. Unsetting a nameref removes the variable it refers
+// to; a readonly variable cannot be unset.
+func (t *varTable) Unset(name string) error {
+	name, err := t.resolve(name)
+	if err != nil {
+		return err
+	}
+	if v, ok := t.vars[name]; ok && v.Attrs&attrReadonly != 0 {
+		return fmt.Errorf("%s: cannot unset: readonly variable", name)
+	}
+	delete(t.vars, name)
+	return nil
+}
+
+// SetAttrs turns the attributes in on on and those in off off for name,
+// creating a declared-only variable if it does not exist. Enabling
+// lowercase clears uppercase and vice versa. The readonly attribute
+// cannot be removed, and turning on array or assoc converts the value.
+func (t *varTable) SetAttrs(name string, on, off varAttr) error {
+	if on&attrNameref == 0 && off&attrNameref == 0 {
+		var err error
+		if name, err = t.resolve(name); err != nil {
+			return err
+		}
+	}
+	v, ok := t.vars[name]
+	if !ok {
+		v = &variable{}
+		t.vars[name] = v
+	}
+	if off&attrReadonly != 0 && v.Attrs&attrReadonly != 0 {
+		return fmt.Errorf("%s: readonly variable", name)
+	}
+	if on&attrLower != 0 {
+		off |= attrUpper
+	}
+	if on&attrUpper != 0 {
+		off |= attrLower
+	}
+	for _, kind := range []varAttr{attrArray, attrAssoc} {
+		if on&kind != 0 {
+			if err := v.toArray(kind); err != nil {
+				return fmt.Errorf("%s: %s", name, err)
+			}
+		}
+	}
+	v.Attrs = v.Attrs&^(off&^(attrArray|attrAssoc)) | on
+	return nil
+}
+
+// Names returns all variable names in sorted order.
+func (t *varTable) Names() []string {
+	names := make([]string, 0, len(t.vars))
+	for name := range t.vars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Environ returns "NAME=value" strings for every exported, set scalar
+// variable, sorted by name, suitable for exec.Cmd.Env. Arrays cannot be
+// exported.
+func (t *varTable) Environ() []string {
+	var env []string
+	for _, name := range t.Names() {
+		v := t.vars[name]
+		v.refresh(t.owner)
+		if v.Attrs&attrExport != 0 && v.IsSet && !v.isArray() {
+			env = append(env, name+"="+v.Value)
+		}
+	}
+	return env
+}
+
+// getVar returns the value of a shell variable, or "" if it is unset.
+func (sh *interp) getVar(name string) string {
+	v, _ := sh.vars.Get(name)
+	return v
+}
+
+// isValidName reports whether s is a valid shell variable name.
+func isValidName(s string) bool {
+	if s == "" || !isNameStart(s[0]) {
+		return false
+	}
+	for i := 1; i < len(s); i++ {
+		if !isNameChar(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// assignment is a "NAME=value" word, optionally with a subscript
+// (NAME[index]=value) or appending (NAME+=value). Index and Value hold the
+// raw, unexpanded text so they can be expanded when the assignment is
+// performed. A compound array value keeps its parentheses: "(a b c)".
+type assignment struct {
+	Name   string
+	Index  string
+	Append bool
+	Value  string
+}
+
+// parseAssignment recognizes a raw word of the form NAME=value,
+// NAME[index]=value or either with +=, where NAME is an unquoted valid
+// variable name.
+func parseAssignment(word string) (assignment, bool) {
+	var a assignment
+	i := 0
+	for i < len(word) && isNameChar(word[i]) {
+		i++
+	}
+	if !isValidName(word[:i]) {
+		return a, false
+	}
+	a.Name = word[:i]
+	if i < len(word) && word[i] == '[' {
+		j := matchingBracket(word, i)
+		if j <= i+1 {
+			return a, false
+		}
+		a.Index = word[i+1 : j]
+		i = j + 1
+	}
+	if strings.HasPrefix(word[i:], "+=") {
+		a.Append = true
+		i++
+	}
+	if i >= len(word) || word[i] != '=' {
+		return a, false
+	}
+	a.Value = word[i+1:]
+	return a, true
+}
+
+// isCompound reports whether a assigns a whole array: NAME=(...).
+func (a assignment) isCompound() bool {
+	return a.Index == "" && len(a.Value) >= 2 && a.Value[0] == '(' && a.Value[len(a.Value)-1] == ')'
+}
+
+// applyAssignments expands and performs assignments left to right, so
+// later values may refer to earlier ones (a=1 b=$a).
+func (sh *interp) applyAssignments(assigns []assignment) error {
+	for _, a := range assigns {
+		if err := sh.performAssignment(a, true); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// performAssignment carries out one assignment. With expand false the
+// index and scalar value are used as-is (declaration builtins receive them
+// already expanded); compound values are always parsed by parseCompound.
+func (sh *interp) performAssignment(a assignment, expand bool) error {
+	if a.isCompound() {
+		elems, err := sh.parseCompound(a.Value)
+		if err != nil {
+			return err
+		}
+		return sh.vars.SetArray(a.Name, elems, a.Append)
+	}
+	index, value := a.Index, a.Value
+	if expand {
+		var err error
+		if value, err = sh.expandAssignment(value); err != nil {
+			return err
+		}
+		if index, err = sh.expandWord(index); err != nil {
+			return err
+		}
+	}
+	switch {
+	case a.Index != "":
+		return sh.vars.SetIndex(a.Name, index, value, a.Append)
+	case a.Append:
+		return sh.vars.Append(a.Name, value)
+	}
+	return sh.vars.Set(a.Name, value)
+}
+
+// withAssignments runs fn with assigns applied as temporary, exported
+// variables (the "FOO=bar cmd" prefix form for builtins). Previous values
+// and attributes are restored afterwards.
+func (sh *interp) withAssignments(assigns []assignment, fn func()) error {
+	if len(assigns) == 0 {
+		fn()
+		return nil
+	}
+	saved := make(map[string]*variable, len(assigns))
+	for _, a := range assigns {
+		if _, done := saved[a.Name]; done {
+			continue
+		}
+		if v, ok := sh.vars.vars[a.Name]; ok {
+			saved[a.Name] = v.clone()
+		} else {
+			saved[a.Name] = nil
+		}
+	}
+	defer func() {
+		for name, v := range saved {
+			if v == nil {
+				delete(sh.vars.vars, name)
+			} else {
+				sh.vars.vars[name] = v
+			}
+		}
+	}()
+	if err := sh.applyAssignments(assigns); err != nil {
+		return err
+	}
+	for _, a := range assigns {
+		sh.vars.SetAttrs(a.Name, attrExport, 0)
+	}
+	fn()
+	return nil
+}
+
+// commandEnv returns the environment for an external command: exported
+// variables plus the command's prefix assignments. Assignments are
+// expanded but do not modify the shell's own variables.
+func (sh *interp) commandEnv(assigns []assignment) ([]string, error) {
+	var env []string
+	err := sh.withAssignments(assigns, func() {
+		env = sh.vars.Environ()
+	})
+	return env, err
+}
@@ -0,0 +1,185 @@
+package shell
+
+import (
+	"slices"
+	"testing"
+)
+
+// setupTestVars replaces the shell's variables with a table built from
+// env.
+func setupTestVars(t *testing.T, sh *interp, env ...string) {
+	t.Helper()
+	sh.vars = newVarTable(env)
+	sh.vars.owner = sh
+}
+
+func TestNewVarTable(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh, "HOME=/root", "EMPTY=", "1BAD=x", "NOEQUALS")
+
+	if v, ok := sh.vars.Get("HOME"); !ok || v != "/root" {
+		t.Errorf("HOME = %q, %v; want %q, true", v, ok, "/root")
+	}
+	if v, ok := sh.vars.Get("EMPTY"); !ok || v != "" {
+		t.Errorf("EMPTY = %q, %v; want empty and set", v, ok)
+	}
+	if _, ok := sh.vars.Get("1BAD"); ok {
+		t.Error("invalid name 1BAD should not be imported")
+	}
+	if sh.vars.Lookup("HOME").Attrs&attrExport == 0 {
+		t.Error("imported variables should be exported")
+	}
+}
+
+func TestVarTableSet(t *testing.T) {
+	sh := newTestShell(t)
+	tests := []struct {
+		name  string
+		attrs varAttr
+		value string
+		want  string
+	}{
+		{name: "plain", value: "Hello", want: "Hello"},
+		{name: "integer evaluates", attrs: attrInteger, value: "2*3+1", want: "7"},
+		{name: "lowercase", attrs: attrLower, value: "MiXeD", want: "mixed"},
+		{name: "uppercase", attrs: attrUpper, value: "MiXeD", want: "MIXED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setupTestVars(t, sh)
+			sh.vars.SetAttrs("v", tt.attrs, 0)
+			if err := sh.vars.Set("v", tt.value); err != nil {
+				t.Fatal(err)
+			}
+			if got := sh.getVar("v"); got != tt.want {
+				t.Errorf("v = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestVarTableReadonly(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh)
+	sh.vars.Set("r", "1")
+	sh.vars.SetAttrs("r", attrReadonly, 0)
+
+	if err := sh.vars.Set("r", "2"); err == nil {
+		t.Error("expected error assigning readonly variable")
+	}
+	if err := sh.vars.Unset("r"); err == nil {
+		t.Error("expected error unsetting readonly variable")
+	}
+	if err := sh.vars.SetAttrs("r", 0, attrReadonly); err == nil {
+		t.Error("expected error removing readonly attribute")
+	}
+	if got := sh.getVar("r"); got != "1" {
+		t.Errorf("r = %q, want %q", got, "1")
+	}
+}
+
+func TestVarTableNameref(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh, "target=old")
+	sh.vars.SetAttrs("ref", attrNameref, 0)
+	sh.vars.vars["ref"].Value = "target"
+	sh.vars.vars["ref"].IsSet = true
+
+	if got := sh.getVar("ref"); got != "old" {
+		t.Errorf("ref = %q, want %q", got, "old")
+	}
+	sh.vars.Set("ref", "new")
+	if got := sh.getVar("target"); got != "new" {
+		t.Errorf("target = %q after assigning through ref, want %q", got, "new")
+	}
+
+	t.Run("cycle is an error", func(t *testing.T) {
+		setupTestVars(t, sh)
+		sh.vars.vars["a"] = &variable{Value: "b", Attrs: attrNameref, IsSet: true}
+		sh.vars.vars["b"] = &variable{Value: "a", Attrs: attrNameref, IsSet: true}
+		if err := sh.vars.Set("a", "x"); err == nil {
+			t.Error("expected circular name reference error")
+		}
+	})
+}
+
+func TestVarTableEnviron(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh, "B=2", "A=1")
+	sh.vars.Set("local", "x")
+	sh.vars.SetAttrs("declared", attrExport, 0)
+
+	got := sh.vars.Environ()
+	want := []string{"A=1", "B=2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("Environ() = %q, want %q", got, want)
+	}
+}
+
+func TestApplyAssignments(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh)
+	err := sh.applyAssignments([]assignment{{Name: "a", Value: "1"}, {Name: "b", Value: "$a-2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sh.getVar("b"); got != "1-2" {
+		t.Errorf("b = %q, want %q", got, "1-2")
+	}
+}
+
+func TestCommandEnv(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh, "KEEP=1", "OVER=old")
+
+	env, err := sh.commandEnv([]assignment{{Name: "OVER", Value: "new"}, {Name: "TMP", Value: "t"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"KEEP=1", "OVER=new", "TMP=t"}
+	if !slices.Equal(env, want) {
+		t.Errorf("commandEnv() = %q, want %q", env, want)
+	}
+	if got := sh.getVar("OVER"); got != "old" {
+		t.Errorf("OVER = %q after commandEnv, want %q", got, "old")
+	}
+	if _, ok := sh.vars.Get("TMP"); ok {
+		t.Error("TMP should not persist after commandEnv")
+	}
+}
+
+func TestScopes(t *testing.T) {
+	sh := newTestShell(t)
+	setupTestVars(t, sh, "x=global", "r=ro")
+	sh.vars.SetAttrs("r", attrReadonly, 0)
+
+	sh.vars.pushScope()
+	if err := sh.vars.makeLocal("x"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := sh.vars.Get("x"); ok {
+		t.Error("a new local should start out unset")
+	}
+	sh.vars.Set("x", "local")
+	sh.vars.makeLocal("y")
+	sh.vars.Set("y", "only here")
+	if err := sh.vars.makeLocal("r"); err == nil {
+		t.Error("making a readonly variable local should fail")
+	}
+
+	saved := sh.vars.clone()
+	sh.vars.popScope()
+	if v := sh.getVar("x"); v != "global" {
+		t.Errorf("x after popScope = %q, want %q", v, "global")
+	}
+	if _, ok := sh.vars.Get("y"); ok {
+		t.Error("y should not outlive its scope")
+	}
+
+	// A clone keeps the scope stack, so it can be popped independently.
+	saved.popScope()
+	if v, _ := saved.Get("x"); v != "global" {
+		t.Errorf("clone x after popScope = %q, want %q", v, "global")
+	}
+}
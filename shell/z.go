@@ -0,0 +1,282 @@
+// z.go — frecency-ranked directory jumping, after rupa/z.
+//
+//	z WORD...     cd to the highest-ranked directory matching every WORD
+//	z -l [WORD...]
+//	              list the matching directories, best last, with scores
+//
+// Every directory an interactive shell changes into (with cd, pushd,
+// popd or z) is recorded with a visit count (its rank) and the time of
+// the last visit. $HOME is not recorded, and neither are the cds of
+// scripts and embedded Interpreters, which would skew the ranks. The
+// records live in .gosh_z in the directory of HISTFILE, one
+// "path|rank|time" line per directory, and are re-read before each
+// update so that concurrent shells share them; without HISTFILE they are
+// kept in memory only. When the ranks add up to more than zMaxRank they
+// are all aged by 1%, and those below 1 are forgotten.
+//
+// A directory matches when its path contains the WORDs in order. Case
+// matters unless no directory matches with it. The score is the rank
+// weighted by how recently the directory was visited:
+//
+//	within the hour  rank*4     within the week  rank/2
+//	within the day   rank*2     older            rank/4
+//
+// TAB after "z " completes a WORD to the names of recorded directories
+// that start with it, best-scoring first (see completer.go).
+
+package shell
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// zMaxRank is the total rank above which the records are aged.
+const zMaxRank = 9000
+
+// zEntry is the record of one directory.
+type zEntry struct {
+	path string
+	rank float64
+	time int64 // Unix seconds of the last visit
+}
+
+// zDataFile returns the path of the database, or "" without HISTFILE.
+func (sh *interp) zDataFile() string {
+	histfile := sh.getVar("HISTFILE")
+	if histfile == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(sh.path(histfile)), ".gosh_z")
+}
+
+// loadZ returns the records. Malformed lines are skipped.
+func (sh *interp) loadZ() []zEntry {
+	path := sh.zDataFile()
+	if path == "" {
+		return slices.Clone(sh.zMemory)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var entries []zEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "|")
+		if len(fields) != 3 || fields[0] == "" {
+			continue
+		}
+		rank, err1 := strconv.ParseFloat(fields[1], 64)
+		t, err2 := strconv.ParseInt(fields[2], 10, 64)
+		if err1 == nil && err2 == nil {
+			entries = append(entries, zEntry{path: fields[0], rank: rank, time: t})
+		}
+	}
+	return entries
+}
+
+// saveZ replaces the records, writing the database through a temporary
+// file so that a concurrent reader never sees half of it.
+func (sh *interp) saveZ(entries []zEntry) error {
+	path := sh.zDataFile()
+	if path == "" {
+		sh.zMemory = entries
+		return nil
+	}
+	var b bytes.Buffer
+	for _, e := range entries {
+		fmt.Fprintf(&b, "%s|%s|%d\n", e.path, strconv.FormatFloat(e.rank, 'g', -1, 64), e.time)
+	}
+	return replaceFile(path, b.Bytes(), 0o644)
+}
+
+// replaceFile writes data to path through a temporary file in the same
+// directory that is then renamed over it, so that a crash or a full disk
+// leaves either the old contents or the new, never part of them. An
+// existing file keeps its permissions (a new one gets perm), and a
+// symbolic link is followed rather than replaced.
+func replaceFile(path string, data []byte, perm os.FileMode) error {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+	if info, err := os.Stat(path); err == nil {
+		perm = info.Mode().Perm()
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if err == nil {
+		err = tmp.Chmod(perm)
+	}
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// zRecord notes a visit to dir. Errors writing the database are ignored,
+// as they are for history.
+func (sh *interp) zRecord(dir string) {
+	if dir == sh.getVar("HOME") || strings.Contains(dir, "|") {
+		return
+	}
+	now := time.Now().Unix()
+	entries := sh.loadZ()
+	total := 0.0
+	found := false
+	for i := range entries {
+		if entries[i].path == dir {
+			entries[i].rank++
+			entries[i].time = now
+			found = true
+		}
+		total += entries[i].rank
+	}
+	if !found {
+		entries = append(entries, zEntry{path: dir, rank: 1, time: now})
+		total++
+	}
+	if total > zMaxRank {
+		aged := entries[:0]
+		for _, e := range entries {
+			if e.rank *= 0.99; e.rank >= 1 {
+				aged = append(aged, e)
+			}
+		}
+		entries = aged
+	}
+	sh.saveZ(entries)
+}
+
+// score weights e's rank by how long ago it was visited.
+func (e zEntry) score(now int64) float64 {
+	switch age := now - e.time; {
+	case age < 3600:
+		return e.rank * 4
+	case age < 86400:
+		return e.rank * 2
+	case age < 604800:
+		return e.rank / 2
+	}
+	return e.rank / 4
+}
+
+// zScored is a directory with its score.
+type zScored struct {
+	path  string
+	score float64
+}
+
+// zRanked returns the recorded directories that still exist, best first.
+func (sh *interp) zRanked() []zScored {
+	now := time.Now().Unix()
+	var ranked []zScored
+	for _, e := range sh.loadZ() {
+		if info, err := os.Stat(e.path); err == nil && info.IsDir() {
+			ranked = append(ranked, zScored{e.path, e.score(now)})
+		}
+	}
+	slices.SortStableFunc(ranked, func(a, b zScored) int { return cmp.Compare(b.score, a.score) })
+	return ranked
+}
+
+// zMatch returns the ranked directories whose paths contain words in
+// order, best first: case-sensitively if any match so, otherwise not.
+func (sh *interp) zMatch(words []string) []zScored {
+	ranked := sh.zRanked()
+	for _, fold := range []bool{false, true} {
+		var matches []zScored
+		for _, d := range ranked {
+			if containsInOrder(d.path, words, fold) {
+				matches = append(matches, d)
+			}
+		}
+		if len(matches) > 0 || len(words) == 0 {
+			return matches
+		}
+	}
+	return nil
+}
+
+// containsInOrder reports whether s contains each of words, each after
+// the one before it.
+func containsInOrder(s string, words []string, fold bool) bool {
+	if fold {
+		s = strings.ToLower(s)
+	}
+	for _, w := range words {
+		if fold {
+			w = strings.ToLower(w)
+		}
+		i := strings.Index(s, w)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(w):]
+	}
+	return true
+}
+
+// builtinZ implements z.
+func (sh *interp) builtinZ(args []string) int {
+	opts, args, ok := sh.parseOptions("z", "l", args)
+	if !ok {
+		return 2
+	}
+	list := len(opts) > 0
+
+	matches := sh.zMatch(args)
+	if list || len(args) == 0 {
+		// Best last, next to the prompt.
+		for _, d := range slices.Backward(matches) {
+			fmt.Fprintf(sh.stdout(), "%-10s %s\n", strconv.FormatFloat(d.score, 'g', 4, 64), d.path)
+		}
+		return boolStatus(len(matches) > 0)
+	}
+	if len(matches) == 0 {
+		fmt.Fprintf(sh.stderr(), "z: %s: no matching directory\n", strings.Join(args, " "))
+		return 1
+	}
+	return sh.cdTo("z", matches[0].path, false, false)
+}
+
+// zCompletions returns the completions of word after "z ": the recorded
+// directories whose full path, or failing that whose name, starts with
+// word, best-scoring first and without repeats.
+func (sh *interp) zCompletions(word string) []string {
+	var names []string
+	seen := map[string]bool{}
+	for _, d := range sh.zRanked() {
+		name := d.path
+		if !strings.HasPrefix(name, word) {
+			name = filepath.Base(d.path)
+		}
+		if strings.HasPrefix(name, word) && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,132 @@
+package shell
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestZ(t *testing.T) {
+	sh := newTestShell(t)
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"src/proj", "src/Other", "docs/proj"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	visits := "cd src/proj; cd ../Other; cd ../proj; cd " + root + "/docs/proj; cd " + root + "/src/proj; cd " + root + "; "
+
+	tests := []struct {
+		name    string
+		src     string
+		want    string
+		wantErr string
+		status  int
+		script  bool // run non-interactively
+	}{
+		{name: "jumps to the best match", src: visits + "z proj; pwd", want: root + "/src/proj\n"},
+		{name: "words in order", src: visits + "z docs pr; pwd", want: root + "/docs/proj\n"},
+		{name: "case-insensitive fallback", src: visits + "z other; pwd", want: root + "/src/Other\n"},
+		{name: "list best last", src: visits + "z -l proj", want: "4          " + root + "/docs/proj\n12         " + root + "/src/proj\n"},
+		{name: "no match", src: visits + "z nothing", wantErr: "z: nothing: no matching directory\n", status: 1},
+		{name: "HOME is not recorded", src: "HOME=" + root + "/src; cd src; cd proj; z -l", want: "4          " + root + "/src/proj\n"},
+		{name: "scripts do not record", script: true, src: visits + "z proj", wantErr: "z: proj: no matching directory\n", status: 1},
+		{name: "invalid option", src: "z -x", wantErr: "z: -x: invalid option\nz: usage: z [-l] [word ...]\n", status: 2},
+		{name: "database next to HISTFILE", src: "HISTFILE=" + root + "/hist; cd docs; cat ../.gosh_z | cut -d'|' -f1,2", want: root + "/docs|1\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Chdir(root)
+			os.Remove(filepath.Join(root, ".gosh_z"))
+			sh.zMemory = nil
+			t.Cleanup(func() { sh.zMemory = nil })
+			var got string
+			var status int
+			gotErr := captureStderr(t, sh, func() { got, status = runTestShell(t, sh, tt.src, !tt.script) })
+			if got != tt.want || gotErr != tt.wantErr || status != tt.status {
+				t.Errorf("got %q, stderr %q, status %d; want %q, %q, %d", got, gotErr, status, tt.want, tt.wantErr, tt.status)
+			}
+		})
+	}
+}
+
+func TestZScore(t *testing.T) {
+	now := time.Now().Unix()
+	tests := []struct {
+		age  int64
+		want float64
+	}{
+		{age: 60, want: 40},
+		{age: 7200, want: 20},
+		{age: 2 * 86400, want: 5},
+		{age: 30 * 86400, want: 2.5},
+	}
+	for _, tt := range tests {
+		if got := (zEntry{rank: 10, time: now - tt.age}).score(now); got != tt.want {
+			t.Errorf("score after %ds = %v, want %v", tt.age, got, tt.want)
+		}
+	}
+}
+
+func TestZAging(t *testing.T) {
+	sh := newTestShell(t)
+	sh.zMemory = []zEntry{{path: "/a", rank: zMaxRank}, {path: "/b", rank: 1}}
+	t.Cleanup(func() { sh.zMemory = nil })
+	sh.zRecord("/c")
+	var paths []string
+	for _, e := range sh.zMemory {
+		paths = append(paths, e.path)
+	}
+	if !slices.Equal(paths, []string{"/a"}) || sh.zMemory[0].rank != zMaxRank*0.99 {
+		t.Errorf("after aging: %+v", sh.zMemory)
+	}
+}
+
+func TestZCompletions(t *testing.T) {
+	sh := newTestShell(t)
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{"project", "projects", "other"} {
+		if err := os.Mkdir(filepath.Join(root, dir), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now().Unix()
+	sh.zMemory = []zEntry{
+		{path: root + "/project", rank: 1, time: now},
+		{path: root + "/projects", rank: 5, time: now},
+		{path: root + "/other", rank: 3, time: now},
+		{path: root + "/gone", rank: 9, time: now},
+	}
+	t.Cleanup(func() { sh.zMemory = nil })
+
+	if got, want := sh.zCompletions("pro"), []string{"projects", "project"}; !slices.Equal(got, want) {
+		t.Errorf("zCompletions(pro) = %q, want %q", got, want)
+	}
+	if got := sh.zCompletions(root + "/o"); !slices.Equal(got, []string{root + "/other"}) {
+		t.Errorf("zCompletions(full path) = %q", got)
+	}
+
+	setupTestTrie(t, sh, []string{"z"})
+	comp := &builtinCompleter{sh: sh}
+	suffix, length := comp.Do([]rune("z oth"), 5)
+	if len(suffix) != 1 || string(suffix[0]) != "er " || length != 3 {
+		t.Errorf("Do(z oth) = %q, %d", suffix, length)
+	}
+	if suffix, _ := comp.Do([]rune("z proj"), 6); len(suffix) != 1 || string(suffix[0]) != "ect" {
+		t.Errorf("Do(z proj) = %q, want LCP suffix \"ect\"", suffix)
+	}
+	captureStderr(t, sh, func() { comp.Do([]rune("z project"), 9) })
+	listing := captureStdout(t, sh, func() { comp.Do([]rune("z project"), 9) })
+	if !strings.Contains(listing, "projects  project\n$ z project") {
+		t.Errorf("second TAB listing = %q, want best-ranked first", listing)
+	}
+}